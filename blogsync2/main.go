@@ -18,15 +18,16 @@ import (
 
 func main() {
 	var (
-		configFile = flag.String("config", "config.toml", "Configuration file path")
-		password   = flag.String("password", "", "Password for token encryption")
-		command    = flag.String("cmd", "start", "Command to run: start, init-config, token")
+		configFile  = flag.String("config", "config.toml", "Configuration file path")
+		password    = flag.String("password", "", "Password for token encryption")
+		command     = flag.String("cmd", "start", "Command to run: start, init-config, token")
+		interactive = flag.Bool("interactive", false, "Prompt for settings when running init-config")
 	)
 	flag.Parse()
 
 	switch *command {
 	case "init-config":
-		if err := generateDefaultConfig(*configFile); err != nil {
+		if err := generateDefaultConfig(*configFile, *interactive); err != nil {
 			log.Fatalf("Failed to generate config: %v", err)
 		}
 		fmt.Printf("Generated default configuration at: %s\n", *configFile)
@@ -47,8 +48,13 @@ func main() {
 	}
 }
 
-func generateDefaultConfig(configPath string) error {
+func generateDefaultConfig(configPath string, interactive bool) error {
 	cfg := config.Default()
+	if interactive {
+		if err := runConfigWizard(os.Stdin, os.Stdout, cfg); err != nil {
+			return fmt.Errorf("failed to read settings: %w", err)
+		}
+	}
 	return config.Save(cfg, configPath)
 }
 
@@ -57,6 +63,12 @@ func startServer(configFile, password string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config %s:\n%w", configFile, err)
+	}
+	if err := cfg.PrepareDirs(); err != nil {
+		return fmt.Errorf("failed to prepare directories:\n%w", err)
+	}
 
 	pwd := getPassword(password)
 	_ = pwd
@@ -80,20 +92,24 @@ func startServer(configFile, password string) error {
 	client := dropbox.NewClient(auth)
 
 	syncManager := sync.NewManager(cfg, client, database)
-	webServer := server.New(cfg, syncManager, auth)
+	syncManager.SetClientFactory(func(userID uint) sync.DropboxClient {
+		return dropbox.NewClient(dropbox.NewAuth(cfg.Dropbox, token.NewDBStorage(database, userID)))
+	})
+	webServer := server.New(cfg, syncManager, auth, database)
 
 	// Start sync manager in background
-	syncChan := make(chan sync.Event, 100)
-	go syncManager.Start(syncChan)
+	syncQueue := sync.NewQueue(cfg.Sync.QueueSize, cfg.Sync.QueueOverflow)
+	go syncManager.Start(syncQueue)
 
 	// Start web server
-	go webServer.Start(syncChan)
+	go webServer.Start(syncQueue)
 
 	log.Printf("BlogSync service started")
-	log.Printf("Public server: http://%s:%d (webhooks, auth)", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("Admin server: http://%s:%d (admin endpoints)", cfg.Server.Host, cfg.Server.AdminPort)
-	log.Printf("Webhook endpoint: http://%s:%d%s", cfg.Server.Host, cfg.Server.Port, cfg.Server.WebhookPath)
-	log.Printf("Auth callback: http://%s:%d/auth/callback", cfg.Server.Host, cfg.Server.Port)
+	publicScheme, adminScheme := cfg.Server.TLS.Scheme(true), cfg.Server.TLS.Scheme(false)
+	log.Printf("Public server: %s://%s:%d (webhooks, auth)", publicScheme, cfg.Server.Host, cfg.Server.Port)
+	log.Printf("Admin server: %s://%s:%d (admin endpoints)", adminScheme, cfg.Server.Host, cfg.Server.AdminPort)
+	log.Printf("Webhook endpoint: %s://%s:%d%s", publicScheme, cfg.Server.Host, cfg.Server.Port, cfg.Server.WebhookPath)
+	log.Printf("Auth callback: %s://%s:%d/auth/callback", publicScheme, cfg.Server.Host, cfg.Server.Port)
 
 	// Wait for shutdown signal
 	c := make(chan os.Signal, 1)
@@ -140,9 +156,4 @@ func getPassword(password string) string {
 	}
 
 	return ""
-
-	fmt.Print("Enter password for token encryption: ")
-	var pwd string
-	fmt.Scanln(&pwd)
-	return pwd
 }
@@ -2,23 +2,29 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	Dropbox   DropboxConfig `toml:"dropbox"`
-	Server    ServerConfig  `toml:"server"`
-	Sync      SyncConfig    `toml:"sync"`
-	Build     BuildConfig   `toml:"build"`
+	Dropbox   DropboxConfig  `toml:"dropbox"`
+	Server    ServerConfig   `toml:"server"`
+	Sync      SyncConfig     `toml:"sync"`
+	Build     BuildConfig    `toml:"build"`
 	Database  DatabaseConfig `toml:"database"`
-	CopyRules []CopyRule    `toml:"copy_rules"`
+	CopyRules []CopyRule     `toml:"copy_rules"`
+	Hooks     HooksConfig    `toml:"hooks"`
+	// Transforms rewrite synced files before the build, in order
+	Transforms []Transform `toml:"transforms,omitempty"`
 }
 
 type DropboxConfig struct {
 	AppKey      string `toml:"app_key"`
 	AppSecret   string `toml:"app_secret"`
 	RedirectURI string `toml:"redirect_uri"`
+	// MaxRetries is how often a rate limited or failed API call is retried, 0 uses the default and -1 disables retries
+	MaxRetries int `toml:"max_retries,omitempty"`
 }
 
 type ServerConfig struct {
@@ -26,16 +32,102 @@ type ServerConfig struct {
 	Port        int    `toml:"port"`
 	AdminPort   int    `toml:"admin_port"`
 	WebhookPath string `toml:"webhook_path"`
+	// TLS serves both ports over HTTPS when set, plain HTTP is used otherwise
+	TLS TLSConfig `toml:"tls,omitempty"`
+	// AdminAuth guards the admin server, it is open when nothing is set
+	AdminAuth AdminAuthConfig `toml:"admin_auth,omitempty"`
+}
+
+// AdminAuthConfig accepts HTTP basic auth with Username and Password
+// or an "Authorization: Bearer" header with Token, either one is enough
+type AdminAuthConfig struct {
+	Username string `toml:"username,omitempty"`
+	Password string `toml:"password,omitempty"`
+	Token    string `toml:"token,omitempty"`
+}
+
+// Enabled reports whether any admin credentials are configured
+func (a AdminAuthConfig) Enabled() bool {
+	return a.Username != "" || a.Password != "" || a.Token != ""
+}
+
+// TLSConfig takes either a certificate and key for both servers,
+// or domains to get Let's Encrypt certificates for on the public server
+type TLSConfig struct {
+	CertFile string `toml:"cert_file,omitempty"`
+	KeyFile  string `toml:"key_file,omitempty"`
+	// AutocertDomains are the host names the public server requests certificates for
+	AutocertDomains []string `toml:"autocert_domains,omitempty"`
+	// AutocertCache is the directory certificates are kept in, "autocert" when empty
+	AutocertCache string `toml:"autocert_cache,omitempty"`
+}
+
+// Scheme is the url scheme a server is reached on, autocert only covers the public server
+func (t TLSConfig) Scheme(public bool) string {
+	if (t.CertFile != "" && t.KeyFile != "") || (public && len(t.AutocertDomains) > 0) {
+		return "https"
+	}
+	return "http"
 }
 
 type SyncConfig struct {
 	LocalBasePath string `toml:"local_base_path"`
 	DropboxFolder string `toml:"dropbox_folder"`
+	// DownloadWorkers is how many files a full sync downloads at once, 0 uses the default
+	DownloadWorkers int `toml:"download_workers,omitempty"`
+	// Sources sync several Dropbox folders into subdirectories of LocalBasePath, DropboxFolder is used when empty
+	Sources []SyncSource `toml:"sources,omitempty"`
+	// QueueSize is how many sync events can wait, 0 uses the default of 100
+	QueueSize int `toml:"queue_size,omitempty"`
+	// QueueOverflow is "coalesce" to merge a webhook into one already waiting, the default,
+	// or "drop" to queue every event and reject new ones while the queue is full
+	QueueOverflow string `toml:"queue_overflow,omitempty"`
+	// TempDir holds zip sync downloads while they are extracted, the system temp dir when empty
+	TempDir string `toml:"temp_dir,omitempty"`
+}
+
+type SyncSource struct {
+	DropboxFolder string `toml:"dropbox_folder"`
+	// LocalDir is relative to LocalBasePath
+	LocalDir string `toml:"local_dir"`
+	// Ignore patterns are matched against each path segment and leading path inside the folder, like "*.psd" or "drafts"
+	Ignore []string `toml:"ignore,omitempty"`
+}
+
+// SyncSources returns the configured sources, or DropboxFolder synced into LocalBasePath when there are none
+func (sc SyncConfig) SyncSources() []SyncSource {
+	if len(sc.Sources) == 0 {
+		return []SyncSource{{DropboxFolder: sc.DropboxFolder}}
+	}
+	return sc.Sources
 }
 
 type BuildConfig struct {
 	Command          string `toml:"command"`
 	WorkingDirectory string `toml:"working_directory"`
+	// Env is added to the environment the build command inherits
+	Env map[string]string `toml:"env,omitempty"`
+	// Debounce delays the build until no sync has finished for this long, so a burst of syncs builds once
+	Debounce time.Duration `toml:"debounce,omitempty"`
+	// Trigger limits the build to syncs changing a file matching one of these globs, like "**/*.md",
+	// they match the end of the changed path and "**" matches any number of directories
+	Trigger []string `toml:"trigger,omitempty"`
+}
+
+type HooksConfig struct {
+	// PostSync URLs are POSTed a JSON report after a sync and build succeed
+	PostSync []string `toml:"post_sync,omitempty"`
+}
+
+// Transform rewrites downloaded files matching Path, a glob relative to the local base path
+// or, without a slash, matched against the file name
+// Find is a regular expression replaced with Replace, which can use $1 style groups,
+// Frontmatter keys are added to the YAML frontmatter of files that do not set them yet
+type Transform struct {
+	Path        string            `toml:"path"`
+	Find        string            `toml:"find,omitempty"`
+	Replace     string            `toml:"replace,omitempty"`
+	Frontmatter map[string]string `toml:"frontmatter,omitempty"`
 }
 
 type DatabaseConfig struct {
@@ -106,4 +198,4 @@ func Default() *Config {
 			},
 		},
 	}
-}
\ No newline at end of file
+}
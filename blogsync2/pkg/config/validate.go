@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Validate checks the settings the service cannot start without and returns every problem found at once,
+// it only reads the filesystem, PrepareDirs creates the directories
+func (c *Config) Validate() error {
+	var errs []error
+
+	if strings.TrimSpace(c.Dropbox.AppKey) == "" {
+		errs = append(errs, errors.New("dropbox.app_key is required"))
+	}
+	if strings.TrimSpace(c.Dropbox.AppSecret) == "" {
+		errs = append(errs, errors.New("dropbox.app_secret is required"))
+	}
+
+	for _, port := range []struct {
+		name  string
+		value int
+	}{{"server.port", c.Server.Port}, {"server.admin_port", c.Server.AdminPort}} {
+		if port.value <= 0 || port.value > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be between 1 and 65535, got %d", port.name, port.value))
+		}
+	}
+	if c.Server.Port != 0 && c.Server.Port == c.Server.AdminPort {
+		errs = append(errs, fmt.Errorf("server.port and server.admin_port are both %d", c.Server.Port))
+	}
+
+	if tls := c.Server.TLS; (tls.CertFile == "") != (tls.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls.cert_file and server.tls.key_file must be set together"))
+	} else if tls.CertFile != "" && len(tls.AutocertDomains) > 0 {
+		errs = append(errs, errors.New("server.tls.autocert_domains cannot be used with cert_file and key_file"))
+	}
+
+	if auth := c.Server.AdminAuth; (auth.Username == "") != (auth.Password == "") {
+		errs = append(errs, errors.New("server.admin_auth.username and server.admin_auth.password must be set together"))
+	}
+
+	if strings.TrimSpace(c.Sync.LocalBasePath) == "" {
+		errs = append(errs, errors.New("sync.local_base_path is required"))
+	} else if err := checkDir(c.Sync.LocalBasePath); err != nil {
+		errs = append(errs, fmt.Errorf("sync.local_base_path %s is not usable: %w", c.Sync.LocalBasePath, err))
+	}
+	if c.Sync.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("sync.queue_size must not be negative, got %d", c.Sync.QueueSize))
+	}
+	switch c.Sync.QueueOverflow {
+	case "", "coalesce", "drop":
+	default:
+		errs = append(errs, fmt.Errorf("sync.queue_overflow must be coalesce or drop, got %q", c.Sync.QueueOverflow))
+	}
+	if c.Sync.TempDir != "" {
+		if err := checkDir(c.Sync.TempDir); err != nil {
+			errs = append(errs, fmt.Errorf("sync.temp_dir %s is not usable: %w", c.Sync.TempDir, err))
+		}
+	}
+	for i, source := range c.Sync.Sources {
+		if strings.TrimSpace(source.DropboxFolder) == "" {
+			errs = append(errs, fmt.Errorf("sync.sources[%d].dropbox_folder is required", i))
+		}
+	}
+
+	for i, transform := range c.Transforms {
+		if strings.TrimSpace(transform.Path) == "" {
+			errs = append(errs, fmt.Errorf("transforms[%d].path is required", i))
+		} else if _, err := path.Match(transform.Path, ""); err != nil {
+			errs = append(errs, fmt.Errorf("transforms[%d].path %q is not a valid glob", i, transform.Path))
+		}
+		if _, err := regexp.Compile(transform.Find); err != nil {
+			errs = append(errs, fmt.Errorf("transforms[%d].find is not a valid regular expression: %w", i, err))
+		}
+	}
+
+	for i, pattern := range c.Build.Trigger {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				errs = append(errs, fmt.Errorf("build.trigger[%d] %q is not a valid glob", i, pattern))
+				break
+			}
+		}
+	}
+
+	if strings.TrimSpace(c.Database.Path) == "" {
+		errs = append(errs, errors.New("database.path is required"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// PrepareDirs creates the sync directories and checks that they can be written to,
+// it is run at start-up once Validate passed
+func (c *Config) PrepareDirs() error {
+	var errs []error
+	if err := checkWritable(c.Sync.LocalBasePath); err != nil {
+		errs = append(errs, fmt.Errorf("sync.local_base_path %s is not writable: %w", c.Sync.LocalBasePath, err))
+	}
+	if c.Sync.TempDir != "" {
+		if err := checkWritable(c.Sync.TempDir); err != nil {
+			errs = append(errs, fmt.Errorf("sync.temp_dir %s is not writable: %w", c.Sync.TempDir, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkDir reports a dir that exists as something else, or that could not be created
+// because the nearest existing parent is not a directory
+func checkDir(dir string) error {
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		info, err := os.Stat(p)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", p)
+			}
+			return nil
+		}
+		// a missing path, or one under a file, is looked up again from its parent
+		if errors.Is(err, fs.ErrPermission) {
+			return err
+		}
+		if parent := filepath.Dir(p); parent == p {
+			return nil
+		}
+	}
+}
+
+// checkWritable creates dir if needed and writes a probe file into it
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".blogsync-write-check-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
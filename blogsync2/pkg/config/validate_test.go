@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg := Default()
+	cfg.Sync.LocalBasePath = filepath.Join(t.TempDir(), "sync")
+	return cfg
+}
+
+func TestValidateAcceptsDefaults(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Errorf("expected the default config to be valid, got %v", err)
+	}
+}
+
+func TestValidateMissingBasePath(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Sync.LocalBasePath = ""
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "sync.local_base_path is required") {
+		t.Errorf("expected a missing base path error, got %v", err)
+	}
+}
+
+func TestValidateCollidingPorts(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Server.AdminPort = cfg.Server.Port
+	cfg.Dropbox.AppSecret = ""
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected colliding ports to be rejected")
+	}
+	// every problem is reported, not just the first
+	for _, want := range []string{"server.port and server.admin_port are both 3000", "dropbox.app_secret is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestValidateTLSNeedsCertAndKey(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Server.TLS.CertFile = "cert.pem"
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cert_file and server.tls.key_file must be set together") {
+		t.Errorf("expected a missing key error, got %v", err)
+	}
+}
+
+func TestValidateDoesNotCreateDirs(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Sync.TempDir = filepath.Join(t.TempDir(), "tmp")
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected the config to be valid, got %v", err)
+	}
+	for _, dir := range []string{cfg.Sync.LocalBasePath, cfg.Sync.TempDir} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected Validate to leave %s uncreated, got %v", dir, err)
+		}
+	}
+
+	if err := cfg.PrepareDirs(); err != nil {
+		t.Fatalf("failed to prepare dirs: %v", err)
+	}
+	for _, dir := range []string{cfg.Sync.LocalBasePath, cfg.Sync.TempDir} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("expected PrepareDirs to create %s, got %v", dir, err)
+		}
+	}
+}
+
+func TestValidateBasePathIsAFile(t *testing.T) {
+	cfg := validConfig(t)
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	cfg.Sync.LocalBasePath = filepath.Join(file, "sync")
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "is not a directory") {
+		t.Errorf("expected a not a directory error, got %v", err)
+	}
+}
@@ -18,6 +18,7 @@ type User struct {
 type SyncCursor struct {
 	ID        uint      `gorm:"primarykey" json:"id"`
 	UserID    uint      `gorm:"not null" json:"user_id"`
+	Source    string    `gorm:"index" json:"source"`
 	Cursor    string    `gorm:"not null" json:"cursor"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -35,6 +36,8 @@ type File struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Size        uint64    `gorm:"not null" json:"size"`
+	// LocalHash is the hash of the local copy when a transform rewrote it, empty when it is the Dropbox content
+	LocalHash string `json:"local_hash,omitempty"`
 }
 
 type Token struct {
@@ -48,6 +51,31 @@ type Token struct {
 	User         User      `gorm:"foreignKey:UserID"`
 }
 
+// SyncManifest is the JSON manifest of the last sync, one row per user
+type SyncManifest struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex;not null" json:"user_id"`
+	Manifest  string    `gorm:"type:text;not null" json:"manifest"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SyncLog is an entry in the activity feed, a webhook receipt or a sync run
+type SyncLog struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	Type          string    `gorm:"index;not null" json:"type"`
+	Outcome       string    `gorm:"not null" json:"outcome"`
+	FilesAffected int       `json:"files_affected"`
+	Message       string    `json:"message,omitempty"`
+	CreatedAt     time.Time `gorm:"index" json:"timestamp"`
+}
+
+const (
+	LogWebhook         = "webhook"
+	LogFullSync        = "full_sync"
+	LogIncrementalSync = "incremental_sync"
+)
+
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(&User{}, &SyncCursor{}, &File{}, &Token{})
+	return db.AutoMigrate(&User{}, &SyncCursor{}, &File{}, &Token{}, &SyncManifest{}, &SyncLog{})
 }
@@ -5,15 +5,41 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	apiURL     = "https://api.dropboxapi.com/2"
+	contentURL = "https://content.dropboxapi.com/2"
+
+	DefaultMaxRetries = 3
+
+	// PartSuffix marks a download that has not completed yet
+	PartSuffix = ".part"
+	// PartRevSuffix marks the file holding the revision a partial download is of
+	PartRevSuffix = ".part.rev"
+)
+
+// HTTPDoer sends HTTP requests, *http.Client implements it
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
 type Client struct {
 	auth   *Auth
-	client *http.Client
+	client HTTPDoer
+
+	apiURL     string
+	contentURL string
+	maxRetries int
+	// retryBase is the first backoff delay, doubled on every further retry
+	retryBase time.Duration
 }
 
 type FileInfo struct {
@@ -59,6 +85,12 @@ type DownloadRequest struct {
 	Path string `json:"path"`
 }
 
+// DownloadResult is the file metadata Dropbox sends in the Dropbox-API-Result header of a download
+type DownloadResult struct {
+	Rev         string `json:"rev"`
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
 type DownloadZipRequest struct {
 	Path string `json:"path"`
 }
@@ -74,11 +106,97 @@ type UserInfo struct {
 	AccountID string `json:"account_id"`
 }
 
-func NewClient(auth *Auth) *Client {
-	return &Client{
-		auth:   auth,
-		client: &http.Client{Timeout: 30 * time.Second},
+// ClientOption changes a default of NewClient
+type ClientOption func(*Client)
+
+// WithAPIURL points the RPC endpoints, like list_folder, at another base URL
+func WithAPIURL(url string) ClientOption {
+	return func(c *Client) {
+		c.apiURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithContentURL points the download endpoints at another base URL
+func WithContentURL(url string) ClientOption {
+	return func(c *Client) {
+		c.contentURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithHTTPClient sends requests through doer instead of an http.Client with a 30s timeout
+func WithHTTPClient(doer HTTPDoer) ClientOption {
+	return func(c *Client) {
+		c.client = doer
+	}
+}
+
+// WithRetryBase sets the first retry backoff, doubled on every further retry
+func WithRetryBase(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.retryBase = d
+	}
+}
+
+func NewClient(auth *Auth, opts ...ClientOption) *Client {
+	maxRetries := auth.config.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	c := &Client{
+		auth:       auth,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		apiURL:     apiURL,
+		contentURL: contentURL,
+		maxRetries: maxRetries,
+		retryBase:  time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// doWithRetry sends the request built by newRequest, retrying network errors, 429 and 5xx responses
+// with exponential backoff, a Retry-After header on the response takes precedence over the backoff
+// the last response is returned as is so callers report its error body
+func (c *Client) doWithRetry(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.client.Do(req)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= c.maxRetries {
+			return resp, err
+		}
+
+		wait := c.retryBase << attempt
+		if err != nil {
+			log.Printf("Dropbox request to %s failed: %v, retrying in %s", req.URL.Path, err, wait)
+		} else {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			log.Printf("Dropbox request to %s returned %d, retrying in %s", req.URL.Path, resp.StatusCode, wait)
+		}
+		time.Sleep(wait)
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds, as Dropbox sends it
+func parseRetryAfter(value string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
 }
 
 func (c *Client) ListFolder(folderPath string, recursive bool) ([]FileInfo, string, error) {
@@ -105,15 +223,15 @@ func (c *Client) ListFolder(folderPath string, recursive bool) ([]FileInfo, stri
 		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dropboxapi.com/2/files/list_folder", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.client.Do(req)
+	resp, err := c.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", c.apiURL+"/files/list_folder", bytes.NewBuffer(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to list folder: %w", err)
 	}
@@ -187,15 +305,15 @@ func (c *Client) listFolderContinue(cursor string) (ListFolderResponse, error) {
 		return ListFolderResponse{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dropboxapi.com/2/files/list_folder/continue", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return ListFolderResponse{}, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.client.Do(req)
+	resp, err := c.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", c.apiURL+"/files/list_folder/continue", bytes.NewBuffer(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return ListFolderResponse{}, fmt.Errorf("failed to continue listing folder: %w", err)
 	}
@@ -255,6 +373,9 @@ func (c *Client) GetChangesFromCursor(cursor string) ([]FileInfo, string, error)
 	return allFiles, continueResp.Cursor, nil
 }
 
+// DownloadFile downloads into <localPath>.part and renames it into place once complete,
+// a .part left by an interrupted download is resumed with a Range request as long as Dropbox still
+// serves the revision it was started from, otherwise it is discarded and the download starts over
 func (c *Client) DownloadFile(dropboxPath, localPath string) error {
 	accessToken, err := c.auth.GetValidAccessToken()
 	if err != nil {
@@ -270,50 +391,125 @@ func (c *Client) DownloadFile(dropboxPath, localPath string) error {
 		return fmt.Errorf("failed to marshal download request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://content.dropboxapi.com/2/files/download", nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	// Create directory if it doesn't exist
+	dir := filepath.Dir(localPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Dropbox-API-Arg", string(reqHeader))
+	partPath := localPath + PartSuffix
+	revPath := localPath + PartRevSuffix
+	var offset int64
+	var partRev string
+	if stat, err := os.Stat(partPath); err == nil {
+		// without its revision a partial file cannot be checked, so it is downloaded again
+		if rev, err := os.ReadFile(revPath); err == nil && len(rev) > 0 {
+			offset, partRev = stat.Size(), string(rev)
+		}
+	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", c.contentURL+"/files/download", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Dropbox-API-Arg", string(reqHeader))
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// the partial file does not fit the current revision, start over
+		log.Printf("Discarding partial download of %s", dropboxPath)
+		if err := discardPartial(localPath); err != nil {
+			return err
+		}
+		return c.DownloadFile(dropboxPath, localPath)
+	}
+
+	rev := downloadResult(resp).Rev
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if rev != partRev {
+			// the file changed since the partial download, its bytes belong to another revision
+			log.Printf("%s changed since its partial download, starting over", dropboxPath)
+			if err := discardPartial(localPath); err != nil {
+				return err
+			}
+			return c.DownloadFile(dropboxPath, localPath)
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		log.Printf("Resuming download of %s at byte %d", dropboxPath, offset)
+	case http.StatusOK:
+		// a fresh download, or the range was ignored and the whole file is sent again
+		if err := discardPartial(localPath); err != nil {
+			return err
+		}
+		if rev != "" {
+			if err := os.WriteFile(revPath, []byte(rev), 0644); err != nil {
+				return fmt.Errorf("failed to record download revision: %w", err)
+			}
+		}
+	default:
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("dropbox download error: %s", string(body))
 	}
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(localPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	// Write to file
-	outFile, err := os.Create(localPath)
+	// Write to the partial file, it is kept on failure so the next attempt can resume
+	outFile, err := os.OpenFile(partPath, flags, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create local file: %w", err)
 	}
-	defer outFile.Close()
-
-	_, err = io.Copy(outFile, resp.Body)
-	if err != nil {
+	if _, err := io.Copy(outFile, resp.Body); err != nil {
+		outFile.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := outFile.Close(); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
+	if err := os.Rename(partPath, localPath); err != nil {
+		return fmt.Errorf("failed to move download into place: %w", err)
+	}
+	os.Remove(revPath)
+
 	return nil
 }
 
-func (c *Client) DownloadZip(folderPath, localZipPath string) error {
+// downloadResult reads the metadata of a download response, empty when the header is missing or invalid
+func downloadResult(resp *http.Response) DownloadResult {
+	var result DownloadResult
+	if header := resp.Header.Get("Dropbox-API-Result"); header != "" {
+		if err := json.Unmarshal([]byte(header), &result); err != nil {
+			log.Printf("Failed to parse download result: %v", err)
+		}
+	}
+	return result
+}
+
+// discardPartial removes the partial download of localPath and its recorded revision
+func discardPartial(localPath string) error {
+	for _, path := range []string{localPath + PartSuffix, localPath + PartRevSuffix} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove partial download: %w", err)
+		}
+	}
+	return nil
+}
+
+// OpenZip starts a zip download of a folder and returns the response body, which the caller closes
+func (c *Client) OpenZip(folderPath string) (io.ReadCloser, error) {
 	accessToken, err := c.auth.GetValidAccessToken()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	path := folderPath
@@ -327,12 +523,12 @@ func (c *Client) DownloadZip(folderPath, localZipPath string) error {
 
 	reqHeader, err := json.Marshal(downloadReq)
 	if err != nil {
-		return fmt.Errorf("failed to marshal download zip request: %w", err)
+		return nil, fmt.Errorf("failed to marshal download zip request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://content.dropboxapi.com/2/files/download_zip", nil)
+	req, err := http.NewRequest("POST", c.contentURL+"/files/download_zip", nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+accessToken)
@@ -340,14 +536,24 @@ func (c *Client) DownloadZip(folderPath, localZipPath string) error {
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download zip: %w", err)
+		return nil, fmt.Errorf("failed to download zip: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("dropbox download zip error: %s", string(body))
+		return nil, fmt.Errorf("dropbox download zip error: %s", string(body))
+	}
+
+	return resp.Body, nil
+}
+
+func (c *Client) DownloadZip(folderPath, localZipPath string) error {
+	body, err := c.OpenZip(folderPath)
+	if err != nil {
+		return err
 	}
+	defer body.Close()
 
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(localZipPath)
@@ -362,7 +568,7 @@ func (c *Client) DownloadZip(folderPath, localZipPath string) error {
 	}
 	defer outFile.Close()
 
-	_, err = io.Copy(outFile, resp.Body)
+	_, err = io.Copy(outFile, body)
 	if err != nil {
 		return fmt.Errorf("failed to write zip file: %w", err)
 	}
@@ -376,7 +582,7 @@ func (c *Client) GetCurrentAccount() (*UserInfo, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.dropboxapi.com/2/users/get_current_account", nil)
+	req, err := http.NewRequest("POST", c.apiURL+"/users/get_current_account", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
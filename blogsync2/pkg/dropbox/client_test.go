@@ -0,0 +1,243 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/token"
+)
+
+// staticTokens is a token storage that always hands out the same valid token
+type staticTokens struct{}
+
+func (staticTokens) SaveToken(string, string, time.Time) error { return nil }
+func (staticTokens) HasValidToken() bool                       { return true }
+func (staticTokens) LoadToken() (*token.TokenData, error) {
+	return &token.TokenData{AccessToken: "test-token", ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+// newTestClient returns a client talking to server for both the API and content endpoints
+func newTestClient(server *httptest.Server, cfg config.DropboxConfig) *Client {
+	return NewClient(NewAuth(cfg, staticTokens{}),
+		WithAPIURL(server.URL),
+		WithContentURL(server.URL),
+		WithRetryBase(time.Millisecond),
+	)
+}
+
+func TestListFolderAgainstStubServer(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/files/list_folder":
+			var req ListFolderRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Path != "/blog" || !req.Recursive {
+				t.Errorf("unexpected list request %+v", req)
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"entries": []map[string]any{
+					{".tag": "folder", "name": "img", "path_display": "/blog/img"},
+					{".tag": "file", "name": "a.md", "path_display": "/blog/a.md", "size": 3, "content_hash": "hash-a"},
+				},
+				"cursor":   "cursor-1",
+				"has_more": true,
+			})
+		case "/files/list_folder/continue":
+			json.NewEncoder(w).Encode(map[string]any{
+				"entries": []map[string]any{{".tag": "file", "name": "b.png", "path_display": "/blog/img/b.png", "size": 9}},
+				"cursor":  "cursor-2",
+			})
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(NewAuth(config.DropboxConfig{}, staticTokens{}),
+		WithAPIURL(server.URL+"/"),
+		WithHTTPClient(server.Client()),
+	)
+	files, cursor, err := client.ListFolder("/blog", true)
+	if err != nil {
+		t.Fatalf("listing failed: %v", err)
+	}
+
+	if cursor != "cursor-2" {
+		t.Errorf("expected the cursor of the last page, got %q", cursor)
+	}
+	if len(files) != 2 || files[0].Path != "/blog/a.md" || files[0].ContentHash != "hash-a" || files[1].Path != "/blog/img/b.png" || files[1].Size != 9 {
+		t.Errorf("unexpected files %+v", files)
+	}
+	if len(paths) != 2 {
+		t.Errorf("expected a list and a continue request, got %v", paths)
+	}
+}
+
+func TestListFolderRetriesRateLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/files/list_folder" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		if calls <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error_summary": "too_many_requests/"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"entries": []map[string]any{{".tag": "file", "name": "post.md", "path_display": "/blog/post.md"}},
+			"cursor":  "cursor-1",
+		})
+	}))
+	defer server.Close()
+
+	files, cursor, err := newTestClient(server, config.DropboxConfig{}).ListFolder("/blog", true)
+	if err != nil {
+		t.Fatalf("expected the listing to succeed after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if len(files) != 1 || files[0].Path != "/blog/post.md" || cursor != "cursor-1" {
+		t.Errorf("unexpected listing %v with cursor %q", files, cursor)
+	}
+}
+
+func TestRetriesGiveUpAfterMax(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, _, err := newTestClient(server, config.DropboxConfig{MaxRetries: 1}).GetChangesFromCursor("cursor-1")
+	if err == nil {
+		t.Fatal("expected an error once retries are exhausted")
+	}
+	if calls != 2 {
+		t.Errorf("expected 1 attempt plus 1 retry, got %d", calls)
+	}
+}
+
+func TestDownloadFileResumesPartial(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	half := len(content) / 2
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Dropbox-API-Result", `{"rev": "rev-1"}`)
+		if len(ranges) == 1 {
+			// promise the whole file but drop the connection halfway through
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content[:half]))
+			return
+		}
+		if want := fmt.Sprintf("bytes=%d-", half); r.Header.Get("Range") != want {
+			t.Errorf("expected range %q, got %q", want, r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[half:]))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server, config.DropboxConfig{})
+	localPath := filepath.Join(t.TempDir(), "blog", "large.bin")
+
+	if err := client.DownloadFile("/blog/large.bin", localPath); err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Errorf("interrupted download should not create %s", localPath)
+	}
+	if stat, err := os.Stat(localPath + PartSuffix); err != nil || stat.Size() != int64(half) {
+		t.Fatalf("expected %d bytes kept in the partial file, got %v, %v", half, stat, err)
+	}
+
+	if err := client.DownloadFile("/blog/large.bin", localPath); err != nil {
+		t.Fatalf("resumed download failed: %v", err)
+	}
+	got, err := os.ReadFile(localPath)
+	if err != nil {
+		t.Fatalf("downloaded file missing: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("resumed file has %d bytes, want %d", len(got), len(content))
+	}
+	if _, err := os.Stat(localPath + PartSuffix); !os.IsNotExist(err) {
+		t.Error("partial file should be renamed away once the download completes")
+	}
+	if len(ranges) != 2 || ranges[0] != "" {
+		t.Errorf("unexpected range headers %q", ranges)
+	}
+}
+
+func TestDownloadFileRestartsChangedPartial(t *testing.T) {
+	oldContent := strings.Repeat("a", 100)
+	newContent := strings.Repeat("b", 150)
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		switch len(ranges) {
+		case 1:
+			// the first revision, dropped halfway through
+			w.Header().Set("Dropbox-API-Result", `{"rev": "rev-1"}`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(oldContent)))
+			w.Write([]byte(oldContent[:50]))
+		case 2:
+			// the file was edited since, the range is honoured against the new revision
+			w.Header().Set("Dropbox-API-Result", `{"rev": "rev-2"}`)
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(newContent[50:]))
+		default:
+			w.Header().Set("Dropbox-API-Result", `{"rev": "rev-2"}`)
+			w.Write([]byte(newContent))
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server, config.DropboxConfig{})
+	localPath := filepath.Join(t.TempDir(), "post.md")
+
+	if err := client.DownloadFile("/blog/post.md", localPath); err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	if err := client.DownloadFile("/blog/post.md", localPath); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	got, err := os.ReadFile(localPath)
+	if err != nil {
+		t.Fatalf("downloaded file missing: %v", err)
+	}
+	if string(got) != newContent {
+		t.Errorf("expected the new revision downloaded from the start, got %q", got)
+	}
+	if len(ranges) != 3 || ranges[1] != "bytes=50-" || ranges[2] != "" {
+		t.Errorf("expected a resume attempt then a full download, got range headers %q", ranges)
+	}
+	for _, leftover := range []string{localPath + PartSuffix, localPath + PartRevSuffix} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", leftover)
+		}
+	}
+}
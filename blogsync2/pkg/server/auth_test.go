@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAdminRouter(t *testing.T, auth config.AdminAuthConfig) (*gin.Engine, *sync.Queue) {
+	t.Helper()
+	s := newTestServer(t)
+	s.config = &config.Config{}
+	s.config.Server.AdminAuth = auth
+
+	syncQueue := sync.NewQueue(1, "")
+	router := gin.New()
+	s.registerAdminRoutes(router, syncQueue)
+	return router, syncQueue
+}
+
+func TestAdminRoutesRequireCredentials(t *testing.T) {
+	router, syncQueue := newAdminRouter(t, config.AdminAuthConfig{Username: "admin", Password: "secret", Token: "t0ken"})
+
+	for _, tc := range []struct {
+		name  string
+		setup func(r *http.Request)
+		want  int
+	}{
+		{"none", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "nope") }, http.StatusUnauthorized},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusOK},
+		{"token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }, http.StatusOK},
+	} {
+		req := httptest.NewRequest("POST", "/admin/sync", nil)
+		tc.setup(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, w.Code)
+		}
+		// drain so the next authorized request can queue its sync
+		if syncQueue.Pending() > 0 {
+			syncQueue.Receive()
+			if tc.want != http.StatusOK {
+				t.Errorf("%s: an unauthorized request started a sync", tc.name)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/health", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected 401 with a basic auth challenge, got %d", w.Code)
+	}
+}
+
+func TestAdminRoutesOpenWithoutCredentials(t *testing.T) {
+	router, _ := newAdminRouter(t, config.AdminAuthConfig{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the admin server to stay open without credentials, got %d", w.Code)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultFilesPerPage = 50
+	maxFilesPerPage     = 500
+	defaultHistoryLimit = 50
+)
+
+// filesHandler lists the files the sync has recorded for ?user_id=, the primary account by default,
+// ?page= starts at 1 and ?per_page= is capped at 500
+func (s *Server) filesHandler(c *gin.Context) {
+	userID, err := queryInt(c, "user_id", sync.PrimaryUserID)
+	if err != nil || userID < 1 {
+		respondJSON(c, http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "user_id must be a positive number",
+		})
+		return
+	}
+	page, err := queryInt(c, "page", 1)
+	if err != nil || page < 1 {
+		respondJSON(c, http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "page must be a positive number",
+		})
+		return
+	}
+	perPage, err := queryInt(c, "per_page", defaultFilesPerPage)
+	if err != nil || perPage < 1 {
+		respondJSON(c, http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "per_page must be a positive number",
+		})
+		return
+	}
+	if perPage > maxFilesPerPage {
+		perPage = maxFilesPerPage
+	}
+
+	var total int64
+	if err := s.db.Model(&db.File{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Failed to count files: %v", err),
+		})
+		return
+	}
+
+	files := []db.File{}
+	if err := s.db.Where("user_id = ?", userID).Order("local_path").Offset((page - 1) * perPage).Limit(perPage).Find(&files).Error; err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Failed to list files: %v", err),
+		})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"files":    files,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+// verifyHandler reports files whose local content no longer matches the recorded hash
+func (s *Server) verifyHandler(c *gin.Context) {
+	report, err := s.syncManager.VerifyFiles()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Failed to verify files: %v", err),
+		})
+		return
+	}
+	respondJSON(c, http.StatusOK, report)
+}
+
+// queryInt reads an integer query parameter, fallback is used when it is missing
+func queryInt(c *gin.Context, name string, fallback int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
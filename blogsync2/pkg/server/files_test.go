@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"blogsync2/pkg/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	database, err := db.Connect(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	return &Server{db: database}
+}
+
+func TestFilesHandlerPaginates(t *testing.T) {
+	s := newTestServer(t)
+	for i := 1; i <= 3; i++ {
+		file := db.File{UserID: 1, LocalPath: fmt.Sprintf("blog/post-%d.md", i), ContentHash: fmt.Sprintf("hash-%d", i), Size: uint64(i)}
+		if err := s.db.Create(&file).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// another user's files are not listed
+	if err := s.db.Create(&db.File{UserID: 2, LocalPath: "other.md", ContentHash: "x"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/admin/files", s.filesHandler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/files?page=2&per_page=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Files   []db.File `json:"files"`
+		Page    int       `json:"page"`
+		PerPage int       `json:"per_page"`
+		Total   int64     `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Total != 3 || resp.Page != 2 || resp.PerPage != 2 {
+		t.Errorf("unexpected pagination total=%d page=%d per_page=%d", resp.Total, resp.Page, resp.PerPage)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].LocalPath != "blog/post-3.md" || resp.Files[0].ContentHash != "hash-3" {
+		t.Errorf("expected only post-3 on the second page, got %+v", resp.Files)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/files?page=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for page 0, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/files?user_id=2", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if w.Code != http.StatusOK || resp.Total != 1 || len(resp.Files) != 1 || resp.Files[0].LocalPath != "other.md" {
+		t.Errorf("expected only the other user's file for user_id=2, got %d %+v", w.Code, resp.Files)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/files?user_id=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad user_id, got %d", w.Code)
+	}
+}
@@ -6,16 +6,17 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"blogsync2/pkg/config"
+	"blogsync2/pkg/db"
 	"blogsync2/pkg/dropbox"
 	"blogsync2/pkg/sync"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Server struct {
@@ -23,18 +24,20 @@ type Server struct {
 	syncManager *sync.Manager
 	auth        *dropbox.Auth
 	client      *dropbox.Client
+	db          *gorm.DB
 }
 
-func New(cfg *config.Config, syncManager *sync.Manager, auth *dropbox.Auth) *Server {
+func New(cfg *config.Config, syncManager *sync.Manager, auth *dropbox.Auth, database *gorm.DB) *Server {
 	return &Server{
 		config:      cfg,
 		syncManager: syncManager,
 		auth:        auth,
 		client:      dropbox.NewClient(auth),
+		db:          database,
 	}
 }
 
-func (s *Server) Start(syncChan chan<- sync.Event) error {
+func (s *Server) Start(syncQueue *sync.Queue) error {
 	// Configure Gin
 	gin.SetMode(gin.ReleaseMode)
 
@@ -46,7 +49,7 @@ func (s *Server) Start(syncChan chan<- sync.Event) error {
 	// Routes
 	publicRouter.GET("/", s.indexHandler)
 	publicRouter.GET(s.config.Server.WebhookPath, s.webhookVerificationHandler)
-	publicRouter.POST(s.config.Server.WebhookPath, s.webhookNotificationHandler(syncChan))
+	publicRouter.POST(s.config.Server.WebhookPath, s.webhookNotificationHandler(syncQueue))
 	publicRouter.GET("/auth/callback", s.authCallbackHandler)
 	publicRouter.GET("/health", s.healthCheckHandler)
 
@@ -54,28 +57,19 @@ func (s *Server) Start(syncChan chan<- sync.Event) error {
 	adminRouter := gin.New()
 	adminRouter.Use(gin.Logger(), gin.Recovery())
 	adminRouter.Use(cors.Default())
-
-	adminRouter.POST("/admin/sync", s.manualSyncHandler(syncChan))
-	adminRouter.POST("/admin/sync_zip", s.syncZipHandler)
-	adminRouter.GET("/admin/status", s.adminStatusHandler)
-	adminRouter.GET("/admin/auth", s.startAuthHandler)
-	adminRouter.GET("/admin/test", s.testDropboxHandler)
-	adminRouter.GET("/admin/webhooks", s.webhookHistoryHandler)
-	adminRouter.GET("/admin/health", s.healthCheckHandler)
+	s.registerAdminRoutes(adminRouter, syncQueue)
 
 	// Start servers
 	go func() {
 		addr := fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port)
-		log.Printf("Starting public server on %s", addr)
-		if err := publicRouter.Run(addr); err != nil {
+		if err := s.serve("public", addr, publicRouter, true); err != nil {
 			log.Fatalf("Public server failed: %v", err)
 		}
 	}()
 
 	go func() {
 		addr := fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.AdminPort)
-		log.Printf("Starting admin server on %s", addr)
-		if err := adminRouter.Run(addr); err != nil {
+		if err := s.serve("admin", addr, adminRouter, false); err != nil {
 			log.Fatalf("Admin server failed: %v", err)
 		}
 	}()
@@ -83,6 +77,24 @@ func (s *Server) Start(syncChan chan<- sync.Event) error {
 	return nil
 }
 
+// registerAdminRoutes adds the admin endpoints behind the admin auth middleware
+func (s *Server) registerAdminRoutes(router *gin.Engine, syncQueue *sync.Queue) {
+	router.Use(s.requireAdminAuth())
+
+	router.POST("/admin/sync", s.manualSyncHandler(syncQueue))
+	router.POST("/admin/sync_zip", s.syncZipHandler)
+	router.POST("/admin/redownload", s.redownloadHandler(syncQueue))
+	router.GET("/admin/status", s.adminStatusHandler)
+	router.GET("/admin/sync/last", s.lastSyncHandler)
+	router.GET("/admin/sync/status", s.syncStatusHandler)
+	router.GET("/admin/files", s.filesHandler)
+	router.GET("/admin/verify", s.verifyHandler)
+	router.GET("/admin/auth", s.startAuthHandler)
+	router.GET("/admin/test", s.testDropboxHandler)
+	router.GET("/admin/webhooks", s.webhookHistoryHandler)
+	router.GET("/admin/health", s.healthCheckHandler)
+}
+
 func (s *Server) indexHandler(c *gin.Context) {
 	html := fmt.Sprintf(`<!DOCTYPE html>
 <html>
@@ -130,7 +142,13 @@ func (s *Server) indexHandler(c *gin.Context) {
             <strong>Admin Endpoints:</strong><br>
             <div class="endpoint">POST /admin/sync</div>
             <div class="endpoint">POST /admin/sync_zip</div>
+            <div class="endpoint">POST /admin/redownload?path=glob</div>
             <div class="endpoint">GET /admin/status</div>
+            <div class="endpoint">GET /admin/sync/last</div>
+            <div class="endpoint">GET /admin/sync/status</div>
+            <div class="endpoint">GET /admin/files?user_id=id</div>
+            <div class="endpoint">GET /admin/verify</div>
+            <div class="endpoint">GET /admin/webhooks</div>
             <div class="endpoint">GET /admin/auth</div>
             <div class="endpoint">GET /admin/test</div>
         </p>
@@ -153,7 +171,7 @@ func (s *Server) webhookVerificationHandler(c *gin.Context) {
 	c.String(http.StatusOK, verification.Challenge)
 }
 
-func (s *Server) webhookNotificationHandler(syncChan chan<- sync.Event) gin.HandlerFunc {
+func (s *Server) webhookNotificationHandler(syncQueue *sync.Queue) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		log.Println("=== WEBHOOK RECEIVED ===")
 		log.Printf("Timestamp: %s", time.Now().Format(time.RFC3339))
@@ -188,12 +206,13 @@ func (s *Server) webhookNotificationHandler(syncChan chan<- sync.Event) gin.Hand
 		log.Println("========================")
 
 		// Send sync event
-		select {
-		case syncChan <- sync.Event{Type: sync.FilesChanged, Data: &payload}:
+		if syncQueue.Send(sync.Event{Type: sync.FilesChanged, Data: &payload}) {
 			log.Println("Sync event sent successfully")
+			s.recordWebhook("queued", "")
 			c.String(http.StatusOK, "OK")
-		default:
-			log.Println("Failed to send sync event: channel full")
+		} else {
+			log.Println("Failed to send sync event: queue full")
+			s.recordWebhook("error", "sync queue full")
 			c.Status(http.StatusInternalServerError)
 		}
 	}
@@ -268,19 +287,46 @@ func (s *Server) healthCheckHandler(c *gin.Context) {
 	})
 }
 
-func (s *Server) manualSyncHandler(syncChan chan<- sync.Event) gin.HandlerFunc {
+func (s *Server) manualSyncHandler(syncQueue *sync.Queue) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		log.Println("Manual sync requested")
 
-		select {
-		case syncChan <- sync.Event{Type: sync.ForceSync}:
+		if syncQueue.Send(sync.Event{Type: sync.ForceSync}) {
 			respondJSON(c, http.StatusOK, gin.H{
 				"status":    "sync_triggered",
 				"message":   "Sync process has been triggered",
 				"timestamp": time.Now().Format(time.RFC3339),
 			})
-		default:
-			log.Println("Failed to send manual sync event: channel full")
+		} else {
+			log.Println("Failed to send manual sync event: queue full")
+			c.Status(http.StatusInternalServerError)
+		}
+	}
+}
+
+// redownloadHandler clears the stored hashes of the files matching ?path= (all files without it) and triggers a sync
+func (s *Server) redownloadHandler(syncQueue *sync.Queue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		glob := c.Query("path")
+		log.Printf("Redownload requested for %q", glob)
+
+		cleared, err := s.syncManager.ForceRedownload(glob)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if syncQueue.Send(sync.Event{Type: sync.ForceSync}) {
+			respondJSON(c, http.StatusOK, gin.H{
+				"status":    "sync_triggered",
+				"cleared":   cleared,
+				"timestamp": time.Now().Format(time.RFC3339),
+			})
+		} else {
+			log.Println("Failed to send redownload sync event: queue full")
 			c.Status(http.StatusInternalServerError)
 		}
 	}
@@ -297,46 +343,18 @@ func (s *Server) syncZipHandler(c *gin.Context) {
 		return
 	}
 
-	tempZipPath := "/tmp/dropbox_sync.zip"
-
-	log.Printf("Requesting download from path: %s", s.config.Sync.DropboxFolder)
-	log.Printf("Temporary zip path: %s", tempZipPath)
-
-	if err := s.client.DownloadZip(s.config.Sync.DropboxFolder, tempZipPath); err != nil {
-		log.Printf("Zip download failed: %v", err)
-		respondJSON(c, http.StatusOK, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Zip download failed: %v", err),
-		})
-		return
-	}
-
-	// Check if file was created and get its size
-	stat, err := os.Stat(tempZipPath)
-	if err != nil {
-		log.Printf("Failed to get zip metadata: %v", err)
-		respondJSON(c, http.StatusOK, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Failed to get zip metadata: %v", err),
-		})
-		return
-	}
-
-	log.Printf("Successfully downloaded zip file: %d bytes", stat.Size())
-	zipSize := stat.Size()
-
-	// Extract the zip contents to sync folder
 	syncFolder := s.config.Sync.LocalBasePath
-	extractedCount, err := s.syncManager.ExtractZip(tempZipPath, syncFolder)
+	zipSize, extractedCount, err := s.syncZip(s.client, syncFolder)
 	if err != nil {
-		log.Printf("Failed to extract zip: %v", err)
-		// Clean up the temp file
-		os.Remove(tempZipPath)
-		respondJSON(c, http.StatusOK, gin.H{
-			"status":   "error",
-			"message":  fmt.Sprintf("Downloaded zip but failed to extract: %v", err),
-			"zip_size": zipSize,
-		})
+		log.Printf("Zip sync failed: %v", err)
+		response := gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Zip sync failed: %v", err),
+		}
+		if zipSize > 0 {
+			response["zip_size"] = zipSize
+		}
+		respondJSON(c, http.StatusOK, response)
 		return
 	}
 
@@ -389,13 +407,66 @@ func (s *Server) adminStatusHandler(c *gin.Context) {
 	respondJSON(c, http.StatusOK, response)
 }
 
-func (s *Server) webhookHistoryHandler(c *gin.Context) {
+// syncStatusHandler returns the phase timings of the most recent sync, last_run is null before the first
+func (s *Server) syncStatusHandler(c *gin.Context) {
 	respondJSON(c, http.StatusOK, gin.H{
-		"message": "Webhook history not implemented yet - check service logs for webhook activity",
-		"tip":     "Look for '=== WEBHOOK RECEIVED ===' in logs",
+		"last_run": s.syncManager.LastTimings(),
 	})
 }
 
+// lastSyncHandler returns the manifest of the most recent sync
+func (s *Server) lastSyncHandler(c *gin.Context) {
+	manifest, err := s.syncManager.LastManifest()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Failed to load sync manifest: %v", err),
+		})
+		return
+	}
+	if manifest == nil {
+		respondJSON(c, http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "No sync has completed yet",
+		})
+		return
+	}
+	respondJSON(c, http.StatusOK, manifest)
+}
+
+// webhookHistoryHandler returns the activity log, newest first, ?limit= defaults to 50
+func (s *Server) webhookHistoryHandler(c *gin.Context) {
+	limit, err := queryInt(c, "limit", defaultHistoryLimit)
+	if err != nil || limit < 1 {
+		respondJSON(c, http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "limit must be a positive number",
+		})
+		return
+	}
+	if limit > maxFilesPerPage {
+		limit = maxFilesPerPage
+	}
+
+	logs := []db.SyncLog{}
+	if err := s.db.Order("created_at desc, id desc").Limit(limit).Find(&logs).Error; err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Failed to read activity log: %v", err),
+		})
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{"entries": logs})
+}
+
+// recordWebhook adds a webhook receipt to the activity log
+func (s *Server) recordWebhook(outcome, message string) {
+	entry := db.SyncLog{Type: db.LogWebhook, Outcome: outcome, Message: message}
+	if err := s.db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record webhook in the activity log: %v", err)
+	}
+}
+
 func (s *Server) startAuthHandler(c *gin.Context) {
 	log.Println("Auth flow requested via admin endpoint")
 
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const defaultAutocertCache = "autocert"
+
+// serve runs handler on addr until it fails, over TLS when the config has a certificate for the server,
+// autocert is only used for the public server since Let's Encrypt has to reach it
+func (s *Server) serve(name, addr string, handler http.Handler, public bool) error {
+	tlsConfig, err := s.tlsConfig(public)
+	if err != nil {
+		return err
+	}
+	listener, err := listen(addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	log.Printf("Starting %s server on %s://%s", name, scheme, listener.Addr())
+	return http.Serve(listener, handler)
+}
+
+// listen opens addr, wrapped in TLS unless tlsConfig is nil
+func listen(addr string, tlsConfig *tls.Config) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return listener, nil
+	}
+	return tls.NewListener(listener, tlsConfig), nil
+}
+
+// tlsConfig builds the TLS settings for a server, nil means plain HTTP
+func (s *Server) tlsConfig(public bool) (*tls.Config, error) {
+	settings := s.config.Server.TLS
+
+	if settings.CertFile != "" && settings.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}, nil
+	}
+
+	if public && len(settings.AutocertDomains) > 0 {
+		cache := settings.AutocertCache
+		if cache == "" {
+			cache = defaultAutocertCache
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(settings.AutocertDomains...),
+			Cache:      autocert.DirCache(cache),
+		}
+		return manager.TLSConfig(), nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"blogsync2/pkg/config"
+)
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key into dir
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "blogsync test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServeUsesTLSWithCertificate(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile = writeSelfSignedCert(t, t.TempDir())
+	s := &Server{config: cfg}
+
+	tlsConfig, err := s.tlsConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig == nil {
+		t.Fatal("expected a TLS config when cert and key are set")
+	}
+	listener, err := listen("127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("expected a TLS handshake, got %v", err)
+	}
+	conn.Close()
+
+	// plain HTTP is not answered
+	resp, err := http.Get("http://" + listener.Addr().String())
+	if err == nil && resp.StatusCode == http.StatusNoContent {
+		t.Error("expected plain HTTP to be refused")
+	}
+}
+
+func TestServeFallsBackToHTTP(t *testing.T) {
+	s := &Server{config: &config.Config{}}
+	tlsConfig, err := s.tlsConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig != nil {
+		t.Error("expected plain HTTP without TLS settings")
+	}
+
+	// autocert is only used for the public server
+	s.config.Server.TLS.AutocertDomains = []string{"blog.example.com"}
+	if tlsConfig, _ := s.tlsConfig(false); tlsConfig != nil {
+		t.Error("expected the admin server to stay on HTTP with autocert")
+	}
+	if tlsConfig, _ := s.tlsConfig(true); tlsConfig == nil {
+		t.Error("expected autocert for the public server")
+	}
+}
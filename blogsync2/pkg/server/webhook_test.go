@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+	"blogsync2/pkg/sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emptyDropbox is a Dropbox with an empty folder
+type emptyDropbox struct{}
+
+func (emptyDropbox) ListFolder(folderPath string, recursive bool) ([]dropbox.FileInfo, string, error) {
+	return nil, "cursor", nil
+}
+
+func (emptyDropbox) GetChangesFromCursor(cursor string) ([]dropbox.FileInfo, string, error) {
+	return nil, cursor, nil
+}
+
+func (emptyDropbox) DownloadFile(dropboxPath, localPath string) error {
+	return nil
+}
+
+func TestWebhookAndSyncAppearInHistory(t *testing.T) {
+	s := newTestServer(t)
+	cfg := &config.Config{}
+	cfg.Sync.LocalBasePath = filepath.Join(t.TempDir(), "sync")
+	cfg.Sync.DropboxFolder = "/blog"
+
+	syncQueue := sync.NewQueue(1, "")
+	router := gin.New()
+	router.POST("/webhook", s.webhookNotificationHandler(syncQueue))
+	router.GET("/admin/webhooks", s.webhookHistoryHandler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/webhook", strings.NewReader(`{"list_folder":{"accounts":[]}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from the webhook, got %d", w.Code)
+	}
+
+	// without a cursor the webhook falls back to a full sync
+	syncQueue.Close()
+	sync.NewManager(cfg, emptyDropbox{}, s.db).Start(syncQueue)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/webhooks", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Entries []db.SyncLog `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	var sawWebhook, sawSync bool
+	for _, entry := range resp.Entries {
+		switch entry.Type {
+		case db.LogWebhook:
+			sawWebhook = entry.Outcome == "queued"
+		case db.LogFullSync:
+			sawSync = entry.Outcome == "success"
+		}
+		if entry.CreatedAt.IsZero() {
+			t.Errorf("entry %+v has no timestamp", entry)
+		}
+	}
+	if !sawWebhook || !sawSync {
+		t.Errorf("expected a queued webhook and a successful full sync, got %+v", resp.Entries)
+	}
+}
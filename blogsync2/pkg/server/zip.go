@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"blogsync2/pkg/sync"
+)
+
+// zipDownloader fetches a Dropbox folder as a zip archive
+type zipDownloader interface {
+	OpenZip(folderPath string) (io.ReadCloser, error)
+}
+
+// syncZip extracts the sync folder into syncFolder while it downloads, the archive is also
+// spooled to a temp file of its own so an entry that cannot be streamed falls back to
+// extracting from the file, which is removed before it returns
+func (s *Server) syncZip(downloader zipDownloader, syncFolder string) (zipSize int64, extracted int, err error) {
+	tempZipPath, err := s.createZipTempFile()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create temporary zip file: %w", err)
+	}
+	defer os.Remove(tempZipPath)
+
+	log.Printf("Requesting download from path: %s", s.config.Sync.DropboxFolder)
+	log.Printf("Temporary zip path: %s", tempZipPath)
+
+	body, err := downloader.OpenZip(s.config.Sync.DropboxFolder)
+	if err != nil {
+		return 0, 0, fmt.Errorf("zip download failed: %w", err)
+	}
+	defer body.Close()
+
+	spool, err := os.OpenFile(tempZipPath, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open temporary zip file: %w", err)
+	}
+	defer spool.Close()
+	download := io.TeeReader(body, spool)
+
+	extracted, streamErr := s.syncManager.ExtractZipStream(download, syncFolder)
+	if streamErr != nil && !errors.Is(streamErr, sync.ErrNotStreamable) {
+		return 0, extracted, fmt.Errorf("failed to extract zip: %w", streamErr)
+	}
+
+	// read the central directory too, or the rest of the archive for the fallback
+	if _, err := io.Copy(io.Discard, download); err != nil {
+		return 0, extracted, fmt.Errorf("zip download failed: %w", err)
+	}
+	if err := spool.Close(); err != nil {
+		return 0, extracted, fmt.Errorf("failed to write temporary zip file: %w", err)
+	}
+
+	stat, err := os.Stat(tempZipPath)
+	if err != nil {
+		return 0, extracted, fmt.Errorf("failed to get zip metadata: %w", err)
+	}
+	zipSize = stat.Size()
+	log.Printf("Successfully downloaded zip file: %d bytes", zipSize)
+
+	if streamErr == nil {
+		return zipSize, extracted, nil
+	}
+
+	log.Printf("Extracting from the downloaded file instead: %v", streamErr)
+	extracted, err = s.syncManager.ExtractZip(tempZipPath, syncFolder)
+	if err != nil {
+		return zipSize, extracted, fmt.Errorf("downloaded zip but failed to extract: %w", err)
+	}
+	return zipSize, extracted, nil
+}
+
+// createZipTempFile creates an empty file with a unique name in the configured temp dir,
+// so concurrent zip syncs never share a download
+func (s *Server) createZipTempFile() (string, error) {
+	dir := s.config.Sync.TempDir
+	if dir == "" {
+		dir = os.TempDir()
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(dir, "dropbox_sync-*.zip")
+	if err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	gosync "sync"
+	"testing"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/sync"
+)
+
+// zipStub serves a single file archive once every concurrent download has started,
+// recording how many zip temp files existed before any of them finished
+type zipStub struct {
+	tempDir   string
+	started   gosync.WaitGroup
+	listed    gosync.WaitGroup
+	mu        gosync.Mutex
+	calls     int
+	tempFiles []int
+}
+
+func (z *zipStub) OpenZip(folderPath string) (io.ReadCloser, error) {
+	z.mu.Lock()
+	n := z.calls
+	z.calls++
+	z.mu.Unlock()
+
+	z.started.Done()
+	z.started.Wait()
+
+	entries, err := os.ReadDir(z.tempDir)
+	if err != nil {
+		return nil, err
+	}
+	z.mu.Lock()
+	z.tempFiles = append(z.tempFiles, len(entries))
+	z.mu.Unlock()
+	z.listed.Done()
+	z.listed.Wait()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	entry, err := w.Create(fmt.Sprintf("blog/post-%d.md", n))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := entry.Write([]byte("# post")); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&buf), nil
+}
+
+func TestConcurrentZipSyncsUseDistinctTempFiles(t *testing.T) {
+	s := newTestServer(t)
+	s.config = &config.Config{}
+	s.config.Sync.TempDir = filepath.Join(t.TempDir(), "tmp")
+	s.config.Sync.DropboxFolder = "/blog"
+	s.syncManager = sync.NewManager(s.config, nil, s.db)
+	syncFolder := filepath.Join(t.TempDir(), "sync")
+
+	stub := &zipStub{tempDir: s.config.Sync.TempDir}
+	stub.started.Add(2)
+	stub.listed.Add(2)
+	var wg gosync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, errs[i] = s.syncZip(stub, syncFolder)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("zip sync failed: %v", err)
+		}
+	}
+	for _, count := range stub.tempFiles {
+		if count != 2 {
+			t.Errorf("expected two distinct temp files while both syncs ran, found %d", count)
+		}
+	}
+	if left, _ := os.ReadDir(s.config.Sync.TempDir); len(left) != 0 {
+		t.Errorf("expected the temp files to be removed after the syncs, found %d", len(left))
+	}
+	for _, name := range []string{"post-0.md", "post-1.md"} {
+		if _, err := os.Stat(filepath.Join(syncFolder, name)); err != nil {
+			t.Errorf("expected %s to be extracted: %v", name, err)
+		}
+	}
+}
+
+func TestZipTempFileRemovedOnError(t *testing.T) {
+	s := newTestServer(t)
+	s.config = &config.Config{}
+	s.config.Sync.TempDir = t.TempDir()
+
+	_, _, err := s.syncZip(failingZip{}, t.TempDir())
+	if err == nil {
+		t.Fatal("expected the failed download to be reported")
+	}
+	left, _ := os.ReadDir(s.config.Sync.TempDir)
+	if len(left) != 0 {
+		t.Errorf("expected the temp dir to be empty, found %d files", len(left))
+	}
+}
+
+type failingZip struct{}
+
+func (failingZip) OpenZip(folderPath string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("dropbox is down")
+}
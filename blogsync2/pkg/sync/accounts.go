@@ -0,0 +1,169 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+
+	"gorm.io/gorm"
+)
+
+// PrimaryUserID is the account zip syncs and the manifest belong to, and the one a webhook
+// naming no known account syncs, the files of every account are recorded under its own user
+const PrimaryUserID = 1
+
+var errNoCursor = errors.New("no cursor saved")
+
+// SetClientFactory lets webhook syncs for other linked Dropbox accounts use their own client,
+// without it every account is synced through the manager's client
+func (m *Manager) SetClientFactory(factory func(userID uint) DropboxClient) {
+	m.clientFactory = factory
+}
+
+func (m *Manager) clientFor(userID uint) DropboxClient {
+	if userID == PrimaryUserID || m.clientFactory == nil {
+		return m.client
+	}
+	return m.clientFactory(userID)
+}
+
+// syncedUsers is every account a full sync covers, the primary one first,
+// other linked accounts are only listed when they have a client of their own
+func (m *Manager) syncedUsers() []uint {
+	ids := []uint{PrimaryUserID}
+	if m.clientFactory == nil {
+		return ids
+	}
+
+	var users []db.User
+	if err := m.db.Where("id <> ?", PrimaryUserID).Order("id").Find(&users).Error; err != nil {
+		log.Printf("Failed to look up linked accounts: %v", err)
+		return ids
+	}
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+// notifiedUsers maps the accounts of a webhook notification to users,
+// the primary user when the notification names no account we know
+func (m *Manager) notifiedUsers(notification *dropbox.WebhookNotification) []uint {
+	if notification == nil || notification.ListFolder == nil || len(notification.ListFolder.Accounts) == 0 {
+		return []uint{PrimaryUserID}
+	}
+
+	var users []db.User
+	if err := m.db.Where("account_id IN ?", notification.ListFolder.Accounts).Order("id").Find(&users).Error; err != nil {
+		log.Printf("Failed to look up notified accounts: %v", err)
+		return []uint{PrimaryUserID}
+	}
+	if len(users) == 0 {
+		log.Printf("Webhook accounts %v are not linked, syncing the primary account", notification.ListFolder.Accounts)
+		return []uint{PrimaryUserID}
+	}
+
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+// collectChanges reads the changes since the user's saved cursors and advances them,
+// errNoCursor means a source has never been synced for the user
+func (m *Manager) collectChanges(userID uint) ([]download, error) {
+	sources := m.config.Sync.SyncSources()
+	cursors := make([]string, len(sources))
+	for i, source := range sources {
+		cursor, err := m.loadCursor(userID, source.DropboxFolder)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errNoCursor
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cursor for %s: %w", source.DropboxFolder, err)
+		}
+		cursors[i] = cursor
+	}
+
+	log.Printf("Starting incremental sync from cursor for user %d", userID)
+
+	client := m.clientFor(userID)
+	var downloads []download
+	for i, source := range sources {
+		changedFiles, latestCursor, err := client.GetChangesFromCursor(cursors[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get changes from cursor for %s: %w", source.DropboxFolder, err)
+		}
+
+		if err := m.saveCursor(userID, source.DropboxFolder, latestCursor); err != nil {
+			log.Printf("Failed to save cursor for %s: %v", source.DropboxFolder, err)
+		}
+
+		for _, file := range changedFiles {
+			if localPath, ok := m.localPathFor(source, file.Path); ok {
+				downloads = append(downloads, download{client: client, userID: userID, file: file, localPath: localPath})
+			}
+		}
+	}
+	return downloads, nil
+}
+
+// listSources lists every source folder of a user's account, it returns the files that need a download,
+// every local path the account has and the cursors to save once the downloads are done
+func (m *Manager) listSources(userID uint) ([]download, map[string]bool, map[string]string, error) {
+	client := m.clientFor(userID)
+	expectedFiles := make(map[string]bool)
+	cursors := make(map[string]string)
+	var downloads []download
+
+	for _, source := range m.config.Sync.SyncSources() {
+		files, newCursor, err := client.ListFolder(source.DropboxFolder, true)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to list Dropbox folder %s: %w", source.DropboxFolder, err)
+		}
+		cursors[source.DropboxFolder] = newCursor
+
+		log.Printf("Found %d files in Dropbox folder %s for user %d", len(files), source.DropboxFolder, userID)
+
+		for _, file := range files {
+			localPath, ok := m.localPathFor(source, file.Path)
+			if !ok {
+				continue
+			}
+			expectedFiles[localPath] = true
+
+			if m.upToDateInDB(userID, file, localPath) {
+				continue
+			}
+			downloads = append(downloads, download{client: client, userID: userID, file: file, localPath: localPath})
+		}
+	}
+	return downloads, expectedFiles, cursors, nil
+}
+
+// saveCursors stores the cursors a listing of the user's account returned
+func (m *Manager) saveCursors(userID uint, cursors map[string]string) {
+	for source, cursor := range cursors {
+		if err := m.saveCursor(userID, source, cursor); err != nil {
+			log.Printf("Failed to save cursor for %s of user %d: %v", source, userID, err)
+			continue
+		}
+		log.Printf("Saved cursor for %s of user %d: %s", source, userID, cursor)
+	}
+}
+
+// firstAccountSync downloads a linked account that has no cursor yet and saves its cursors,
+// so its next webhook syncs incrementally, removing deleted files is left to full syncs
+func (m *Manager) firstAccountSync(userID uint) ([]ManifestEntry, error) {
+	downloads, _, cursors, err := m.listSources(userID)
+	if err != nil {
+		return nil, err
+	}
+	entries := m.downloadFiles(downloads)
+	m.saveCursors(userID, cursors)
+	return entries, nil
+}
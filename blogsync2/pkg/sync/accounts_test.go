@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+)
+
+func TestWebhookUsesNotifiedAccountCursor(t *testing.T) {
+	client := &stubClient{
+		content: map[string]string{"/blog/b.md": "from b"},
+		changes: map[string][]dropbox.FileInfo{
+			"cursor-b": {{Path: "/blog/b.md", Size: 6}},
+		},
+	}
+	m, base := newTestManager(t, client, 0)
+
+	for _, user := range []db.User{{ID: 1, AccountID: "dbid:account-a"}, {ID: 2, AccountID: "dbid:account-b"}} {
+		if err := m.db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.saveCursor(1, "/blog", "cursor-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.saveCursor(2, "/blog", "cursor-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	var clientUsers []uint
+	m.SetClientFactory(func(userID uint) DropboxClient {
+		clientUsers = append(clientUsers, userID)
+		return client
+	})
+
+	notification := &dropbox.WebhookNotification{}
+	if err := json.Unmarshal([]byte(`{"list_folder": {"accounts": ["dbid:account-b"]}}`), notification); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.incrementalSync(notification); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	if len(client.cursors) != 1 || client.cursors[0] != "cursor-b" {
+		t.Errorf("expected only account B's cursor to be used, got %v", client.cursors)
+	}
+	if len(clientUsers) != 1 || clientUsers[0] != 2 {
+		t.Errorf("expected account B's client, got clients for users %v", clientUsers)
+	}
+	if _, err := os.Stat(filepath.Join(base, "b.md")); err != nil {
+		t.Errorf("account B's change was not downloaded: %v", err)
+	}
+	if cursor, _ := m.loadCursor(1, "/blog"); cursor != "cursor-a" {
+		t.Errorf("account A's cursor changed to %q", cursor)
+	}
+}
+
+func TestWebhookSyncsNewAccountWithoutCursor(t *testing.T) {
+	primary := &stubClient{content: map[string]string{}}
+	m, base := newTestManager(t, primary, 0)
+
+	accountB := &stubClient{
+		files:   []dropbox.FileInfo{{Path: "/blog/b.md", Size: 6, ContentHash: "hash-b"}},
+		content: map[string]string{"/blog/b.md": "from b", "/blog/c.md": "from c"},
+		changes: map[string][]dropbox.FileInfo{
+			"cursor:/blog": {{Path: "/blog/c.md", Size: 6, ContentHash: "hash-c"}},
+		},
+	}
+	m.SetClientFactory(func(userID uint) DropboxClient {
+		return accountB
+	})
+
+	for _, user := range []db.User{{ID: 1, AccountID: "dbid:account-a"}, {ID: 2, AccountID: "dbid:account-b"}} {
+		if err := m.db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.saveCursor(1, "/blog", "cursor-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	notification := &dropbox.WebhookNotification{}
+	if err := json.Unmarshal([]byte(`{"list_folder": {"accounts": ["dbid:account-b"]}}`), notification); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.incrementalSync(notification); err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(base, "b.md")); err != nil {
+		t.Errorf("account B was not synced without a cursor: %v", err)
+	}
+	if cursor, err := m.loadCursor(2, "/blog"); err != nil || cursor != "cursor:/blog" {
+		t.Errorf("expected account B's cursor to be saved under user 2, got %q, %v", cursor, err)
+	}
+	var record db.File
+	if err := m.db.Where("local_path = ?", "b.md").First(&record).Error; err != nil || record.UserID != 2 {
+		t.Errorf("expected b.md to be recorded under user 2, got user %d, %v", record.UserID, err)
+	}
+
+	// the next webhook for account B continues from its own cursor
+	if err := m.incrementalSync(notification); err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if len(accountB.cursors) != 1 || accountB.cursors[0] != "cursor:/blog" {
+		t.Errorf("expected account B's saved cursor to be used, got %v", accountB.cursors)
+	}
+	if _, err := os.Stat(filepath.Join(base, "c.md")); err != nil {
+		t.Errorf("account B's change was not downloaded: %v", err)
+	}
+	if cursor, _ := m.loadCursor(1, "/blog"); cursor != "cursor-a" {
+		t.Errorf("account A's cursor changed to %q", cursor)
+	}
+}
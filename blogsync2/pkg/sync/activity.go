@@ -0,0 +1,22 @@
+package sync
+
+import (
+	"log"
+
+	"blogsync2/pkg/db"
+)
+
+// recordSync adds a sync run to the activity log, mode is full or incremental
+func (m *Manager) recordSync(mode string, filesAffected int, err error) {
+	entry := db.SyncLog{Type: db.LogFullSync, Outcome: "success", FilesAffected: filesAffected}
+	if mode == "incremental" {
+		entry.Type = db.LogIncrementalSync
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.Message = err.Error()
+	}
+	if err := m.db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record %s sync in the activity log: %v", mode, err)
+	}
+}
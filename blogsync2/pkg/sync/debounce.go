@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"log"
+	"time"
+)
+
+// scheduleBuild restarts the debounce timer, syncs finishing within the window share one build
+// and their reports are merged into a single post-sync notification
+func (m *Manager) scheduleBuild(report SyncReport) {
+	m.debounceMu.Lock()
+	defer m.debounceMu.Unlock()
+
+	if m.pendingBuild == nil {
+		m.pendingBuild = &report
+	} else {
+		pending := m.pendingBuild
+		if report.Mode == "full" {
+			pending.Mode = "full"
+		}
+		pending.Changed = append(pending.Changed, report.Changed...)
+		pending.Removed = append(pending.Removed, report.Removed...)
+		pending.Timings = report.Timings
+	}
+
+	if m.buildTimer != nil {
+		m.buildTimer.Stop()
+	}
+	m.buildTimer = time.AfterFunc(m.config.Build.Debounce, m.runDebouncedBuild)
+	log.Printf("Build scheduled in %s", m.config.Build.Debounce)
+}
+
+func (m *Manager) runDebouncedBuild() {
+	m.debounceMu.Lock()
+	report := m.pendingBuild
+	m.pendingBuild = nil
+	m.debounceMu.Unlock()
+
+	// a timer that fired while being replaced finds its report already built
+	if report == nil {
+		return
+	}
+	if err := m.buildAndNotify(*report); err != nil {
+		log.Printf("Debounced build failed: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"blogsync2/pkg/dropbox"
+)
+
+func TestDebouncedBuildRunsOnce(t *testing.T) {
+	client := &stubClient{content: map[string]string{}}
+	m, _ := newTestManager(t, client, 0)
+
+	buildDir := t.TempDir()
+	m.config.Build.Command = "echo build >> builds.txt"
+	m.config.Build.WorkingDirectory = buildDir
+	m.config.Build.Debounce = 100 * time.Millisecond
+
+	if err := m.saveCursor(PrimaryUserID, "/blog", "cursor:/blog"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		path := fmt.Sprintf("/blog/post-%d.md", i)
+		client.content[path] = "hello"
+		client.changes = map[string][]dropbox.FileInfo{"cursor:/blog": {{Path: path, Size: 5}}}
+
+		if err := m.incrementalSync(&dropbox.WebhookNotification{}); err != nil {
+			t.Fatalf("sync %d failed: %v", i, err)
+		}
+		if _, err := os.Stat(filepath.Join(buildDir, "builds.txt")); err == nil {
+			t.Fatalf("build ran before the debounce window closed, after sync %d", i)
+		}
+	}
+	if len(client.downloads) != 3 {
+		t.Errorf("each sync should download right away, got %d downloads", len(client.downloads))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var builds []byte
+	for time.Now().Before(deadline) {
+		var err error
+		if builds, err = os.ReadFile(filepath.Join(buildDir, "builds.txt")); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// give a stray second build the chance to show up
+	time.Sleep(3 * m.config.Build.Debounce)
+	builds, _ = os.ReadFile(filepath.Join(buildDir, "builds.txt"))
+
+	if got := strings.Count(string(builds), "build"); got != 1 {
+		t.Errorf("expected a single build, got %d", got)
+	}
+}
@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+)
+
+// stubClient serves files from memory and records how many downloads run at once
+type stubClient struct {
+	files   []dropbox.FileInfo
+	content map[string]string
+	fail    map[string]bool
+	changes map[string][]dropbox.FileInfo
+	cursors []string
+
+	mu          gosync.Mutex
+	inFlight    int
+	maxInFlight int
+	downloads   []string
+}
+
+// ListFolder lists the files under folderPath, the cursor names the folder
+func (s *stubClient) ListFolder(folderPath string, recursive bool) ([]dropbox.FileInfo, string, error) {
+	var files []dropbox.FileInfo
+	for _, file := range s.files {
+		if strings.HasPrefix(file.Path, strings.TrimSuffix(folderPath, "/")+"/") {
+			files = append(files, file)
+		}
+	}
+	return files, "cursor:" + folderPath, nil
+}
+
+func (s *stubClient) GetChangesFromCursor(cursor string) ([]dropbox.FileInfo, string, error) {
+	s.mu.Lock()
+	s.cursors = append(s.cursors, cursor)
+	s.mu.Unlock()
+	return s.changes[cursor], cursor, nil
+}
+
+func (s *stubClient) DownloadFile(dropboxPath, localPath string) error {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.downloads = append(s.downloads, dropboxPath)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if s.fail[dropboxPath] {
+		return fmt.Errorf("download of %s failed", dropboxPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(localPath, []byte(s.content[dropboxPath]), 0644)
+}
+
+// newTestManager returns a manager syncing /blog into a temp dir with no build command
+func newTestManager(t *testing.T, client DropboxClient, workers int) (*Manager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	database, err := db.Connect(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	cfg := &config.Config{Sync: config.SyncConfig{
+		LocalBasePath:   filepath.Join(dir, "sync"),
+		DropboxFolder:   "/blog",
+		DownloadWorkers: workers,
+	}}
+	return NewManager(cfg, client, database), cfg.Sync.LocalBasePath
+}
+
+func TestSyncFilesBoundedConcurrency(t *testing.T) {
+	client := &stubClient{content: map[string]string{}, fail: map[string]bool{"/blog/post-3.md": true}}
+	for i := 0; i < 12; i++ {
+		path := fmt.Sprintf("/blog/post-%d.md", i)
+		client.files = append(client.files, dropbox.FileInfo{Path: path, Size: 5})
+		client.content[path] = "hello"
+	}
+
+	m, base := newTestManager(t, client, 3)
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	if client.maxInFlight > 3 {
+		t.Errorf("%d downloads ran at once, want at most 3", client.maxInFlight)
+	}
+	if client.maxInFlight < 2 {
+		t.Errorf("downloads did not run concurrently, max in flight %d", client.maxInFlight)
+	}
+	if len(client.downloads) != 12 {
+		t.Errorf("attempted %d downloads, want 12", len(client.downloads))
+	}
+	for i := 0; i < 12; i++ {
+		_, err := os.Stat(filepath.Join(base, fmt.Sprintf("post-%d.md", i)))
+		if i == 3 {
+			if err == nil {
+				t.Errorf("failed download post-3.md was written")
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("post-%d.md was not downloaded after another file failed: %v", i, err)
+		}
+	}
+}
+
+func TestSyncFilesKeepsUpToDateFiles(t *testing.T) {
+	client := &stubClient{
+		files:   []dropbox.FileInfo{{Path: "/blog/a.md", Size: 5, ContentHash: "hash-a"}},
+		content: map[string]string{"/blog/a.md": "hello"},
+	}
+	m, base := newTestManager(t, client, 0)
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "a.md"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the database already knows the file at this hash, so it is skipped before any download
+	if err := m.db.Create(&db.File{UserID: 1, LocalPath: "a.md", ContentHash: "hash-a", Size: 5}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if len(client.downloads) != 0 {
+		t.Errorf("up to date file was downloaded again: %v", client.downloads)
+	}
+	if _, err := os.Stat(filepath.Join(base, "a.md")); err != nil {
+		t.Errorf("up to date file was removed: %v", err)
+	}
+}
@@ -9,12 +9,13 @@ import (
 	"os"
 )
 
+// FilesMatch reports whether the local file has the given Dropbox content hash
 func FilesMatch(localPath, dropboxHash string) (bool, error) {
 	if dropboxHash == "" {
 		return false, fmt.Errorf("no dropbox hash provided")
 	}
 
-	localHash, err := CalculateContentHash(localPath)
+	localHash, err := HashFile(localPath)
 	if err != nil {
 		return false, fmt.Errorf("failed to calculate local hash: %w", err)
 	}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// SyncReport is the payload sent to post-sync hooks
+// Changed are the Dropbox paths that were downloaded, Removed the local files that were deleted
+type SyncReport struct {
+	Status    string    `json:"status"`
+	Mode      string    `json:"mode"`
+	Changed   []string  `json:"changed"`
+	Removed   []string  `json:"removed"`
+	Timestamp time.Time `json:"timestamp"`
+	// Timings are the phases of the run, of the latest one when debounced builds were merged
+	Timings *SyncTimings `json:"timings,omitempty"`
+}
+
+var hookClient = &http.Client{Timeout: 10 * time.Second}
+
+// notifyPostSync POSTs the report to every configured post-sync hook
+// failures are only logged, the sync itself already succeeded
+func (m *Manager) notifyPostSync(report SyncReport) {
+	if len(m.config.Hooks.PostSync) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("Failed to encode post-sync report: %v", err)
+		return
+	}
+
+	for _, url := range m.config.Hooks.PostSync {
+		if err := postHook(url, body); err != nil {
+			log.Printf("Post-sync hook %s failed: %v", url, err)
+			continue
+		}
+		log.Printf("Notified post-sync hook %s", url)
+	}
+}
+
+func postHook(url string, body []byte) error {
+	resp, err := hookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blogsync2/pkg/config"
+)
+
+func TestNotifyPostSync(t *testing.T) {
+	received := make(chan SyncReport, 1)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("hook got content type %q", ct)
+		}
+		var report SyncReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("hook got an undecodable body: %v", err)
+		}
+		received <- report
+	}))
+	defer stub.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	// the failing hook comes first, it must not stop the others
+	m := &Manager{config: &config.Config{Hooks: config.HooksConfig{
+		PostSync: []string{failing.URL, stub.URL},
+	}}}
+	m.notifyPostSync(SyncReport{
+		Status:    "success",
+		Mode:      "incremental",
+		Changed:   []string{"/blog/content/post.md"},
+		Timestamp: time.Now(),
+	})
+
+	select {
+	case report := <-received:
+		if report.Status != "success" || report.Mode != "incremental" {
+			t.Errorf("unexpected report %+v", report)
+		}
+		if len(report.Changed) != 1 || report.Changed[0] != "/blog/content/post.md" {
+			t.Errorf("expected the changed file in the report, got %v", report.Changed)
+		}
+	default:
+		t.Fatal("hook was not notified")
+	}
+}
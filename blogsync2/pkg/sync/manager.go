@@ -3,13 +3,18 @@ package sync
 import (
 	"archive/zip"
 	"blogsync2/pkg/db"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	gosync "sync"
+	"time"
 
 	"blogsync2/pkg/config"
 	"blogsync2/pkg/dropbox"
@@ -17,10 +22,42 @@ import (
 	"gorm.io/gorm"
 )
 
+// DropboxClient is the part of the Dropbox API the manager uses, *dropbox.Client implements it
+type DropboxClient interface {
+	ListFolder(folderPath string, recursive bool) ([]dropbox.FileInfo, string, error)
+	GetChangesFromCursor(cursor string) ([]dropbox.FileInfo, string, error)
+	DownloadFile(dropboxPath, localPath string) error
+}
+
+// DefaultDownloadWorkers is how many downloads run at once when the config does not say
+const DefaultDownloadWorkers = 4
+
 type Manager struct {
 	config *config.Config
-	client *dropbox.Client
+	client DropboxClient
 	db     *gorm.DB
+
+	// buildMu keeps builds from overlapping
+	buildMu gosync.Mutex
+
+	// debounceMu guards the pending debounced build
+	debounceMu   gosync.Mutex
+	buildTimer   *time.Timer
+	pendingBuild *SyncReport
+
+	// forced holds the record paths a redownload was requested for
+	forceMu gosync.Mutex
+	forced  map[string]bool
+
+	// recordMu serializes database writes made from the download workers
+	recordMu gosync.Mutex
+
+	// timingsMu guards the phase timings of the runs
+	timingsMu   gosync.Mutex
+	lastTimings *SyncTimings
+
+	// clientFactory returns the client of another user's Dropbox account
+	clientFactory func(userID uint) DropboxClient
 }
 
 type EventType int
@@ -36,7 +73,7 @@ const (
 	ForceSync
 )
 
-func NewManager(cfg *config.Config, client *dropbox.Client, database *gorm.DB) *Manager {
+func NewManager(cfg *config.Config, client DropboxClient, database *gorm.DB) *Manager {
 	manager := &Manager{
 		config: cfg,
 		client: client,
@@ -51,10 +88,15 @@ func NewManager(cfg *config.Config, client *dropbox.Client, database *gorm.DB) *
 	return manager
 }
 
-func (m *Manager) Start(eventChan <-chan Event) {
+// Start runs the queued syncs one after another until the queue is closed
+func (m *Manager) Start(queue *Queue) {
 	log.Println("Starting sync manager loop")
 
-	for event := range eventChan {
+	for {
+		event, ok := queue.Receive()
+		if !ok {
+			return
+		}
 		switch event.Type {
 		case FilesChanged:
 			log.Println("File changed event received, starting incremental sync")
@@ -72,177 +114,282 @@ func (m *Manager) Start(eventChan <-chan Event) {
 	}
 }
 
-func (m *Manager) syncFiles() error {
+func (m *Manager) syncFiles() (err error) {
 	log.Println("Starting file synchronization")
 
+	var entries []ManifestEntry
+	timings := newSyncTimings("full")
+	defer func() {
+		m.finishTimings(timings)
+		m.recordSync("full", len(entries), err)
+	}()
+
 	basePath := m.config.Sync.LocalBasePath
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return fmt.Errorf("failed to create local base directory: %w", err)
 	}
 
-	files, newCursor, err := m.client.ListFolder(m.config.Sync.DropboxFolder, true)
-	if err != nil {
-		return fmt.Errorf("failed to list Dropbox folder: %w", err)
-	}
+	// Build a set of all files that should exist locally, across every linked account
+	expectedFiles := make(map[string]bool)
+	var downloads []download
+	cursors := make(map[uint]map[string]string)
 
-	log.Printf("Found %d files in Dropbox", len(files))
+	phase := time.Now()
+	for _, userID := range m.syncedUsers() {
+		userDownloads, userFiles, userCursors, err := m.listSources(userID)
+		if err != nil {
+			if userID == PrimaryUserID {
+				return err
+			}
+			log.Printf("Skipping user %d in full sync: %v", userID, err)
+			continue
+		}
+		downloads = append(downloads, userDownloads...)
+		for localPath := range userFiles {
+			expectedFiles[localPath] = true
+		}
+		cursors[userID] = userCursors
+	}
 
-	// Build a set of all files that should exist locally
-	expectedFiles := make(map[string]bool)
+	m.endPhase(&timings.ListMS, phase)
 
 	// Download/update files from Dropbox
-	for _, file := range files {
-		localPathRef := strings.TrimPrefix(file.Path, "/")
+	phase = time.Now()
+	entries = m.downloadFiles(downloads)
+	m.endPhase(&timings.DownloadMS, phase)
 
-		needsDownload := true
+	for userID, userCursors := range cursors {
+		m.saveCursors(userID, userCursors)
+	}
 
-		// check if it exists in db
-		f := db.File{
-			UserID:    1,
-			LocalPath: localPathRef,
-		}
+	// Remove local files that no longer exist in Dropbox
+	phase = time.Now()
+	removed, err := m.removeDeletedFiles(basePath, expectedFiles)
+	if err != nil {
+		log.Printf("Failed to remove deleted files: %v", err)
+	}
+	m.endPhase(&timings.RemoveDeletedMS, phase)
+	entries = append(entries, removed...)
 
-		tx := m.db.Where("user_id = ? AND local_path = ?", f.UserID, f.LocalPath).First(&f)
-		if err := tx.Error; err != nil && err != gorm.ErrRecordNotFound {
-			log.Printf("Failed to query file %s from database: %v", localPathRef, err)
-		} else {
-			if tx.RowsAffected > 0 {
-				dbHash := f.ContentHash
-				// if db hash is not up to date, compute it
-				if dbHash == "" {
-					localPath := filepath.Join(m.config.Sync.LocalBasePath, localPathRef)
-					// check if file exists
-					if _, err := os.Stat(localPath); err == nil {
-						computedHash, err := HashFile(localPath)
-						if err != nil {
-							log.Printf("Failed to compute hash for %s: %v", localPathRef, err)
-						} else {
-							dbHash = computedHash
-							f.ContentHash = dbHash
-							if err := m.db.Save(&f).Error; err != nil {
-								log.Printf("Failed to update hash for %s in database: %v", localPathRef, err)
-							} else {
-								log.Printf("Updated hash for %s in database", localPathRef)
-							}
-						}
-					}
-				}
+	if err := m.saveManifest("full", entries); err != nil {
+		log.Printf("Failed to save sync manifest: %v", err)
+	}
 
-				if dbHash != "" && file.ContentHash != "" && dbHash == file.ContentHash {
-					needsDownload = false
-					log.Printf("File %s already up to date (hash match from DB)", file.Path)
-				}
-			}
-		}
+	log.Println("File synchronization completed")
 
-		if !needsDownload {
-			continue
-		}
+	err = m.finishSync(SyncReport{
+		Status:  "success",
+		Mode:    "full",
+		Changed: entryPaths(entries, ActionAdded, ActionUpdated),
+		Removed: entryPaths(entries, ActionRemoved),
+		Timings: timings,
+	})
+	if err != nil {
+		return err
+	}
 
-		relativePath := strings.TrimPrefix(file.Path, m.config.Sync.DropboxFolder)
-		relativePath = strings.TrimPrefix(relativePath, "/")
+	log.Println("Full sync process completed successfully")
+	return nil
+}
 
-		localPath := filepath.Join(basePath, relativePath)
-		expectedFiles[localPath] = true
+// upToDateInDB reports whether the database records the file with the content hash Dropbox has,
+// a missing hash is computed from the local copy and stored
+func (m *Manager) upToDateInDB(userID uint, file dropbox.FileInfo, localPath string) bool {
+	localPathRef := m.recordPath(localPath)
+	if m.isForced(localPathRef) {
+		return false
+	}
 
-		log.Printf("Syncing file: %s -> %s", file.Path, localPath)
+	// check if it exists in db
+	f := db.File{
+		UserID:    userID,
+		LocalPath: localPathRef,
+	}
 
-		if err := m.syncSingleFile(&file, localPath); err != nil {
-			log.Printf("Failed to sync file %s: %v", file.Path, err)
-			continue
-		}
+	tx := m.db.Where("user_id = ? AND local_path = ?", f.UserID, f.LocalPath).First(&f)
+	if err := tx.Error; err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("Failed to query file %s from database: %v", localPathRef, err)
+		return false
+	}
+	if tx.RowsAffected == 0 {
+		return false
 	}
 
-	if err := m.saveCursor(newCursor); err != nil {
-		log.Printf("Failed to save cursor: %v", err)
-	} else {
-		//m.lastCursor = newCursor
-		err := m.saveCursor(newCursor)
-		if err != nil {
-			log.Printf("Failed to save cursor: %v", err)
+	dbHash := f.ContentHash
+	// if db hash is not up to date, compute it
+	if dbHash == "" {
+		// check if file exists
+		if _, err := os.Stat(localPath); err == nil {
+			computedHash, err := HashFile(localPath)
+			if err != nil {
+				log.Printf("Failed to compute hash for %s: %v", localPathRef, err)
+			} else {
+				dbHash = computedHash
+				f.ContentHash = dbHash
+				if err := m.db.Save(&f).Error; err != nil {
+					log.Printf("Failed to update hash for %s in database: %v", localPathRef, err)
+				} else {
+					log.Printf("Updated hash for %s in database", localPathRef)
+				}
+			}
 		}
-		log.Printf("Saved cursor: %s", newCursor)
 	}
 
-	// Remove local files that no longer exist in Dropbox
-	if err := m.removeDeletedFiles(basePath, expectedFiles); err != nil {
-		log.Printf("Failed to remove deleted files: %v", err)
+	if dbHash != "" && file.ContentHash != "" && dbHash == file.ContentHash {
+		log.Printf("File %s already up to date (hash match from DB)", file.Path)
+		return true
 	}
+	return false
+}
 
-	log.Println("File synchronization completed")
+// download is a file queued for the download workers, client is the one of the account it belongs to
+type download struct {
+	client    DropboxClient
+	userID    uint
+	file      dropbox.FileInfo
+	localPath string
+}
 
-	if err := m.runBuildCommand(); err != nil {
-		log.Printf("Build command failed: %v", err)
-		return err
+// downloadFiles syncs the files on a bounded pool of workers and returns what was downloaded,
+// a file that fails is logged and skipped without stopping the others
+func (m *Manager) downloadFiles(downloads []download) []ManifestEntry {
+	workers := m.config.Sync.DownloadWorkers
+	if workers <= 0 {
+		workers = DefaultDownloadWorkers
+	}
+
+	queue := make(chan download)
+	var (
+		mu      gosync.Mutex
+		wg      gosync.WaitGroup
+		entries []ManifestEntry
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range queue {
+				log.Printf("Syncing file: %s -> %s", d.file.Path, d.localPath)
+
+				existed := fileExists(d.localPath)
+				downloaded, err := m.syncSingleFile(d.client, d.userID, &d.file, d.localPath)
+				if err != nil {
+					log.Printf("Failed to sync file %s: %v", d.file.Path, err)
+					continue
+				}
+				if downloaded {
+					m.applyTransforms(d.userID, d.localPath)
+					mu.Lock()
+					entries = append(entries, downloadEntry(d.file, existed))
+					mu.Unlock()
+				}
+			}
+		}()
 	}
 
-	if err := m.applyCopyRules(); err != nil {
-		log.Printf("Copy rules failed: %v", err)
-		return err
+	for _, d := range downloads {
+		queue <- d
 	}
+	close(queue)
+	wg.Wait()
 
-	log.Println("Full sync process completed successfully")
-	return nil
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
 }
 
-func (m *Manager) incrementalSync(data any) error {
-	//dropbox.WebhookNotification{}
-	notificationData, ok := data.(*dropbox.WebhookNotification)
+func (m *Manager) incrementalSync(data any) (err error) {
+	var entries []ManifestEntry
+	fellBack := false
+	timings := newSyncTimings("incremental")
+	defer func() {
+		// a fallback full sync records itself
+		if !fellBack {
+			m.finishTimings(timings)
+			m.recordSync("incremental", len(entries), err)
+		}
+	}()
+
+	notification, ok := data.(*dropbox.WebhookNotification)
 	if !ok {
 		return fmt.Errorf("invalid data for incremental sync")
 	}
-	_ = notificationData
-
-	//if m.lastCursor == "" {
-	//	log.Println("No cursor available, falling back to full sync")
-	//	return m.syncFiles()
-	//}
-
-	cursor, err := m.loadCursor()
-	if err != nil {
-		log.Println("No cursor available, falling back to full sync")
-		return m.syncFiles()
-	}
-
-	log.Println("Starting incremental sync from cursor")
 
-	changedFiles, latestCursor, err := m.client.GetChangesFromCursor(cursor)
-	if err != nil {
-		return fmt.Errorf("failed to get changes from cursor: %w", err)
+	phase := time.Now()
+	var downloads []download
+	for _, userID := range m.notifiedUsers(notification) {
+		userDownloads, err := m.collectChanges(userID)
+		if errors.Is(err, errNoCursor) {
+			if userID == PrimaryUserID {
+				log.Println("No cursor available, falling back to full sync")
+				fellBack = true
+				return m.syncFiles()
+			}
+			log.Printf("No cursor available for user %d, syncing the whole account", userID)
+			firstEntries, err := m.firstAccountSync(userID)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, firstEntries...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		downloads = append(downloads, userDownloads...)
 	}
 
-	m.saveCursor(latestCursor)
-
-	if len(changedFiles) == 0 {
+	if len(downloads) == 0 && len(entries) == 0 {
 		log.Println("No files changed since last sync")
 		return nil
 	}
 
-	log.Printf("Found %d changed files", len(changedFiles))
-
-	basePath := m.config.Sync.LocalBasePath
+	m.endPhase(&timings.ListMS, phase)
+	log.Printf("Found %d changed files", len(downloads))
 
-	for _, file := range changedFiles {
-		relativePath := strings.TrimPrefix(file.Path, m.config.Sync.DropboxFolder)
-		relativePath = strings.TrimPrefix(relativePath, "/")
-
-		localPath := filepath.Join(basePath, relativePath)
+	phase = time.Now()
+	for _, d := range downloads {
+		file, localPath := d.file, d.localPath
 
 		log.Printf("Syncing changed file: %s -> %s", file.Path, localPath)
 
-		if err := m.syncSingleFile(&file, localPath); err != nil {
+		// a zip sync may have fetched this content already
+		if m.upToDateInDB(d.userID, file, localPath) {
+			continue
+		}
+
+		existed := fileExists(localPath)
+		downloaded, err := m.syncSingleFile(d.client, d.userID, &file, localPath)
+		if err != nil {
 			log.Printf("Failed to sync changed file %s: %v", file.Path, err)
 			continue
 		}
+		if downloaded {
+			m.applyTransforms(d.userID, localPath)
+			entries = append(entries, downloadEntry(file, existed))
+		}
 	}
 
-	if err := m.runBuildCommand(); err != nil {
-		log.Printf("Build command failed: %v", err)
-		return err
+	m.endPhase(&timings.DownloadMS, phase)
+
+	if err := m.saveManifest("incremental", entries); err != nil {
+		log.Printf("Failed to save sync manifest: %v", err)
 	}
 
-	if err := m.applyCopyRules(); err != nil {
-		log.Printf("Copy rules failed: %v", err)
+	// files Dropbox reported whose local copy already had the same content hash were not downloaded
+	if len(entries) == 0 {
+		log.Println("No file content changed, skipping build")
+		return nil
+	}
+
+	err = m.finishSync(SyncReport{
+		Status:  "success",
+		Mode:    "incremental",
+		Changed: entryPaths(entries, ActionAdded, ActionUpdated),
+		Timings: timings,
+	})
+	if err != nil {
 		return err
 	}
 
@@ -250,21 +397,28 @@ func (m *Manager) incrementalSync(data any) error {
 	return nil
 }
 
-func (m *Manager) syncSingleFile(fileInfo *dropbox.FileInfo, localPath string) error {
+// syncSingleFile downloads the file unless the local copy is already up to date, reporting whether it did
+// the file is recorded under userID, the account it was downloaded from
+func (m *Manager) syncSingleFile(client DropboxClient, userID uint, fileInfo *dropbox.FileInfo, localPath string) (bool, error) {
+	forced := m.isForced(m.recordPath(localPath))
+	if forced {
+		log.Printf("Redownload requested for %s", fileInfo.Path)
+	}
+
 	// Check if file already exists and is up to date
-	if _, err := os.Stat(localPath); err == nil {
+	if _, err := os.Stat(localPath); err == nil && !forced {
 		if fileInfo.ContentHash != "" {
 			match, err := FilesMatch(localPath, fileInfo.ContentHash)
 			if err == nil && match {
 				log.Printf("File %s already up to date (hash match)", fileInfo.Path)
-				return nil
+				return false, nil
 			}
 			if err != nil {
 				log.Printf("Failed to check content hash for %s: %v, falling back to size check", fileInfo.Path, err)
 				if stat, statErr := os.Stat(localPath); statErr == nil {
 					if uint64(stat.Size()) == fileInfo.Size {
 						log.Printf("File %s size matches, assuming up to date", fileInfo.Path)
-						return nil
+						return false, nil
 					}
 				}
 			} else {
@@ -274,15 +428,21 @@ func (m *Manager) syncSingleFile(fileInfo *dropbox.FileInfo, localPath string) e
 			if stat, statErr := os.Stat(localPath); statErr == nil {
 				if uint64(stat.Size()) == fileInfo.Size {
 					log.Printf("File %s size matches and no hash available, assuming up to date", fileInfo.Path)
-					return nil
+					return false, nil
 				}
 			}
 		}
 	}
 
 	log.Printf("Downloading file: %s", fileInfo.Path)
-	if err := m.client.DownloadFile(fileInfo.Path, localPath); err != nil {
-		return fmt.Errorf("failed to download file: %w", err)
+	if err := client.DownloadFile(fileInfo.Path, localPath); err != nil {
+		return false, fmt.Errorf("failed to download file: %w", err)
+	}
+	if forced {
+		m.clearForced(m.recordPath(localPath))
+	}
+	if fileInfo.ContentHash != "" {
+		m.recordDropboxHash(userID, *fileInfo, localPath)
 	}
 
 	//f := db.File{
@@ -294,6 +454,47 @@ func (m *Manager) syncSingleFile(fileInfo *dropbox.FileInfo, localPath string) e
 	//}
 
 	log.Printf("Downloaded: %s", fileInfo.Path)
+	return true, nil
+}
+
+// finishSync builds the site, applies the copy rules and notifies the post-sync hooks,
+// with a build debounce configured this is only scheduled and errors are logged when it runs,
+// nothing happens when no change matches the build trigger
+func (m *Manager) finishSync(report SyncReport) error {
+	if !m.triggersBuild(report) {
+		return nil
+	}
+	if m.config.Build.Debounce > 0 {
+		m.scheduleBuild(report)
+		return nil
+	}
+	return m.buildAndNotify(report)
+}
+
+func (m *Manager) buildAndNotify(report SyncReport) error {
+	m.buildMu.Lock()
+	defer m.buildMu.Unlock()
+
+	phase := time.Now()
+	if err := m.runBuildCommand(); err != nil {
+		log.Printf("Build command failed: %v", err)
+		return err
+	}
+	if report.Timings != nil {
+		m.endPhase(&report.Timings.BuildMS, phase)
+	}
+
+	phase = time.Now()
+	if err := m.applyCopyRules(); err != nil {
+		log.Printf("Copy rules failed: %v", err)
+		return err
+	}
+	if report.Timings != nil {
+		m.endPhase(&report.Timings.CopyRulesMS, phase)
+	}
+
+	report.Timestamp = time.Now()
+	m.notifyPostSync(report)
 	return nil
 }
 
@@ -333,6 +534,7 @@ func (m *Manager) runBuildCommand() error {
 
 	cmd := exec.Command("sh", "-c", m.config.Build.Command)
 	cmd.Dir = workingDir
+	cmd.Env = buildEnv(os.Environ(), m.config.Build.Env)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -345,6 +547,27 @@ func (m *Manager) runBuildCommand() error {
 	return nil
 }
 
+// buildEnv returns the parent environment with the configured variables added, overriding any of the same name
+func buildEnv(parent []string, extra map[string]string) []string {
+	env := make([]string, 0, len(parent)+len(extra))
+	for _, kv := range parent {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, overridden := extra[name]; !overridden {
+			env = append(env, kv)
+		}
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+extra[name])
+	}
+	return env
+}
+
 func (m *Manager) applyCopyRules() error {
 	if len(m.config.CopyRules) == 0 {
 		return nil
@@ -444,7 +667,8 @@ func copyFile(src, dst string) error {
 	return err
 }
 
-func (m *Manager) removeDeletedFiles(basePath string, expectedFiles map[string]bool) error {
+// removeDeletedFiles removes local files that are not expected and returns what was removed
+func (m *Manager) removeDeletedFiles(basePath string, expectedFiles map[string]bool) ([]ManifestEntry, error) {
 	log.Println("Checking for deleted files to remove")
 
 	var filesToRemove []string
@@ -462,17 +686,22 @@ func (m *Manager) removeDeletedFiles(basePath string, expectedFiles map[string]b
 	})
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	removedCount := 0
+	var removed []ManifestEntry
 	for _, localFile := range filesToRemove {
-		if !expectedFiles[localFile] {
+		// keep partial downloads of files still in Dropbox and their revisions so they can be resumed
+		expected := expectedFiles[localFile] ||
+			expectedFiles[strings.TrimSuffix(localFile, dropbox.PartSuffix)] ||
+			expectedFiles[strings.TrimSuffix(localFile, dropbox.PartRevSuffix)]
+		if !expected {
 			log.Printf("Removing deleted file: %s", localFile)
+			entry := removedEntry(localFile)
 			if err := os.Remove(localFile); err != nil {
 				log.Printf("Failed to remove file %s: %v", localFile, err)
 			} else {
-				removedCount++
+				removed = append(removed, entry)
 			}
 		}
 	}
@@ -480,11 +709,11 @@ func (m *Manager) removeDeletedFiles(basePath string, expectedFiles map[string]b
 	// Remove empty directories
 	m.removeEmptyDirectories(basePath)
 
-	if removedCount > 0 {
-		log.Printf("Removed %d deleted files", removedCount)
+	if len(removed) > 0 {
+		log.Printf("Removed %d deleted files", len(removed))
 	}
 
-	return nil
+	return removed, nil
 }
 
 func (m *Manager) removeEmptyDirectories(basePath string) {
@@ -515,26 +744,56 @@ func (m *Manager) cursorFilePath() string {
 	return filepath.Join(m.config.Sync.LocalBasePath, ".blogsync_cursor")
 }
 
-func (m *Manager) loadCursor() (string, error) {
+// localPathFor maps a Dropbox path inside the source folder to its local path, false if the source ignores it
+func (m *Manager) localPathFor(source config.SyncSource, dropboxPath string) (string, bool) {
+	relativePath := strings.TrimPrefix(dropboxPath, source.DropboxFolder)
+	relativePath = strings.TrimPrefix(relativePath, "/")
+
+	if ignored(source.Ignore, relativePath) {
+		log.Printf("Ignoring %s", dropboxPath)
+		return "", false
+	}
+	return filepath.Join(m.config.Sync.LocalBasePath, source.LocalDir, relativePath), true
+}
+
+// ignored reports whether any pattern matches a segment of relativePath or the path up to a segment
+func ignored(patterns []string, relativePath string) bool {
+	segments := strings.Split(relativePath, "/")
+	for _, pattern := range patterns {
+		for i, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, strings.Join(segments[:i+1], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadCursor returns the cursor saved for a user's source folder
+func (m *Manager) loadCursor(userID uint, source string) (string, error) {
 	//data, err := os.ReadFile(m.cursorFilePath())
 	//if err != nil {
 	//	return "", err
 	//}
 	//return string(data), nil
 	var cursor db.SyncCursor
-	if err := m.db.First(&cursor, "user_id = ?", 1).Error; err != nil {
+	if err := m.db.First(&cursor, "user_id = ? AND source = ?", userID, source).Error; err != nil {
 		return "", err
 	}
 	return cursor.Cursor, nil
 }
 
-func (m *Manager) saveCursor(cursor string) error {
+func (m *Manager) saveCursor(userID uint, source, cursor string) error {
 	//return os.WriteFile(m.cursorFilePath(), []byte(cursor), 0644)
 	var syncCursor db.SyncCursor
-	if err := m.db.First(&syncCursor, "user_id = ?", 1).Error; err != nil {
+	if err := m.db.First(&syncCursor, "user_id = ? AND source = ?", userID, source).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			syncCursor = db.SyncCursor{
-				UserID: 1,
+				UserID: userID,
+				Source: source,
 				Cursor: cursor,
 			}
 			if err := m.db.Create(&syncCursor).Error; err != nil {
@@ -564,11 +823,13 @@ func (m *Manager) ExtractZip(zipPath, extractTo string) (int, error) {
 	extractedCount := 0
 
 	for _, file := range reader.File {
-		path := filepath.Join(extractTo, file.Name)
-
-		// Ensure the path is within the extract directory
-		if !strings.HasPrefix(path, filepath.Clean(extractTo)+string(os.PathSeparator)) {
-			return extractedCount, fmt.Errorf("invalid file path: %s", file.Name)
+		name, ok := m.zipRelativePath(file.Name)
+		if !ok {
+			continue
+		}
+		path, err := zipEntryPath(extractTo, name)
+		if err != nil {
+			return extractedCount, err
 		}
 
 		if file.FileInfo().IsDir() {
@@ -592,49 +853,90 @@ func (m *Manager) ExtractZip(zipPath, extractTo string) (int, error) {
 		}
 		defer targetFile.Close()
 
-		_, err = io.Copy(targetFile, fileReader)
+		hasher := NewDropboxContentHasher()
+		_, err = io.Copy(io.MultiWriter(targetFile, hasher), fileReader)
 		if err != nil {
 			return extractedCount, err
 		}
 
-		// persist to db
-		f := db.File{
-			UserID:    1,
-			LocalPath: file.Name,
-			Size:      uint64(file.FileInfo().Size()),
-		}
-
 		extractedCount++
+		m.recordExtractedFile(PrimaryUserID, path, uint64(file.FileInfo().Size()), hasher.SumHex())
+	}
+
+	return extractedCount, nil
+}
 
-		// get record with user id and localpath
-		existingFile := &db.File{}
-		tx := m.db.Where("user_id = ? AND local_path = ?", f.UserID, f.LocalPath).First(existingFile)
-		if err := tx.Error; err != nil {
-			if err != gorm.ErrRecordNotFound {
-				log.Printf("Failed to query file %s from database: %v", path, err)
+// zipRelativePath strips the folder an archive of the synced Dropbox folder is rooted at,
+// so its files land where a regular sync puts them, false means the entry is that folder itself
+func (m *Manager) zipRelativePath(name string) (string, bool) {
+	folder := strings.Trim(m.config.Sync.DropboxFolder, "/")
+	if folder == "" {
+		return name, true
+	}
+	root := path.Base(folder) + "/"
+	if name == root {
+		return "", false
+	}
+	return strings.TrimPrefix(name, root), true
+}
+
+// recordPath is how a local file is keyed in the database, its slash separated path relative to
+// the local base path, every sync mode records files this way
+func (m *Manager) recordPath(localPath string) string {
+	relativePath, err := filepath.Rel(m.config.Sync.LocalBasePath, localPath)
+	if err != nil {
+		return filepath.ToSlash(localPath)
+	}
+	return filepath.ToSlash(relativePath)
+}
+
+// zipEntryPath is where an archive entry is extracted to, entries escaping extractTo are rejected
+func zipEntryPath(extractTo, name string) (string, error) {
+	path := filepath.Join(extractTo, name)
+
+	// Ensure the path is within the extract directory
+	if !strings.HasPrefix(path, filepath.Clean(extractTo)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid file path: %s", name)
+	}
+	return path, nil
+}
+
+// recordExtractedFile creates or updates the database record of a file extracted from a zip,
+// contentHash is its Dropbox content hash so the next sync can skip it when unchanged
+func (m *Manager) recordExtractedFile(userID uint, path string, size uint64, contentHash string) {
+	// persist to db
+	f := db.File{
+		UserID:      userID,
+		LocalPath:   m.recordPath(path),
+		Size:        size,
+		ContentHash: contentHash,
+	}
+
+	// get record with user id and localpath
+	existingFile := &db.File{}
+	tx := m.db.Where("user_id = ? AND local_path = ?", f.UserID, f.LocalPath).First(existingFile)
+	if err := tx.Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Failed to query file %s from database: %v", path, err)
+		} else {
+			// create
+			log.Printf("File %s not found in database, will create new record", path)
+			if err := m.db.Create(&f).Error; err != nil {
+				log.Printf("Failed to create file %s in database: %v", path, err)
 			} else {
-				// create
-				log.Printf("File %s not found in database, will create new record", path)
-				if err := m.db.Create(&f).Error; err != nil {
-					log.Printf("Failed to create file %s in database: %v", path, err)
-				} else {
-					log.Printf("Created file %s in database", path)
-				}
-				continue
+				log.Printf("Created file %s in database", path)
 			}
+			return
 		}
-		if tx.RowsAffected > 0 {
-			log.Printf("File %s already exists in database, will update size if changed", path)
-			existingFile.Size = f.Size
-			if err := m.db.Save(existingFile).Error; err != nil {
-				log.Printf("Failed to update file %s in database: %v", path, err)
-			} else {
-				log.Printf("Updated file %s in database", path)
-			}
-			continue
+	}
+	if tx.RowsAffected > 0 {
+		log.Printf("File %s already exists in database, will update size and hash if changed", path)
+		existingFile.Size = f.Size
+		existingFile.ContentHash = f.ContentHash
+		if err := m.db.Save(existingFile).Error; err != nil {
+			log.Printf("Failed to update file %s in database: %v", path, err)
+		} else {
+			log.Printf("Updated file %s in database", path)
 		}
-
 	}
-
-	return extractedCount, nil
 }
@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/dropbox"
+)
+
+func TestRunBuildCommandEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BLOGSYNC_PARENT", "inherited")
+
+	m := &Manager{config: &config.Config{Build: config.BuildConfig{
+		Command:          `echo "$SITE_ENV $BLOGSYNC_PARENT" > env.txt`,
+		WorkingDirectory: dir,
+		Env:              map[string]string{"SITE_ENV": "staging"},
+	}}}
+	if err := m.runBuildCommand(); err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	out, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+	if err != nil {
+		t.Fatalf("build did not write its output: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "staging inherited" {
+		t.Errorf("build saw %q, want %q", got, "staging inherited")
+	}
+}
+
+func TestUnchangedFileSkipsBuild(t *testing.T) {
+	client := &stubClient{content: map[string]string{"/blog/post.md": "hello"}}
+	m, base := newTestManager(t, client, 0)
+
+	buildDir := t.TempDir()
+	m.config.Build.Command = "echo build >> builds.txt"
+	m.config.Build.WorkingDirectory = buildDir
+
+	localPath := filepath.Join(base, "post.md")
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashFile(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dropbox notifies about the file again without its content changing
+	client.changes = map[string][]dropbox.FileInfo{
+		"cursor:/blog": {{Path: "/blog/post.md", Size: 5, ContentHash: hash}},
+	}
+	if err := m.saveCursor(PrimaryUserID, "/blog", "cursor:/blog"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.incrementalSync(&dropbox.WebhookNotification{}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	if len(client.downloads) != 0 {
+		t.Errorf("unchanged file was downloaded: %v", client.downloads)
+	}
+	if _, err := os.Stat(filepath.Join(buildDir, "builds.txt")); err == nil {
+		t.Error("build ran although no file content changed")
+	}
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ActionAdded   = "added"
+	ActionUpdated = "updated"
+	ActionRemoved = "removed"
+)
+
+// ManifestEntry is a file a sync touched, downloads are listed by Dropbox path and removals by local path
+type ManifestEntry struct {
+	Path        string `json:"path"`
+	Action      string `json:"action"`
+	Size        uint64 `json:"size"`
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// Manifest lists what the last sync changed
+type Manifest struct {
+	Mode      string          `json:"mode"`
+	Timestamp time.Time       `json:"timestamp"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+func downloadEntry(file dropbox.FileInfo, existed bool) ManifestEntry {
+	action := ActionAdded
+	if existed {
+		action = ActionUpdated
+	}
+	return ManifestEntry{Path: file.Path, Action: action, Size: file.Size, ContentHash: file.ContentHash}
+}
+
+// removedEntry describes a local file about to be removed, read before it is gone
+func removedEntry(localPath string) ManifestEntry {
+	entry := ManifestEntry{Path: localPath, Action: ActionRemoved}
+	if stat, err := os.Stat(localPath); err == nil {
+		entry.Size = uint64(stat.Size())
+	}
+	if hash, err := HashFile(localPath); err == nil {
+		entry.ContentHash = hash
+	} else {
+		log.Printf("Failed to hash removed file %s: %v", localPath, err)
+	}
+	return entry
+}
+
+// entryPaths returns the paths of the entries with any of the actions
+func entryPaths(entries []ManifestEntry, actions ...string) []string {
+	var paths []string
+	for _, entry := range entries {
+		for _, action := range actions {
+			if entry.Action == action {
+				paths = append(paths, entry.Path)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// saveManifest replaces the stored manifest with the one for this sync
+func (m *Manager) saveManifest(mode string, entries []ManifestEntry) error {
+	if entries == nil {
+		entries = []ManifestEntry{}
+	}
+	data, err := json.Marshal(Manifest{Mode: mode, Timestamp: time.Now(), Files: entries})
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	var row db.SyncManifest
+	if err := m.db.First(&row, "user_id = ?", 1).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to load manifest: %w", err)
+	}
+	row.UserID = 1
+	row.Manifest = string(data)
+	if err := m.db.Save(&row).Error; err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+	return nil
+}
+
+// LastManifest returns the manifest of the most recent sync, nil if there has not been one
+func (m *Manager) LastManifest() (*Manifest, error) {
+	var row db.SyncManifest
+	if err := m.db.First(&row, "user_id = ?", 1).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal([]byte(row.Manifest), &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
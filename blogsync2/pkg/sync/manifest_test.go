@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"blogsync2/pkg/dropbox"
+)
+
+func TestSyncManifestRecordsChanges(t *testing.T) {
+	client := &stubClient{
+		files:   []dropbox.FileInfo{{Path: "/blog/new.md", Size: 5, ContentHash: "hash-new"}},
+		content: map[string]string{"/blog/new.md": "hello"},
+	}
+	m, base := newTestManager(t, client, 0)
+
+	if manifest, err := m.LastManifest(); err != nil || manifest != nil {
+		t.Fatalf("expected no manifest before the first sync, got %v, %v", manifest, err)
+	}
+
+	stale := filepath.Join(base, "old.md")
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("gone"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	manifest, err := m.LastManifest()
+	if err != nil || manifest == nil {
+		t.Fatalf("expected a manifest after the sync, got %v, %v", manifest, err)
+	}
+	if manifest.Mode != "full" || manifest.Timestamp.IsZero() {
+		t.Errorf("unexpected manifest header %q at %v", manifest.Mode, manifest.Timestamp)
+	}
+	if len(manifest.Files) != 2 {
+		t.Fatalf("expected 2 manifest entries, got %+v", manifest.Files)
+	}
+
+	added := manifest.Files[0]
+	if added.Path != "/blog/new.md" || added.Action != ActionAdded || added.Size != 5 || added.ContentHash != "hash-new" {
+		t.Errorf("unexpected download entry %+v", added)
+	}
+	removed := manifest.Files[1]
+	if removed.Path != stale || removed.Action != ActionRemoved || removed.Size != 4 || removed.ContentHash == "" {
+		t.Errorf("unexpected removal entry %+v", removed)
+	}
+}
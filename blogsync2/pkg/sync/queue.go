@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"slices"
+	gosync "sync"
+
+	"blogsync2/pkg/dropbox"
+)
+
+const (
+	// DefaultQueueSize is how many sync events wait for the manager when queue_size is not set
+	DefaultQueueSize = 100
+
+	// OverflowCoalesce merges an event into a waiting one of the same type, the default
+	OverflowCoalesce = "coalesce"
+	// OverflowDrop queues every event and rejects new ones while the queue is full
+	OverflowDrop = "drop"
+)
+
+// Queue hands sync events to the manager, with the coalesce policy a webhook arriving while
+// another one is waiting is merged into it instead of queued, so a burst runs one sync
+type Queue struct {
+	mu       gosync.Mutex
+	events   []Event
+	size     int
+	coalesce bool
+	closed   bool
+	ready    chan struct{}
+}
+
+// NewQueue returns a queue holding up to size events, 0 uses DefaultQueueSize,
+// overflow is OverflowCoalesce or OverflowDrop, empty means coalesce
+func NewQueue(size int, overflow string) *Queue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	return &Queue{
+		size:     size,
+		coalesce: overflow != OverflowDrop,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// Send queues an event, it reports false when the queue is full or closed and the event was dropped
+func (q *Queue) Send(event Event) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+	if q.coalesce && q.merge(event) {
+		return true
+	}
+	if len(q.events) >= q.size {
+		return false
+	}
+	q.events = append(q.events, event)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// merge folds event into a waiting event of the same type, webhook notifications keep every account
+func (q *Queue) merge(event Event) bool {
+	for i := range q.events {
+		waiting := &q.events[i]
+		if waiting.Type != event.Type {
+			continue
+		}
+		switch event.Type {
+		case ForceSync:
+			return true
+		case FilesChanged:
+			waiting.Data = mergeNotifications(waiting.Data, event.Data)
+			return true
+		}
+	}
+	return false
+}
+
+// mergeNotifications combines the accounts of two webhook notifications
+func mergeNotifications(waiting, incoming any) any {
+	into, ok := waiting.(*dropbox.WebhookNotification)
+	if !ok || into == nil {
+		return incoming
+	}
+	from, ok := incoming.(*dropbox.WebhookNotification)
+	if !ok || from == nil || from.ListFolder == nil {
+		return into
+	}
+	if into.ListFolder == nil {
+		into.ListFolder = from.ListFolder
+		return into
+	}
+	for _, account := range from.ListFolder.Accounts {
+		if !slices.Contains(into.ListFolder.Accounts, account) {
+			into.ListFolder.Accounts = append(into.ListFolder.Accounts, account)
+		}
+	}
+	return into
+}
+
+// Receive waits for the next event, it returns false once the queue is closed and empty
+func (q *Queue) Receive() (Event, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.events) > 0 {
+			event := q.events[0]
+			q.events = q.events[1:]
+			if len(q.events) > 0 {
+				select {
+				case q.ready <- struct{}{}:
+				default:
+				}
+			}
+			q.mu.Unlock()
+			return event, true
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return Event{}, false
+		}
+		q.mu.Unlock()
+		<-q.ready
+	}
+}
+
+// Pending is the number of events waiting
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.events)
+}
+
+// Close stops the queue, Receive drains the waiting events before it reports the close
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"blogsync2/pkg/dropbox"
+)
+
+func notification(t *testing.T, account string) *dropbox.WebhookNotification {
+	t.Helper()
+	n := &dropbox.WebhookNotification{}
+	if err := json.Unmarshal([]byte(fmt.Sprintf(`{"list_folder": {"accounts": [%q]}}`, account)), n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestQueueCoalescesWebhooks(t *testing.T) {
+	q := NewQueue(2, "")
+	for i := 0; i < 50; i++ {
+		if !q.Send(Event{Type: FilesChanged, Data: notification(t, fmt.Sprintf("dbid:%d", i%3))}) {
+			t.Fatalf("event %d was dropped", i)
+		}
+	}
+	if !q.Send(Event{Type: ForceSync}) || !q.Send(Event{Type: ForceSync}) {
+		t.Fatal("a manual sync was dropped")
+	}
+
+	if q.Pending() != 2 {
+		t.Fatalf("expected one pending incremental and one full sync, got %d", q.Pending())
+	}
+	q.Close()
+
+	event, ok := q.Receive()
+	if !ok || event.Type != FilesChanged {
+		t.Fatalf("expected the incremental sync first, got %+v", event)
+	}
+	accounts := event.Data.(*dropbox.WebhookNotification).ListFolder.Accounts
+	sort.Strings(accounts)
+	if fmt.Sprint(accounts) != "[dbid:0 dbid:1 dbid:2]" {
+		t.Errorf("expected every notified account to be kept, got %v", accounts)
+	}
+	if event, ok := q.Receive(); !ok || event.Type != ForceSync {
+		t.Errorf("expected the full sync next, got %+v", event)
+	}
+	if _, ok := q.Receive(); ok {
+		t.Error("expected the closed queue to be empty")
+	}
+}
+
+func TestQueueDropPolicy(t *testing.T) {
+	q := NewQueue(2, OverflowDrop)
+	sent := 0
+	for i := 0; i < 5; i++ {
+		if q.Send(Event{Type: FilesChanged, Data: notification(t, "dbid:a")}) {
+			sent++
+		}
+	}
+	if sent != 2 || q.Pending() != 2 {
+		t.Errorf("expected the queue to fill up and drop the rest, sent %d with %d pending", sent, q.Pending())
+	}
+}
@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"blogsync2/pkg/db"
+)
+
+// ForceRedownload clears the stored hash of the recorded files whose path matches glob, every file when glob
+// is empty, so the next sync downloads them even when the local copy looks up to date, it returns the count
+func (m *Manager) ForceRedownload(glob string) (int, error) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return 0, fmt.Errorf("invalid path pattern %q: %w", glob, err)
+	}
+
+	var files []db.File
+	if err := m.db.Find(&files).Error; err != nil {
+		return 0, fmt.Errorf("failed to load files: %w", err)
+	}
+
+	m.forceMu.Lock()
+	defer m.forceMu.Unlock()
+	if m.forced == nil {
+		m.forced = make(map[string]bool)
+	}
+
+	count := 0
+	for _, f := range files {
+		relativePath := strings.TrimPrefix(f.LocalPath, "/")
+		if glob != "" {
+			if ok, _ := path.Match(glob, relativePath); !ok {
+				continue
+			}
+		}
+		if err := m.db.Model(&db.File{}).Where("id = ?", f.ID).Update("content_hash", "").Error; err != nil {
+			return count, fmt.Errorf("failed to clear hash of %s: %w", f.LocalPath, err)
+		}
+		m.forced[forcedKey(relativePath)] = true
+		count++
+	}
+
+	log.Printf("Cleared hashes of %d files for redownload", count)
+	return count, nil
+}
+
+// isForced reports whether a redownload was requested for the file recorded at recordPath
+func (m *Manager) isForced(recordPath string) bool {
+	m.forceMu.Lock()
+	defer m.forceMu.Unlock()
+	return m.forced[forcedKey(recordPath)]
+}
+
+func (m *Manager) clearForced(recordPath string) {
+	m.forceMu.Lock()
+	defer m.forceMu.Unlock()
+	delete(m.forced, forcedKey(recordPath))
+}
+
+// forcedKey folds case since Dropbox paths are case insensitive
+func forcedKey(recordPath string) string {
+	return strings.ToLower(recordPath)
+}
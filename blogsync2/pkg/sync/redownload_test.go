@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+)
+
+func TestForceRedownloadClearsHash(t *testing.T) {
+	client := &stubClient{content: map[string]string{"/blog/post.md": "fresh"}}
+	m, base := newTestManager(t, client, 0)
+
+	// a corrupted local copy that still matches by size
+	localPath := filepath.Join(base, "post.md")
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, []byte("xxxxx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []db.File{
+		{UserID: 1, LocalPath: "post.md", ContentHash: "stale", Size: 5},
+		{UserID: 1, LocalPath: "other/page.md", ContentHash: "kept", Size: 5},
+	} {
+		if err := m.db.Create(&f).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := dropbox.FileInfo{Path: "/blog/post.md", Size: 5}
+	if downloaded, err := m.syncSingleFile(client, PrimaryUserID, &file, localPath); err != nil || downloaded {
+		t.Fatalf("expected the size match to skip the download, got %v, %v", downloaded, err)
+	}
+
+	cleared, err := m.ForceRedownload("*.md")
+	if err != nil || cleared != 1 {
+		t.Fatalf("expected one cleared file, got %d, %v", cleared, err)
+	}
+	var rows []db.File
+	m.db.Order("local_path").Find(&rows)
+	if rows[0].ContentHash != "kept" || rows[1].ContentHash != "" {
+		t.Errorf("expected only post.md to lose its hash, got %q and %q", rows[0].ContentHash, rows[1].ContentHash)
+	}
+
+	downloaded, err := m.syncSingleFile(client, PrimaryUserID, &file, localPath)
+	if err != nil || !downloaded {
+		t.Fatalf("expected a forced redownload, got %v, %v", downloaded, err)
+	}
+	if got, _ := os.ReadFile(localPath); string(got) != "fresh" {
+		t.Errorf("local copy was not replaced, got %q", got)
+	}
+
+	// the request is used up once the file is downloaded
+	if downloaded, _ := m.syncSingleFile(client, PrimaryUserID, &file, localPath); downloaded {
+		t.Error("file was downloaded again after the forced redownload")
+	}
+}
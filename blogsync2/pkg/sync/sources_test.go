@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/dropbox"
+)
+
+func TestSyncMultipleSources(t *testing.T) {
+	client := &stubClient{
+		files: []dropbox.FileInfo{
+			{Path: "/blog/post.md", Size: 4},
+			{Path: "/assets/logo.png", Size: 4},
+			{Path: "/assets/logo.psd", Size: 4},
+			{Path: "/elsewhere/skip.md", Size: 4},
+		},
+		content: map[string]string{
+			"/blog/post.md":    "post",
+			"/assets/logo.png": "logo",
+			"/assets/logo.psd": "layr",
+			"/assets/new.png":  "newp",
+		},
+	}
+	m, base := newTestManager(t, client, 0)
+	m.config.Sync.Sources = []config.SyncSource{
+		{DropboxFolder: "/blog", LocalDir: "content"},
+		{DropboxFolder: "/assets", LocalDir: "static", Ignore: []string{"*.psd"}},
+	}
+
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	for _, want := range []string{"content/post.md", "static/logo.png"} {
+		if _, err := os.Stat(filepath.Join(base, want)); err != nil {
+			t.Errorf("expected %s to be synced: %v", want, err)
+		}
+	}
+	for _, unwanted := range []string{"static/logo.psd", "skip.md", "elsewhere/skip.md"} {
+		if _, err := os.Stat(filepath.Join(base, unwanted)); err == nil {
+			t.Errorf("%s should not be synced", unwanted)
+		}
+	}
+
+	for _, folder := range []string{"/blog", "/assets"} {
+		cursor, err := m.loadCursor(PrimaryUserID, folder)
+		if err != nil || cursor != "cursor:"+folder {
+			t.Errorf("expected the cursor of %s to be saved, got %q, %v", folder, cursor, err)
+		}
+	}
+
+	// a change seen through the assets cursor lands in the assets directory
+	client.changes = map[string][]dropbox.FileInfo{
+		"cursor:/assets": {{Path: "/assets/new.png", Size: 4}},
+	}
+	if err := m.incrementalSync(&dropbox.WebhookNotification{}); err != nil {
+		t.Fatalf("incremental sync failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "static", "new.png")); err != nil {
+		t.Errorf("expected the changed asset in the static directory: %v", err)
+	}
+}
+
+func TestIgnoredPatterns(t *testing.T) {
+	patterns := []string{"*.psd", "drafts", "notes/*.txt"}
+	cases := map[string]bool{
+		"logo.psd":           true,
+		"img/logo.psd":       true,
+		"drafts/post.md":     true,
+		"posts/drafts/a.md":  true,
+		"notes/todo.txt":     true,
+		"notes/deep/todo.md": false,
+		"posts/post.md":      false,
+	}
+	for relativePath, want := range cases {
+		if got := ignored(patterns, relativePath); got != want {
+			t.Errorf("ignored(%q) = %v, want %v", relativePath, got, want)
+		}
+	}
+}
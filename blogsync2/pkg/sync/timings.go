@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"log"
+	"time"
+)
+
+// SyncTimings is how long each phase of a sync run took, in milliseconds,
+// a phase that did not run is 0, incremental syncs list changes and remove nothing
+type SyncTimings struct {
+	Mode            string    `json:"mode"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	ListMS          float64   `json:"list_ms"`
+	DownloadMS      float64   `json:"download_ms"`
+	RemoveDeletedMS float64   `json:"remove_deleted_ms"`
+	BuildMS         float64   `json:"build_ms"`
+	CopyRulesMS     float64   `json:"copy_rules_ms"`
+	TotalMS         float64   `json:"total_ms"`
+}
+
+func newSyncTimings(mode string) *SyncTimings {
+	return &SyncTimings{Mode: mode, StartedAt: time.Now()}
+}
+
+// endPhase stores the time since start in one of the phase fields of a run's timings,
+// a debounced build ends its phases after the run was reported
+func (m *Manager) endPhase(phase *float64, start time.Time) {
+	m.timingsMu.Lock()
+	defer m.timingsMu.Unlock()
+	*phase = float64(time.Since(start)) / float64(time.Millisecond)
+}
+
+// finishTimings logs the phases of a finished run and keeps them as the last run's timings
+func (m *Manager) finishTimings(timings *SyncTimings) {
+	m.timingsMu.Lock()
+	defer m.timingsMu.Unlock()
+
+	timings.FinishedAt = time.Now()
+	timings.TotalMS = float64(timings.FinishedAt.Sub(timings.StartedAt)) / float64(time.Millisecond)
+	m.lastTimings = timings
+
+	log.Printf("Sync timings (%s): list=%.1fms download=%.1fms remove_deleted=%.1fms build=%.1fms copy_rules=%.1fms total=%.1fms",
+		timings.Mode, timings.ListMS, timings.DownloadMS, timings.RemoveDeletedMS, timings.BuildMS, timings.CopyRulesMS, timings.TotalMS)
+}
+
+// LastTimings returns a copy of the phase timings of the most recent sync run, nil before the first
+func (m *Manager) LastTimings() *SyncTimings {
+	m.timingsMu.Lock()
+	defer m.timingsMu.Unlock()
+	if m.lastTimings == nil {
+		return nil
+	}
+	timings := *m.lastTimings
+	return &timings
+}
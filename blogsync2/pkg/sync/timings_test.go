@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"testing"
+
+	"blogsync2/pkg/dropbox"
+)
+
+func TestSyncRecordsPhaseTimings(t *testing.T) {
+	client := &stubClient{
+		files:   []dropbox.FileInfo{{Path: "/blog/post.md", Size: 5}},
+		content: map[string]string{"/blog/post.md": "hello"},
+	}
+	m, _ := newTestManager(t, client, 0)
+	m.config.Build.Command = "true"
+	m.config.Build.WorkingDirectory = t.TempDir()
+
+	if m.LastTimings() != nil {
+		t.Fatal("expected no timings before the first run")
+	}
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	timings := m.LastTimings()
+	if timings == nil {
+		t.Fatal("expected timings after a run")
+	}
+	if timings.Mode != "full" || timings.StartedAt.IsZero() || timings.FinishedAt.Before(timings.StartedAt) {
+		t.Errorf("unexpected run details %+v", timings)
+	}
+	for name, value := range map[string]float64{
+		"list":           timings.ListMS,
+		"download":       timings.DownloadMS,
+		"remove_deleted": timings.RemoveDeletedMS,
+		"build":          timings.BuildMS,
+		"copy_rules":     timings.CopyRulesMS,
+		"total":          timings.TotalMS,
+	} {
+		if value <= 0 {
+			t.Errorf("expected the %s phase to be timed, got %v", name, value)
+		}
+	}
+	// the stub takes 10ms per download
+	if timings.DownloadMS < 10 || timings.TotalMS < timings.DownloadMS+timings.BuildMS {
+		t.Errorf("phase timings do not add up: %+v", timings)
+	}
+}
@@ -0,0 +1,169 @@
+package sync
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+
+	"gorm.io/gorm"
+)
+
+// applyTransforms runs the configured transforms matching a downloaded file, failures are logged
+// the Dropbox hash recorded at download keeps the rewritten file from being downloaded again as changed,
+// the hash of the rewritten file is recorded next to it for verify
+func (m *Manager) applyTransforms(userID uint, localPath string) {
+	if len(m.config.Transforms) == 0 {
+		return
+	}
+
+	relativePath, err := filepath.Rel(m.config.Sync.LocalBasePath, localPath)
+	if err != nil {
+		return
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	var matching []config.Transform
+	for _, transform := range m.config.Transforms {
+		if transformMatches(transform.Path, relativePath) {
+			matching = append(matching, transform)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		log.Printf("Failed to read %s for transforms: %v", localPath, err)
+		return
+	}
+
+	content := string(data)
+	for _, transform := range matching {
+		transformed, err := applyTransform(transform, content)
+		if err != nil {
+			log.Printf("Transform for %s failed on %s: %v", transform.Path, relativePath, err)
+			continue
+		}
+		content = transformed
+	}
+	if content == string(data) {
+		return
+	}
+
+	if err := os.WriteFile(localPath, []byte(content), 0644); err != nil {
+		log.Printf("Failed to write transformed %s: %v", localPath, err)
+		return
+	}
+	m.recordLocalHash(userID, localPath, HashBytes([]byte(content)))
+	log.Printf("Transformed %s", relativePath)
+}
+
+// recordLocalHash stores the hash of a local copy a transform rewrote
+func (m *Manager) recordLocalHash(userID uint, localPath, hash string) {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+
+	err := m.db.Model(&db.File{}).
+		Where("user_id = ? AND local_path = ?", userID, m.recordPath(localPath)).
+		Update("local_hash", hash).Error
+	if err != nil {
+		log.Printf("Failed to record local hash of %s: %v", localPath, err)
+	}
+}
+
+func transformMatches(pattern, relativePath string) bool {
+	if !strings.Contains(pattern, "/") {
+		relativePath = path.Base(relativePath)
+	}
+	ok, _ := path.Match(pattern, relativePath)
+	return ok
+}
+
+func applyTransform(transform config.Transform, content string) (string, error) {
+	if transform.Find != "" {
+		re, err := regexp.Compile(transform.Find)
+		if err != nil {
+			return "", fmt.Errorf("invalid find pattern: %w", err)
+		}
+		content = re.ReplaceAllString(content, transform.Replace)
+	}
+	if len(transform.Frontmatter) > 0 {
+		content = injectFrontmatter(content, transform.Frontmatter)
+	}
+	return content, nil
+}
+
+// injectFrontmatter adds the keys a document's YAML frontmatter does not set yet,
+// a document without frontmatter gets one
+func injectFrontmatter(content string, values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	frontmatter, body, hasFrontmatter := "", content, false
+	if strings.HasPrefix(content, "---\n") {
+		if end := strings.Index(content[4:], "\n---"); end >= 0 {
+			frontmatter = content[4 : 4+end+1]
+			body = content[4+end+1:]
+			hasFrontmatter = true
+		}
+	}
+
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(frontmatter, "\n") {
+		if key, _, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, " ") {
+			existing[strings.TrimSpace(key)] = true
+		}
+	}
+
+	var added strings.Builder
+	for _, key := range keys {
+		if !existing[key] {
+			fmt.Fprintf(&added, "%s: %s\n", key, values[key])
+		}
+	}
+	if added.Len() == 0 {
+		return content
+	}
+
+	if hasFrontmatter {
+		return "---\n" + frontmatter + added.String() + body
+	}
+	return "---\n" + added.String() + "---\n" + body
+}
+
+// recordDropboxHash stores the Dropbox hash of a file under the user it was downloaded for,
+// so later syncs of that account treat the local copy as current
+func (m *Manager) recordDropboxHash(userID uint, file dropbox.FileInfo, localPath string) {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+
+	f := db.File{UserID: userID, LocalPath: m.recordPath(localPath)}
+	err := m.db.Where("user_id = ? AND local_path = ?", f.UserID, f.LocalPath).First(&f).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("Failed to look up %s: %v", f.LocalPath, err)
+		return
+	}
+	f.RemotePath = file.Path
+	f.FileID = file.ID
+	f.ContentHash = file.ContentHash
+	// a fresh download is the Dropbox content until a transform rewrites it
+	f.LocalHash = ""
+	f.Size = file.Size
+	f.ModifiedAt = file.Modified
+	if err := m.db.Save(&f).Error; err != nil {
+		log.Printf("Failed to record hash of %s: %v", f.LocalPath, err)
+	}
+}
@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/dropbox"
+)
+
+func TestTransformsRewriteSyncedFiles(t *testing.T) {
+	client := &stubClient{
+		files: []dropbox.FileInfo{
+			{Path: "/blog/post.md", Size: 31, ContentHash: "hash-post"},
+			{Path: "/blog/notes.txt", Size: 13},
+		},
+		content: map[string]string{
+			"/blog/post.md":   "![cat](../images/cat.png) text",
+			"/blog/notes.txt": "../images/x y",
+		},
+	}
+	m, base := newTestManager(t, client, 0)
+	m.config.Transforms = []config.Transform{
+		{Path: "*.md", Find: `\.\./images/(\w+)`, Replace: "/img/$1"},
+		{Path: "*.md", Frontmatter: map[string]string{"draft": "false"}},
+	}
+
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(base, "post.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\ndraft: false\n---\n![cat](/img/cat.png) text"; string(got) != want {
+		t.Errorf("transformed post is %q, want %q", got, want)
+	}
+	if got, _ := os.ReadFile(filepath.Join(base, "notes.txt")); string(got) != "../images/x y" {
+		t.Errorf("file outside the glob was changed: %q", got)
+	}
+
+	// the rewritten post is not downloaded again on the next sync
+	client.downloads = nil
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	for _, path := range client.downloads {
+		if path == "/blog/post.md" {
+			t.Error("transformed post was downloaded again")
+		}
+	}
+}
+
+func TestInjectFrontmatterKeepsExistingKeys(t *testing.T) {
+	content := "---\ntitle: Hello\ndraft: true\n---\nbody"
+	got := injectFrontmatter(content, map[string]string{"draft": "false", "layout": "post"})
+	if want := "---\ntitle: Hello\ndraft: true\nlayout: post\n---\nbody"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"log"
+	"path"
+	"strings"
+)
+
+// triggersBuild reports whether a sync changed a file matching one of the build trigger patterns,
+// every change does when none are configured
+func (m *Manager) triggersBuild(report SyncReport) bool {
+	patterns := m.config.Build.Trigger
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, changes := range [][]string{report.Changed, report.Removed} {
+		for _, changed := range changes {
+			for _, pattern := range patterns {
+				if matchesChange(pattern, changed) {
+					return true
+				}
+			}
+		}
+	}
+	log.Printf("No change matches the build trigger %v, skipping build", patterns)
+	return false
+}
+
+// matchesChange matches pattern against the end of a changed path, so "*.md" and "posts/*.md"
+// match wherever the synced folder is, a "**" segment matches any number of directories
+func matchesChange(pattern, changed string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	segments := strings.Split(strings.Trim(changed, "/"), "/")
+	for start := range segments {
+		if matchSegments(patternSegments, segments[start:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(segments); skip++ {
+			if matchSegments(pattern[1:], segments[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
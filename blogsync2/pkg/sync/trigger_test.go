@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blogsync2/pkg/dropbox"
+)
+
+func TestBuildTriggerSkipsImageOnlySync(t *testing.T) {
+	client := &stubClient{
+		files: []dropbox.FileInfo{{Path: "/blog/img/photo.jpg", Size: 3}},
+		content: map[string]string{
+			"/blog/img/photo.jpg": "jpg",
+			"/blog/posts/new.md":  "# new",
+		},
+	}
+	m, _ := newTestManager(t, client, 0)
+	buildDir := t.TempDir()
+	m.config.Build.Command = "echo build >> builds.txt"
+	m.config.Build.WorkingDirectory = buildDir
+	m.config.Build.Trigger = []string{"**/*.md"}
+
+	builds := func() int {
+		out, err := os.ReadFile(filepath.Join(buildDir, "builds.txt"))
+		if os.IsNotExist(err) {
+			return 0
+		}
+		return strings.Count(string(out), "build")
+	}
+
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if n := builds(); n != 0 {
+		t.Errorf("expected an image-only sync not to build, built %d times", n)
+	}
+
+	client.files = append(client.files, dropbox.FileInfo{Path: "/blog/posts/new.md", Size: 5})
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if n := builds(); n != 1 {
+		t.Errorf("expected a markdown change to build once, built %d times", n)
+	}
+}
+
+func TestMatchesChange(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, changed string
+		want             bool
+	}{
+		{"**/*.md", "/blog/posts/a.md", true},
+		{"**/*.md", "/blog/a.md", true},
+		{"*.md", "/blog/posts/a.md", true},
+		{"posts/*.md", "/blog/posts/a.md", true},
+		{"posts/*.md", "/blog/drafts/a.md", false},
+		{"content/**/index.md", "/site/content/a/b/index.md", true},
+		{"**/*.md", "/blog/img/photo.jpg", false},
+	} {
+		if got := matchesChange(tc.pattern, tc.changed); got != tc.want {
+			t.Errorf("matchesChange(%q, %q) = %v, want %v", tc.pattern, tc.changed, got, tc.want)
+		}
+	}
+}
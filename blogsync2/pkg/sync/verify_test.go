@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"blogsync2/pkg/config"
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+)
+
+func TestVerifyFilesReportsMismatch(t *testing.T) {
+	m, base := newTestManager(t, &stubClient{}, 0)
+
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"good.md": "intact", "tampered.md": "edited locally"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(base, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range []db.File{
+		{UserID: 1, LocalPath: "good.md", ContentHash: HashBytes([]byte("intact"))},
+		{UserID: 1, LocalPath: "tampered.md", ContentHash: HashBytes([]byte("as synced"))},
+		{UserID: 1, LocalPath: "gone.md", ContentHash: HashBytes([]byte("deleted"))},
+		{UserID: 1, LocalPath: "unhashed.md"},
+	} {
+		if err := m.db.Create(&f).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := m.VerifyFiles()
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if report.Checked != 3 || report.Skipped != 1 {
+		t.Errorf("expected 3 checked and 1 skipped, got %d and %d", report.Checked, report.Skipped)
+	}
+	if len(report.Problems) != 2 {
+		t.Fatalf("expected 2 problems, got %+v", report.Problems)
+	}
+	if p := report.Problems[0]; p.Path != "gone.md" || !p.Missing {
+		t.Errorf("expected gone.md reported missing, got %+v", p)
+	}
+	if p := report.Problems[1]; p.Path != "tampered.md" || p.ActualHash != HashBytes([]byte("edited locally")) {
+		t.Errorf("expected tampered.md reported with its local hash, got %+v", p)
+	}
+}
+
+func TestVerifyFilesAcceptsTransformedFiles(t *testing.T) {
+	client := &stubClient{
+		files:   []dropbox.FileInfo{{Path: "/blog/post.md", Size: 25, ContentHash: HashBytes([]byte("![cat](../images/cat.png)"))}},
+		content: map[string]string{"/blog/post.md": "![cat](../images/cat.png)"},
+	}
+	m, base := newTestManager(t, client, 0)
+	m.config.Transforms = []config.Transform{{Path: "*.md", Find: `\.\./images/`, Replace: "/img/"}}
+
+	if err := m.syncFiles(); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	report, err := m.VerifyFiles()
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if report.Checked != 1 || len(report.Problems) != 0 {
+		t.Errorf("expected the transformed post to verify, got %+v", report)
+	}
+
+	// an edit after the transform is still reported
+	if err := os.WriteFile(filepath.Join(base, "post.md"), []byte("edited locally"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err = m.VerifyFiles()
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].ExpectedHash != HashBytes([]byte("![cat](/img/cat.png)")) {
+		t.Errorf("expected the local edit to be reported against the transformed hash, got %+v", report.Problems)
+	}
+}
@@ -0,0 +1,265 @@
+package sync
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	zipLocalHeaderSignature   = 0x04034b50
+	zipCentralHeaderSignature = 0x02014b50
+	zipEndSignature           = 0x06054b50
+	zipDescriptorSignature    = 0x08074b50
+	zip64ExtraID              = 0x0001
+
+	zipFlagEncrypted  = 0x1
+	zipFlagDescriptor = 0x8
+)
+
+// ErrNotStreamable is returned by ExtractZipStream for an entry that can only be read
+// through the central directory, a stored entry with a data descriptor or an encrypted one,
+// the archive has to be extracted from a file with ExtractZip instead
+var ErrNotStreamable = errors.New("zip entry cannot be extracted while streaming")
+
+// zipLocalHeader is the local file header in front of each entry's data
+type zipLocalHeader struct {
+	flags            uint16
+	method           uint16
+	crc32            uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+	name             string
+	zip64            bool
+}
+
+// ExtractZipStream extracts an archive as it is read, entry by entry from the local headers,
+// so a download never has to be written to disk and read back, archives are of the primary account,
+// it stops at the central directory and returns the number of files extracted
+func (m *Manager) ExtractZipStream(r io.Reader, extractTo string) (int, error) {
+	if err := os.MkdirAll(extractTo, 0755); err != nil {
+		return 0, err
+	}
+
+	// a bufio.Reader is an io.ByteReader, so flate stops exactly at the end of each entry
+	br := bufio.NewReader(r)
+	extractedCount := 0
+
+	for {
+		var signature uint32
+		if err := binary.Read(br, binary.LittleEndian, &signature); err != nil {
+			if err == io.EOF && extractedCount == 0 {
+				return 0, fmt.Errorf("empty zip archive")
+			}
+			return extractedCount, fmt.Errorf("failed to read zip header: %w", err)
+		}
+		switch signature {
+		case zipLocalHeaderSignature:
+		case zipCentralHeaderSignature, zipEndSignature:
+			return extractedCount, nil
+		default:
+			return extractedCount, fmt.Errorf("invalid zip header signature %#x", signature)
+		}
+
+		header, err := readZipLocalHeader(br)
+		if err != nil {
+			return extractedCount, err
+		}
+		if header.flags&zipFlagEncrypted != 0 {
+			return extractedCount, fmt.Errorf("%s: %w", header.name, ErrNotStreamable)
+		}
+
+		name, ok := m.zipRelativePath(header.name)
+		if !ok {
+			if err := skipZipEntry(br, header); err != nil {
+				return extractedCount, err
+			}
+			continue
+		}
+		path, err := zipEntryPath(extractTo, name)
+		if err != nil {
+			return extractedCount, err
+		}
+
+		if strings.HasSuffix(header.name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return extractedCount, err
+			}
+			if err := skipZipEntry(br, header); err != nil {
+				return extractedCount, err
+			}
+			continue
+		}
+
+		size, contentHash, err := extractZipEntry(br, header, path)
+		if err != nil {
+			return extractedCount, fmt.Errorf("failed to extract %s: %w", header.name, err)
+		}
+
+		extractedCount++
+		m.recordExtractedFile(PrimaryUserID, path, size, contentHash)
+	}
+}
+
+// readZipLocalHeader reads the rest of a local file header after its signature
+func readZipLocalHeader(br *bufio.Reader) (zipLocalHeader, error) {
+	var raw struct {
+		Version          uint16
+		Flags            uint16
+		Method           uint16
+		ModTime          uint16
+		ModDate          uint16
+		CRC32            uint32
+		CompressedSize   uint32
+		UncompressedSize uint32
+		NameLength       uint16
+		ExtraLength      uint16
+	}
+	if err := binary.Read(br, binary.LittleEndian, &raw); err != nil {
+		return zipLocalHeader{}, fmt.Errorf("failed to read zip header: %w", err)
+	}
+
+	name := make([]byte, raw.NameLength)
+	if _, err := io.ReadFull(br, name); err != nil {
+		return zipLocalHeader{}, fmt.Errorf("failed to read zip entry name: %w", err)
+	}
+	extra := make([]byte, raw.ExtraLength)
+	if _, err := io.ReadFull(br, extra); err != nil {
+		return zipLocalHeader{}, fmt.Errorf("failed to read zip extra field: %w", err)
+	}
+
+	header := zipLocalHeader{
+		flags:            raw.Flags,
+		method:           raw.Method,
+		crc32:            raw.CRC32,
+		compressedSize:   uint64(raw.CompressedSize),
+		uncompressedSize: uint64(raw.UncompressedSize),
+		name:             string(name),
+	}
+
+	// the zip64 extra field carries the sizes that do not fit the header, in that order
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+size > len(extra) {
+			break
+		}
+		field := extra[4 : 4+size]
+		if id == zip64ExtraID {
+			header.zip64 = true
+			if raw.UncompressedSize == 0xffffffff && len(field) >= 8 {
+				header.uncompressedSize = binary.LittleEndian.Uint64(field)
+				field = field[8:]
+			}
+			if raw.CompressedSize == 0xffffffff && len(field) >= 8 {
+				header.compressedSize = binary.LittleEndian.Uint64(field)
+			}
+		}
+		extra = extra[4+size:]
+	}
+
+	return header, nil
+}
+
+// entryReader returns the uncompressed data of an entry, and a function to call once it is
+// fully read that consumes the rest of the entry and returns its expected CRC-32
+func entryReader(br *bufio.Reader, header zipLocalHeader) (io.Reader, func() (uint32, error), error) {
+	if header.flags&zipFlagDescriptor == 0 {
+		limited := io.LimitReader(br, int64(header.compressedSize))
+		finish := func() (uint32, error) {
+			_, err := io.Copy(io.Discard, limited)
+			return header.crc32, err
+		}
+		switch header.method {
+		case 0:
+			return limited, finish, nil
+		case 8:
+			return flate.NewReader(limited), finish, nil
+		}
+		return nil, nil, fmt.Errorf("unsupported compression method %d", header.method)
+	}
+
+	// without sizes in the header only a deflate stream shows where the entry ends
+	if header.method != 8 {
+		return nil, nil, ErrNotStreamable
+	}
+	finish := func() (uint32, error) {
+		return readZipDescriptor(br, header.zip64)
+	}
+	return flate.NewReader(br), finish, nil
+}
+
+// readZipDescriptor reads the data descriptor following an entry and returns its CRC-32
+func readZipDescriptor(br *bufio.Reader, zip64 bool) (uint32, error) {
+	var first uint32
+	if err := binary.Read(br, binary.LittleEndian, &first); err != nil {
+		return 0, fmt.Errorf("failed to read data descriptor: %w", err)
+	}
+	// the descriptor signature is optional
+	crc := first
+	if first == zipDescriptorSignature {
+		if err := binary.Read(br, binary.LittleEndian, &crc); err != nil {
+			return 0, fmt.Errorf("failed to read data descriptor: %w", err)
+		}
+	}
+	sizes := 8
+	if zip64 {
+		sizes = 16
+	}
+	if _, err := br.Discard(sizes); err != nil {
+		return 0, fmt.Errorf("failed to read data descriptor: %w", err)
+	}
+	return crc, nil
+}
+
+// extractZipEntry writes an entry to path, checking its CRC-32, and returns its size and Dropbox content hash
+func extractZipEntry(br *bufio.Reader, header zipLocalHeader, path string) (uint64, string, error) {
+	data, finish, err := entryReader(br, header)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, "", err
+	}
+	targetFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, "", err
+	}
+	defer targetFile.Close()
+
+	checksum := crc32.NewIEEE()
+	hasher := NewDropboxContentHasher()
+	size, err := io.Copy(io.MultiWriter(targetFile, checksum, hasher), data)
+	if err != nil {
+		return 0, "", err
+	}
+	expected, err := finish()
+	if err != nil {
+		return 0, "", err
+	}
+	if checksum.Sum32() != expected {
+		return 0, "", fmt.Errorf("checksum mismatch")
+	}
+	return uint64(size), hasher.SumHex(), targetFile.Close()
+}
+
+// skipZipEntry consumes the data of an entry that is not extracted
+func skipZipEntry(br *bufio.Reader, header zipLocalHeader) error {
+	data, finish, err := entryReader(br, header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, data); err != nil {
+		return err
+	}
+	_, err = finish()
+	return err
+}
@@ -0,0 +1,210 @@
+package sync
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blogsync2/pkg/db"
+	"blogsync2/pkg/dropbox"
+)
+
+// buildZip writes deflated entries with data descriptors, as zip.Writer does,
+// and a stored entry with its sizes in the local header
+func buildZip(t *testing.T, files map[string]string, stored string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	if _, err := w.Create("blog/"); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stored != "" {
+		entry, err := w.CreateRaw(&zip.FileHeader{
+			Name:               "blog/stored.txt",
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE([]byte(stored)),
+			CompressedSize64:   uint64(len(stored)),
+			UncompressedSize64: uint64(len(stored)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(stored)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractZipStream(t *testing.T) {
+	m, base := newTestManager(t, &stubClient{}, 1)
+	files := map[string]string{
+		"blog/index.md":            "# Home",
+		"blog/posts/first.md":      strings.Repeat("first post ", 200),
+		"blog/static/img/logo.svg": "<svg/>",
+	}
+	archive := buildZip(t, files, "stored as is")
+
+	count, err := m.ExtractZipStream(bytes.NewReader(archive), base)
+	if err != nil {
+		t.Fatalf("streaming extraction failed: %v", err)
+	}
+	if count != len(files)+1 {
+		t.Errorf("expected %d files extracted, got %d", len(files)+1, count)
+	}
+
+	// the archive is rooted at the synced folder, files land where a regular sync puts them
+	files["blog/stored.txt"] = "stored as is"
+	for entry, want := range files {
+		name := strings.TrimPrefix(entry, "blog/")
+		got, err := os.ReadFile(filepath.Join(base, name))
+		if err != nil {
+			t.Errorf("expected %s to be extracted: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+
+		var record db.File
+		if err := m.db.Where("user_id = ? AND local_path = ?", 1, name).First(&record).Error; err != nil {
+			t.Errorf("expected a database record for %s: %v", name, err)
+		} else if record.Size != uint64(len(want)) {
+			t.Errorf("%s: expected size %d, got %d", name, len(want), record.Size)
+		}
+	}
+}
+
+func TestExtractZipStreamRejectsEscapingPaths(t *testing.T) {
+	m, base := newTestManager(t, &stubClient{}, 1)
+	archive := buildZip(t, map[string]string{"../outside.md": "nope"}, "")
+
+	if _, err := m.ExtractZipStream(bytes.NewReader(archive), base); err == nil || !strings.Contains(err.Error(), "invalid file path") {
+		t.Errorf("expected the escaping entry to be rejected, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(base), "outside.md")); !os.IsNotExist(err) {
+		t.Error("expected nothing written outside the extract directory")
+	}
+}
+
+func TestExtractZipStreamNotStreamable(t *testing.T) {
+	m, base := newTestManager(t, &stubClient{}, 1)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	// a stored entry written through CreateHeader has its sizes only in the data descriptor
+	entry, err := w.CreateHeader(&zip.FileHeader{Name: "blog/raw.bin", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Write([]byte("raw"))
+	w.Close()
+
+	if _, err := m.ExtractZipStream(bytes.NewReader(buf.Bytes()), base); !errors.Is(err, ErrNotStreamable) {
+		t.Errorf("expected ErrNotStreamable, got %v", err)
+	}
+}
+
+func TestExtractZipStoresContentHash(t *testing.T) {
+	content := strings.Repeat("hashed ", 1000)
+	archive := buildZip(t, map[string]string{"blog/post.md": content}, "")
+	zipPath := filepath.Join(t.TempDir(), "sync.zip")
+	if err := os.WriteFile(zipPath, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extractors := map[string]func(m *Manager, base string) (int, error){
+		"file": func(m *Manager, base string) (int, error) {
+			return m.ExtractZip(zipPath, base)
+		},
+		"stream": func(m *Manager, base string) (int, error) {
+			return m.ExtractZipStream(bytes.NewReader(archive), base)
+		},
+	}
+	for name, extract := range extractors {
+		m, base := newTestManager(t, &stubClient{}, 1)
+		// a record from before the extraction gets the hash too
+		if err := m.db.Create(&db.File{UserID: 1, LocalPath: "post.md", Size: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err := extract(m, base); err != nil {
+			t.Fatalf("%s: extraction failed: %v", name, err)
+		}
+
+		var record db.File
+		if err := m.db.Where("user_id = ? AND local_path = ?", 1, "post.md").First(&record).Error; err != nil {
+			t.Fatalf("%s: expected a database record: %v", name, err)
+		}
+		if want := HashBytes([]byte(content)); record.ContentHash != want {
+			t.Errorf("%s: expected content hash %s, got %q", name, want, record.ContentHash)
+		}
+	}
+}
+
+func TestZipThenIncrementalShareRecord(t *testing.T) {
+	unchanged := dropbox.FileInfo{Path: "/blog/post.md", Size: 5, ContentHash: HashBytes([]byte("first"))}
+	changed := dropbox.FileInfo{Path: "/blog/post.md", Size: 6, ContentHash: HashBytes([]byte("second"))}
+	client := &stubClient{
+		content: map[string]string{"/blog/post.md": "second"},
+		changes: map[string][]dropbox.FileInfo{
+			"cursor-1": {unchanged},
+			"cursor-2": {changed},
+		},
+	}
+	m, base := newTestManager(t, client, 0)
+
+	archive := buildZip(t, map[string]string{"blog/post.md": "first"}, "")
+	if _, err := m.ExtractZipStream(bytes.NewReader(archive), base); err != nil {
+		t.Fatal(err)
+	}
+	// the zip sync left a file the disk check alone would not trust
+	if err := os.WriteFile(filepath.Join(base, "post.md"), []byte("edit!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.saveCursor(1, "/blog", "cursor-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.incrementalSync(&dropbox.WebhookNotification{}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if len(client.downloads) != 0 {
+		t.Errorf("expected the file recorded by the zip sync to be skipped, downloaded %v", client.downloads)
+	}
+
+	if err := m.saveCursor(1, "/blog", "cursor-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.incrementalSync(&dropbox.WebhookNotification{}); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if len(client.downloads) != 1 {
+		t.Errorf("expected the changed file to be downloaded once, downloaded %v", client.downloads)
+	}
+
+	var records []db.File
+	m.db.Where("user_id = ?", 1).Find(&records)
+	if len(records) != 1 {
+		t.Fatalf("expected one record shared by both syncs, got %+v", records)
+	}
+	if records[0].LocalPath != "post.md" || records[0].ContentHash != changed.ContentHash {
+		t.Errorf("expected post.md with the new hash, got %+v", records[0])
+	}
+}
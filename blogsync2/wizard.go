@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"blogsync2/pkg/config"
+)
+
+// runConfigWizard prompts for the settings a new install needs, an empty answer keeps the value shown in brackets
+func runConfigWizard(in io.Reader, out io.Writer, cfg *config.Config) error {
+	scanner := bufio.NewScanner(in)
+
+	ask := func(prompt, current string) string {
+		fmt.Fprintf(out, "%s [%s]: ", prompt, current)
+		if !scanner.Scan() {
+			return current
+		}
+		if answer := strings.TrimSpace(scanner.Text()); answer != "" {
+			return answer
+		}
+		return current
+	}
+
+	askPort := func(prompt string, current int) int {
+		for {
+			answer := ask(prompt, strconv.Itoa(current))
+			port, err := strconv.Atoi(answer)
+			if err == nil && port > 0 && port <= 65535 {
+				return port
+			}
+			fmt.Fprintf(out, "%q is not a valid port\n", answer)
+		}
+	}
+
+	fmt.Fprintln(out, "Create an app at https://www.dropbox.com/developers/apps to get a key and secret.")
+	cfg.Dropbox.AppKey = ask("Dropbox app key", cfg.Dropbox.AppKey)
+	cfg.Dropbox.AppSecret = ask("Dropbox app secret", cfg.Dropbox.AppSecret)
+	cfg.Sync.DropboxFolder = ask("Dropbox folder to sync", cfg.Sync.DropboxFolder)
+	cfg.Sync.LocalBasePath = ask("Local folder to sync into", cfg.Sync.LocalBasePath)
+	cfg.Server.Port = askPort("Public port", cfg.Server.Port)
+	cfg.Server.AdminPort = askPort("Admin port", cfg.Server.AdminPort)
+	cfg.Dropbox.RedirectURI = ask("OAuth redirect URI", fmt.Sprintf("http://localhost:%d/auth/callback", cfg.Server.Port))
+
+	return scanner.Err()
+}
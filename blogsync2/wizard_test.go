@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blogsync2/pkg/config"
+)
+
+func TestConfigWizardWritesAnswers(t *testing.T) {
+	answers := strings.Join([]string{
+		"key-123",
+		"secret-456",
+		"/blog",
+		"", // keep the default local folder
+		"8080",
+		"not-a-port",
+		"8081",
+		"",
+	}, "\n") + "\n"
+
+	cfg := config.Default()
+	var out bytes.Buffer
+	if err := runConfigWizard(strings.NewReader(answers), &out, cfg); err != nil {
+		t.Fatalf("wizard failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := config.Save(cfg, path); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	saved, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load written config: %v", err)
+	}
+
+	if saved.Dropbox.AppKey != "key-123" || saved.Dropbox.AppSecret != "secret-456" {
+		t.Errorf("unexpected credentials %q / %q", saved.Dropbox.AppKey, saved.Dropbox.AppSecret)
+	}
+	if saved.Sync.DropboxFolder != "/blog" || saved.Sync.LocalBasePath != "./sync" {
+		t.Errorf("unexpected folders %q -> %q", saved.Sync.DropboxFolder, saved.Sync.LocalBasePath)
+	}
+	if saved.Server.Port != 8080 || saved.Server.AdminPort != 8081 {
+		t.Errorf("unexpected ports %d and %d", saved.Server.Port, saved.Server.AdminPort)
+	}
+	if saved.Dropbox.RedirectURI != "http://localhost:8080/auth/callback" {
+		t.Errorf("redirect URI should follow the public port, got %q", saved.Dropbox.RedirectURI)
+	}
+	if !strings.Contains(out.String(), `"not-a-port" is not a valid port`) {
+		t.Errorf("expected the invalid port to be reported, got %q", out.String())
+	}
+}
@@ -0,0 +1,1471 @@
+package admin
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/authz"
+	"oddity/pkg/config"
+	"oddity/pkg/contentstuff"
+)
+
+// newTestAdmin builds an AdminApp over files written into a temp content dir
+// routes are registered by each test without the auth middleware
+func newTestAdmin(t *testing.T, files map[string]string) (*AdminApp, *gin.Engine) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{Content: config.ContentConfig{
+		ContentDir: dir,
+		UploadDir:  t.TempDir(),
+		SidecarDB:  filepath.Join(t.TempDir(), "sidecar.db"),
+	}}
+	content := contentstuff.NewContentStuff(&cfg)
+	if err := content.LoadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+	wire := contentstuff.NewWire(content)
+	wire.Subscribe(content.Events())
+	if err := wire.ScanForQueries(); err != nil {
+		t.Fatalf("failed to scan queries: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	return &AdminApp{WireController: wire, SiteContent: content}, gin.New()
+}
+
+func postJSON(r *gin.Engine, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func get(r *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestHandleRawFile(t *testing.T) {
+	raw := "---\ntitle: Post\nprivate: true\n---\n# Post\n\nBody with  trailing spaces  \n"
+	s, r := newTestAdmin(t, map[string]string{"blog/post.md": raw})
+	r.GET("/admin/raw", s.HandleRawFile)
+
+	for _, path := range []string{"blog/post.md", "blog/post", "/blog/post"} {
+		w := get(r, "/admin/raw?path="+path)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, w.Code)
+		}
+		if w.Body.String() != raw {
+			t.Errorf("%s: expected exact file bytes, got %q", path, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/markdown; charset=utf-8" {
+			t.Errorf("%s: unexpected content type %q", path, ct)
+		}
+	}
+
+	for _, path := range []string{"../secret.md", "blog/../../etc/passwd", ""} {
+		if w := get(r, "/admin/raw?path="+path); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", path, w.Code)
+		}
+	}
+
+	if w := get(r, "/admin/raw?path=blog/missing.md"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for missing file, got %d", w.Code)
+	}
+}
+
+type replaceResponse struct {
+	Files  []ReplaceResult `json:"files"`
+	Total  int             `json:"total"`
+	DryRun bool            `json:"dryRun"`
+}
+
+func TestHandleReplace(t *testing.T) {
+	files := map[string]string{
+		"blog/one.md":  "---\ntitle: One\n---\n# One\n\nold name and old name again\n",
+		"blog/two.md":  "---\ntitle: Two\n---\n# Two\n\nthe old name\n",
+		"notes/old.md": "---\ntitle: Notes\n---\n# Notes\n\nold name outside the glob\n",
+	}
+	s, r := newTestAdmin(t, files)
+	r.POST("/admin/replace", s.HandleReplace)
+	dir := s.SiteContent.Config().Content.ContentDir
+
+	readFile := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		return string(data)
+	}
+
+	decode := func(w *httptest.ResponseRecorder) replaceResponse {
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp replaceResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		resp := decode(postJSON(r, "/admin/replace", `{"find":"old name","replace":"new name","pathGlob":"blog/*","dryRun":true}`))
+		if !resp.DryRun || resp.Total != 3 || len(resp.Files) != 2 {
+			t.Fatalf("unexpected dry run response: %+v", resp)
+		}
+		if resp.Files[0].File != "blog/one.md" || resp.Files[0].Count != 2 || resp.Files[1].Count != 1 {
+			t.Errorf("unexpected per-file counts: %+v", resp.Files)
+		}
+		for name, body := range files {
+			if readFile(name) != body {
+				t.Errorf("dry run modified %s", name)
+			}
+		}
+	})
+
+	t.Run("applied", func(t *testing.T) {
+		resp := decode(postJSON(r, "/admin/replace", `{"find":"old (name)","replace":"new $1","pathGlob":"blog/*","regex":true}`))
+		if resp.DryRun || resp.Total != 3 || len(resp.Files) != 2 {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+		if got := readFile("blog/one.md"); !strings.Contains(got, "new name and new name again") {
+			t.Errorf("blog/one.md not replaced: %q", got)
+		}
+		if got := readFile("blog/two.md"); !strings.Contains(got, "the new name") {
+			t.Errorf("blog/two.md not replaced: %q", got)
+		}
+		if readFile("notes/old.md") != files["notes/old.md"] {
+			t.Errorf("file outside the glob was modified")
+		}
+
+		fd, ok := s.SiteContent.DoPath("blog/two.md")
+		if !ok || fd.ParsedContent == nil || !strings.Contains(string(fd.ParsedContent.HTML), "the new name") {
+			t.Errorf("content store was not refreshed after replace")
+		}
+	})
+
+	if w := postJSON(r, "/admin/replace", `{"find":"(","regex":true}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid regex, got %d", w.Code)
+	}
+}
+
+func TestHandleStats(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"index.md":         "---\ntitle: Home\n---\n# Home\n\nwelcome home\n",
+		"blog/first.md":    "---\ntitle: First\ncreated: 1704412800\n---\n# First\n\none two three #go\n",
+		"blog/second.md":   "---\ntitle: Second\ncreated: 1705708800\n---\n# Second\n\nfour five #go #web\n",
+		"blog/third.md":    "---\ntitle: Third\ncreated: 1709337600\n---\n# Third\n\nsix\n",
+		"blog/private.md":  "---\ntitle: Secret\nprivate: true\n---\n# Secret\n\nhidden words\n",
+		"blog/unfinish.md": "---\ntitle: Draft\ndraft: true\n---\n# Draft\n\nnot yet\n",
+		"journal/_meta.md": "---\nprivate: true\n---\n",
+		"journal/day.md":   "---\ntitle: Day\ncreated: 1704412800\n---\n# Day\n\nprivate by folder\n",
+	})
+	r.GET("/admin/stats", s.HandleStats)
+
+	w := get(r, "/admin/stats")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var stats ContentStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+
+	if stats.Posts != 3 || stats.Pages != 1 || stats.Drafts != 3 {
+		t.Errorf("expected 3 posts, 1 page, 3 drafts, got %d/%d/%d", stats.Posts, stats.Pages, stats.Drafts)
+	}
+	if stats.Tags["go"] != 2 || stats.Tags["web"] != 1 {
+		t.Errorf("unexpected tag counts: %v", stats.Tags)
+	}
+	if stats.PostsPerMonth["2024-01"] != 2 || stats.PostsPerMonth["2024-03"] != 1 || len(stats.PostsPerMonth) != 2 {
+		t.Errorf("unexpected posts per month: %v", stats.PostsPerMonth)
+	}
+
+	expectedWords := 0
+	for _, fd := range s.SiteContent.AllFiles() {
+		if fd.ParsedContent != nil {
+			expectedWords += contentstuff.ExtractWordCount(fd.ParsedContent.Body)
+		}
+	}
+	if stats.TotalWords == 0 || stats.TotalWords != expectedWords {
+		t.Errorf("expected %d total words, got %d", expectedWords, stats.TotalWords)
+	}
+	if stats.AverageReadingTime != 1 {
+		t.Errorf("expected average reading time 1, got %v", stats.AverageReadingTime)
+	}
+}
+
+func TestHandleDelete(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"blog/index.md": "# Blog\n\n<!-- <query type=\"posts\" path=\"blog/*\" sort=\"recent\"> -->\n<!-- </query> -->\n",
+		"blog/keep.md":  "---\ncreated: 1700000000\n---\n# Keep\n",
+		"blog/gone.md":  "---\ncreated: 1700100000\n---\n# Gone\n",
+	})
+	r.POST("/admin/delete", s.HandleDelete)
+	r.GET("/admin/raw", s.HandleRawFile)
+
+	// fill the listing the way a save would
+	err := s.SiteContent.Events().Publish(contentstuff.ContentEvent{Type: contentstuff.ContentUpdated, FileName: "blog/index.md"})
+	if err != nil {
+		t.Fatalf("failed to run index query: %v", err)
+	}
+	indexPath := filepath.Join(s.SiteContent.Config().Content.ContentDir, "blog/index.md")
+	if index, _ := os.ReadFile(indexPath); !strings.Contains(string(index), "blog/gone") {
+		t.Fatalf("expected index to list the post before deleting, got:\n%s", index)
+	}
+
+	uploads := filepath.Join(s.SiteContent.Config().Content.UploadDir, "blog/gone")
+	if err := os.MkdirAll(uploads, 0755); err != nil {
+		t.Fatalf("failed to create uploads dir: %v", err)
+	}
+
+	w := postJSON(r, "/admin/delete", `{"slug":"blog/gone"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := get(r, "/admin/raw?path=blog/gone"); w.Code != http.StatusNotFound {
+		t.Errorf("expected deleted post to 404, got %d", w.Code)
+	}
+	if w := postJSON(r, "/admin/delete", `{"slug":"blog/gone"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected deleting twice to 404, got %d", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(s.SiteContent.Config().Content.ContentDir, "blog/gone.md")); !os.IsNotExist(err) {
+		t.Errorf("expected markdown file to be removed, stat err: %v", err)
+	}
+	if _, err := os.Stat(uploads); !os.IsNotExist(err) {
+		t.Errorf("expected uploads dir to be removed, stat err: %v", err)
+	}
+
+	index, _ := os.ReadFile(indexPath)
+	if strings.Contains(string(index), "blog/gone") || !strings.Contains(string(index), "blog/keep") {
+		t.Errorf("expected index listing to drop only the deleted post, got:\n%s", index)
+	}
+
+	var history []contentstuff.PostHistory
+	s.SiteContent.DB().Where("file_name = ? AND deleted = ?", "blog/gone.md", true).Find(&history)
+	if len(history) != 1 || !strings.Contains(history[0].Content, "# Gone") {
+		t.Errorf("expected one deleted history record holding the content, got %+v", history)
+	}
+}
+
+func TestTrashRestore(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"notes/draft.md": "---\ntitle: Draft\n---\n# Draft\n\nbody",
+	})
+	r.POST("/admin/delete", s.HandleDelete)
+	r.GET("/admin/trash", s.HandleTrashList)
+	r.POST("/admin/trash/restore", s.HandleTrashRestore)
+	r.POST("/admin/trash/purge", s.HandleTrashPurge)
+
+	contentDir := s.SiteContent.Config().Content.ContentDir
+	uploads := filepath.Join(s.SiteContent.Config().Content.UploadDir, "notes/draft")
+	if err := os.MkdirAll(uploads, 0755); err != nil {
+		t.Fatalf("failed to create uploads dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(uploads, "a.png"), []byte("png"), 0644); err != nil {
+		t.Fatalf("failed to write upload: %v", err)
+	}
+
+	w := postJSON(r, "/admin/delete", `{"slug":"notes/draft"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var deleted struct {
+		Entry contentstuff.TrashEntry `json:"entry"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &deleted); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	id := deleted.Entry.ID
+
+	trashed := filepath.Join(contentDir, contentstuff.TrashDir, id, "content", "notes/draft.md")
+	if _, err := os.Stat(trashed); err != nil {
+		t.Errorf("expected file moved to %s: %v", trashed, err)
+	}
+	if _, err := os.Stat(filepath.Join(contentDir, "notes/draft.md")); !os.IsNotExist(err) {
+		t.Errorf("expected original file to be gone, stat err: %v", err)
+	}
+	if _, exists := s.SiteContent.DoPath("notes/draft"); exists {
+		t.Errorf("expected trashed page to leave the content store")
+	}
+
+	w = get(r, "/admin/trash")
+	var listed struct {
+		Entries []contentstuff.TrashEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode trash list: %v", err)
+	}
+	if len(listed.Entries) != 1 || listed.Entries[0].ID != id || listed.Entries[0].Slug != "notes/draft" ||
+		listed.Entries[0].Title != "Draft" || !listed.Entries[0].HasUploads {
+		t.Fatalf("unexpected trash listing: %+v", listed.Entries)
+	}
+
+	// the trash is never loaded as content
+	if err := s.SiteContent.ReloadContent(); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if _, exists := s.SiteContent.DoPath("notes/draft"); exists {
+		t.Errorf("expected trash to be ignored when loading content")
+	}
+
+	if w := postJSON(r, "/admin/trash/restore", `{"id":"missing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown id to 404, got %d", w.Code)
+	}
+	w = postJSON(r, "/admin/trash/restore", `{"id":"`+id+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected restore to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	fd, exists := s.SiteContent.DoPath("notes/draft")
+	if !exists || fd.FileName != "notes/draft.md" {
+		t.Fatalf("expected page restored at its original slug, got %+v", fd)
+	}
+	if _, err := os.Stat(filepath.Join(uploads, "a.png")); err != nil {
+		t.Errorf("expected uploads restored: %v", err)
+	}
+	if entries, _ := s.SiteContent.ListTrash(); len(entries) != 0 {
+		t.Errorf("expected trash to be empty after restore, got %+v", entries)
+	}
+
+	// purge is the explicit hard delete
+	postJSON(r, "/admin/delete", `{"slug":"notes/draft"}`)
+	entries, _ := s.SiteContent.ListTrash()
+	if len(entries) != 1 {
+		t.Fatalf("expected one trash entry, got %+v", entries)
+	}
+	if w := postJSON(r, "/admin/trash/purge", `{"id":"`+entries[0].ID+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected purge to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(contentDir, contentstuff.TrashDir, entries[0].ID)); !os.IsNotExist(err) {
+		t.Errorf("expected purged entry to be removed, stat err: %v", err)
+	}
+}
+
+func TestEditRejectsFrontmatterSchemaViolations(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"legacy.md": "---\nprivate: \"yes\"\n---\n# Legacy\n",
+	})
+	r.POST("/admin/edit-data", s.HandleEditPageData)
+	s.SiteContent.Config().Frontmatter = config.FrontmatterSchema{
+		Required: []string{"title"},
+		Fields:   map[string]config.FrontmatterField{"private": {Type: "bool"}},
+	}
+
+	// files already on disk keep loading
+	if _, exists := s.SiteContent.DoPath("legacy"); !exists {
+		t.Fatalf("expected existing file to load regardless of the schema")
+	}
+
+	body, _ := json.Marshal(editPageData{FullSlug: "notes/bad", Frontmatter: "title: Bad\nprivate: \"true\"", Content: "# Bad\n"})
+	w := postJSON(r, "/admin/edit-data", string(body))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Violations []string `json:"violations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Violations) != 1 || !strings.Contains(resp.Violations[0], "private must be a bool") {
+		t.Errorf("unexpected violations: %v", resp.Violations)
+	}
+	if _, exists := s.SiteContent.DoPath("notes/bad"); exists {
+		t.Errorf("expected rejected post not to be saved")
+	}
+
+	body, _ = json.Marshal(editPageData{FullSlug: "notes/untitled", Frontmatter: "private: true", Content: "# Untitled\n"})
+	w = postJSON(r, "/admin/edit-data", string(body))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "title is required") {
+		t.Errorf("expected missing title to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body, _ = json.Marshal(editPageData{FullSlug: "notes/good", Frontmatter: "title: Good\nprivate: true", Content: "# Good\n"})
+	if w := postJSON(r, "/admin/edit-data", string(body)); w.Code != http.StatusOK {
+		t.Errorf("expected valid post to save, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestEditRejectedSaveKeepsLoadedPage(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"notes/secret.md": "---\ntitle: Secret\nprivate: true\n---\n# Secret\n",
+	})
+	r.POST("/admin/edit-data", s.HandleEditPageData)
+	s.SiteContent.Config().Frontmatter = config.FrontmatterSchema{
+		Fields: map[string]config.FrontmatterField{"private": {Type: "bool"}},
+	}
+
+	before, _ := s.SiteContent.DoPath("notes/secret.md")
+	raw := before.ParsedContent.Frontmatter.Raw
+
+	for _, frontmatter := range []string{"title: Secret\nprivate: \"true\"", "title: [unclosed"} {
+		body, _ := json.Marshal(editPageData{CurrentFile: "notes/secret.md", Frontmatter: frontmatter, Content: "# Secret\n"})
+		if w := postJSON(r, "/admin/edit-data", string(body)); w.Code == http.StatusOK {
+			t.Fatalf("%q: expected the save to be rejected", frontmatter)
+		}
+
+		after, _ := s.SiteContent.DoPath("notes/secret.md")
+		if after.ParsedContent.Frontmatter.Raw != raw {
+			t.Errorf("%q: expected the loaded frontmatter to be unchanged, got %q", frontmatter, after.ParsedContent.Frontmatter.Raw)
+		}
+		if !contentstuff.IsPrivate(s.SiteContent, after) {
+			t.Errorf("%q: expected the page to stay private", frontmatter)
+		}
+	}
+}
+
+func TestEditNormalizesFrontmatter(t *testing.T) {
+	created := time.Date(2024, 1, 5, 10, 30, 0, 0, time.Local)
+	stale := "title: Post\ncreated: " + strconv.FormatInt(created.Unix(), 10) + "\ncreated_time: \"2020-01-01 00:00:00\"\ntags: go, web"
+	s, r := newTestAdmin(t, map[string]string{
+		"blog/post.md": "---\n" + stale + "\n---\n# Post\n",
+	})
+	r.POST("/admin/edit-data", s.HandleEditPageData)
+
+	save := func(frontmatter string) *contentstuff.FrontmatterData {
+		t.Helper()
+		body, _ := json.Marshal(editPageData{CurrentFile: "blog/post.md", Frontmatter: frontmatter, Content: "# Post\n"})
+		if w := postJSON(r, "/admin/edit-data", string(body)); w.Code != http.StatusOK {
+			t.Fatalf("expected save to succeed, got %d: %s", w.Code, w.Body.String())
+		}
+		raw, err := os.ReadFile(filepath.Join(s.SiteContent.Config().Content.ContentDir, "blog/post.md"))
+		if err != nil {
+			t.Fatalf("failed to read saved file: %v", err)
+		}
+		fm, _, err := contentstuff.ExtractFrontmatter(raw)
+		if err != nil {
+			t.Fatalf("failed to parse saved frontmatter: %v", err)
+		}
+		return fm
+	}
+
+	// the unix timestamp is authoritative when both are present
+	fm := save(stale)
+	if got, _ := fm.GetString("created_time"); got != created.Format(contentstuff.FrontmatterTimeLayout) {
+		t.Errorf("expected created_time reconciled to %s, got %s", created.Format(contentstuff.FrontmatterTimeLayout), got)
+	}
+	if got := fm.GetStringSlice("tags"); len(got) != 2 || got[0] != "go" || got[1] != "web" {
+		t.Errorf("expected tags coerced into a list, got %#v", fm.DataKV["tags"])
+	}
+	var keys []string
+	for _, item := range fm.Data {
+		keys = append(keys, item.Key.(string))
+	}
+	if want := "title,tags,created,created_time,updated,updated_time"; strings.Join(keys, ",") != want {
+		t.Errorf("expected key order %s, got %s", want, strings.Join(keys, ","))
+	}
+
+	// editing only created_time moves created along with it
+	edited := strings.Replace(fm.Raw, created.Format(contentstuff.FrontmatterTimeLayout), "2023-06-01 08:00:00", 1)
+	fm = save(edited)
+	want := time.Date(2023, 6, 1, 8, 0, 0, 0, time.Local).Unix()
+	if got, _ := fm.GetInt("created"); int64(got) != want {
+		t.Errorf("expected created derived from created_time as %d, got %d", want, got)
+	}
+}
+
+func TestEditKeepsTOMLFrontmatter(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"blog/toml.md": "+++\ntitle = \"TOML Post\"\ntags = [\"a\", \"b\"]\ncreated = 1704412800\n\n[extra]\nmood = \"good\"\n+++\n# TOML Post\n\nbody\n",
+	})
+	r.POST("/admin/edit-data", s.HandleEditPageData)
+
+	frontmatter := "title = \"TOML Post, edited\"\ntags = [\"a\", \"b\"]\ncreated = 1704412800\n\n[extra]\nmood = \"good\"\n"
+	body, _ := json.Marshal(editPageData{CurrentFile: "blog/toml.md", Frontmatter: frontmatter, Content: "# TOML Post\n\nbody\n"})
+	if w := postJSON(r, "/admin/edit-data", string(body)); w.Code != http.StatusOK {
+		t.Fatalf("expected save to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	raw, err := os.ReadFile(filepath.Join(s.SiteContent.Config().Content.ContentDir, "blog/toml.md"))
+	if err != nil {
+		t.Fatalf("failed to read saved file: %v", err)
+	}
+	saved := string(raw)
+	if !strings.HasPrefix(saved, "+++\ntitle = \"TOML Post, edited\"\n") {
+		t.Fatalf("expected +++ delimited TOML in original key order, got:\n%s", saved)
+	}
+	if strings.Contains(saved, "---") {
+		t.Errorf("expected no YAML delimiters, got:\n%s", saved)
+	}
+
+	fm, _, err := contentstuff.ExtractFrontmatter(raw)
+	if err != nil {
+		t.Fatalf("failed to parse saved frontmatter: %v", err)
+	}
+	if fm.Type != contentstuff.FrontmatterTOML {
+		t.Errorf("expected TOML frontmatter after save, got %v", fm.Type)
+	}
+	if tags := fm.GetStringSlice("tags"); len(tags) != 2 {
+		t.Errorf("expected tags to survive, got %v", tags)
+	}
+	if extra, ok := fm.DataKV["extra"].(map[string]interface{}); !ok || extra["mood"] != "good" {
+		t.Errorf("expected [extra] table to survive, got %#v", fm.DataKV["extra"])
+	}
+	if !fm.HasKey("updated_time") {
+		t.Errorf("expected updated_time to be written")
+	}
+}
+
+func TestSlugifyTransliterate(t *testing.T) {
+	tests := map[string]string{
+		"Café René":         "cafe-rene",
+		"Ærøskøbing Straße": "aeroskobing-strasse",
+		"Hello, World!":     "hello-world",
+		"  crème  brûlée  ": "creme-brulee",
+		"日本語":               "",
+		"Über/naïve Résumé": "ubernaive-resume",
+	}
+	for in, want := range tests {
+		if got := slugify(in, SlugTransliterate); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := slugifyWithSlash("Notes/Café René", ""); got != "notes/cafe-rene" {
+		t.Errorf("slugifyWithSlash kept %q, want notes/cafe-rene", got)
+	}
+}
+
+func TestSlugifyUnicode(t *testing.T) {
+	tests := map[string]string{
+		"Café René":    "café-rené",
+		"日本語 メモ":       "日本語-メモ",
+		"Привет, мир!": "привет-мир",
+		"Hello World":  "hello-world",
+	}
+	for in, want := range tests {
+		if got := slugify(in, SlugUnicode); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	s, _ := newTestAdmin(t, nil)
+	if got := s.slugStyle(); got != SlugTransliterate {
+		t.Errorf("default slug style = %q, want %q", got, SlugTransliterate)
+	}
+	s.SiteContent.Config().Content.SlugStyle = SlugUnicode
+	if got := slugifyWithSlash("Notes/Café", s.slugStyle()); got != "notes/café" {
+		t.Errorf("configured unicode style gave %q, want notes/café", got)
+	}
+}
+
+func TestNewPageSlugAvoidsCollisions(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		// served at notes/hello, but the file is notes/first.md
+		"notes/first.md": "---\nslug: hello\n---\n# First\n",
+		// a custom slug elsewhere leaves notes/second.md taken only on disk and by file name
+		"notes/second.md": "---\nslug: moved\n---\n# Second\n",
+	})
+	r.POST("/admin/edit-data", s.HandleEditPageData)
+
+	// not loaded yet, only on disk
+	dir := s.SiteContent.Config().Content.ContentDir
+	if err := os.WriteFile(filepath.Join(dir, "notes/third.md"), []byte("# Third\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	for slug, want := range map[string]string{
+		"notes/hello":  "notes/hello-1.md",
+		"notes/second": "notes/second-1.md",
+		"notes/third":  "notes/third-1.md",
+	} {
+		body, _ := json.Marshal(editPageData{FullSlug: slug, Frontmatter: "title: New", Content: "# New\n"})
+		if w := postJSON(r, "/admin/edit-data", string(body)); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected save to succeed, got %d: %s", slug, w.Code, w.Body.String())
+		}
+		if _, err := os.Stat(filepath.Join(dir, want)); err != nil {
+			t.Errorf("%s: expected new page saved as %s: %v", slug, want, err)
+		}
+	}
+
+	if raw, _ := os.ReadFile(filepath.Join(dir, "notes/second.md")); !strings.Contains(string(raw), "# Second") {
+		t.Errorf("existing notes/second.md was overwritten: %s", raw)
+	}
+}
+
+func TestNewPostUsesConfiguredTemplate(t *testing.T) {
+	s, _ := newTestAdmin(t, nil)
+	s.SiteContent.Config().Templates = config.TemplatesConfig{
+		Post:  "---\ntitle: {{title}}\ndate: {{date}}\ntags: []\n---\n# {{title}}\n\nSee /{{slug}}\n",
+		Index: "---\nlayout: list\n---\n# All of {{dir}}\n",
+	}
+
+	data, err := s.buildEditPageDataResponse("notes/2024-01-05-first-light", "")
+	if err != nil {
+		t.Fatalf("failed to build edit response: %v", err)
+	}
+	wantFM := "title: 2024-01-05 First Light\ndate: " + time.Now().Format("2006-01-02") + "\ntags: []\n"
+	if data.Frontmatter != wantFM {
+		t.Errorf("expected frontmatter %q, got %q", wantFM, data.Frontmatter)
+	}
+	if want := "# 2024-01-05 First Light\n\nSee /notes/2024-01-05-first-light\n"; data.Content != want {
+		t.Errorf("expected content %q, got %q", want, data.Content)
+	}
+
+	data, _ = s.buildEditPageDataResponse("notes/index", "")
+	if data.Frontmatter != "layout: list\n" || data.Content != "# All of notes\n" {
+		t.Errorf("expected the index template, got %q / %q", data.Frontmatter, data.Content)
+	}
+
+	// without configuration the built-in templates are kept
+	s.SiteContent.Config().Templates = config.TemplatesConfig{}
+	data, _ = s.buildEditPageDataResponse("notes/hello", "")
+	if data.Frontmatter != "private: true\n" || data.Content != "# Hello\n\nwrite..." {
+		t.Errorf("expected the default template, got %q / %q", data.Frontmatter, data.Content)
+	}
+}
+
+func TestNewPostFromNamedTemplate(t *testing.T) {
+	s, r := newTestAdmin(t, nil)
+	r.GET("/admin/edit-data", s.HandleEditPageData)
+
+	dir := t.TempDir()
+	s.SiteContent.Config().Templates.Dir = dir
+	recipe := "---\ntemplate_description: Ingredients and method\ntitle: {{title}}\nservings: 4\n---\n# {{title}}\n\n## Ingredients\n\n## Method\n"
+	if err := os.WriteFile(filepath.Join(dir, "recipe.md"), []byte(recipe), 0644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+	// a template whose only frontmatter is its label gives pages without frontmatter
+	if err := os.WriteFile(filepath.Join(dir, "plain.md"), []byte("---\ntemplate_description: Just text\n---\n# {{title}}\n"), 0644); err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+
+	load := func(query string) editPageData {
+		t.Helper()
+		w := get(r, "/admin/edit-data?"+query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var data editPageData
+		if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", query, err)
+		}
+		return data
+	}
+
+	data := load("path=food/pancakes&template=recipe")
+	if data.Frontmatter != "title: Pancakes\nservings: 4\n" {
+		t.Errorf("expected the recipe frontmatter, got %q", data.Frontmatter)
+	}
+	if want := "# Pancakes\n\n## Ingredients\n\n## Method\n"; data.Content != want {
+		t.Errorf("expected content %q, got %q", want, data.Content)
+	}
+
+	data = load("path=food/pancakes&template=plain")
+	if data.Frontmatter != "" || data.Content != "# Pancakes\n" {
+		t.Errorf("expected the label to be dropped with its frontmatter, got %q / %q", data.Frontmatter, data.Content)
+	}
+
+	for _, name := range []string{"review", "../recipe"} {
+		data = load("path=food/pancakes&template=" + url.QueryEscape(name))
+		if data.Frontmatter != "private: true\n" || data.Content != "# Pancakes\n\nwrite..." {
+			t.Errorf("template %q: expected the default template, got %q / %q", name, data.Frontmatter, data.Content)
+		}
+	}
+}
+
+func TestHandleTemplateList(t *testing.T) {
+	s, r := newTestAdmin(t, nil)
+	r.GET("/admin/templates", s.HandleTemplateList)
+
+	dir := t.TempDir()
+	s.SiteContent.Config().Templates.Dir = dir
+	for name, body := range map[string]string{
+		"recipe.md": "---\ntemplate_description: Ingredients and method\ntitle: {{title}}\n---\n# {{title}}\n",
+		"review.md": "---\ntemplate_description: A rated review\nrating: 0\n---\n# {{title}}\n",
+		"notes.txt": "not a template",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	w := get(r, "/admin/templates")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Templates []TemplateInfo `json:"templates"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := []TemplateInfo{
+		{Name: "recipe", Description: "Ingredients and method"},
+		{Name: "review", Description: "A rated review"},
+	}
+	if len(resp.Templates) != len(want) {
+		t.Fatalf("expected %v, got %v", want, resp.Templates)
+	}
+	for i := range want {
+		if resp.Templates[i] != want[i] {
+			t.Errorf("template %d: expected %+v, got %+v", i, want[i], resp.Templates[i])
+		}
+	}
+}
+
+// postUpload sends files as a multipart upload along with the form fields
+func postUpload(r *gin.Engine, fields map[string]string, files map[string][]byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for key, value := range fields {
+		mw.WriteField(key, value)
+	}
+	for name, data := range files {
+		fw, _ := mw.CreateFormFile("files", name)
+		fw.Write(data)
+	}
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadDateLayout(t *testing.T) {
+	s, r := newTestAdmin(t, nil)
+	r.POST("/admin/upload", s.HandleFileUpload)
+	s.SiteContent.Config().Content.UploadLayout = config.UploadLayoutDate
+
+	w := postUpload(r, map[string]string{"fullSlug": "blog/post"}, map[string][]byte{"notes.txt": []byte("hello")})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected upload to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Uploaded []string `json:"uploaded"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	month := time.Now().Format("2006/01")
+	if want := "/uploads/" + month + "/notes.txt"; len(resp.Uploaded) != 1 || resp.Uploaded[0] != want {
+		t.Errorf("expected upload URL %s, got %v", want, resp.Uploaded)
+	}
+	stored := filepath.Join(s.SiteContent.Config().Content.UploadDir, filepath.FromSlash(month), "notes.txt")
+	if raw, err := os.ReadFile(stored); err != nil || string(raw) != "hello" {
+		t.Errorf("expected upload stored at %s: %v", stored, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.SiteContent.Config().Content.UploadDir, "blog")); !os.IsNotExist(err) {
+		t.Errorf("expected no per post upload directory with the date layout")
+	}
+}
+
+func TestUploadDateLayoutKeepsSameNamedUploads(t *testing.T) {
+	month := time.Now().Format("2006/01")
+	s, r := newTestAdmin(t, map[string]string{
+		"blog/first.md":  "# First\n\n![photo](/uploads/" + month + "/photo.txt)\n",
+		"blog/second.md": "# Second\n\n![photo](/uploads/" + month + "/photo-1.txt)\n",
+	})
+	r.POST("/admin/upload", s.HandleFileUpload)
+	r.GET("/admin/uploads-list", s.HandleUploadsList)
+	s.SiteContent.Config().Content.UploadLayout = config.UploadLayoutDate
+
+	var urls []string
+	for _, post := range []struct{ slug, body string }{{"blog/first", "first"}, {"blog/second", "second"}} {
+		w := postUpload(r, map[string]string{"fullSlug": post.slug}, map[string][]byte{"photo.txt": []byte(post.body)})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected upload to succeed, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Uploaded []string `json:"uploaded"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Uploaded) != 1 {
+			t.Fatalf("unexpected upload response %s: %v", w.Body.String(), err)
+		}
+		urls = append(urls, resp.Uploaded[0])
+	}
+
+	want := []string{"/uploads/" + month + "/photo.txt", "/uploads/" + month + "/photo-1.txt"}
+	if !slices.Equal(urls, want) {
+		t.Fatalf("expected the second upload to get its own name, got %v", urls)
+	}
+	for i, body := range []string{"first", "second"} {
+		stored := filepath.Join(s.SiteContent.Config().Content.UploadDir, filepath.FromSlash(strings.TrimPrefix(want[i], "/uploads/")))
+		if raw, err := os.ReadFile(stored); err != nil || string(raw) != body {
+			t.Errorf("expected %s to hold %q, got %q, %v", stored, body, raw, err)
+		}
+	}
+
+	w := get(r, "/admin/uploads-list?fullSlug=blog/second")
+	var listed struct {
+		Files []FileInfo `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode uploads list: %v", err)
+	}
+	if len(listed.Files) != 1 || listed.Files[0].Name != "photo-1.txt" || listed.Files[0].URL != want[1] {
+		t.Errorf("expected the page's upload to be listed with its url, got %+v", listed.Files)
+	}
+}
+
+func TestUploadDateLayoutDelete(t *testing.T) {
+	month := time.Now().Format("2006/01")
+	s, r := newTestAdmin(t, map[string]string{"blog/post.md": "# Post\n\n![photo](/uploads/" + month + "/photo.txt)\n"})
+	r.POST("/admin/upload", s.HandleFileUpload)
+	r.POST("/admin/upload-delete", s.HandleFileDelete)
+	s.SiteContent.Config().Content.UploadLayout = config.UploadLayoutDate
+
+	if w := postUpload(r, map[string]string{"fullSlug": "blog/post"}, map[string][]byte{"photo.txt": []byte("photo")}); w.Code != http.StatusOK {
+		t.Fatalf("expected upload to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	stored := filepath.Join(s.SiteContent.Config().Content.UploadDir, filepath.FromSlash(month), "photo.txt")
+
+	if w := postJSON(r, "/admin/upload-delete", `{"fullSlug":"blog/post","filename":"photo.txt","url":"/uploads/../secret.txt"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a url outside the upload dir to be rejected, got %d", w.Code)
+	}
+	w := postJSON(r, "/admin/upload-delete", `{"fullSlug":"blog/post","filename":"photo.txt","url":"/uploads/`+month+`/photo.txt"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected delete to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(stored); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be deleted, stat err: %v", stored, err)
+	}
+}
+
+func TestUploadConvertsToWebP(t *testing.T) {
+	s, r := newTestAdmin(t, nil)
+	r.POST("/admin/upload", s.HandleFileUpload)
+	s.SiteContent.Config().Content.UploadFormat = "webp"
+	s.SiteContent.Config().Content.UploadQuality = 70
+
+	// ImageMagick is not needed for the test, the stub checks what it is handed
+	var gotArgs []string
+	defer func(orig func(...string) error) { runMagick = orig }(runMagick)
+	runMagick = func(args ...string) error {
+		gotArgs = args
+		if _, err := imaging.Open(args[0]); err != nil {
+			t.Errorf("expected a decodable intermediate image, got %v", err)
+		}
+		return os.WriteFile(args[len(args)-1], []byte("RIFF\x00\x00\x00\x00WEBP"), 0644)
+	}
+
+	var png bytes.Buffer
+	if err := imaging.Encode(&png, imaging.New(4, 4, color.White), imaging.PNG); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	w := postUpload(r, map[string]string{"fullSlug": "blog/post"}, map[string][]byte{"photo.png": png.Bytes()})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected upload to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Uploaded []string `json:"uploaded"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Uploaded) != 1 || resp.Uploaded[0] != "/uploads/blog/post/photo.webp" {
+		t.Errorf("expected the webp URL, got %v", resp.Uploaded)
+	}
+	uploadDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, "blog/post")
+	if raw, err := os.ReadFile(filepath.Join(uploadDir, "photo.webp")); err != nil || !bytes.HasPrefix(raw, []byte("RIFF")) {
+		t.Errorf("expected photo.webp to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(uploadDir, "photo.png")); !os.IsNotExist(err) {
+		t.Errorf("expected the original png not to be kept")
+	}
+	if len(gotArgs) != 4 || gotArgs[1] != "-quality" || gotArgs[2] != "70" {
+		t.Errorf("expected conversion at quality 70, got %v", gotArgs)
+	}
+}
+
+func TestUploadSrcsetVariants(t *testing.T) {
+	s, r := newTestAdmin(t, nil)
+	r.POST("/admin/upload", s.HandleFileUpload)
+
+	var png bytes.Buffer
+	if err := imaging.Encode(&png, imaging.New(1600, 800, color.White), imaging.PNG); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	w := postUpload(r, map[string]string{"fullSlug": "blog/post", "srcset": "true"}, map[string][]byte{"wide.png": png.Bytes()})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected upload to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Uploaded []string          `json:"uploaded"`
+		Srcsets  map[string]string `json:"srcsets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	primary := "/uploads/blog/post/wide.png"
+	if len(resp.Uploaded) != 1 || resp.Uploaded[0] != primary {
+		t.Fatalf("expected %s uploaded, got %v", primary, resp.Uploaded)
+	}
+	want := "/uploads/blog/post/wide-480w.png 480w, /uploads/blog/post/wide-960w.png 960w, /uploads/blog/post/wide-1440w.png 1440w"
+	if got := resp.Srcsets[primary]; got != want {
+		t.Errorf("expected srcset %q, got %q", want, got)
+	}
+
+	uploadDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, "blog/post")
+	for _, width := range []int{480, 960, 1440} {
+		img, err := imaging.Open(filepath.Join(uploadDir, fmt.Sprintf("wide-%dw.png", width)))
+		if err != nil {
+			t.Errorf("expected a %dw variant: %v", width, err)
+			continue
+		}
+		if got := img.Bounds().Dx(); got != width {
+			t.Errorf("expected the %dw variant to be %d wide, got %d", width, width, got)
+		}
+	}
+
+	// widths at or above the image width are not generated
+	s.SiteContent.Config().Content.SrcsetWidths = []int{800, 3000}
+	w = postUpload(r, map[string]string{"fullSlug": "blog/post", "srcset": "true"}, map[string][]byte{"again.png": png.Bytes()})
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got := resp.Srcsets["/uploads/blog/post/again.png"]; got != "/uploads/blog/post/again-800w.png 800w" {
+		t.Errorf("expected only the 800w variant, got %q", got)
+	}
+}
+
+func TestEditHistoryQueryDiff(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"index.md":    "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n- [One](/blog/one)\n<!-- </query> -->\n",
+		"blog/one.md": "---\ntitle: One\ncreated: 1704456000\n---\n# One\n",
+	})
+	r.GET("/admin/edit-data", s.HandleEditPageData)
+
+	// saving a post regenerates the index block, which leaves a history row for the index
+	parsed, err := contentstuff.NewMarkdownParser(s.SiteContent.ParserConfig()).Parse([]byte("---\ntitle: Two\ncreated: 1704456100\n---\n# Two\n"))
+	if err != nil {
+		t.Fatalf("failed to parse post: %v", err)
+	}
+	post := contentstuff.FileDetail{FileName: "blog/two.md", FileType: contentstuff.FileTypeMarkdown, ParsedContent: parsed}
+	if err := contentstuff.SaveFileDetail(s.SiteContent, &post); err != nil {
+		t.Fatalf("failed to save post: %v", err)
+	}
+
+	w := get(r, "/admin/edit-data?path=index.md&action=history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		History []struct {
+			Queries []queryDiff `json:"queries"`
+		} `json:"history"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.History) != 1 || len(resp.History[0].Queries) != 1 {
+		t.Fatalf("expected one history item with one query diff, got %s", w.Body.String())
+	}
+	diff := resp.History[0].Queries[0]
+	if !strings.Contains(diff.DiffHTML, `<span class="diff-insert">- [Two](/blog/two)</span>`) {
+		t.Errorf("expected the new post as an insert, got %s", diff.DiffHTML)
+	}
+	if !strings.Contains(diff.DiffHTML, `<span class="diff-equal">- [One](/blog/one)</span>`) {
+		t.Errorf("expected the existing post unchanged, got %s", diff.DiffHTML)
+	}
+	if strings.Contains(diff.DiffHTML, "diff-delete") {
+		t.Errorf("expected nothing removed, got %s", diff.DiffHTML)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{"index.md": "# Home\n"})
+	s.Authz = &authz.AuthzApp{SiteContent: s.SiteContent}
+	s.Authz.Init()
+	r.Use(s.Authz.AuthMiddleware())
+	s.RegisterRoutes(r)
+
+	var admin authz.User
+	if err := s.SiteContent.DB().Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatalf("failed to find admin user: %v", err)
+	}
+	session, err := s.Authz.CreateSession(admin.ID)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	send := func(path, body string, auth func(*http.Request)) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		auth(req)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	withSession := func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: session.Token})
+	}
+	withToken := func(token string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+	}
+	anonymous := func(*http.Request) {}
+
+	w := send("/admin/tokens", `{"name": "deploy script"}`, withSession)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected token to be issued, got %d: %s", w.Code, w.Body.String())
+	}
+	var issued struct {
+		Token string         `json:"token"`
+		Entry authz.APIToken `json:"entry"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &issued); err != nil {
+		t.Fatalf("failed to decode token response: %v", err)
+	}
+	if !strings.HasPrefix(issued.Token, "odt_") || issued.Entry.Role != authz.RoleEditor {
+		t.Fatalf("expected an editor token, got %+v", issued)
+	}
+	var stored authz.APIToken
+	s.SiteContent.DB().First(&stored, issued.Entry.ID)
+	if stored.TokenHash == "" || strings.Contains(stored.TokenHash, issued.Token) {
+		t.Errorf("expected only a hash of the token to be stored, got %q", stored.TokenHash)
+	}
+
+	edit, _ := json.Marshal(editPageData{FullSlug: "notes/scripted", Frontmatter: "title: Scripted", Content: "# Scripted\n\nFrom a script.\n"})
+	if w := send("/admin/edit-data", string(edit), anonymous); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an edit without credentials to be rejected, got %d", w.Code)
+	}
+	if w := send("/admin/edit-data", string(edit), withToken("odt_made-up")); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown token to be rejected, got %d", w.Code)
+	}
+	if w := send("/admin/edit-data", string(edit), withToken(issued.Token)); w.Code != http.StatusOK {
+		t.Fatalf("expected the token to post an edit, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := s.SiteContent.DoPath("notes/scripted"); !ok {
+		t.Errorf("expected the page posted with the token to be saved")
+	}
+
+	// tokens cannot manage tokens
+	if w := send("/admin/tokens", `{"name": "another"}`, withToken(issued.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a token not to issue tokens, got %d", w.Code)
+	}
+
+	revoke := fmt.Sprintf(`{"id": %d}`, issued.Entry.ID)
+	if w := send("/admin/tokens/revoke", revoke, withSession); w.Code != http.StatusOK {
+		t.Fatalf("expected the token to be revoked, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("/admin/edit-data", string(edit), withToken(issued.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked token to get 401, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("/admin/tokens/revoke", revoke, withSession); w.Code != http.StatusNotFound {
+		t.Errorf("expected revoking twice to fail, got %d", w.Code)
+	}
+}
+
+func TestAPITokenRoles(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{"index.md": "# Home\n"})
+	s.Authz = &authz.AuthzApp{SiteContent: s.SiteContent}
+	s.Authz.Init()
+	r.Use(s.Authz.AuthMiddleware())
+	s.RegisterRoutes(r)
+
+	var admin authz.User
+	if err := s.SiteContent.DB().Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatalf("failed to find admin user: %v", err)
+	}
+	editorToken, _, err := s.Authz.IssueToken(&admin, "writer", authz.RoleEditor)
+	if err != nil {
+		t.Fatalf("failed to issue editor token: %v", err)
+	}
+	adminToken, _, err := s.Authz.IssueToken(&admin, "backups", authz.RoleAdmin)
+	if err != nil {
+		t.Fatalf("failed to issue admin token: %v", err)
+	}
+
+	send := func(method, path, body, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	edit, _ := json.Marshal(editPageData{FullSlug: "notes/by-editor", Content: "# By editor\n"})
+	if w := send(http.MethodPost, "/admin/edit-data", string(edit), editorToken); w.Code != http.StatusOK {
+		t.Errorf("expected an editor token to edit, got %d: %s", w.Code, w.Body.String())
+	}
+	post := `{"slug": "blog/by-editor", "markdown": "# By editor\n\nPosted.\n"}`
+	if w := send(http.MethodPost, "/api/posts", post, editorToken); w.Code != http.StatusCreated {
+		t.Errorf("expected an editor token to post, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := send(http.MethodGet, "/admin/export", "", editorToken); w.Code != http.StatusForbidden {
+		t.Errorf("expected an editor token to be refused the export, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/admin/delete", `{"path": "notes/by-editor"}`, editorToken); w.Code != http.StatusForbidden {
+		t.Errorf("expected an editor token to be refused a delete, got %d", w.Code)
+	}
+	if _, ok := s.SiteContent.DoPath("notes/by-editor"); !ok {
+		t.Errorf("expected the page to survive the refused delete")
+	}
+
+	if w := send(http.MethodGet, "/admin/export", "", adminToken); w.Code != http.StatusOK {
+		t.Errorf("expected an admin token to export, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := s.SiteContent.DB().Model(&admin).Update("role", authz.RoleEditor).Error; err != nil {
+		t.Fatalf("failed to demote admin: %v", err)
+	}
+	if w := send(http.MethodGet, "/admin/export", "", adminToken); w.Code != http.StatusForbidden {
+		t.Errorf("expected an admin token of a demoted user to be refused the export, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/api/posts", `{"slug": "blog/demoted", "markdown": "# Demoted\n"}`, adminToken); w.Code != http.StatusCreated {
+		t.Errorf("expected an admin token of a demoted user to still edit, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := s.SiteContent.DB().Delete(&admin).Error; err != nil {
+		t.Fatalf("failed to delete admin: %v", err)
+	}
+	if w := send(http.MethodPost, "/api/posts", `{"slug": "blog/orphan", "markdown": "# Orphan\n"}`, editorToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a token of a deleted user to be rejected, got %d", w.Code)
+	}
+}
+
+func TestTokenOwnership(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{"index.md": "# Home\n"})
+	s.Authz = &authz.AuthzApp{SiteContent: s.SiteContent}
+	s.Authz.Init()
+	r.Use(s.Authz.AuthMiddleware())
+	s.RegisterRoutes(r)
+
+	var admin authz.User
+	if err := s.SiteContent.DB().Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatalf("failed to find admin user: %v", err)
+	}
+	editor := authz.User{Username: "editor", Email: "editor@localhost", Role: authz.RoleEditor}
+	if err := s.SiteContent.DB().Create(&editor).Error; err != nil {
+		t.Fatalf("failed to create editor: %v", err)
+	}
+
+	_, adminEntry, err := s.Authz.IssueToken(&admin, "admin script", "")
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	_, editorEntry, err := s.Authz.IssueToken(&editor, "editor script", "")
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+
+	sessionFor := func(user authz.User) *http.Cookie {
+		session, err := s.Authz.CreateSession(user.ID)
+		if err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		return &http.Cookie{Name: "session_token", Value: session.Token}
+	}
+	adminCookie, editorCookie := sessionFor(admin), sessionFor(editor)
+
+	send := func(method, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	listed := func(cookie *http.Cookie) []string {
+		w := send(http.MethodGet, "/admin/tokens", "", cookie)
+		var resp struct {
+			Tokens []authz.APIToken `json:"tokens"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode token list: %v", err)
+		}
+		var names []string
+		for _, token := range resp.Tokens {
+			names = append(names, token.Name)
+		}
+		return names
+	}
+
+	if names := listed(editorCookie); !slices.Equal(names, []string{"editor script"}) {
+		t.Errorf("expected the editor to list only their own token, got %v", names)
+	}
+	if names := listed(adminCookie); !slices.Equal(names, []string{"editor script", "admin script"}) {
+		t.Errorf("expected the admin to list every token, got %v", names)
+	}
+
+	if w := send(http.MethodPost, "/admin/tokens/revoke", fmt.Sprintf(`{"id": %d}`, adminEntry.ID), editorCookie); w.Code != http.StatusNotFound {
+		t.Errorf("expected the editor not to revoke the admin's token, got %d", w.Code)
+	}
+	var stillActive authz.APIToken
+	if s.SiteContent.DB().First(&stillActive, adminEntry.ID); stillActive.RevokedAt != nil {
+		t.Errorf("expected the admin's token to stay active")
+	}
+
+	if w := send(http.MethodPost, "/admin/tokens/revoke", fmt.Sprintf(`{"id": %d}`, editorEntry.ID), editorCookie); w.Code != http.StatusOK {
+		t.Errorf("expected the editor to revoke their own token, got %d: %s", w.Code, w.Body.String())
+	}
+	_, otherEntry, err := s.Authz.IssueToken(&editor, "another editor script", "")
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if w := send(http.MethodPost, "/admin/tokens/revoke", fmt.Sprintf(`{"id": %d}`, otherEntry.ID), adminCookie); w.Code != http.StatusOK {
+		t.Errorf("expected the admin to revoke the editor's token, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIPost(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{"index.md": "# Home\n"})
+	s.Authz = &authz.AuthzApp{SiteContent: s.SiteContent}
+	s.Authz.Init()
+	r.Use(s.Authz.AuthMiddleware())
+	s.RegisterRoutes(r)
+
+	var admin authz.User
+	if err := s.SiteContent.DB().Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatalf("failed to find admin user: %v", err)
+	}
+	token, _, err := s.Authz.IssueToken(&admin, "integration", "")
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+
+	send := func(body string, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	post := `{"slug": "/blog/from-api", "frontmatter": {"title": "From the API", "tags": ["api", "go"]}, "markdown": "Posted by a script.\n"}`
+	if w := send(post, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a post without a token to be rejected, got %d", w.Code)
+	}
+
+	w := send(post, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected the post to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Slug string `json:"slug"`
+		URL  string `json:"url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if created.Slug != "blog/from-api" || created.URL != "http://example.com/blog/from-api" {
+		t.Errorf("unexpected slug and url: %+v", created)
+	}
+
+	r.GET("/admin/raw-test", s.HandleRawFile)
+	raw := get(r, "/admin/raw-test?path=blog/from-api").Body.String()
+	for _, want := range []string{"title: From the API", "- api", "created: ", "Posted by a script."} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("expected %q in the saved post, got:\n%s", want, raw)
+		}
+	}
+	fd, ok := s.SiteContent.DoPath("blog/from-api")
+	if !ok || contentstuff.NewPageFromFileDetail(&fd).Title() != "From the API" {
+		t.Fatalf("expected the post to be loaded with its title")
+	}
+	created1, _ := fd.ParsedContent.Frontmatter.GetValue("created")
+
+	update := `{"slug": "blog/from-api", "frontmatter": {"title": "Updated"}, "markdown": "Changed.\n"}`
+	if w := send(update, token); w.Code != http.StatusOK {
+		t.Fatalf("expected the post to be updated, got %d: %s", w.Code, w.Body.String())
+	}
+	fd, _ = s.SiteContent.DoPath("blog/from-api")
+	if title := contentstuff.NewPageFromFileDetail(&fd).Title(); title != "Updated" {
+		t.Errorf("expected the updated title, got %q", title)
+	}
+	if created2, _ := fd.ParsedContent.Frontmatter.GetValue("created"); fmt.Sprint(created2) != fmt.Sprint(created1) {
+		t.Errorf("expected the created time to be kept, got %v then %v", created1, created2)
+	}
+
+	for _, bad := range []string{
+		`{"slug": "blog/Not A Slug", "frontmatter": {"title": "Bad"}, "markdown": "x"}`,
+		`{"slug": "../escape", "frontmatter": {"title": "Bad"}, "markdown": "x"}`,
+		`{"slug": "blog/untitled", "markdown": "No title here.\n"}`,
+		`{"slug": "blog/list", "frontmatter": ["title"], "markdown": "# List\n"}`,
+	} {
+		if w := send(bad, token); w.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected, got %d: %s", bad, w.Code, w.Body.String())
+		}
+	}
+}
+
+// buildZip returns a zip archive holding files
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s to zip: %v", name, err)
+		}
+		fw.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func postImport(r *gin.Engine, archive []byte, overwrite bool) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if overwrite {
+		mw.WriteField("overwrite", "true")
+	}
+	fw, _ := mw.CreateFormFile("file", "import.zip")
+	fw.Write(archive)
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportZip(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{"index.md": "# Home\n"})
+	r.POST("/admin/import", s.HandleImport)
+
+	archive := buildZip(t, map[string]string{
+		"blog/imported.md":        "---\ntitle: Imported\n---\n# Imported\n\nFrom a zip.\n",
+		"uploads/blog/photo.png":  "png",
+		"notes/.DS_Store":         "junk",
+		"__MACOSX/blog/._photo":   "junk",
+		"notes/second/index.html": "<p>Second page</p>\n",
+	})
+	if w := postImport(r, archive, false); w.Code != http.StatusOK {
+		t.Fatalf("expected import to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, slug := range []string{"blog/imported", "notes/second/index"} {
+		if _, ok := s.SiteContent.DoPath(slug); !ok {
+			t.Errorf("expected %s to resolve after import", slug)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.SiteContent.Config().Content.UploadDir, "blog", "photo.png")); err != nil {
+		t.Errorf("expected the asset in the upload dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.SiteContent.Config().Content.ContentDir, "notes", ".DS_Store")); !os.IsNotExist(err) {
+		t.Errorf("expected dot files to be skipped")
+	}
+
+	changed := buildZip(t, map[string]string{
+		"blog/imported.md": "---\ntitle: Replaced\n---\n# Replaced\n",
+		"blog/new.md":      "# New\n",
+	})
+	w := postImport(r, changed, false)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "blog/imported.md") {
+		t.Fatalf("expected the existing page to be reported, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := s.SiteContent.DoPath("blog/new"); ok {
+		t.Errorf("expected nothing to be written when there are conflicts")
+	}
+
+	if w := postImport(r, changed, true); w.Code != http.StatusOK {
+		t.Fatalf("expected overwrite to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	fd, ok := s.SiteContent.DoPath("blog/imported")
+	if !ok || contentstuff.NewPageFromFileDetail(&fd).Title() != "Replaced" {
+		t.Errorf("expected the page to be replaced")
+	}
+
+	escape := buildZip(t, map[string]string{"../outside.md": "# Outside\n"})
+	if w := postImport(r, escape, true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a path outside the content dir to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(s.SiteContent.Config().Content.ContentDir), "outside.md")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written outside the content dir")
+	}
+}
+
+func TestExportZip(t *testing.T) {
+	s, r := newTestAdmin(t, map[string]string{
+		"index.md":        "# Home\n",
+		"blog/post.md":    "# Post\n",
+		"blog/private.md": "---\nprivate: true\n---\n# Private\n",
+		"notes/page.html": "<p>Page</p>\n",
+	})
+	r.GET("/admin/export", s.HandleExport)
+	uploadDir := s.SiteContent.Config().Content.UploadDir
+	os.MkdirAll(filepath.Join(uploadDir, "blog", "post"), 0755)
+	os.WriteFile(filepath.Join(uploadDir, "blog", "post", "photo.png"), []byte("png"), 0644)
+
+	entries := func(path string) map[string]string {
+		t.Helper()
+		w := get(r, path)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("expected a zip download, got %d %s", w.Code, w.Header().Get("Content-Type"))
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+			t.Errorf("expected an attachment, got %q", w.Header().Get("Content-Disposition"))
+		}
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatalf("failed to read export: %v", err)
+		}
+		files := make(map[string]string)
+		for _, f := range archive.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("failed to open %s: %v", f.Name, err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(data)
+		}
+		return files
+	}
+
+	files := entries("/admin/export")
+	for name, body := range map[string]string{
+		"index.md":        "# Home\n",
+		"blog/post.md":    "# Post\n",
+		"blog/private.md": "---\nprivate: true\n---\n# Private\n",
+		"notes/page.html": "<p>Page</p>\n",
+	} {
+		if files[name] != body {
+			t.Errorf("expected %s to hold %q, got %q", name, body, files[name])
+		}
+	}
+	if _, ok := files["uploads/blog/post/photo.png"]; ok {
+		t.Errorf("expected uploads to be left out by default")
+	}
+
+	files = entries("/admin/export?uploads=true")
+	if files["uploads/blog/post/photo.png"] != "png" || files["blog/post.md"] != "# Post\n" {
+		t.Errorf("expected content and uploads in the export, got %v", slices.Sorted(maps.Keys(files)))
+	}
+}
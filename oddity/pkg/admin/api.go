@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+	log "github.com/sirupsen/logrus"
+
+	"oddity/pkg/contentstuff"
+)
+
+// apiPostRequest creates or updates the markdown page at Slug,
+// Frontmatter is a JSON object whose keys keep their order in the file
+type apiPostRequest struct {
+	Slug        string          `json:"slug" binding:"required"`
+	Frontmatter json.RawMessage `json:"frontmatter"`
+	Markdown    string          `json:"markdown"`
+}
+
+// HandleAPIPost saves a post from {slug, frontmatter, markdown} and returns its slug and url,
+// 201 when the post was created and 200 when an existing one was updated
+func (s *AdminApp) HandleAPIPost(c *gin.Context) {
+	var req apiPostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	slug, err := s.validAPISlug(req.Slug)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	fm, err := apiFrontmatter(req.Frontmatter)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	parser := contentstuff.NewMarkdownParser(s.SiteContent.ParserConfig())
+	parsed, err := parser.Parse([]byte(req.Markdown))
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("error parsing markdown: %v", err)})
+		return
+	}
+
+	var violations []string
+	if title, _ := fm.GetString("title"); strings.TrimSpace(title) == "" && parsed.Title == "" {
+		violations = append(violations, "title is required, in the frontmatter or as a # heading")
+	}
+	violations = append(violations, contentstuff.ValidateFrontmatter(fm, s.SiteContent.Config().Frontmatter)...)
+	if len(violations) > 0 {
+		c.JSON(400, gin.H{"error": "frontmatter does not match the schema", "violations": violations})
+		return
+	}
+
+	file, existingPage := s.SiteContent.DoPath(slug)
+	if existingPage && file.FileType != contentstuff.FileTypeMarkdown {
+		c.JSON(409, gin.H{"error": fmt.Sprintf("%s is not a markdown page", slug)})
+		return
+	}
+	var previous *contentstuff.FrontmatterData
+	if existingPage && file.ParsedContent != nil && file.ParsedContent.Frontmatter != nil {
+		previous = file.ParsedContent.Frontmatter
+		// the created times are kept unless the request sets them
+		for _, key := range []string{"created", "created_time"} {
+			if value, ok := previous.GetValue(key); ok && !fm.HasKey(key) {
+				fm.SetValue(key, value)
+			}
+		}
+	}
+	if !existingPage {
+		file = contentstuff.FileDetail{FileName: slug + ".md", FileType: contentstuff.FileTypeMarkdown}
+	}
+
+	parsed.Frontmatter = fm
+	file.ParsedContent = parsed
+	stampEditTimes(fm, existingPage)
+	contentstuff.NormalizeFrontmatter(fm, previous)
+
+	if err := contentstuff.SaveFileDetail(s.SiteContent, &file); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("error saving file: %v", err)})
+		return
+	}
+
+	saved, ok := s.SiteContent.DoPath(file.FileName)
+	if !ok {
+		c.JSON(500, gin.H{"error": "saved post did not load"})
+		return
+	}
+	savedSlug := contentstuff.NewPageFromFileDetail(&saved).Slug()
+
+	status := http.StatusCreated
+	if existingPage {
+		status = http.StatusOK
+	}
+	log.Infof("Saved %s through the api", file.FileName)
+	c.JSON(status, gin.H{
+		"slug": savedSlug,
+		"url":  s.siteURL(c, savedSlug),
+	})
+}
+
+// validAPISlug cleans slug and rejects it unless every part is already a slug,
+// the api does not rename what a script asked for
+func (s *AdminApp) validAPISlug(slug string) (string, error) {
+	slug, err := cleanContentPath(slug)
+	if err != nil {
+		return "", fmt.Errorf("invalid slug: %v", err)
+	}
+	slug = strings.TrimSuffix(slug, ".md")
+	for _, part := range strings.Split(slug, "/") {
+		if part == "" || part == "." || slugify(part, s.slugStyle()) != part {
+			return "", fmt.Errorf("invalid slug %q, use lowercase letters, digits and hyphens", slug)
+		}
+	}
+	return slug, nil
+}
+
+// apiFrontmatter turns the JSON frontmatter object of a request into frontmatter data
+func apiFrontmatter(raw json.RawMessage) (*contentstuff.FrontmatterData, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		raw = []byte("{}")
+	}
+	if raw[0] != '{' {
+		return nil, fmt.Errorf("frontmatter must be a JSON object")
+	}
+	data, err := yaml.JSONToYAML(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontmatter: %v", err)
+	}
+	if bytes.Equal(bytes.TrimSpace(data), []byte("{}")) {
+		data = nil
+	}
+	fm, _, err := contentstuff.ExtractFrontmatter([]byte("---\n" + string(data) + "\n---\n"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontmatter: %v", err)
+	}
+	return fm, nil
+}
+
+// siteURL is the absolute url of slug on the site, the configured base url or else the request host
+func (s *AdminApp) siteURL(c *gin.Context, slug string) string {
+	base := strings.TrimSuffix(s.SiteContent.Config().Site.BaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/" + slug
+}
@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type DeleteRequest struct {
+	Slug string `json:"slug" binding:"required"`
+}
+
+type TrashRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// HandleDelete moves a page and its uploads to the trash, it can be restored until purged
+func (s *AdminApp) HandleDelete(c *gin.Context) {
+	var req DeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	req.Slug = strings.Trim(strings.TrimSpace(req.Slug), "/")
+	fd, exists := s.SiteContent.DoPath(req.Slug)
+	if !exists {
+		c.JSON(404, gin.H{"error": "page not found"})
+		return
+	}
+
+	entry, err := s.SiteContent.TrashContentFile(fd.FileName)
+	if err != nil {
+		log.Errorf("Failed to delete %s: %v", fd.FileName, err)
+		c.JSON(500, gin.H{"error": fmt.Sprintf("delete failed: %v", err)})
+		return
+	}
+
+	s.cleanupEmptyDirectories(filepath.Dir(filepath.Join(s.SiteContent.Config().Content.ContentDir, fd.FileName)))
+
+	log.Infof("Moved %s to trash as %s", fd.FileName, entry.ID)
+	c.JSON(200, gin.H{
+		"message": "Post moved to trash",
+		"entry":   entry,
+	})
+}
+
+// HandleTrashList lists the pages in the trash
+func (s *AdminApp) HandleTrashList(c *gin.Context) {
+	entries, err := s.SiteContent.ListTrash()
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to list trash: %v", err)})
+		return
+	}
+	c.JSON(200, gin.H{"entries": entries})
+}
+
+// HandleTrashRestore puts a trashed page back at its original slug
+func (s *AdminApp) HandleTrashRestore(c *gin.Context) {
+	var req TrashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	entry, ok := s.SiteContent.GetTrashEntry(req.ID)
+	if !ok {
+		c.JSON(404, gin.H{"error": "trash entry not found"})
+		return
+	}
+	if _, exists := s.SiteContent.DoPath(entry.FileName); exists {
+		c.JSON(409, gin.H{"error": "a page already exists at the original slug"})
+		return
+	}
+
+	entry, err := s.SiteContent.RestoreTrash(req.ID)
+	if err != nil {
+		log.Errorf("Failed to restore %s: %v", req.ID, err)
+		c.JSON(500, gin.H{"error": fmt.Sprintf("restore failed: %v", err)})
+		return
+	}
+
+	log.Infof("Restored %s from trash", entry.FileName)
+	c.JSON(200, gin.H{
+		"message": "Post restored",
+		"entry":   entry,
+	})
+}
+
+// HandleTrashPurge permanently removes a trashed page and its uploads
+func (s *AdminApp) HandleTrashPurge(c *gin.Context) {
+	var req TrashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	if _, ok := s.SiteContent.GetTrashEntry(req.ID); !ok {
+		c.JSON(404, gin.H{"error": "trash entry not found"})
+		return
+	}
+	if err := s.SiteContent.PurgeTrash(req.ID); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("purge failed: %v", err)})
+		return
+	}
+
+	log.Infof("Purged trash entry %s", req.ID)
+	c.JSON(200, gin.H{"success": true})
+}
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"html"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -38,21 +41,50 @@ type AdminApp struct {
 }
 
 func (s *AdminApp) RegisterRoutes(r *gin.Engine) {
+	// the admin pages also take an API token in place of the session cookie, the token's role
+	// decides what it can do, editors write content and admins also manage the site
+	editorGroup := r.Group("/admin")
+	editorGroup.Use(s.Authz.BearerAuth(), s.Authz.RequireAuth(), s.Authz.RequireRole(authz.RoleEditor))
+	editorGroup.GET("/edit", s.HandleAdminEditor)
+	editorGroup.Any("/edit-data", s.HandleEditPageData)
+	editorGroup.POST("/upload", s.HandleFileUpload)
+	editorGroup.GET("/uploads-list", s.HandleUploadsList)
+	editorGroup.POST("/upload-rename", s.HandleFileRename)
+	editorGroup.POST("/rename", s.HandleRename)
+	editorGroup.GET("/raw", s.HandleRawFile)
+	editorGroup.GET("/stats", s.HandleStats)
+	editorGroup.GET("/templates", s.HandleTemplateList)
+
 	adminGroup := r.Group("/admin")
-	adminGroup.Use(s.Authz.RequireAuth())
-	adminGroup.GET("/edit", s.HandleAdminEditor)
-	adminGroup.Any("/edit-data", s.HandleEditPageData)
-	adminGroup.POST("/upload", s.HandleFileUpload)
-	adminGroup.GET("/uploads-list", s.HandleUploadsList)
+	adminGroup.Use(s.Authz.BearerAuth(), s.Authz.RequireAuth(), s.Authz.RequireRole(authz.RoleAdmin))
 	adminGroup.POST("/upload-delete", s.HandleFileDelete)
-	adminGroup.POST("/upload-rename", s.HandleFileRename)
-	adminGroup.POST("/rename", s.HandleRename)
+	adminGroup.POST("/delete", s.HandleDelete)
+	adminGroup.GET("/trash", s.HandleTrashList)
+	adminGroup.POST("/trash/restore", s.HandleTrashRestore)
+	adminGroup.POST("/trash/purge", s.HandleTrashPurge)
+	adminGroup.POST("/replace", s.HandleReplace)
+	adminGroup.POST("/import", s.HandleImport)
+	adminGroup.GET("/export", s.HandleExport)
+
+	// tokens are only managed from a session, a token cannot issue or revoke tokens
+	tokenGroup := r.Group("/admin")
+	tokenGroup.Use(s.Authz.RequireAuth())
+	tokenGroup.GET("/tokens", s.HandleTokenList)
+	tokenGroup.POST("/tokens", s.HandleTokenCreate)
+	tokenGroup.POST("/tokens/revoke", s.HandleTokenRevoke)
+
+	// the integration api only takes API tokens
+	integrations := r.Group("/api")
+	integrations.Use(s.Authz.BearerAuth(), s.Authz.RequireToken(), s.Authz.RequireRole(authz.RoleEditor))
+	integrations.POST("/posts", s.HandleAPIPost)
 }
 
 type FileInfo struct {
 	Name string `json:"name"`
 	Type string `json:"type"`
 	Size int64  `json:"size"`
+	// URL is set when the file is not in the page's upload directory
+	URL string `json:"url,omitempty"`
 }
 
 func (s *AdminApp) HandleEditPageData(c *gin.Context) {
@@ -78,66 +110,52 @@ func (s *AdminApp) HandleEditPageData(c *gin.Context) {
 		targetFile := reqData.CurrentFile
 		file, existingPage := s.SiteContent.DoPath(targetFile)
 
-		parser := contentstuff.NewMarkdownParser(contentstuff.DefaultParserConfig())
+		parser := contentstuff.NewMarkdownParser(s.SiteContent.ParserConfig())
 		editedFile, err := parser.Parse([]byte(reqData.Content))
 		if err != nil {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("error parsing content: %v", err)})
 			return
 		}
 
-		var fm *contentstuff.FrontmatterData
-		if file.ParsedContent == nil || file.ParsedContent.Frontmatter == nil {
-			fm, _, err = contentstuff.ExtractFrontmatter([]byte("---\n" + reqData.Frontmatter + "\n---\n"))
-			if err != nil {
-				c.JSON(500, gin.H{"error": fmt.Sprintf("error parsing frontmatter: %v", err)})
-				return
-			}
-		} else {
-			fm = file.ParsedContent.Frontmatter
-			if err = fm.SetRaw([]byte(reqData.Frontmatter)); err != nil {
-				c.JSON(500, gin.H{"error": fmt.Sprintf("error updating frontmatter: %v", err)})
-				return
+		// the loaded page keeps its frontmatter until the new one is parsed and validated
+		var previous *contentstuff.FrontmatterData
+		delimiter := "---"
+		if file.ParsedContent != nil && file.ParsedContent.Frontmatter != nil {
+			previous = file.ParsedContent.Frontmatter
+			if previous.Type == contentstuff.FrontmatterTOML {
+				delimiter = "+++"
 			}
 		}
+		fm, _, err := contentstuff.ExtractFrontmatter([]byte(delimiter + "\n" + reqData.Frontmatter + "\n" + delimiter + "\n"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("error parsing frontmatter: %v", err)})
+			return
+		}
+
+		// only pages being saved are checked, existing files load regardless of the schema
+		if violations := contentstuff.ValidateFrontmatter(fm, s.SiteContent.Config().Frontmatter); len(violations) > 0 {
+			c.JSON(400, gin.H{"error": "frontmatter does not match the schema", "violations": violations})
+			return
+		}
 
 		editedFile.Frontmatter = fm
 		file.ParsedContent = editedFile
 
-		// set times
-		if !existingPage {
-			file.ParsedContent.Frontmatter.SetValue("created", time.Now().Unix())
-			file.ParsedContent.Frontmatter.SetValue("created_time", time.Now().Format("2006-01-02 15:04:05"))
-		}
-		if !file.ParsedContent.Frontmatter.HasKey("created") {
-			file.ParsedContent.Frontmatter.SetValue("created", time.Now().Unix())
-			file.ParsedContent.Frontmatter.SetValue("created_time", time.Now().Format("2006-01-02 15:04:05"))
-		}
-		file.ParsedContent.Frontmatter.SetValue("updated", time.Now().Unix())
-		file.ParsedContent.Frontmatter.SetValue("updated_time", time.Now().Format("2006-01-02 15:04:05"))
+		stampEditTimes(file.ParsedContent.Frontmatter, existingPage)
+		contentstuff.NormalizeFrontmatter(file.ParsedContent.Frontmatter, previous)
 
 		// if new file, generate filename from slug
 		if file.FileName == "" {
 			slugParts := SplitPath(reqData.FullSlug)
 			for i, part := range slugParts {
-				slugParts[i] = slugify(part)
+				slugParts[i] = slugify(part, s.slugStyle())
 			}
 			reqData.FullSlug = strings.Join(slugParts, "/")
 
-			slug := reqData.FullSlug
-			// ensure unique filename
-			originalSlug := slug
-			i := 1
-			for {
-				if _, exists := s.SiteContent.DoPath(slug); !exists {
-					break
-				}
-				slug = fmt.Sprintf("%s-%d", originalSlug, i)
-				i++
-			}
-			file.FileName = slug + ".md"
+			file.FileName = s.uniqueSlug(reqData.FullSlug) + ".md"
 		}
 
-		err = contentstuff.SaveFileDetail(s.SiteContent, s.WireController, &file)
+		err = contentstuff.SaveFileDetail(s.SiteContent, &file)
 		if err != nil {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("error saving file: %v", err)})
 			return
@@ -165,7 +183,7 @@ func (s *AdminApp) HandleEditPageData(c *gin.Context) {
 		//	}
 		//}
 
-		data, err := s.buildEditPageDataResponse(file.FileName)
+		data, err := s.buildEditPageDataResponse(file.FileName, "")
 		if err != nil {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("error building response: %v", err)})
 			return
@@ -187,6 +205,8 @@ func (s *AdminApp) HandleEditPageData(c *gin.Context) {
 				DiffText     string `json:"diffText,omitempty"`
 				DiffHTML     string `json:"diffHTML,omitempty"`
 				DeltaSummary string `json:"deltaSummary,omitempty"` // e.g. +10/-2
+				// Queries are the generated blocks the change touched
+				Queries []queryDiff `json:"queries,omitempty"`
 			}
 			var fullHistory []histReponse
 
@@ -217,16 +237,8 @@ func (s *AdminApp) HandleEditPageData(c *gin.Context) {
 				if inserts > 0 || deletes > 0 {
 					histItem := fullHistory[i]
 					histItem.DiffHTML = diffHTML
-
-					var insertClass = "summary-inserts"
-					var deleteClass = "summary-deletes"
-					if inserts == 0 {
-						insertClass = "summary-grey"
-					}
-					if deletes == 0 {
-						deleteClass = "summary-grey"
-					}
-					histItem.DeltaSummary = fmt.Sprintf(`<span class="%s">+%d</span> / <span class="%s">-%d</span>`, insertClass, inserts, deleteClass, deletes)
+					histItem.DeltaSummary = deltaSummaryHTML(inserts, deletes)
+					histItem.Queries = s.buildQueryDiffs(prev.Body, curr.Body)
 					historyResponse = append(historyResponse, histItem)
 				}
 			}
@@ -244,7 +256,7 @@ func (s *AdminApp) HandleEditPageData(c *gin.Context) {
 		}
 
 		// if path exists return its content
-		data, err := s.buildEditPageDataResponse(path)
+		data, err := s.buildEditPageDataResponse(path, c.Query("template"))
 		if err != nil {
 			c.JSON(404, gin.H{"error": err.Error()})
 			return
@@ -254,6 +266,72 @@ func (s *AdminApp) HandleEditPageData(c *gin.Context) {
 	}
 }
 
+// stampEditTimes sets the updated times, and the created times on a new page or one without them
+func stampEditTimes(fm *contentstuff.FrontmatterData, existingPage bool) {
+	now := time.Now()
+	if !existingPage || (!fm.HasKey("created") && !fm.HasKey("created_time")) {
+		fm.SetValue("created", now.Unix())
+		fm.SetValue("created_time", now.Format(contentstuff.FrontmatterTimeLayout))
+	}
+	fm.SetValue("updated", now.Unix())
+	fm.SetValue("updated_time", now.Format(contentstuff.FrontmatterTimeLayout))
+}
+
+// queryDiff shows how the generated block of a query changed between two versions of a page
+type queryDiff struct {
+	Query        string `json:"query"`
+	DiffHTML     string `json:"diffHTML"`
+	DeltaSummary string `json:"deltaSummary"`
+}
+
+// buildQueryDiffs diffs the generated blocks of the queries in prev and curr, a query is paired with
+// the one at the same position when it is the same query, only blocks that changed are returned
+func (s *AdminApp) buildQueryDiffs(prev, curr string) []queryDiff {
+	if s.WireController == nil {
+		return nil
+	}
+	before := s.WireController.QueryBlocks(prev)
+
+	var diffs []queryDiff
+	for i, block := range s.WireController.QueryBlocks(curr) {
+		var previous []string
+		if i < len(before) && before[i].Query.String() == block.Query.String() {
+			previous = before[i].Content
+		}
+		if slices.Equal(previous, block.Content) {
+			continue
+		}
+		diffHTML, inserts, deletes := buildDiffToDeltaHTML(blockText(previous), blockText(block.Content))
+		diffs = append(diffs, queryDiff{
+			Query:        block.Query.String(),
+			DiffHTML:     diffHTML,
+			DeltaSummary: deltaSummaryHTML(inserts, deletes),
+		})
+	}
+	return diffs
+}
+
+// blockText joins the lines of a query block the way they sit in the file
+func blockText(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// deltaSummaryHTML renders a +inserts / -deletes summary, a zero count is greyed out
+func deltaSummaryHTML(inserts, deletes int) string {
+	var insertClass = "summary-inserts"
+	var deleteClass = "summary-deletes"
+	if inserts == 0 {
+		insertClass = "summary-grey"
+	}
+	if deletes == 0 {
+		deleteClass = "summary-grey"
+	}
+	return fmt.Sprintf(`<span class="%s">+%d</span> / <span class="%s">-%d</span>`, insertClass, inserts, deleteClass, deletes)
+}
+
 func buildDiffToDeltaHTML(text1, text2 string) (string, int, int) {
 	var text bytes.Buffer
 	text.WriteString(`<div class="diff">`)
@@ -330,39 +408,29 @@ func buildBreadCrumbLinks(path string) []contentstuff.LinkData {
 	return links
 }
 
-func (s *AdminApp) buildEditPageDataResponse(path string) (editPageData, error) {
-	defaultFMRaw := `private: true
-`
+// buildEditPageDataResponse loads the page at path for the editor, a new page is pre-filled
+// from the named content template, or the default one when name is empty or unknown
+func (s *AdminApp) buildEditPageDataResponse(path string, template string) (editPageData, error) {
 	file, ok := s.SiteContent.DoPath(path)
 	if !ok {
 		path = strings.Trim(path, "/")
-		defaultResponse := editPageData{
+		frontmatter, content := s.newPageTemplate(path, template)
+		return editPageData{
 			FullSlug:        path,
 			BreadCrumbs:     buildBreadCrumbLinks(path),
 			NewPostHintSlug: s.createNewPostSlugHint(nil),
-			Frontmatter:     defaultFMRaw,
-			Content:         fmt.Sprintf("# %s\n\nwrite...", buildMaybeTitle(path)),
+			Frontmatter:     frontmatter,
+			Content:         content,
 			CurrentFile:     fmt.Sprintf("%s.md", strings.Trim(path, "/")),
-		}
-
-		if strings.HasSuffix(path, "index") || strings.HasSuffix(path, "index.md") {
-			if strings.Contains(path, "/") {
-				defaultResponse.Content = fmt.Sprintf(`
-# %s
-
-<!-- <query type="posts" sort="recent" path="%s/*"> -->
-<!-- </query> -->
-`, filepath.Dir(path), filepath.Dir(path))
-			}
-		}
-
-		return defaultResponse, nil
+		}, nil
 	}
 	if file.FileType != contentstuff.FileTypeMarkdown && file.FileType != contentstuff.FileTypeHTML {
 		return editPageData{}, fmt.Errorf("not editable file type")
 	}
 	pg := contentstuff.NewPageFromFileDetail(&file)
 
+	defaultFMRaw := `private: true
+`
 	if file.ParsedContent != nil && file.ParsedContent.Frontmatter != nil {
 		defaultFMRaw = string(file.ParsedContent.Frontmatter.Raw)
 	}
@@ -399,16 +467,20 @@ func (s *AdminApp) HandleAdminEditor(c *gin.Context) {
 		return
 	}
 
-	if slugifyWithSlash(path) != path {
+	if slugifyWithSlash(path, s.slugStyle()) != path {
 		//maybeTitle := buildMaybeTitle(newPath)
 		//_ = s.Authz.SetSessionData(c, "original_path", newPath)
 		//_ = s.Authz.SetSessionData(c, "maybe_title", maybeTitle)
 
-		c.Redirect(302, "/admin/edit?path="+slugifyWithSlash(path))
+		redirect := "/admin/edit?path=" + slugifyWithSlash(path, s.slugStyle())
+		if name := c.Query("template"); name != "" {
+			redirect += "&template=" + url.QueryEscape(name)
+		}
+		c.Redirect(302, redirect)
 		return
 	}
 
-	data, err := s.buildEditPageDataResponse(path)
+	data, err := s.buildEditPageDataResponse(path, c.Query("template"))
 	if err != nil {
 		log.Errorf("error building edit page data response: %v", err)
 	}
@@ -462,34 +534,6 @@ func (s *AdminApp) createNewPostSlugHint(path *contentstuff.Page) string {
 	return hintSlug
 }
 
-func slugify(s string) string {
-	// convert to lowercase
-	s = strings.ToLower(s)
-	// replace spaces with hyphens
-	s = strings.ReplaceAll(s, " ", "-")
-	// remove all non-alphanumeric and non-hyphen characters
-	s = regexp.MustCompile(`[^a-z0-9\-]`).ReplaceAllString(s, "")
-	// replace multiple hyphens with a single hyphen
-	s = regexp.MustCompile(`-+`).ReplaceAllString(s, "-")
-	// trim leading and trailing hyphens
-	s = strings.Trim(s, "-")
-	return s
-}
-
-func slugifyWithSlash(s string) string {
-	// convert to lowercase
-	s = strings.ToLower(s)
-	// replace spaces with hyphens
-	s = strings.ReplaceAll(s, " ", "-")
-	// remove all non-alphanumeric and non-hyphen characters
-	s = regexp.MustCompile(`[^a-z0-9\-/]`).ReplaceAllString(s, "")
-	// replace multiple hyphens with a single hyphen
-	s = regexp.MustCompile(`-+`).ReplaceAllString(s, "-")
-	// trim leading and trailing hyphens
-	s = strings.Trim(s, "-")
-	return s
-}
-
 // SplitPath returns path components as a slice, OS-aware.
 func SplitPath(path string) []string {
 	var parts []string
@@ -523,6 +567,8 @@ func (s *AdminApp) HandleFileRename(c *gin.Context) {
 		FullSlug    string `json:"fullSlug"`
 		OldFilename string `json:"oldFilename"`
 		NewFilename string `json:"newFilename"`
+		// URL is the /uploads/ url the list gave the file, set for the date and hashed layouts
+		URL string `json:"url"`
 	}
 
 	if err := c.BindJSON(&req); err != nil {
@@ -530,17 +576,21 @@ func (s *AdminApp) HandleFileRename(c *gin.Context) {
 		return
 	}
 
-	if req.FullSlug == "" || req.OldFilename == "" || req.NewFilename == "" {
-		c.JSON(400, gin.H{"error": "fullSlug, oldFilename, and newFilename are required"})
+	if req.NewFilename == "" {
+		c.JSON(400, gin.H{"error": "newFilename is required"})
 		return
 	}
 
 	// Security: ensure filenames don't contain path traversal
-	if strings.Contains(req.OldFilename, "..") || strings.Contains(req.OldFilename, "/") || strings.Contains(req.OldFilename, "\\") ||
-		strings.Contains(req.NewFilename, "..") || strings.Contains(req.NewFilename, "/") || strings.Contains(req.NewFilename, "\\") {
+	if strings.Contains(req.NewFilename, "..") || strings.Contains(req.NewFilename, "/") || strings.Contains(req.NewFilename, "\\") {
 		c.JSON(400, gin.H{"error": "invalid filename"})
 		return
 	}
+	oldFilePath, err := s.uploadFilePath(req.FullSlug, req.OldFilename, req.URL)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Validate filename is not empty after trimming
 	req.NewFilename = strings.TrimSpace(req.NewFilename)
@@ -549,8 +599,7 @@ func (s *AdminApp) HandleFileRename(c *gin.Context) {
 		return
 	}
 
-	oldFilePath := filepath.Join(s.SiteContent.Config().Content.UploadDir, req.FullSlug, req.OldFilename)
-	newFilePath := filepath.Join(s.SiteContent.Config().Content.UploadDir, req.FullSlug, req.NewFilename)
+	newFilePath := filepath.Join(filepath.Dir(oldFilePath), req.NewFilename)
 
 	// Check if old file exists
 	if _, err := os.Stat(oldFilePath); os.IsNotExist(err) {
@@ -571,5 +620,9 @@ func (s *AdminApp) HandleFileRename(c *gin.Context) {
 	}
 
 	log.Infof("Renamed file: %s -> %s", oldFilePath, newFilePath)
-	c.JSON(200, gin.H{"success": true, "newFilename": req.NewFilename})
+	resp := gin.H{"success": true, "newFilename": req.NewFilename}
+	if req.URL != "" {
+		resp["url"] = path.Join(path.Dir(req.URL), req.NewFilename)
+	}
+	c.JSON(200, resp)
 }
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleExport streams a zip of the content dir, private pages included, and with uploads=true
+// the upload dir under uploads/, the layout /admin/import takes back
+func (s *AdminApp) HandleExport(c *gin.Context) {
+	cfg := s.SiteContent.Config().Content
+	withUploads := c.Query("uploads") == "true" && cfg.UploadDir != ""
+
+	fileName := fmt.Sprintf("oddity-export-%s.zip", time.Now().Format("2006-01-02-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Status(200)
+
+	zw := zip.NewWriter(c.Writer)
+	err := addDirToZip(zw, cfg.ContentDir, "")
+	if err == nil && withUploads {
+		err = addDirToZip(zw, cfg.UploadDir, "uploads")
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		// the headers are gone, all that is left is to cut the download short
+		log.Errorf("Export failed: %v", err)
+		c.Abort()
+		return
+	}
+	log.Infof("Exported content as %s", fileName)
+}
+
+// addDirToZip adds the regular files under dir to zw, named by their path below prefix
+func addDirToZip(zw *zip.Writer, dir string, prefix string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(prefix, filepath.ToSlash(rel))
+		header.Method = zip.Deflate
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
@@ -0,0 +1,184 @@
+package admin
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"oddity/pkg/contentstuff"
+)
+
+// maxImportSize caps the uncompressed size of an imported archive
+const maxImportSize = 512 << 20
+
+// importEntry is a file of an imported archive and where it is extracted to
+type importEntry struct {
+	file    *zip.File
+	name    string // path inside the archive
+	target  string
+	content bool // a page rather than an upload
+}
+
+// HandleImport extracts an uploaded zip of pages and assets, .md and .html files into the content dir
+// and everything else into the upload dir (an uploads/ prefix is dropped), then reloads the content.
+// Files that already exist are reported with a 409 and nothing is written, unless overwrite=true
+func (s *AdminApp) HandleImport(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "a zip file is required"})
+		return
+	}
+	overwrite := c.PostForm("overwrite") == "true" || c.Query("overwrite") == "true"
+
+	upload, err := header.Open()
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read upload: %v", err)})
+		return
+	}
+	defer upload.Close()
+
+	archive, err := zip.NewReader(upload, header.Size)
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid zip file: %v", err)})
+		return
+	}
+
+	entries, err := s.importEntries(archive)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(400, gin.H{"error": "the zip file has no files to import"})
+		return
+	}
+
+	var conflicts []string
+	for _, entry := range entries {
+		if _, err := os.Stat(entry.target); err == nil {
+			conflicts = append(conflicts, entry.name)
+		}
+	}
+	if len(conflicts) > 0 && !overwrite {
+		c.JSON(409, gin.H{"error": "files already exist, import again with overwrite=true to replace them", "conflicts": conflicts})
+		return
+	}
+
+	var imported []string
+	for _, entry := range entries {
+		if err := extractImportEntry(entry); err != nil {
+			log.Errorf("Failed to import %s: %v", entry.name, err)
+			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to import %s: %v", entry.name, err), "imported": imported})
+			return
+		}
+		imported = append(imported, entry.name)
+	}
+
+	if err := s.SiteContent.ReloadContent(); err != nil {
+		log.Errorf("Failed to reload content after import: %v", err)
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to reload content: %v", err)})
+		return
+	}
+	contentDir := s.SiteContent.Config().Content.ContentDir
+	for _, entry := range entries {
+		if !entry.content {
+			continue
+		}
+		fileName, _ := filepath.Rel(contentDir, entry.target)
+		err := s.SiteContent.Events().Publish(contentstuff.ContentEvent{Type: contentstuff.ContentCreated, FileName: filepath.ToSlash(fileName)})
+		if err != nil {
+			log.Errorf("Failed to handle import of %s: %v", fileName, err)
+		}
+	}
+
+	log.Infof("Imported %d files, %d replaced", len(imported), len(conflicts))
+	c.JSON(200, gin.H{
+		"message":  "Import complete",
+		"imported": imported,
+		"replaced": conflicts,
+	})
+}
+
+// importEntries maps the files of archive to their targets, an entry that would land
+// outside the content or upload dir fails the whole import
+func (s *AdminApp) importEntries(archive *zip.Reader) ([]importEntry, error) {
+	cfg := s.SiteContent.Config().Content
+
+	var entries []importEntry
+	var total uint64
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name := strings.ReplaceAll(file.Name, "\\", "/")
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("invalid path in zip file: %s", file.Name)
+		}
+		name = path.Clean(name)
+		if hiddenImportPath(name) {
+			continue
+		}
+
+		total += file.UncompressedSize64
+		if total > maxImportSize {
+			return nil, fmt.Errorf("the zip file is larger than %d MB uncompressed", maxImportSize>>20)
+		}
+
+		entry := importEntry{file: file, name: name}
+		switch ext := strings.ToLower(path.Ext(name)); {
+		case ext == ".md" || ext == ".html":
+			entry.content = true
+			entry.target = filepath.Join(cfg.ContentDir, filepath.FromSlash(name))
+		case cfg.UploadDir != "":
+			entry.target = filepath.Join(cfg.UploadDir, filepath.FromSlash(strings.TrimPrefix(name, "uploads/")))
+		default:
+			return nil, fmt.Errorf("no upload dir is configured for %s", name)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries, nil
+}
+
+// hiddenImportPath reports whether name is in or is a dot file, or macOS archive metadata
+func hiddenImportPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractImportEntry writes an archive file to its target, reading no more than it claims to hold
+func extractImportEntry(entry importEntry) error {
+	if err := os.MkdirAll(filepath.Dir(entry.target), 0755); err != nil {
+		return err
+	}
+	src, err := entry.file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(entry.target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, io.LimitReader(src, int64(entry.file.UncompressedSize64))); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
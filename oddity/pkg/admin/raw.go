@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/contentstuff"
+)
+
+// HandleRawFile returns the exact on-disk bytes of a content file, frontmatter included
+func (s *AdminApp) HandleRawFile(c *gin.Context) {
+	path, err := cleanContentPath(c.Query("path"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, ok := s.SiteContent.DoPath(path)
+	if !ok || file.FileType == contentstuff.FileTypeDirectory {
+		c.JSON(404, gin.H{"error": "file not found"})
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.SiteContent.Config().Content.ContentDir, file.FileName))
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read file: %v", err)})
+		return
+	}
+
+	contentType := "text/markdown; charset=utf-8"
+	if file.FileType == contentstuff.FileTypeHTML {
+		contentType = "text/html; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// cleanContentPath normalizes a content relative path and rejects anything escaping the content dir
+func cleanContentPath(path string) (string, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "/")
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if filepath.IsAbs(path) || strings.Contains(path, "\\") {
+		return "", fmt.Errorf("invalid path")
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid path")
+		}
+	}
+	return strings.Trim(filepath.Clean(path), "/"), nil
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+
+	"oddity/pkg/contentstuff"
 )
 
 type RenameRequest struct {
@@ -57,9 +59,9 @@ func (s *AdminApp) HandleRename(c *gin.Context) {
 		return
 	}
 
-	// Check if target uploads directory already exists
+	// Check if target uploads directory already exists, only uploads stored per page move with it
 	newUploadsDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, req.NewSlug)
-	if _, err := os.Stat(newUploadsDir); !os.IsNotExist(err) {
+	if _, err := os.Stat(newUploadsDir); s.SiteContent.Config().Content.UploadsPerPage() && !os.IsNotExist(err) {
 		c.JSON(409, gin.H{"error": "target uploads directory already exists"})
 		return
 	}
@@ -78,6 +80,15 @@ func (s *AdminApp) HandleRename(c *gin.Context) {
 		return
 	}
 
+	err = s.SiteContent.Events().Publish(contentstuff.ContentEvent{
+		Type:        contentstuff.ContentRenamed,
+		FileName:    req.NewSlug + ".md",
+		OldFileName: req.OldSlug + ".md",
+	})
+	if err != nil {
+		log.Errorf("Failed to handle rename of %s to %s: %v", req.OldSlug, req.NewSlug, err)
+	}
+
 	log.Infof("Successfully renamed %s to %s", req.OldSlug, req.NewSlug)
 	c.JSON(200, gin.H{
 		"message": "Post renamed successfully",
@@ -101,11 +112,12 @@ func (s *AdminApp) performRename(oldSlug, newSlug string) error {
 		return fmt.Errorf("failed to move markdown file: %v", err)
 	}
 
-	// Move uploads directory if it exists
+	// Move uploads directory if it exists, uploads stored by date or hash stay where they are
+	uploadsPerPage := s.SiteContent.Config().Content.UploadsPerPage()
 	oldUploadsDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, oldSlug)
 	newUploadsDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, newSlug)
 
-	if _, err := os.Stat(oldUploadsDir); err == nil {
+	if _, err := os.Stat(oldUploadsDir); uploadsPerPage && err == nil {
 		// Create target uploads parent directory if needed
 		newUploadsParent := filepath.Dir(newUploadsDir)
 		if err := os.MkdirAll(newUploadsParent, 0755); err != nil {
@@ -137,16 +149,18 @@ func (s *AdminApp) performRename(oldSlug, newSlug string) error {
 	}
 
 	// Update image paths in the markdown content
-	if err := s.updateContentPaths(newFilePath, oldSlug, newSlug); err != nil {
-		log.Errorf("Warning: failed to update content paths: %v", err)
-		// Don't fail the operation - the rename was successful
+	if uploadsPerPage {
+		if err := s.updateContentPaths(newFilePath, oldSlug, newSlug); err != nil {
+			log.Errorf("Warning: failed to update content paths: %v", err)
+			// Don't fail the operation - the rename was successful
+		}
 	}
 
 	// Update database history records
 	if err := s.updateHistoryRecords(oldSlug, newSlug); err != nil {
 		// Try to rollback file and directory moves
 		os.Rename(newFilePath, oldFilePath)
-		if _, err := os.Stat(newUploadsDir); err == nil {
+		if _, err := os.Stat(newUploadsDir); uploadsPerPage && err == nil {
 			os.Rename(newUploadsDir, oldUploadsDir)
 		}
 		return fmt.Errorf("failed to update history records: %v", err)
@@ -154,7 +168,9 @@ func (s *AdminApp) performRename(oldSlug, newSlug string) error {
 
 	// Clean up empty directories in the old path
 	s.cleanupEmptyDirectories(filepath.Dir(oldFilePath))
-	s.cleanupEmptyDirectories(filepath.Dir(oldUploadsDir))
+	if uploadsPerPage {
+		s.cleanupEmptyDirectories(filepath.Dir(oldUploadsDir))
+	}
 
 	return nil
 }
@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"oddity/pkg/contentstuff"
+)
+
+type ReplaceRequest struct {
+	Find     string `json:"find" binding:"required"`
+	Replace  string `json:"replace"`
+	PathGlob string `json:"pathGlob"`
+	Regex    bool   `json:"regex"`
+	DryRun   bool   `json:"dryRun"`
+}
+
+type ReplaceResult struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
+	Error string `json:"error,omitempty"`
+}
+
+// HandleReplace runs a find-and-replace across content files matching pathGlob
+// every changed file is re-parsed before it is saved, dry runs only report counts
+func (s *AdminApp) HandleReplace(c *gin.Context) {
+	var req ReplaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	replaceFn, err := replacerFor(req)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	glob := strings.TrimPrefix(strings.TrimSpace(req.PathGlob), "/")
+	if glob != "" {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("invalid path glob: %v", err)})
+			return
+		}
+	}
+
+	files := s.SiteContent.AllFiles()
+	sort.Slice(files, func(i, j int) bool { return files[i].FileName < files[j].FileName })
+
+	contentDir := s.SiteContent.Config().Content.ContentDir
+
+	results := []ReplaceResult{}
+	total := 0
+	for _, file := range files {
+		if file.FileType != contentstuff.FileTypeMarkdown && file.FileType != contentstuff.FileTypeHTML {
+			continue
+		}
+		if glob != "" {
+			if ok, _ := filepath.Match(glob, file.FileName); !ok {
+				continue
+			}
+		}
+
+		data, err := os.ReadFile(filepath.Join(contentDir, file.FileName))
+		if err != nil {
+			results = append(results, ReplaceResult{File: file.FileName, Error: fmt.Sprintf("failed to read file: %v", err)})
+			continue
+		}
+
+		updated, count := replaceFn(string(data))
+		if count == 0 {
+			continue
+		}
+
+		result := ReplaceResult{File: file.FileName, Count: count}
+		if !req.DryRun {
+			if err := s.saveReplaced(file, updated); err != nil {
+				log.Errorf("replace failed for %s: %v", file.FileName, err)
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+		}
+		total += count
+		results = append(results, result)
+	}
+
+	c.JSON(200, gin.H{
+		"files":  results,
+		"total":  total,
+		"dryRun": req.DryRun,
+	})
+}
+
+// saveReplaced re-parses markdown before writing it back so a broken replacement is never saved
+func (s *AdminApp) saveReplaced(file contentstuff.FileDetail, content string) error {
+	if file.FileType == contentstuff.FileTypeMarkdown {
+		parser := contentstuff.NewMarkdownParser(s.SiteContent.ParserConfig())
+		if _, err := parser.Parse([]byte(content)); err != nil {
+			return fmt.Errorf("failed to parse replaced content: %v", err)
+		}
+	}
+	return contentstuff.SaveRawContent(s.SiteContent, file.FileName, content)
+}
+
+// replacerFor returns a function applying the requested replacement and reporting how many matches it changed
+func replacerFor(req ReplaceRequest) (func(string) (string, int), error) {
+	if !req.Regex {
+		return func(text string) (string, int) {
+			count := strings.Count(text, req.Find)
+			if count == 0 {
+				return text, 0
+			}
+			return strings.ReplaceAll(text, req.Find, req.Replace), count
+		}, nil
+	}
+
+	re, err := regexp.Compile(req.Find)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return func(text string) (string, int) {
+		count := len(re.FindAllStringIndex(text, -1))
+		if count == 0 {
+			return text, 0
+		}
+		return re.ReplaceAllString(text, req.Replace), count
+	}, nil
+}
@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	SlugTransliterate = "transliterate"
+	SlugUnicode       = "unicode"
+)
+
+// transliterations covers letters that do not decompose into an ASCII letter plus accents
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ł': "l", 'ı': "i", 'ŋ': "ng", 'ħ': "h",
+}
+
+var multipleHyphens = regexp.MustCompile(`-+`)
+
+// slugStyle is the configured slug style, transliterate unless set to unicode
+func (s *AdminApp) slugStyle() string {
+	if s.SiteContent == nil || s.SiteContent.Config() == nil {
+		return SlugTransliterate
+	}
+	if s.SiteContent.Config().Content.SlugStyle == SlugUnicode {
+		return SlugUnicode
+	}
+	return SlugTransliterate
+}
+
+func slugify(s string, style string) string {
+	return slugifyWith(s, style, false)
+}
+
+func slugifyWithSlash(s string, style string) string {
+	return slugifyWith(s, style, true)
+}
+
+// slugifyWith lowercases s, turns spaces into hyphens and drops everything but letters, digits and hyphens
+// (and slashes when keepSlash is set), the transliterate style first maps accented letters to ASCII
+func slugifyWith(s string, style string, keepSlash bool) string {
+	s = strings.ToLower(s)
+	if style == SlugUnicode {
+		s = norm.NFC.String(s)
+	} else {
+		s = transliterate(s)
+	}
+	s = strings.ReplaceAll(s, " ", "-")
+
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '-' || (keepSlash && r == '/'):
+			return r
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			return r
+		case style == SlugUnicode && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)):
+			return r
+		}
+		return -1
+	}, s)
+
+	// replace multiple hyphens with a single hyphen
+	s = multipleHyphens.ReplaceAllString(s, "-")
+	// trim leading and trailing hyphens
+	return strings.Trim(s, "-")
+}
+
+// transliterate maps accented and other latin letters to their closest ASCII spelling, é becomes e
+func transliterate(s string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if ascii, ok := transliterations[r]; ok {
+			sb.WriteString(ascii)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// uniqueSlug appends -1, -2, ... to slug until no page is served at it, no loaded file is named
+// <slug>.md and nothing is on disk at that path, a page with a custom slug can own the file name
+func (s *AdminApp) uniqueSlug(slug string) string {
+	candidate := slug
+	for i := 1; s.slugTaken(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%d", slug, i)
+	}
+	return candidate
+}
+
+func (s *AdminApp) slugTaken(slug string) bool {
+	if _, exists := s.SiteContent.DoPath(slug); exists {
+		return true
+	}
+	if _, exists := s.SiteContent.DoPath(slug + ".md"); exists {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(s.SiteContent.Config().Content.ContentDir, slug+".md"))
+	return !os.IsNotExist(err)
+}
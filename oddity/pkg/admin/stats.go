@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"math"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/contentstuff"
+)
+
+// ContentStats summarises the markdown content of the site
+// drafts are private or `draft: true` pages and are not counted as posts or pages
+type ContentStats struct {
+	Posts              int            `json:"posts"`
+	Pages              int            `json:"pages"`
+	Drafts             int            `json:"drafts"`
+	TotalWords         int            `json:"totalWords"`
+	Tags               map[string]int `json:"tags"`
+	PostsPerMonth      map[string]int `json:"postsPerMonth"`
+	AverageReadingTime float64        `json:"averageReadingTime"`
+}
+
+// HandleStats returns content statistics for the admin dashboard
+func (s *AdminApp) HandleStats(c *gin.Context) {
+	c.JSON(200, s.contentStats())
+}
+
+func (s *AdminApp) contentStats() ContentStats {
+	stats := ContentStats{
+		Tags:          map[string]int{},
+		PostsPerMonth: map[string]int{},
+	}
+
+	totalReadingTime := 0
+	documents := 0
+	for _, fd := range s.SiteContent.AllFiles() {
+		if fd.FileType != contentstuff.FileTypeMarkdown || fd.ParsedContent == nil || contentstuff.IsSectionMeta(fd) {
+			continue
+		}
+		pg := contentstuff.NewPageFromFileDetail(&fd)
+
+		documents++
+		stats.TotalWords += contentstuff.ExtractWordCount(fd.ParsedContent.Body)
+		totalReadingTime += contentstuff.ExtractReadingTime(fd.ParsedContent.Body)
+		for _, tag := range pg.Hashtags() {
+			stats.Tags[tag]++
+		}
+
+		isDraft := contentstuff.IsPrivate(s.SiteContent, fd)
+		if fd.ParsedContent.Frontmatter != nil && fd.ParsedContent.Frontmatter.GetBool("draft") {
+			isDraft = true
+		}
+
+		switch {
+		case isDraft:
+			stats.Drafts++
+		case filepath.Base(fd.FileName) == "index.md":
+			stats.Pages++
+		default:
+			stats.Posts++
+			if created := pg.DateCreated(); created != nil {
+				stats.PostsPerMonth[created.Format("2006-01")]++
+			}
+		}
+	}
+
+	if documents > 0 {
+		stats.AverageReadingTime = math.Round(float64(totalReadingTime)/float64(documents)*10) / 10
+	}
+	return stats
+}
@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"oddity/pkg/contentstuff"
+)
+
+const defaultPostTemplate = `---
+private: true
+---
+# {{title}}
+
+write...`
+
+const defaultIndexTemplate = `---
+private: true
+---
+
+# {{dir}}
+
+<!-- <query type="posts" sort="recent" path="{{dir}}/*"> -->
+<!-- </query> -->
+`
+
+// TemplateInfo describes a named content template for the editor's picker
+type TemplateInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+var templateFrontmatter = regexp.MustCompile(`(?s)^---\s*\n(.*?)\n---\s*\n`)
+
+// templateDescriptionKey labels a named template in the picker, pages made from the template leave it out
+const templateDescriptionKey = "template_description"
+
+var templateDescriptionLine = regexp.MustCompile(`(?m)^` + templateDescriptionKey + `:.*\n`)
+
+// newPageTemplate returns the frontmatter and body the editor opens a new page at path with
+// the named template wins when it exists, otherwise the configured or built-in default is used
+func (s *AdminApp) newPageTemplate(path string, name string) (string, string) {
+	tmpl := s.SiteContent.Config().Templates
+	if name != "" {
+		if tpl, ok := s.readNamedTemplate(name); ok {
+			return splitTemplate(expandTemplate(tpl, path))
+		}
+		log.Warnf("unknown content template %q, using the default", name)
+	}
+
+	isIndex := filepath.Base(strings.TrimSuffix(path, ".md")) == "index"
+
+	tpl := tmpl.Post
+	if tpl == "" {
+		tpl = defaultPostTemplate
+	}
+	if isIndex {
+		if tmpl.Index != "" {
+			tpl = tmpl.Index
+		} else if strings.Contains(path, "/") {
+			// a top level index has no directory to list
+			tpl = defaultIndexTemplate
+		}
+	}
+	return splitTemplate(expandTemplate(tpl, path))
+}
+
+// readNamedTemplate reads <name>.md from the templates dir
+func (s *AdminApp) readNamedTemplate(name string) (string, bool) {
+	dir := s.SiteContent.Config().Templates.Dir
+	if dir == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, name+".md"))
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// expandTemplate fills in the placeholders of a new page template and drops its picker label
+func expandTemplate(tpl string, path string) string {
+	filled := fillTemplate(tpl, path)
+	loc := templateFrontmatter.FindStringSubmatchIndex(filled)
+	if loc == nil {
+		return filled
+	}
+	fm := templateDescriptionLine.ReplaceAllString(filled[loc[2]:loc[3]]+"\n", "")
+	if strings.TrimSpace(fm) == "" {
+		return filled[loc[1]:]
+	}
+	return "---\n" + fm + "---\n" + filled[loc[1]:]
+}
+
+// fillTemplate replaces the placeholders of a template
+func fillTemplate(tpl string, path string) string {
+	slug := strings.TrimSuffix(path, ".md")
+	return strings.NewReplacer(
+		"{{title}}", buildMaybeTitle(slug),
+		"{{date}}", time.Now().Format("2006-01-02"),
+		"{{slug}}", slug,
+		"{{dir}}", filepath.Dir(slug),
+	).Replace(tpl)
+}
+
+// splitTemplate separates the YAML frontmatter of a template from its body
+func splitTemplate(tpl string) (string, string) {
+	m := templateFrontmatter.FindStringSubmatch(tpl)
+	if m == nil {
+		return "", tpl
+	}
+	return m[1] + "\n", tpl[len(m[0]):]
+}
+
+// listTemplates returns the named templates in the templates dir sorted by name,
+// the description comes from the template_description key of each template's frontmatter
+func (s *AdminApp) listTemplates() ([]TemplateInfo, error) {
+	dir := s.SiteContent.Config().Templates.Dir
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading templates dir: %v", err)
+	}
+
+	var templates []TemplateInfo
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".md")
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		info := TemplateInfo{Name: name}
+		if tpl, ok := s.readNamedTemplate(name); ok {
+			fm, _, err := contentstuff.ExtractFrontmatter([]byte(fillTemplate(tpl, name)))
+			if err != nil {
+				log.Warnf("error parsing frontmatter of template %s: %v", name, err)
+			}
+			info.Description, _ = fm.GetString(templateDescriptionKey)
+		}
+		templates = append(templates, info)
+	}
+	return templates, nil
+}
+
+// HandleTemplateList lists the named content templates new pages can be opened with
+func (s *AdminApp) HandleTemplateList(c *gin.Context) {
+	templates, err := s.listTemplates()
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to list templates: %v", err)})
+		return
+	}
+	c.JSON(200, gin.H{"templates": templates})
+}
@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"oddity/pkg/authz"
+)
+
+type TokenRequest struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role"`
+}
+
+type RevokeTokenRequest struct {
+	ID uint `json:"id" binding:"required"`
+}
+
+// HandleTokenList lists the signed in user's API tokens, revoked ones included, admins see every token
+func (s *AdminApp) HandleTokenList(c *gin.Context) {
+	user, ok := authz.GetCurrentUser(c)
+	if !ok {
+		c.JSON(401, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	tokens, err := s.Authz.ListTokens(user)
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to list tokens: %v", err)})
+		return
+	}
+	c.JSON(200, gin.H{"tokens": tokens})
+}
+
+// HandleTokenCreate issues an API token for the signed in user, the token is only in this response
+func (s *AdminApp) HandleTokenCreate(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	user, ok := authz.GetCurrentUser(c)
+	if !ok {
+		c.JSON(401, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	token, apiToken, err := s.Authz.IssueToken(user, req.Name, req.Role)
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("failed to issue token: %v", err)})
+		return
+	}
+
+	log.Infof("Issued %s API token %q for %s", apiToken.Role, apiToken.Name, user.Username)
+	c.JSON(200, gin.H{
+		"message": "Token issued, it will not be shown again",
+		"token":   token,
+		"entry":   apiToken,
+	})
+}
+
+// HandleTokenRevoke revokes one of the signed in user's API tokens, admins can revoke any token
+func (s *AdminApp) HandleTokenRevoke(c *gin.Context) {
+	var req RevokeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	user, ok := authz.GetCurrentUser(c)
+	if !ok {
+		c.JSON(401, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	if err := s.Authz.RevokeToken(user, req.ID); err != nil {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("revoke failed: %v", err)})
+		return
+	}
+
+	log.Infof("Revoked API token %d", req.ID)
+	c.JSON(200, gin.H{"message": "Token revoked"})
+}
@@ -1,19 +1,28 @@
 package admin
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"image"
 	"io"
 	"io/fs"
 	"mime/multipart"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+
+	"oddity/pkg/config"
 )
 
 func (s *AdminApp) HandleFileUpload(c *gin.Context) {
@@ -35,8 +44,9 @@ func (s *AdminApp) HandleFileUpload(c *gin.Context) {
 		return
 	}
 
-	// Create post-specific upload directory
-	uploadDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, fullSlug)
+	// Create the upload directory, per post unless another layout is configured
+	subDir := s.uploadSubDir(fullSlug, time.Now())
+	uploadDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, subDir)
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to create upload directory: %v", err)})
 		return
@@ -57,20 +67,66 @@ func (s *AdminApp) HandleFileUpload(c *gin.Context) {
 		}
 	}
 
+	// Images can be converted to a modern format, per upload or by default from the config
+	format := strings.ToLower(c.DefaultPostForm("format", s.SiteContent.Config().Content.UploadFormat))
+	if format != "" && format != "webp" && format != "avif" {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("unsupported format %q, use webp or avif", format)})
+		return
+	}
+	quality := s.SiteContent.Config().Content.UploadQuality
+	if q, err := strconv.Atoi(c.PostForm("quality")); err == nil && q > 0 && q <= 100 {
+		quality = q
+	}
+	if quality <= 0 || quality > 100 {
+		quality = config.DefaultUploadQuality
+	}
+
+	// Images can also get resized copies for a responsive srcset
+	withSrcset := s.SiteContent.Config().Content.Srcset
+	if v, err := strconv.ParseBool(c.PostForm("srcset")); err == nil {
+		withSrcset = v
+	}
+	srcsetWidths := s.SiteContent.Config().Content.SrcsetWidths
+	if len(srcsetWidths) == 0 {
+		srcsetWidths = config.DefaultSrcsetWidths
+	}
+
 	var uploadedFiles []string
+	srcsets := make(map[string]string)
 	for _, fileHeader := range files {
 		filename := filepath.Base(fileHeader.Filename)
-		targetPath := filepath.Join(uploadDir, filename)
+		if s.SiteContent.Config().Content.UploadLayout == config.UploadLayoutHashed {
+			hashed, err := hashedUploadName(fileHeader)
+			if err != nil {
+				c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read %s: %v", filename, err)})
+				return
+			}
+			filename = hashed
+		}
 
 		// Check if this is an image and should be processed
 		isImage := isImageFile(filename)
 		ext := strings.ToLower(filepath.Ext(filename))
 		isHeif := ext == ".heic" || ext == ".heif"
-		shouldProcess := isImage && ((resizeWidth > 0 || resizeHeight > 0) || isHeif)
+		// animated gifs and vector images are kept as they are
+		convert := format != "" && isImage && ext != ".gif" && ext != ".svg" && ext != "."+format
+		shouldProcess := isImage && ((resizeWidth > 0 || resizeHeight > 0) || isHeif || convert)
+
+		finalFilename := filename
+		if convert {
+			finalFilename = strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + format
+		} else if isHeif {
+			finalFilename = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".jpg"
+		}
+		if s.SiteContent.Config().Content.UploadLayout == config.UploadLayoutDate {
+			// every post of the month shares the directory, a name already taken gets a number
+			finalFilename = uniqueUploadName(uploadDir, finalFilename)
+		}
+		targetPath := filepath.Join(uploadDir, finalFilename)
 
 		if shouldProcess {
 			// Process and resize image
-			if err := s.processAndSaveImage(fileHeader, targetPath, resizeWidth, resizeHeight); err != nil {
+			if err := s.processAndSaveImage(fileHeader, targetPath, resizeWidth, resizeHeight, quality); err != nil {
 				c.JSON(500, gin.H{"error": fmt.Sprintf("failed to process image %s: %v", filename, err)})
 				return
 			}
@@ -82,15 +138,128 @@ func (s *AdminApp) HandleFileUpload(c *gin.Context) {
 			}
 		}
 
-		finalFilename := filename
-		if isHeif {
-			finalFilename = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".jpg"
-		}
-		uploadedFiles = append(uploadedFiles, fmt.Sprintf("/uploads/%s/%s", fullSlug, finalFilename))
+		fileURL := "/uploads/" + path.Join(filepath.ToSlash(subDir), finalFilename)
+		uploadedFiles = append(uploadedFiles, fileURL)
 		logrus.Infof("Uploaded file: %s (processed: %v)", targetPath, shouldProcess)
+
+		if withSrcset && isImage && ext != ".gif" && ext != ".svg" {
+			srcset, err := s.saveSrcsetVariants(fileHeader, targetPath, fileURL, srcsetWidths, resizeWidth, quality)
+			if err != nil {
+				c.JSON(500, gin.H{"error": fmt.Sprintf("failed to resize image %s: %v", filename, err)})
+				return
+			}
+			if srcset != "" {
+				srcsets[fileURL] = srcset
+			}
+		}
+	}
+
+	response := gin.H{"uploaded": uploadedFiles}
+	if len(srcsets) > 0 {
+		response["srcsets"] = srcsets
+	}
+	c.JSON(200, response)
+}
+
+// saveSrcsetVariants writes a copy of the uploaded image next to targetPath for each width narrower
+// than the image (and than maxWidth if set) as <name>-<width>w.<ext>, and returns their srcset
+func (s *AdminApp) saveSrcsetVariants(fileHeader *multipart.FileHeader, targetPath, fileURL string, widths []int, maxWidth, quality int) (string, error) {
+	if width, ok := uploadedImageWidth(fileHeader); ok && (maxWidth <= 0 || width < maxWidth) {
+		maxWidth = width
+	}
+
+	sorted := append([]int(nil), widths...)
+	sort.Ints(sorted)
+
+	ext := filepath.Ext(targetPath)
+	var candidates []string
+	for _, width := range sorted {
+		if width <= 0 || (maxWidth > 0 && width >= maxWidth) {
+			continue
+		}
+		suffix := fmt.Sprintf("-%dw", width)
+		variantPath := strings.TrimSuffix(targetPath, ext) + suffix + ext
+		if err := s.processAndSaveImage(fileHeader, variantPath, width, 0, quality); err != nil {
+			return "", err
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %dw", strings.TrimSuffix(fileURL, ext)+suffix+ext, width))
+	}
+	return strings.Join(candidates, ", "), nil
+}
+
+// uploadedImageWidth reads the width of an uploaded image without decoding it, HEIF is not supported
+func uploadedImageWidth(fileHeader *multipart.FileHeader) (int, bool) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return 0, false
+	}
+	defer src.Close()
+
+	cfg, _, err := image.DecodeConfig(src)
+	if err != nil {
+		return 0, false
+	}
+	return cfg.Width, true
+}
+
+// uploadSubDir is the directory below the upload dir an upload for fullSlug made at now is stored in
+func (s *AdminApp) uploadSubDir(fullSlug string, now time.Time) string {
+	switch s.SiteContent.Config().Content.UploadLayout {
+	case config.UploadLayoutDate:
+		return filepath.Join(now.Format("2006"), now.Format("01"))
+	case config.UploadLayoutHashed:
+		return ""
+	}
+	return fullSlug
+}
+
+// uploadFilePath is where an upload is stored, from its /uploads/ url when the list gave one,
+// otherwise from the page's upload dir and the file name
+func (s *AdminApp) uploadFilePath(fullSlug, filename, url string) (string, error) {
+	uploadDir := s.SiteContent.Config().Content.UploadDir
+	if url != "" {
+		rel := filepath.FromSlash(strings.TrimPrefix(url, "/uploads/"))
+		if !strings.HasPrefix(url, "/uploads/") || !filepath.IsLocal(rel) {
+			return "", fmt.Errorf("invalid upload url")
+		}
+		return filepath.Join(uploadDir, rel), nil
+	}
+
+	if fullSlug == "" || filename == "" {
+		return "", fmt.Errorf("fullSlug and filename are required")
 	}
+	// Security: ensure filename doesn't contain path traversal
+	if strings.Contains(filename, "..") || strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
+		return "", fmt.Errorf("invalid filename")
+	}
+	return filepath.Join(uploadDir, fullSlug, filename), nil
+}
+
+// uniqueUploadName appends -1, -2, ... to name until nothing is stored under it in dir
+func uniqueUploadName(dir, name string) string {
+	ext := filepath.Ext(name)
+	candidate := name
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+}
 
-	c.JSON(200, gin.H{"uploaded": uploadedFiles})
+// hashedUploadName names an upload after the hash of its content, identical uploads share a file
+func hashedUploadName(fileHeader *multipart.FileHeader) (string, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16] + strings.ToLower(filepath.Ext(fileHeader.Filename)), nil
 }
 
 func (s *AdminApp) HandleUploadsList(c *gin.Context) {
@@ -100,6 +269,11 @@ func (s *AdminApp) HandleUploadsList(c *gin.Context) {
 		return
 	}
 
+	if !s.SiteContent.Config().Content.UploadsPerPage() {
+		c.JSON(200, gin.H{"files": s.pageUploads(fullSlug)})
+		return
+	}
+
 	uploadDir := filepath.Join(s.SiteContent.Config().Content.UploadDir, fullSlug)
 
 	// Check if directory exists
@@ -144,10 +318,45 @@ func (s *AdminApp) HandleUploadsList(c *gin.Context) {
 	c.JSON(200, gin.H{"files": files})
 }
 
+// uploadLinkPattern finds links into the upload dir in a page's markdown
+var uploadLinkPattern = regexp.MustCompile(`/uploads/([^\s"'()<>\[\]?#]+)`)
+
+// pageUploads lists the uploads a page links to, the date and hashed layouts keep no directory per page
+func (s *AdminApp) pageUploads(fullSlug string) []FileInfo {
+	files := []FileInfo{}
+	fd, ok := s.SiteContent.DoPath(fullSlug)
+	if !ok || fd.ParsedContent == nil {
+		return files
+	}
+
+	uploadDir := s.SiteContent.Config().Content.UploadDir
+	seen := make(map[string]bool)
+	for _, match := range uploadLinkPattern.FindAllStringSubmatch(string(fd.ParsedContent.Body), -1) {
+		name := match[1]
+		if seen[name] || !filepath.IsLocal(filepath.FromSlash(name)) {
+			continue
+		}
+		seen[name] = true
+
+		info, err := os.Stat(filepath.Join(uploadDir, filepath.FromSlash(name)))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		fileType := "file"
+		if strings.HasPrefix(getContentType(name), "image/") {
+			fileType = "image"
+		}
+		files = append(files, FileInfo{Name: path.Base(name), Type: fileType, Size: info.Size(), URL: "/uploads/" + name})
+	}
+	return files
+}
+
 func (s *AdminApp) HandleFileDelete(c *gin.Context) {
 	type reqStruct struct {
 		FullSlug string `json:"fullSlug"`
 		Filename string `json:"filename"`
+		// URL is the /uploads/ url the list gave the file, set for the date and hashed layouts
+		URL string `json:"url"`
 	}
 
 	var req reqStruct
@@ -157,19 +366,12 @@ func (s *AdminApp) HandleFileDelete(c *gin.Context) {
 		return
 	}
 
-	if req.FullSlug == "" || req.Filename == "" {
-		c.JSON(400, gin.H{"error": "fullSlug and filename are required"})
-		return
-	}
-
-	// Security: ensure filename doesn't contain path traversal
-	if strings.Contains(req.Filename, "..") || strings.Contains(req.Filename, "/") || strings.Contains(req.Filename, "\\") {
-		c.JSON(400, gin.H{"error": "invalid filename"})
+	filePath, err := s.uploadFilePath(req.FullSlug, req.Filename, req.URL)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	filePath := filepath.Join(s.SiteContent.Config().Content.UploadDir, req.FullSlug, req.Filename)
-
 	if err := os.Remove(filePath); err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
@@ -196,6 +398,8 @@ func getContentType(filename string) string {
 		return "image/svg+xml"
 	case ".webp":
 		return "image/webp"
+	case ".avif":
+		return "image/avif"
 	case ".heic", ".heif":
 		return "image/heif"
 	default:
@@ -207,11 +411,12 @@ func isImageFile(filename string) bool {
 	return strings.HasPrefix(getContentType(filename), "image/")
 }
 
-func (s *AdminApp) processAndSaveImage(fileHeader *multipart.FileHeader, targetPath string, width, height int) error {
+// processAndSaveImage resizes an uploaded image and saves it in the format of targetPath's extension
+func (s *AdminApp) processAndSaveImage(fileHeader *multipart.FileHeader, targetPath string, width, height, quality int) error {
 	// Check if this is a HEIF file that needs conversion
 	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
 	if ext == ".heic" || ext == ".heif" {
-		return s.processHeifImage(fileHeader, targetPath, width, height)
+		return s.processHeifImage(fileHeader, targetPath, width, height, quality)
 	}
 
 	// Open uploaded file
@@ -241,11 +446,40 @@ func (s *AdminApp) processAndSaveImage(fileHeader *multipart.FileHeader, targetP
 		}
 	}
 
+	// imaging cannot encode webp or avif, hand a lossless png to ImageMagick instead
+	targetExt := strings.ToLower(filepath.Ext(targetPath))
+	if targetExt == ".webp" || targetExt == ".avif" {
+		tmpFile, err := os.CreateTemp("", "upload_*.png")
+		if err != nil {
+			return fmt.Errorf("failed to create temp file: %v", err)
+		}
+		tmpFile.Close()
+		defer os.Remove(tmpFile.Name())
+
+		if err := imaging.Save(img, tmpFile.Name()); err != nil {
+			return fmt.Errorf("failed to save intermediate image: %v", err)
+		}
+		if err := runMagick(tmpFile.Name(), "-quality", strconv.Itoa(quality), targetPath); err != nil {
+			return err
+		}
+		logrus.Infof("Converted %s to %s", fileHeader.Filename, targetExt)
+		return nil
+	}
+
 	// Save the processed image (imaging.Save automatically detects format from extension)
 	return imaging.Save(img, targetPath)
 }
 
-func (s *AdminApp) processHeifImage(fileHeader *multipart.FileHeader, targetPath string, width, height int) error {
+// runMagick runs ImageMagick, the output format follows the extension of the last argument
+var runMagick = func(args ...string) error {
+	cmd := exec.Command("magick", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ImageMagick conversion failed: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+func (s *AdminApp) processHeifImage(fileHeader *multipart.FileHeader, targetPath string, width, height, quality int) error {
 	// Create temporary file for HEIF input
 	tmpFile, err := os.CreateTemp("", "heif_*.heic")
 	if err != nil {
@@ -279,15 +513,16 @@ func (s *AdminApp) processHeifImage(fileHeader *multipart.FileHeader, targetPath
 		}
 	}
 
-	// Change extension to jpg for output
-	jpgPath := strings.TrimSuffix(targetPath, filepath.Ext(targetPath)) + ".jpg"
-	args = append(args, jpgPath)
+	// targetPath already has the output extension, jpg unless converting to webp or avif
+	if ext := strings.ToLower(filepath.Ext(targetPath)); ext == ".webp" || ext == ".avif" {
+		args = append(args, "-quality", strconv.Itoa(quality))
+	}
+	args = append(args, targetPath)
 
-	cmd := exec.Command("magick", args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("ImageMagick conversion failed: %v, output: %s", err, string(output))
+	if err := runMagick(args...); err != nil {
+		return err
 	}
 
-	logrus.Infof("Converted HEIF to JPEG: %s -> %s", fileHeader.Filename, jpgPath)
+	logrus.Infof("Converted HEIF: %s -> %s", fileHeader.Filename, targetPath)
 	return nil
 }
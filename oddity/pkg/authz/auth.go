@@ -46,10 +46,11 @@ func (a *AuthzApp) RegisterRoutes(r *gin.Engine) {
 		authGroup.POST("/logout", a.HandleLogout)
 		authGroup.POST("/change-password", a.HandleChangePassword)
 	}
+	r.POST("/theme", a.HandleTheme)
 }
 
 func (a *AuthzApp) Init() {
-	err := a.SiteContent.DB().AutoMigrate(&User{}, &UserSession{})
+	err := a.SiteContent.DB().AutoMigrate(&User{}, &UserSession{}, &APIToken{})
 	if err != nil {
 		log.Fatalf("Failed to migrate authz models: %v", err)
 	}
@@ -98,7 +99,7 @@ func (a *AuthzApp) CreateDefaultAdmin() error {
 		Username:     "admin",
 		Email:        "admin@localhost",
 		PasswordHash: hash,
-		Role:         "admin",
+		Role:         RoleAdmin,
 	}
 
 	return a.SiteContent.DB().Create(&user).Error
@@ -0,0 +1,66 @@
+package authz
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+
+	// themeKey is the session data key and the cookie name the theme is kept under
+	themeKey = "theme"
+	// themeCookieMaxAge keeps the theme of visitors without a session for a year
+	themeCookieMaxAge = 365 * 24 * 60 * 60
+)
+
+func validTheme(theme string) bool {
+	return theme == ThemeLight || theme == ThemeDark
+}
+
+// Theme returns the theme picked for this request, from the session of a signed in user
+// or the theme cookie otherwise, empty when none was picked
+func Theme(c *gin.Context) string {
+	if value, ok := c.Get("session"); ok {
+		if session, ok := value.(*UserSession); ok && session.CustomData != "" {
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(session.CustomData), &data); err == nil {
+				if theme, ok := data[themeKey].(string); ok && validTheme(theme) {
+					return theme
+				}
+			}
+		}
+	}
+	if theme, err := c.Cookie(themeKey); err == nil && validTheme(theme) {
+		return theme
+	}
+	return ""
+}
+
+// HandleTheme sets the theme to the posted theme value, or toggles between light and dark without one
+func (a *AuthzApp) HandleTheme(c *gin.Context) {
+	theme := c.PostForm("theme")
+	switch {
+	case theme == "" && Theme(c) == ThemeDark:
+		theme = ThemeLight
+	case theme == "":
+		theme = ThemeDark
+	case !validTheme(theme):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Theme must be light or dark"})
+		return
+	}
+
+	if IsAuthenticated(c) {
+		if err := a.SetSessionData(c, themeKey, theme); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save theme"})
+			return
+		}
+	} else {
+		c.SetCookie(themeKey, theme, themeCookieMaxAge, "/", "", false, false)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "theme": theme})
+}
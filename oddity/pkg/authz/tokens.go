@@ -0,0 +1,186 @@
+package authz
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+
+	// apiTokenPrefix marks issued tokens so they are easy to spot in scripts and logs
+	apiTokenPrefix = "odt_"
+)
+
+// APIToken lets scripts call the admin api with an Authorization: Bearer header,
+// only a hash of the token is stored, the token itself is shown once when issued
+type APIToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Hint       string     `json:"hint"` // last characters of the token
+	Role       string     `json:"role"`
+	UserID     uint       `gorm:"index" json:"userId"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueToken creates a token acting as user with role, only admins can issue admin tokens,
+// it returns the token, which is not stored and cannot be shown again
+func (a *AuthzApp) IssueToken(user *User, name string, role string) (string, *APIToken, error) {
+	if role == "" {
+		role = RoleEditor
+	}
+	if role != RoleAdmin && role != RoleEditor {
+		return "", nil, fmt.Errorf("unknown role %q", role)
+	}
+	if role == RoleAdmin && user.Role != RoleAdmin {
+		return "", nil, fmt.Errorf("only admins can issue admin tokens")
+	}
+
+	secret, err := a.GenerateSessionToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token := apiTokenPrefix + secret
+
+	apiToken := APIToken{
+		Name:      name,
+		TokenHash: hashToken(token),
+		Hint:      token[len(token)-4:],
+		Role:      role,
+		UserID:    user.ID,
+	}
+	if err := a.SiteContent.DB().Create(&apiToken).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &apiToken, nil
+}
+
+// ListTokens returns the tokens user can see, newest first, admins see every issued token
+func (a *AuthzApp) ListTokens(user *User) ([]APIToken, error) {
+	var tokens []APIToken
+	query := a.SiteContent.DB().Order("id desc")
+	if !HasRole(user, RoleAdmin) {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	err := query.Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeToken stops a token from authenticating, it is kept for the record,
+// users can only revoke their own tokens unless they are an admin
+func (a *AuthzApp) RevokeToken(user *User, id uint) error {
+	query := a.SiteContent.DB().Model(&APIToken{}).Where("id = ? AND revoked_at IS NULL", id)
+	if !HasRole(user, RoleAdmin) {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("token not found: %d", id)
+	}
+	return nil
+}
+
+// GetTokenByValue finds the unrevoked token matching token
+func (a *AuthzApp) GetTokenByValue(token string) (*APIToken, error) {
+	var apiToken APIToken
+	err := a.SiteContent.DB().Preload("User").
+		Where("token_hash = ? AND revoked_at IS NULL", hashToken(token)).
+		First(&apiToken).Error
+	if err != nil {
+		return nil, err
+	}
+	return &apiToken, nil
+}
+
+// BearerAuth authenticates requests carrying an Authorization: Bearer token in place of the session cookie,
+// the request acts as the token's user with the token's role capped at the user's role, a bad token is rejected outright
+func (a *AuthzApp) BearerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.Next()
+			return
+		}
+
+		apiToken, err := a.GetTokenByValue(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API token"})
+			c.Abort()
+			return
+		}
+
+		// the token's user may have been removed since it was issued
+		user := apiToken.User
+		if user.ID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API token"})
+			c.Abort()
+			return
+		}
+
+		a.SiteContent.DB().Model(apiToken).Update("last_used_at", time.Now())
+
+		// a token never grants more than its user currently has
+		if HasRole(&user, apiToken.Role) {
+			user.Role = apiToken.Role
+		}
+		c.Set("authenticated_user", &user)
+		c.Set("api_token", apiToken)
+		c.Next()
+	}
+}
+
+// RequireToken only lets through requests authenticated by BearerAuth, not by a session cookie
+func (a *AuthzApp) RequireToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_token"); !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API token required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasRole reports whether user has one of roles, an admin has every role
+func HasRole(user *User, roles ...string) bool {
+	return user.Role == RoleAdmin || slices.Contains(roles, user.Role)
+}
+
+// RequireRole only lets through users with one of roles, for a request made with an API token
+// that is the token's role, so an editor token cannot reach admin routes
+func (a *AuthzApp) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+		if !HasRole(user, roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("%s role required", strings.Join(roles, " or "))})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
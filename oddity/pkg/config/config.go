@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	toml "github.com/pelletier/go-toml/v2"
 
@@ -12,9 +13,14 @@ import (
 )
 
 type Config struct {
-	Content ContentConfig `toml:"content"`
-	Site    SiteConfig    `toml:"site"`
-	Admin   SiteConfig    `toml:"admin,omitempty"` // admin overrides
+	Content  ContentConfig  `toml:"content"`
+	Site     SiteConfig     `toml:"site"`
+	Admin    SiteConfig     `toml:"admin,omitempty"` // admin overrides
+	Markdown MarkdownConfig `toml:"markdown,omitempty"`
+	// Frontmatter is checked when a page is saved from the editor
+	Frontmatter FrontmatterSchema `toml:"frontmatter,omitempty"`
+	Feed        FeedConfig        `toml:"feed,omitempty"`
+	Templates   TemplatesConfig   `toml:"templates,omitempty"`
 
 	filePath string
 }
@@ -55,6 +61,10 @@ func LoadConfigTOML(path string) (Config, error) {
 		return Config{}, fmt.Errorf("failed to unmarshal config file: %w", err)
 	}
 
+	if err := cfg.Content.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config file: %w", err)
+	}
+
 	cfg.filePath = path
 
 	return cfg, nil
@@ -84,6 +94,9 @@ func (c Config) GetSiteConfig(isAdmin bool) SiteConfig {
 		if c.Admin.Author != "" {
 			siteConfig.Author = c.Admin.Author
 		}
+		if c.Admin.DefaultAuthor != "" {
+			siteConfig.DefaultAuthor = c.Admin.DefaultAuthor
+		}
 		if c.Admin.AuthorEmail != "" {
 			siteConfig.AuthorEmail = c.Admin.AuthorEmail
 		}
@@ -107,6 +120,59 @@ type ContentConfig struct {
 	Addr       string   `toml:"addr"`
 	SidecarDB  string   `toml:"sidecar_db"`
 	AdminAddr  string   `toml:"admin_addr,omitempty"`
+	// AutoIndex lists the posts of a directory without an index file for public visitors
+	AutoIndex bool `toml:"auto_index,omitempty"`
+	// AliasRedirect answers frontmatter aliases with a 301 to the canonical slug instead of serving the page
+	AliasRedirect bool `toml:"alias_redirect,omitempty"`
+	// ServeMarkdown returns the page markdown to clients that send Accept: text/markdown
+	ServeMarkdown bool `toml:"serve_markdown,omitempty"`
+	// QueryDefaultLimit applies to queries without a limit, QueryMaxLimit clamps any query; 0 disables either
+	QueryDefaultLimit int `toml:"query_default_limit,omitempty"`
+	QueryMaxLimit     int `toml:"query_max_limit,omitempty"`
+	// IgnoreGlobs excludes content paths from loading, e.g. "_drafts" or "*.tmpl.md"
+	IgnoreGlobs []string `toml:"ignore_globs,omitempty"`
+	// FollowSymlinks indexes content inside symlinked directories, links that loop back are skipped
+	FollowSymlinks bool `toml:"follow_symlinks,omitempty"`
+	// SlugStyle is how new slugs are made from titles, "transliterate" (default) turns "Café" into "cafe",
+	// "unicode" keeps non-ASCII letters as they are
+	SlugStyle string `toml:"slug_style,omitempty"`
+	// UploadLayout is where uploads are stored under UploadDir, see the UploadLayout* values
+	UploadLayout string `toml:"upload_layout,omitempty"`
+	// UploadFormat converts uploaded images to "webp" or "avif" with ImageMagick, empty keeps their format
+	UploadFormat string `toml:"upload_format,omitempty"`
+	// UploadQuality is the quality of converted images from 1 to 100, 0 uses DefaultUploadQuality
+	UploadQuality int `toml:"upload_quality,omitempty"`
+	// Srcset gives image uploads resized copies for a responsive srcset, an upload can override it
+	// SrcsetWidths are the widths of those copies, empty uses DefaultSrcsetWidths
+	Srcset       bool  `toml:"srcset,omitempty"`
+	SrcsetWidths []int `toml:"srcset_widths,omitempty"`
+}
+
+const DefaultUploadQuality = 80
+
+var DefaultSrcsetWidths = []int{480, 960, 1440}
+
+const (
+	// UploadLayoutSlug stores uploads in <slug>/, they move along with the page (default)
+	UploadLayoutSlug = "slug"
+	// UploadLayoutDate stores uploads in <year>/<month>/ of the upload date
+	UploadLayoutDate = "date"
+	// UploadLayoutHashed stores uploads flat, named by a hash of their content
+	UploadLayoutHashed = "hashed"
+)
+
+// Validate rejects settings outside their fixed set of values
+func (cc ContentConfig) Validate() error {
+	switch cc.UploadLayout {
+	case "", UploadLayoutSlug, UploadLayoutDate, UploadLayoutHashed:
+		return nil
+	}
+	return fmt.Errorf("unknown upload_layout %q, use %s, %s or %s", cc.UploadLayout, UploadLayoutSlug, UploadLayoutDate, UploadLayoutHashed)
+}
+
+// UploadsPerPage reports whether uploads live in a directory of their page and so follow it on rename and delete
+func (cc ContentConfig) UploadsPerPage() bool {
+	return cc.UploadLayout == "" || cc.UploadLayout == UploadLayoutSlug
 }
 
 type SiteConfig struct {
@@ -117,6 +183,185 @@ type SiteConfig struct {
 	AuthorEmail    string           `toml:"author_email,omitempty"`
 	Author         string           `toml:"author"`
 	DefaultNewHint string           `toml:"default_new_hint,omitempty"`
+	// Comments renders a comments container for third-party comment widgets on posts
+	Comments bool `toml:"comments,omitempty"`
+	// DateFormat is the Go time layout used to display dates, defaults to 2006-01-02
+	DateFormat string `toml:"date_format,omitempty"`
+	// RelativeDates displays rendered dates as "3 days ago" instead of DateFormat
+	RelativeDates bool `toml:"relative_dates,omitempty"`
+	// DefaultAuthor is credited on posts without an author in their frontmatter
+	DefaultAuthor string `toml:"default_author,omitempty"`
+	// PostNavigation links each post to the previous and next post of its directory by date
+	PostNavigation bool `toml:"post_navigation,omitempty"`
+	// UpdatedThreshold is how long after creation an edit must be for a page to show as updated,
+	// a Go duration like "72h", empty uses DefaultUpdatedThreshold
+	UpdatedThreshold string `toml:"updated_threshold,omitempty"`
+}
+
+// DefaultUpdatedThreshold keeps saves made on the day a page was written from showing as updates
+const DefaultUpdatedThreshold = 24 * time.Hour
+
+// UpdatedAfter is the parsed UpdatedThreshold, DefaultUpdatedThreshold when unset or invalid
+func (sc SiteConfig) UpdatedAfter() time.Duration {
+	if threshold, err := time.ParseDuration(sc.UpdatedThreshold); err == nil && threshold >= 0 {
+		return threshold
+	}
+	return DefaultUpdatedThreshold
+}
+
+// PostAuthor is who a post without its own author is credited to: DefaultAuthor, then Author
+func (sc SiteConfig) PostAuthor() string {
+	if sc.DefaultAuthor != "" {
+		return sc.DefaultAuthor
+	}
+	return sc.Author
+}
+
+// DefaultDateFormat is used when no date_format is configured
+const DefaultDateFormat = "2006-01-02"
+
+// FormatDate formats t with the configured date layout
+// use it for dates written into content, which should never go stale
+func (sc SiteConfig) FormatDate(t time.Time) string {
+	layout := sc.DateFormat
+	if layout == "" {
+		layout = DefaultDateFormat
+	}
+	return t.Format(layout)
+}
+
+// DisplayDate formats t for rendered pages, relative to now when RelativeDates is set
+func (sc SiteConfig) DisplayDate(t time.Time) string {
+	if sc.RelativeDates {
+		return RelativeDate(t, time.Now())
+	}
+	return sc.FormatDate(t)
+}
+
+// RelativeDate describes t relative to now, e.g. "3 days ago"
+func RelativeDate(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	if d < 0 {
+		return "just now"
+	}
+
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, unit := range units {
+		if n := int(d / unit.size); n >= 1 {
+			if n == 1 {
+				return fmt.Sprintf("1 %s ago", unit.name)
+			}
+			return fmt.Sprintf("%d %ss ago", n, unit.name)
+		}
+	}
+	return "just now"
+}
+
+// MarkdownConfig controls how content markdown is rendered
+type MarkdownConfig struct {
+	// ExternalLinkRel adds rel="noopener noreferrer" to links pointing off-site
+	ExternalLinkRel bool `toml:"external_link_rel,omitempty"`
+	// ExternalLinkNewTab also opens off-site links with target="_blank"
+	ExternalLinkNewTab bool `toml:"external_link_new_tab,omitempty"`
+	// Emoji converts :shortcode: emoji while rendering
+	Emoji bool `toml:"emoji,omitempty"`
+	// EmojiImageBaseURL renders emoji as <img> from this base url (e.g. a twemoji svg dir) instead of unicode
+	EmojiImageBaseURL string `toml:"emoji_image_base_url,omitempty"`
+	// WikiLinks resolves [[target]] to a page by slug, title or filename, empty links to the target as written
+	WikiLinks string `toml:"wiki_links,omitempty"`
+	// AssetVersions appends ?v=<hash> of the file's modtime and size to images under /uploads/
+	// so browsers fetch them again once they change
+	AssetVersions bool `toml:"asset_versions,omitempty"`
+	// DisableAutolinks leaves bare urls as text
+	DisableAutolinks bool `toml:"disable_autolinks,omitempty"`
+	// HandleLinkBase links bare @handle to this url followed by the handle, e.g. https://github.com/
+	HandleLinkBase string `toml:"handle_link_base,omitempty"`
+	// IssueLinkBase links bare #123 to this url followed by the number, e.g. https://github.com/owner/repo/issues/
+	IssueLinkBase string `toml:"issue_link_base,omitempty"`
+}
+
+const (
+	// WikiLinksSlug links [[blog/post]] to the page at that slug
+	WikiLinksSlug = "slug"
+	// WikiLinksTitle links [[My Page Title]] to the page with that title, then falls back to the slug
+	WikiLinksTitle = "title"
+	// WikiLinksFilename links [[post]] or [[post.md]] to the page in that file, in any directory
+	WikiLinksFilename = "filename"
+)
+
+// TemplatesConfig is what the editor pre-fills new pages with
+// templates are whole pages, frontmatter included, where {{title}}, {{date}}, {{slug}} and {{dir}} are replaced
+type TemplatesConfig struct {
+	// Post is used for new pages and Index for new index pages, empty keeps the built-in ones
+	Post  string `toml:"post,omitempty"`
+	Index string `toml:"index,omitempty"`
+	// Dir holds named templates as <name>.md, picked with /admin/edit?path=...&template=<name>,
+	// a template_description frontmatter key labels the template in the picker and is left out of new pages
+	Dir string `toml:"dir,omitempty"`
+}
+
+// DefaultFeedLimit is how many items a feed carries when no limit is configured
+const DefaultFeedLimit = 20
+
+// FeedConfig controls the items of the page feeds and feed.json (main) and of /tags/<tag>.xml feeds (tag)
+type FeedConfig struct {
+	// MainLimit and TagLimit cap the number of items, 0 uses DefaultFeedLimit
+	MainLimit int `toml:"main_limit,omitempty"`
+	TagLimit  int `toml:"tag_limit,omitempty"`
+	// MainFullContent and TagFullContent put the whole post in each item instead of an excerpt, unset means true
+	MainFullContent *bool `toml:"main_full_content,omitempty"`
+	TagFullContent  *bool `toml:"tag_full_content,omitempty"`
+}
+
+// FeedOptions is what a single feed is built with
+type FeedOptions struct {
+	Limit       int
+	FullContent bool
+}
+
+// Main returns the options of page feeds and feed.json
+func (fc FeedConfig) Main() FeedOptions {
+	return feedOptions(fc.MainLimit, fc.MainFullContent)
+}
+
+// Tag returns the options of per-tag feeds
+func (fc FeedConfig) Tag() FeedOptions {
+	return feedOptions(fc.TagLimit, fc.TagFullContent)
+}
+
+func feedOptions(limit int, fullContent *bool) FeedOptions {
+	opts := FeedOptions{Limit: limit, FullContent: true}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultFeedLimit
+	}
+	if fullContent != nil {
+		opts.FullContent = *fullContent
+	}
+	return opts
+}
+
+// FrontmatterSchema describes the frontmatter a saved page must have, an empty schema accepts anything
+type FrontmatterSchema struct {
+	// Required keys must be present and not empty
+	Required []string `toml:"required,omitempty"`
+	// Fields constrains the type and allowed values of keys when they are present
+	Fields map[string]FrontmatterField `toml:"fields,omitempty"`
+}
+
+type FrontmatterField struct {
+	// Type is one of string, bool, number, list or date
+	Type string `toml:"type,omitempty"`
+	// Allowed limits the value, or every item of a list, to these values
+	Allowed []string `toml:"allowed,omitempty"`
 }
 
 type NavigationLink struct {
@@ -133,6 +378,9 @@ var DefaultConfig = ContentConfig{
 	SidecarDB:  "sqlite.db",
 	ThemeDir:   "tmpl",
 	Addr:       "0.0.0.0:8081",
+
+	QueryDefaultLimit: 50,
+	QueryMaxLimit:     500,
 }
 
 var DefaultSiteConfig = SiteConfig{
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigRejectsUnknownUploadLayout(t *testing.T) {
+	dir := t.TempDir()
+	for layout, valid := range map[string]bool{"": true, "slug": true, "date": true, "hashed": true, "monthly": false} {
+		path := filepath.Join(dir, "config.toml")
+		body := "[content]\ncontent_dir = \"content\"\nupload_layout = \"" + layout + "\"\n"
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadConfigTOML(path)
+		if valid && err != nil {
+			t.Errorf("expected upload_layout %q to load, got %v", layout, err)
+		}
+		if !valid && (err == nil || !strings.Contains(err.Error(), "upload_layout")) {
+			t.Errorf("expected upload_layout %q to be rejected, got %v", layout, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package contentstuff
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// uploadsURLPrefix is where the upload dir is served
+const uploadsURLPrefix = "/uploads/"
+
+// applyAssetVersions appends a ?v= version to the local images of doc and returns
+// the version each local url got, empty when the versioner did not know it
+func (mp *MarkdownParser) applyAssetVersions(doc ast.Node) map[string]string {
+	versions := make(map[string]string)
+	record := func(src string) (string, bool) {
+		v, ok := mp.config.AssetVersion(src)
+		versions[src] = v
+		return v, ok
+	}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		img, ok := node.(*ast.Image)
+		if !entering || !ok {
+			return ast.GoToNext
+		}
+		img.Destination = []byte(versionedURL(string(img.Destination), record))
+		return ast.GoToNext
+	})
+	return versions
+}
+
+// assetsStale reports whether an image pc shows has a different version than when it was parsed
+func (pc *ParsedContent) assetsStale(version func(string) (string, bool)) bool {
+	for src, parsed := range pc.AssetVersions {
+		if current, _ := version(src); current != parsed {
+			return true
+		}
+	}
+	return false
+}
+
+// versionedURL adds v=<version> to src when it is a local url the versioner knows,
+// remote urls and urls that already carry a version are returned unchanged
+func versionedURL(src string, version func(string) (string, bool)) string {
+	u, err := url.Parse(src)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Query().Has("v") {
+		return src
+	}
+	v, ok := version(u.Path)
+	if !ok {
+		return src
+	}
+	sep := "?"
+	if u.RawQuery != "" {
+		sep = "&"
+	}
+	if i := strings.IndexByte(src, '#'); i >= 0 {
+		return src[:i] + sep + "v=" + v + src[i:]
+	}
+	return src + sep + "v=" + v
+}
+
+// uploadVersioner versions /uploads/ urls by a short hash of the modtime and size of the file in uploadDir
+func uploadVersioner(uploadDir string) func(string) (string, bool) {
+	return func(src string) (string, bool) {
+		if !strings.HasPrefix(src, uploadsURLPrefix) {
+			return "", false
+		}
+		rel := filepath.FromSlash(strings.TrimPrefix(src, uploadsURLPrefix))
+		if !filepath.IsLocal(rel) {
+			return "", false
+		}
+		info, err := os.Stat(filepath.Join(uploadDir, rel))
+		if err != nil || info.IsDir() {
+			return "", false
+		}
+		sum := sha1.Sum([]byte(fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())))
+		return hex.EncodeToString(sum[:4]), true
+	}
+}
@@ -0,0 +1,140 @@
+package contentstuff
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+var (
+	// handlePattern matches @handle, a trailing dot or hyphen ends the sentence rather than the handle
+	handlePattern = regexp.MustCompile(`@([A-Za-z0-9_](?:[A-Za-z0-9_.-]*[A-Za-z0-9_])?)`)
+	// issuePattern matches #123
+	issuePattern = regexp.MustCompile(`#([0-9]+)`)
+)
+
+// autolinkMatch is a span of a text node that becomes a link
+type autolinkMatch struct {
+	start, end int
+	href       string
+	class      string
+}
+
+// applyAutolinks links bare @handles and #123 issue references in text nodes,
+// code spans and code blocks are separate node types and text already inside a link or image is skipped
+func (mp *MarkdownParser) applyAutolinks(doc ast.Node) {
+	var texts []*ast.Text
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node.(type) {
+		case *ast.Link, *ast.Image:
+			return ast.SkipChildren
+		}
+		if text, ok := node.(*ast.Text); ok && entering {
+			texts = append(texts, text)
+		}
+		return ast.GoToNext
+	})
+
+	for _, text := range texts {
+		if matches := mp.autolinkMatches(text.Literal); len(matches) > 0 {
+			splitAutolinks(text, matches)
+		}
+	}
+}
+
+// autolinkMatches finds the enabled autolinks in literal, in order
+func (mp *MarkdownParser) autolinkMatches(literal []byte) []autolinkMatch {
+	var matches []autolinkMatch
+	if mp.config.EnableHandleLinks {
+		for _, loc := range handlePattern.FindAllSubmatchIndex(literal, -1) {
+			// user@example.com and @user@host are addresses, not handles
+			if !autolinkBoundary(literal, loc[0], loc[1]) || (loc[1] < len(literal) && literal[loc[1]] == '@') {
+				continue
+			}
+			matches = append(matches, autolinkMatch{
+				start: loc[0], end: loc[1],
+				href:  mp.config.HandleLinkBase + string(literal[loc[2]:loc[3]]),
+				class: "mention",
+			})
+		}
+	}
+	if mp.config.EnableIssueLinks {
+		for _, loc := range issuePattern.FindAllSubmatchIndex(literal, -1) {
+			if !autolinkBoundary(literal, loc[0], loc[1]) {
+				continue
+			}
+			matches = append(matches, autolinkMatch{
+				start: loc[0], end: loc[1],
+				href:  mp.config.IssueLinkBase + string(literal[loc[2]:loc[3]]),
+				class: "issue",
+			})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+	return matches
+}
+
+// isIssueNumber reports whether s is all digits
+func isIssueNumber(s []byte) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// autolinkBoundary reports whether literal[start:end] stands on its own rather than inside a word,
+// an email address, a url path or an html entity like &#123;
+func autolinkBoundary(literal []byte, start, end int) bool {
+	if start > 0 {
+		switch prev := literal[start-1]; {
+		case isHashtagChar(prev), prev == '@', prev == '#', prev == '&', prev == '/', prev == '.':
+			return false
+		}
+	}
+	return end >= len(literal) || !isHashtagChar(literal[end])
+}
+
+// splitAutolinks replaces a text node with text and link siblings for each match
+func splitAutolinks(text *ast.Text, matches []autolinkMatch) {
+	parent := text.GetParent()
+	if parent == nil {
+		return
+	}
+
+	var nodes []ast.Node
+	literal := text.Literal
+	last := 0
+	for _, m := range matches {
+		if m.start > last {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: literal[last:m.start]}})
+		}
+		link := &ast.Link{
+			Destination:          []byte(m.href),
+			AdditionalAttributes: []string{`class="` + m.class + `"`},
+		}
+		ast.AppendChild(link, &ast.Text{Leaf: ast.Leaf{Literal: literal[m.start:m.end]}})
+		nodes = append(nodes, link)
+		last = m.end
+	}
+	if last < len(literal) {
+		nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: literal[last:]}})
+	}
+
+	var children []ast.Node
+	for _, child := range parent.GetChildren() {
+		if child != text {
+			children = append(children, child)
+			continue
+		}
+		for _, node := range nodes {
+			node.SetParent(parent)
+			children = append(children, node)
+		}
+	}
+	parent.SetChildren(children)
+}
@@ -0,0 +1,147 @@
+package contentstuff
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// CodeFenceInfo is the structured form of a code fence info string like `go title="main.go" {2,4-5}`
+type CodeFenceInfo struct {
+	Language  string      `json:"language,omitempty"`
+	Filename  string      `json:"filename,omitempty"`
+	Highlight []LineRange `json:"highlight,omitempty"`
+}
+
+// LineRange is an inclusive range of 1-based line numbers
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// ParseCodeFenceInfo parses a code fence info string, unknown attributes and malformed ranges are ignored
+func ParseCodeFenceInfo(info string) CodeFenceInfo {
+	var result CodeFenceInfo
+	for i, token := range splitFenceInfo(info) {
+		switch {
+		case strings.HasPrefix(token, "{") && strings.HasSuffix(token, "}"):
+			result.Highlight = append(result.Highlight, parseLineRanges(token[1:len(token)-1])...)
+		case strings.Contains(token, "="):
+			key, value, _ := strings.Cut(token, "=")
+			switch strings.ToLower(key) {
+			case "title", "filename", "file":
+				result.Filename = strings.Trim(value, `"'`)
+			}
+		case i == 0:
+			result.Language = token
+		}
+	}
+	return result
+}
+
+// splitFenceInfo splits on whitespace while keeping quoted values and {...} groups together
+func splitFenceInfo(info string) []string {
+	var tokens []string
+	var current strings.Builder
+	var quote rune
+	inBraces := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range info {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '{':
+			inBraces = true
+		case r == '}':
+			inBraces = false
+		case (r == ' ' || r == '\t') && !inBraces:
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return tokens
+}
+
+// parseLineRanges parses "2,4-5" into line ranges, skipping anything that is not a valid range
+func parseLineRanges(spec string) []LineRange {
+	var ranges []LineRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(startStr))
+		if err != nil || start < 1 {
+			continue
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(endStr))
+			if err != nil || end < start {
+				continue
+			}
+		}
+		ranges = append(ranges, LineRange{Start: start, End: end})
+	}
+	return ranges
+}
+
+// renderCodeBlock renders fenced code with a filename header and data-highlight marker
+// blocks without either are left to the default renderer
+func renderCodeBlock(w io.Writer, codeBlock *ast.CodeBlock) bool {
+	info := ParseCodeFenceInfo(string(codeBlock.Info))
+	if info.Filename == "" && len(info.Highlight) == 0 {
+		return false
+	}
+
+	io.WriteString(w, `<div class="code-block">`)
+	if info.Filename != "" {
+		io.WriteString(w, `<div class="code-filename">`)
+		html.EscapeHTML(w, []byte(info.Filename))
+		io.WriteString(w, `</div>`)
+	}
+
+	io.WriteString(w, "<pre")
+	if len(info.Highlight) > 0 {
+		ranges := make([]string, len(info.Highlight))
+		for i, r := range info.Highlight {
+			ranges[i] = r.String()
+		}
+		fmt.Fprintf(w, ` data-highlight="%s"`, strings.Join(ranges, ","))
+	}
+	io.WriteString(w, "><code")
+	if info.Language != "" {
+		io.WriteString(w, ` class="language-`)
+		html.EscapeHTML(w, []byte(info.Language))
+		io.WriteString(w, `"`)
+	}
+	io.WriteString(w, ">")
+	html.EscapeHTML(w, codeBlock.Literal)
+	io.WriteString(w, "</code></pre></div>\n")
+	return true
+}
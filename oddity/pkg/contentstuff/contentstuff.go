@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,9 +22,21 @@ import (
 )
 
 type fileCMS struct {
-	fileNameMap map[string]FileDetail
-	slugFileMap map[string]FileDetail
-	ContentDir  string
+	fileNameMap  map[string]FileDetail
+	slugFileMap  map[string]FileDetail
+	ContentDir   string
+	parserConfig *ParserConfig
+	// ignoreGlobs excludes matching content relative paths from loading
+	ignoreGlobs []string
+	// followSymlinks walks into symlinked directories and files
+	followSymlinks bool
+
+	// search is the inverted index over every loaded page
+	search *searchIndex
+
+	// aliasFileMap maps frontmatter aliases to the file name they point at
+	aliasFileMap    map[string]string
+	aliasCollisions map[string][]string
 }
 
 func (c *fileCMS) doPath(p string) (FileDetail, bool) {
@@ -31,9 +46,120 @@ func (c *fileCMS) doPath(p string) (FileDetail, bool) {
 	if fd, ok := c.slugFileMap[p]; ok {
 		return fd, true
 	}
+	return c.resolveAlias(p)
+}
+
+// resolveAlias looks up p only among aliases, real files and slugs always win
+func (c *fileCMS) resolveAlias(p string) (FileDetail, bool) {
+	if _, ok := c.fileNameMap[p]; ok {
+		return FileDetail{}, false
+	}
+	if _, ok := c.slugFileMap[p]; ok {
+		return FileDetail{}, false
+	}
+	if fileName, ok := c.aliasFileMap[p]; ok {
+		fd, exists := c.fileNameMap[fileName]
+		return fd, exists
+	}
 	return FileDetail{}, false
 }
 
+// registerAliases records the aliases of fd, reporting aliases already taken by another page
+func (c *fileCMS) registerAliases(fd FileDetail) {
+	if c.aliasFileMap == nil {
+		c.aliasFileMap = make(map[string]string)
+	}
+	if c.aliasCollisions == nil {
+		c.aliasCollisions = make(map[string][]string)
+	}
+
+	// drop aliases and collisions from a previous version of this file
+	c.dropAliases(fd.FileName)
+
+	for _, alias := range NewPageFromFileDetail(&fd).Aliases() {
+		owner, taken := c.aliasFileMap[alias]
+		if !taken {
+			if existing, ok := c.slugFileMap[alias]; ok {
+				owner, taken = existing.FileName, true
+			} else if existing, ok := c.fileNameMap[alias]; ok {
+				owner, taken = existing.FileName, true
+			}
+		}
+		if taken && owner != fd.FileName {
+			logrus.Warnf("alias %s of %s collides with %s", alias, fd.FileName, owner)
+			c.aliasCollisions[alias] = append(c.aliasCollisions[alias], fd.FileName)
+			continue
+		}
+		c.aliasFileMap[alias] = fd.FileName
+	}
+}
+
+// dropAliases forgets the aliases fileName claimed and the collisions it was rejected for
+func (c *fileCMS) dropAliases(fileName string) {
+	for alias, owner := range c.aliasFileMap {
+		if owner == fileName {
+			delete(c.aliasFileMap, alias)
+		}
+	}
+	for alias, files := range c.aliasCollisions {
+		files = slices.DeleteFunc(files, func(f string) bool { return f == fileName })
+		if len(files) == 0 {
+			delete(c.aliasCollisions, alias)
+		} else {
+			c.aliasCollisions[alias] = files
+		}
+	}
+}
+
+// isIgnored reports whether relPath matches one of the ignore globs
+// a glob matches the path itself, any parent directory of it, or its base name when it has no slash
+// the trash store is always ignored
+func (c *fileCMS) isIgnored(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == TrashDir || strings.HasPrefix(relPath, TrashDir+"/") {
+		return true
+	}
+	if relPath == "." || len(c.ignoreGlobs) == 0 {
+		return false
+	}
+	for _, glob := range c.ignoreGlobs {
+		glob = strings.TrimSuffix(strings.Trim(glob, "/"), "/**")
+		if glob == "" {
+			continue
+		}
+		if !strings.Contains(glob, "/") {
+			if ok, _ := filepath.Match(glob, filepath.Base(relPath)); ok {
+				return true
+			}
+		}
+		for p := relPath; p != "." && p != "/"; p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(glob, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeFile drops fileName from the file, slug and alias maps and from the search index
+func (c *fileCMS) removeFile(fileName string) {
+	fd, ok := c.fileNameMap[fileName]
+	if !ok {
+		return
+	}
+	delete(c.fileNameMap, fileName)
+
+	if slug := NewPageFromFileDetail(&fd).Slug(); slug != "" {
+		if existing, ok := c.slugFileMap[slug]; ok && existing.FileName == fileName {
+			delete(c.slugFileMap, slug)
+		}
+	}
+	c.dropAliases(fileName)
+	if c.search != nil {
+		c.search.remove(fileName)
+	}
+}
+
 func (c *fileCMS) allFiles() []FileDetail {
 	var fds []FileDetail
 	for _, fd := range c.fileNameMap {
@@ -49,7 +175,82 @@ func (c *fileCMS) scanContent() error {
 	if c.slugFileMap == nil {
 		c.slugFileMap = make(map[string]FileDetail)
 	}
-	return filepath.Walk(c.ContentDir, c.scanContentPath)
+	if c.search == nil {
+		c.search = newSearchIndex()
+	}
+	var err error
+	if c.followSymlinks {
+		err = c.walkFollowingSymlinks(c.ContentDir, make(map[string]bool))
+	} else {
+		err = filepath.Walk(c.ContentDir, c.scanContentPath)
+	}
+	if err != nil {
+		return err
+	}
+	return c.reresolveWikiLinks()
+}
+
+// walkFollowingSymlinks walks root like filepath.Walk but also descends into symlinked directories,
+// paths keep their logical location under the content dir. The real tree is walked before any link,
+// so a link to a directory that is also in the tree does not claim it, visited tracks resolved
+// directories so a link back into an already walked directory is skipped instead of looping forever
+func (c *fileCMS) walkFollowingSymlinks(root string, visited map[string]bool) error {
+	links := []string{root}
+	for len(links) > 0 {
+		dir := links[0]
+		links = links[1:]
+		if err := c.walkRealDirs(dir, visited, &links); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkRealDirs walks dir and the directories in it, symlinked directories are added to links for later
+func (c *fileCMS) walkRealDirs(dir string, visited map[string]bool, links *[]string) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if visited[real] {
+		logrus.Warnf("skipping symlink at %s, %s is already indexed", dir, real)
+		return nil
+	}
+	visited[real] = true
+
+	info, err := os.Stat(dir)
+	if err := c.scanContentPath(dir, info, err); err != nil {
+		if errors.Is(err, filepath.SkipDir) {
+			return nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		isLink := entry.Type()&os.ModeSymlink != 0
+		if err != nil && isLink {
+			logrus.Warnf("skipping broken symlink %s: %v", path, err)
+			continue
+		}
+		switch {
+		case err == nil && info.IsDir() && isLink:
+			*links = append(*links, path)
+		case err == nil && info.IsDir():
+			err = c.walkRealDirs(path, visited, links)
+		default:
+			err = c.scanContentPath(path, info, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *fileCMS) scanContentPath(path string, info fs.FileInfo, err error) error {
@@ -69,6 +270,13 @@ func (c *fileCMS) scanContentPath(path string, info fs.FileInfo, err error) erro
 		}
 	}
 
+	if c.isIgnored(relPath) {
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
 	//var ctime time.Time
 	//if stat, ok := info.Sys().(*syscall.Stat_t); ok {
 	//	// convert to time.Time
@@ -87,9 +295,9 @@ func (c *fileCMS) scanContentPath(path string, info fs.FileInfo, err error) erro
 
 	if !info.IsDir() && (filepath.Ext(path) == ".md" || filepath.Ext(path) == ".html") {
 
-		// if it already exists and modtime is same then skip
+		// if it already exists and modtime is same then skip, unless an image it shows changed
 		if existing, ok := c.fileNameMap[relPath]; ok {
-			if existing.ModifiedAt.Equal(info.ModTime()) {
+			if existing.ModifiedAt.Equal(info.ModTime()) && !c.assetsStale(existing) {
 				return nil
 			}
 		}
@@ -99,8 +307,13 @@ func (c *fileCMS) scanContentPath(path string, info fs.FileInfo, err error) erro
 			return err
 		}
 
-		mdParser := NewMarkdownParser(DefaultParserConfig())
-		pc, err := mdParser.Parse(fileContent)
+		mdParser := NewMarkdownParser(c.pageParserConfig())
+		var pc *ParsedContent
+		if filepath.Ext(path) == ".html" {
+			pc, err = mdParser.ParseHTML(fileContent)
+		} else {
+			pc, err = mdParser.Parse(fileContent)
+		}
 		if err != nil {
 			return err
 		}
@@ -119,18 +332,38 @@ func (c *fileCMS) scanContentPath(path string, info fs.FileInfo, err error) erro
 			}(),
 			CreatedAt: info.ModTime(),
 		}
-		c.fileNameMap[relPath] = fd
-
-		// crreate at <dir>/<slug>
-		pg := NewPageFromFileDetail(&fd)
-		slugPath := pg.Slug()
-		if slugPath != "" {
-			c.slugFileMap[slugPath] = fd
-		}
+		c.storePage(fd)
 	}
 	return nil
 }
 
+// storePage puts fd in every map that holds a copy of a page: by file name, by slug,
+// its aliases and the search index
+func (c *fileCMS) storePage(fd FileDetail) {
+	c.fileNameMap[fd.FileName] = fd
+	if c.search == nil {
+		c.search = newSearchIndex()
+	}
+	c.search.add(fd)
+
+	// crreate at <dir>/<slug>
+	pg := NewPageFromFileDetail(&fd)
+	slugPath := pg.Slug()
+	if slugPath != "" && !IsSectionMeta(fd) {
+		c.slugFileMap[slugPath] = fd
+	}
+
+	c.registerAliases(fd)
+}
+
+// assetsStale reports whether an upload fd shows changed since fd was parsed
+func (c *fileCMS) assetsStale(fd FileDetail) bool {
+	if c.parserConfig == nil || c.parserConfig.AssetVersion == nil || fd.ParsedContent == nil {
+		return false
+	}
+	return fd.ParsedContent.assetsStale(c.parserConfig.AssetVersion)
+}
+
 type ContentStuff struct {
 	//FileName    map[string]FileDetail
 	//SlugFileMap map[string]FileDetail
@@ -140,6 +373,8 @@ type ContentStuff struct {
 
 	config   *config.Config
 	dbHandle *gorm.DB
+
+	events *EventBus
 }
 
 func (c *ContentStuff) AllFiles() []FileDetail {
@@ -148,6 +383,26 @@ func (c *ContentStuff) AllFiles() []FileDetail {
 	return c.cms.allFiles()
 }
 
+// AllTags returns the sorted set of hashtags used across public pages
+func (c *ContentStuff) AllTags() []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, fd := range c.AllFiles() {
+		if IsPrivate(c, fd) {
+			continue
+		}
+		pg := NewPageFromFileDetail(&fd)
+		for _, tag := range pg.Hashtags() {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
 func (c *ContentStuff) DB() *gorm.DB {
 	return c.dbHandle
 }
@@ -157,19 +412,121 @@ func (c *ContentStuff) Config() *config.Config {
 }
 
 func (c *ContentStuff) DoPath(p string) (FileDetail, bool) {
+	c.cmsMux.RLock()
+	fd, ok := c.cms.doPath(p)
+	stale := ok && c.cms.assetsStale(fd)
+	c.cmsMux.RUnlock()
+	if !stale {
+		return fd, ok
+	}
+
+	// an upload the page shows was replaced, parse the page again for the new ?v= version
+	if err := c.RefreshContent(fd.FileName); err != nil {
+		logrus.Errorf("failed to refresh %s: %v", fd.FileName, err)
+		return fd, ok
+	}
 	c.cmsMux.RLock()
 	defer c.cmsMux.RUnlock()
 	return c.cms.doPath(p)
 }
 
+// RebuildSearchIndex reindexes every loaded page from scratch
+func (c *ContentStuff) RebuildSearchIndex() {
+	c.cmsMux.Lock()
+	defer c.cmsMux.Unlock()
+	idx := newSearchIndex()
+	for _, fd := range c.cms.fileNameMap {
+		idx.add(fd)
+	}
+	c.cms.search = idx
+}
+
+// ResolveAlias returns the page a frontmatter alias points at, if p is only an alias
+func (c *ContentStuff) ResolveAlias(p string) (FileDetail, bool) {
+	c.cmsMux.RLock()
+	defer c.cmsMux.RUnlock()
+	return c.cms.resolveAlias(p)
+}
+
+// AliasCollisions returns aliases that were claimed by more than one page,
+// mapped to the files whose claim was rejected
+func (c *ContentStuff) AliasCollisions() map[string][]string {
+	c.cmsMux.RLock()
+	defer c.cmsMux.RUnlock()
+	collisions := make(map[string][]string, len(c.cms.aliasCollisions))
+	for alias, files := range c.cms.aliasCollisions {
+		collisions[alias] = append([]string(nil), files...)
+	}
+	return collisions
+}
+
+// ParserConfig returns the markdown parser configuration pages are loaded with, wiki links resolve against the loaded pages
+func (c *ContentStuff) ParserConfig() *ParserConfig {
+	if c.cms.parserConfig.WikiLinkResolution == "" {
+		return c.cms.parserConfig
+	}
+	pc := *c.cms.parserConfig
+	pc.WikiLinkResolver = c.resolveWikiLink
+	return &pc
+}
+
+// resolveWikiLink resolves a wiki link target against the loaded pages
+func (c *ContentStuff) resolveWikiLink(target string) (string, bool) {
+	c.cmsMux.RLock()
+	defer c.cmsMux.RUnlock()
+	return c.cms.resolveWikiLink(target)
+}
+
+// parserConfigFor builds the markdown parser configuration from the site config
+func parserConfigFor(cfg *config.Config) *ParserConfig {
+	pc := DefaultParserConfig()
+	pc.ExternalLinkRel = cfg.Markdown.ExternalLinkRel
+	pc.ExternalLinkTargetBlank = cfg.Markdown.ExternalLinkNewTab
+	pc.EnableEmoji = cfg.Markdown.Emoji
+	pc.EmojiImageBaseURL = cfg.Markdown.EmojiImageBaseURL
+	pc.WikiLinkResolution = cfg.Markdown.WikiLinks
+	pc.EnableAutolinks = !cfg.Markdown.DisableAutolinks
+	pc.EnableHandleLinks = cfg.Markdown.HandleLinkBase != ""
+	pc.HandleLinkBase = cfg.Markdown.HandleLinkBase
+	pc.EnableIssueLinks = cfg.Markdown.IssueLinkBase != ""
+	pc.IssueLinkBase = cfg.Markdown.IssueLinkBase
+	if cfg.Markdown.AssetVersions && cfg.Content.UploadDir != "" {
+		pc.AssetVersion = uploadVersioner(cfg.Content.UploadDir)
+	}
+	if u, err := url.Parse(cfg.Site.BaseURL); err == nil {
+		pc.SiteHost = u.Hostname()
+	}
+	return pc
+}
+
 func NewContentStuff(config *config.Config) *ContentStuff {
-	return &ContentStuff{
+	c := &ContentStuff{
 		config: config,
 		cms: &fileCMS{
-			ContentDir: config.Content.ContentDir,
+			ContentDir:   config.Content.ContentDir,
+			parserConfig: parserConfigFor(config),
+			ignoreGlobs:  config.Content.IgnoreGlobs,
+
+			followSymlinks: config.Content.FollowSymlinks,
 		},
 		cmsMux: &sync.RWMutex{},
+		events: NewEventBus(),
 	}
+	// subscribed first so later handlers already see re-resolved links
+	c.events.Subscribe(c.reresolveWikiLinks)
+	return c
+}
+
+// reresolveWikiLinks parses again the pages whose wiki links resolve differently after ev
+func (c *ContentStuff) reresolveWikiLinks(ev ContentEvent) error {
+	c.cmsMux.Lock()
+	defer c.cmsMux.Unlock()
+	return c.cms.reresolveWikiLinks()
+}
+
+// Events returns the bus content changes are published on
+func (c *ContentStuff) Events() *EventBus {
+	return c.events
 }
 
 func (c *ContentStuff) LoadContent() error {
@@ -200,7 +557,13 @@ func (c *ContentStuff) LoadContent() error {
 }
 
 func (c *ContentStuff) ReloadContent() error {
-	newCMS := &fileCMS{ContentDir: c.config.Content.ContentDir}
+	newCMS := &fileCMS{
+		ContentDir:   c.config.Content.ContentDir,
+		parserConfig: c.cms.parserConfig,
+		ignoreGlobs:  c.config.Content.IgnoreGlobs,
+
+		followSymlinks: c.config.Content.FollowSymlinks,
+	}
 	err := newCMS.scanContent()
 	if err != nil {
 		return fmt.Errorf("error walking content dir: %v", err)
@@ -333,12 +696,17 @@ func (c *ContentStuff) RefreshContent(path string) error {
 	path = filepath.Join(c.config.Content.ContentDir, path)
 	c.cmsMux.Lock()
 	defer c.cmsMux.Unlock()
-	return c.cms.scanContentPath(path, nil, nil)
+	err := c.cms.scanContentPath(path, nil, nil)
+	if errors.Is(err, filepath.SkipDir) {
+		// an ignored directory
+		return nil
+	}
+	return err
 }
 
 func (c *ContentStuff) GetHistory(path string) []PostHistory {
 	var histories []PostHistory
-	result := c.dbHandle.Where("file_name = ? or full_slug = ?", path, path).Order("created DESC").Find(&histories)
+	result := c.dbHandle.Where("file_name = ? or full_slug = ?", path, path).Order("created DESC, id DESC").Find(&histories)
 	if result.Error != nil {
 		logrus.Errorf("error getting history for %s: %v", path, result.Error)
 		return nil
@@ -424,7 +792,7 @@ type FileDetail struct {
 	ParsedContent *ParsedContent
 }
 
-func SaveFileDetail(sc *ContentStuff, wc *Wire, fd *FileDetail) error {
+func SaveFileDetail(sc *ContentStuff, fd *FileDetail) error {
 	if fd.FileName == "" {
 		return fmt.Errorf("file name is empty")
 	}
@@ -436,78 +804,53 @@ func SaveFileDetail(sc *ContentStuff, wc *Wire, fd *FileDetail) error {
 
 		//targetFile := filepath.Join(sc.Config.Content.ContentDir, fd.FileName)
 
-		err = sc.WriteContentFile(fd.FileName, content)
-		if err != nil {
-			return fmt.Errorf("error writing file: %v", err)
-		}
-
-		// refresh the file
-		err = sc.RefreshContent(fd.FileName)
-		if err != nil {
-			return fmt.Errorf("error refreshing content: %v", err)
-		}
-
-		err = wc.ScanContentFileForQueries(fd.FileName)
-		if err != nil {
-			return fmt.Errorf("error scanning content file for queries: %v", err)
-		}
+		return SaveRawContent(sc, fd.FileName, content)
+	}
 
-		err = wc.NotifyFileChanged(fd.FileName)
+	if fd.FileType == FileTypeHTML {
+		// the body with its frontmatter, if it has any
+		content, err := fd.ParsedContent.ToHTML()
 		if err != nil {
-			return fmt.Errorf("error notifying file %s changed: %v", fd.FileName, err)
+			return fmt.Errorf("error converting to html: %v", err)
 		}
-
-		// refresh the dir
-		err = sc.RefreshContent(filepath.Dir(fd.FileName))
+		err = sc.WriteContentFile(fd.FileName, content)
 		if err != nil {
-			return fmt.Errorf("error refreshing content: %v", err)
+			return fmt.Errorf("error writing file: %v", err)
 		}
+	}
 
-		// refresh the file
-		err = sc.RefreshContent(fd.FileName)
-		if err != nil {
-			return fmt.Errorf("error refreshing content: %v", err)
-		}
+	return nil
+}
 
-		//targetFileDir := filepath.Dir(targetFile)
-		//indexPaths := []string{
-		//	filepath.Join(targetFileDir, "index.md"),
-		//	filepath.Join(targetFileDir, "index.html"),
-		//}
+// SaveRawContent writes content as-is to fileName, refreshes the content store and
+// publishes a ContentCreated or ContentUpdated event for subscribers to follow up on
+func SaveRawContent(sc *ContentStuff, fileName string, content string) error {
+	_, existed := sc.DoPath(fileName)
 
-		err = wc.TriggerDependencyUpdates(fd.FileName)
-		if err != nil {
-			logrus.Errorf("error notifying file change for %s: %v", fd.FileName, err)
-		}
+	err := sc.WriteContentFile(fileName, content)
+	if err != nil {
+		return fmt.Errorf("error writing file: %v", err)
+	}
 
-		for _, ip := range wc.FindDependencies(fd.FileName) {
-			targetFile := filepath.Join(sc.config.Content.ContentDir, ip)
-			if _, err := os.Stat(targetFile); err == nil {
-				relativeIP, err := filepath.Rel(sc.config.Content.ContentDir, targetFile)
-				if err != nil {
-					logrus.Errorf("error getting relative path for %s: %v", ip, err)
-					continue
-				}
-				err = sc.RefreshContent(relativeIP)
-				if err != nil {
-					logrus.Errorf("error refreshing content for %s: %v", ip, err)
-				}
-				err = wc.ScanContentFileForQueries(relativeIP)
-				if err != nil {
-					logrus.Errorf("error scanning content file for queries %s: %v", ip, err)
-				}
-			}
-		}
+	// refresh the file
+	err = sc.RefreshContent(fileName)
+	if err != nil {
+		return fmt.Errorf("error refreshing content: %v", err)
+	}
 
-		return nil
+	// refresh the dir
+	err = sc.RefreshContent(filepath.Dir(fileName))
+	if err != nil {
+		return fmt.Errorf("error refreshing content: %v", err)
 	}
 
-	if fd.FileType == FileTypeHTML {
-		// just write body
-		err := sc.WriteContentFile(fd.FileName, string(fd.ParsedContent.HTML))
-		if err != nil {
-			return fmt.Errorf("error writing file: %v", err)
-		}
+	ev := ContentEvent{Type: ContentUpdated, FileName: fileName}
+	if !existed {
+		ev.Type = ContentCreated
+	}
+	err = sc.Events().Publish(ev)
+	if err != nil {
+		return fmt.Errorf("error handling %s event for %s: %v", ev.Type, fileName, err)
 	}
 
 	return nil
@@ -534,6 +877,7 @@ type PostHistory struct {
 	Title    string    `gorm:"text"`
 	Content  string    `gorm:"text"`
 	HTML     string    `gorm:"text"`
+	Deleted  bool      `gorm:"index"` // the page was deleted, Content is what it held
 	Created  time.Time `gorm:"autoCreateTime"`
 	Updated  time.Time `gorm:"autoUpdateTime"`
 }
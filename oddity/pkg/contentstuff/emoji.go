@@ -0,0 +1,303 @@
+package contentstuff
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// emojiShortcodePattern matches :shortcode: candidates, only known names are replaced
+var emojiShortcodePattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// emojiShortcodes maps GitHub style shortcodes to their unicode emoji
+var emojiShortcodes = map[string]string{
+	"+1":                         "👍",
+	"-1":                         "👎",
+	"thumbsup":                   "👍",
+	"thumbsdown":                 "👎",
+	"smile":                      "😄",
+	"smiley":                     "😃",
+	"grin":                       "😁",
+	"grinning":                   "😀",
+	"laughing":                   "😆",
+	"joy":                        "😂",
+	"rofl":                       "🤣",
+	"sweat_smile":                "😅",
+	"wink":                       "😉",
+	"blush":                      "😊",
+	"innocent":                   "😇",
+	"heart_eyes":                 "😍",
+	"kissing_heart":              "😘",
+	"yum":                        "😋",
+	"stuck_out_tongue":           "😛",
+	"sunglasses":                 "😎",
+	"nerd_face":                  "🤓",
+	"thinking":                   "🤔",
+	"neutral_face":               "😐",
+	"expressionless":             "😑",
+	"unamused":                   "😒",
+	"roll_eyes":                  "🙄",
+	"grimacing":                  "😬",
+	"relieved":                   "😌",
+	"pensive":                    "😔",
+	"sleepy":                     "😪",
+	"sleeping":                   "😴",
+	"mask":                       "😷",
+	"dizzy_face":                 "😵",
+	"exploding_head":             "🤯",
+	"confused":                   "😕",
+	"worried":                    "😟",
+	"slightly_frowning_face":     "🙁",
+	"open_mouth":                 "😮",
+	"astonished":                 "😲",
+	"flushed":                    "😳",
+	"cry":                        "😢",
+	"sob":                        "😭",
+	"scream":                     "😱",
+	"angry":                      "😠",
+	"rage":                       "😡",
+	"skull":                      "💀",
+	"poop":                       "💩",
+	"ghost":                      "👻",
+	"alien":                      "👽",
+	"robot":                      "🤖",
+	"wave":                       "👋",
+	"ok_hand":                    "👌",
+	"v":                          "✌️",
+	"crossed_fingers":            "🤞",
+	"point_up":                   "☝️",
+	"point_right":                "👉",
+	"point_left":                 "👈",
+	"clap":                       "👏",
+	"raised_hands":               "🙌",
+	"pray":                       "🙏",
+	"muscle":                     "💪",
+	"eyes":                       "👀",
+	"brain":                      "🧠",
+	"heart":                      "❤️",
+	"broken_heart":               "💔",
+	"sparkling_heart":            "💖",
+	"100":                        "💯",
+	"boom":                       "💥",
+	"sparkles":                   "✨",
+	"star":                       "⭐",
+	"star2":                      "🌟",
+	"fire":                       "🔥",
+	"zap":                        "⚡",
+	"sunny":                      "☀️",
+	"cloud":                      "☁️",
+	"umbrella":                   "☔",
+	"snowflake":                  "❄️",
+	"rainbow":                    "🌈",
+	"ocean":                      "🌊",
+	"earth_africa":               "🌍",
+	"earth_americas":             "🌎",
+	"earth_asia":                 "🌏",
+	"seedling":                   "🌱",
+	"evergreen_tree":             "🌲",
+	"deciduous_tree":             "🌳",
+	"cactus":                     "🌵",
+	"tulip":                      "🌷",
+	"sunflower":                  "🌻",
+	"rose":                       "🌹",
+	"apple":                      "🍎",
+	"lemon":                      "🍋",
+	"pizza":                      "🍕",
+	"hamburger":                  "🍔",
+	"coffee":                     "☕",
+	"tea":                        "🍵",
+	"beer":                       "🍺",
+	"wine_glass":                 "🍷",
+	"cake":                       "🍰",
+	"birthday":                   "🎂",
+	"tada":                       "🎉",
+	"confetti_ball":              "🎊",
+	"gift":                       "🎁",
+	"balloon":                    "🎈",
+	"trophy":                     "🏆",
+	"medal_sports":               "🏅",
+	"soccer":                     "⚽",
+	"basketball":                 "🏀",
+	"musical_note":               "🎵",
+	"notes":                      "🎶",
+	"headphones":                 "🎧",
+	"art":                        "🎨",
+	"camera":                     "📷",
+	"movie_camera":               "🎥",
+	"tv":                         "📺",
+	"computer":                   "💻",
+	"keyboard":                   "⌨️",
+	"iphone":                     "📱",
+	"phone":                      "☎️",
+	"bulb":                       "💡",
+	"battery":                    "🔋",
+	"electric_plug":              "🔌",
+	"wrench":                     "🔧",
+	"hammer":                     "🔨",
+	"gear":                       "⚙️",
+	"lock":                       "🔒",
+	"unlock":                     "🔓",
+	"key":                        "🔑",
+	"link":                       "🔗",
+	"paperclip":                  "📎",
+	"pushpin":                    "📌",
+	"memo":                       "📝",
+	"pencil2":                    "✏️",
+	"book":                       "📖",
+	"books":                      "📚",
+	"bookmark":                   "🔖",
+	"newspaper":                  "📰",
+	"email":                      "📧",
+	"envelope":                   "✉️",
+	"inbox_tray":                 "📥",
+	"outbox_tray":                "📤",
+	"package":                    "📦",
+	"calendar":                   "📆",
+	"date":                       "📅",
+	"chart_with_upwards_trend":   "📈",
+	"chart_with_downwards_trend": "📉",
+	"bar_chart":                  "📊",
+	"clipboard":                  "📋",
+	"file_folder":                "📁",
+	"mag":                        "🔍",
+	"bell":                       "🔔",
+	"loudspeaker":                "📢",
+	"mega":                       "📣",
+	"speech_balloon":             "💬",
+	"thought_balloon":            "💭",
+	"hourglass":                  "⌛",
+	"alarm_clock":                "⏰",
+	"stopwatch":                  "⏱️",
+	"rocket":                     "🚀",
+	"airplane":                   "✈️",
+	"car":                        "🚗",
+	"bike":                       "🚲",
+	"train":                      "🚆",
+	"ship":                       "🚢",
+	"house":                      "🏠",
+	"office":                     "🏢",
+	"construction":               "🚧",
+	"warning":                    "⚠️",
+	"no_entry":                   "⛔",
+	"x":                          "❌",
+	"white_check_mark":           "✅",
+	"heavy_check_mark":           "✔️",
+	"question":                   "❓",
+	"exclamation":                "❗",
+	"bangbang":                   "‼️",
+	"information_source":         "ℹ️",
+	"recycle":                    "♻️",
+	"arrow_right":                "➡️",
+	"arrow_left":                 "⬅️",
+	"arrow_up":                   "⬆️",
+	"arrow_down":                 "⬇️",
+	"new":                        "🆕",
+	"free":                       "🆓",
+	"cool":                       "🆒",
+	"ok":                         "🆗",
+	"sos":                        "🆘",
+	"dog":                        "🐶",
+	"cat":                        "🐱",
+	"mouse":                      "🐭",
+	"rabbit":                     "🐰",
+	"fox_face":                   "🦊",
+	"bear":                       "🐻",
+	"panda_face":                 "🐼",
+	"penguin":                    "🐧",
+	"bird":                       "🐦",
+	"owl":                        "🦉",
+	"turtle":                     "🐢",
+	"snake":                      "🐍",
+	"octopus":                    "🐙",
+	"whale":                      "🐳",
+	"bug":                        "🐛",
+	"bee":                        "🐝",
+	"butterfly":                  "🦋",
+	"unicorn":                    "🦄",
+	"crab":                       "🦀",
+}
+
+// replaceEmojiShortcodes rewrites known :shortcodes: in text nodes
+// code spans and code blocks are separate node types so their contents are never touched
+func (mp *MarkdownParser) replaceEmojiShortcodes(doc ast.Node) {
+	var texts []*ast.Text
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if text, ok := node.(*ast.Text); ok && entering && emojiShortcodePattern.Match(text.Literal) {
+			texts = append(texts, text)
+		}
+		return ast.GoToNext
+	})
+
+	for _, text := range texts {
+		if mp.config.EmojiImageBaseURL == "" {
+			text.Literal = emojiShortcodePattern.ReplaceAllFunc(text.Literal, func(match []byte) []byte {
+				if emoji, ok := emojiShortcodes[string(match[1:len(match)-1])]; ok {
+					return []byte(emoji)
+				}
+				return match
+			})
+			continue
+		}
+		mp.splitEmojiImages(text)
+	}
+}
+
+// splitEmojiImages replaces a text node with text and <img> siblings for each known shortcode
+func (mp *MarkdownParser) splitEmojiImages(text *ast.Text) {
+	parent := text.GetParent()
+	if parent == nil {
+		return
+	}
+
+	var nodes []ast.Node
+	literal := text.Literal
+	last := 0
+	for _, loc := range emojiShortcodePattern.FindAllSubmatchIndex(literal, -1) {
+		emoji, ok := emojiShortcodes[string(literal[loc[2]:loc[3]])]
+		if !ok {
+			continue
+		}
+		if loc[0] > last {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: literal[last:loc[0]]}})
+		}
+		img := fmt.Sprintf(`<img class="emoji" alt="%s" src="%s/%s.svg">`,
+			html.EscapeString(emoji), strings.TrimSuffix(mp.config.EmojiImageBaseURL, "/"), emojiCodepoints(emoji))
+		nodes = append(nodes, &ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(img)}})
+		last = loc[1]
+	}
+	if len(nodes) == 0 {
+		return
+	}
+	if last < len(literal) {
+		nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: literal[last:]}})
+	}
+
+	var children []ast.Node
+	for _, child := range parent.GetChildren() {
+		if child != text {
+			children = append(children, child)
+			continue
+		}
+		for _, node := range nodes {
+			node.SetParent(parent)
+			children = append(children, node)
+		}
+	}
+	parent.SetChildren(children)
+}
+
+// emojiCodepoints returns the twemoji file name for an emoji, e.g. 1f680
+// the variation selector is dropped since twemoji names omit it
+func emojiCodepoints(emoji string) string {
+	var parts []string
+	for _, r := range emoji {
+		if r == 0xfe0f {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%x", r))
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,95 @@
+package contentstuff
+
+import (
+	"errors"
+	"sync"
+)
+
+// ContentEventType says what happened to a content file
+type ContentEventType int
+
+const (
+	ContentCreated ContentEventType = iota
+	ContentUpdated
+	ContentDeleted
+	ContentRenamed
+)
+
+func (t ContentEventType) String() string {
+	switch t {
+	case ContentCreated:
+		return "created"
+	case ContentUpdated:
+		return "updated"
+	case ContentDeleted:
+		return "deleted"
+	case ContentRenamed:
+		return "renamed"
+	}
+	return "unknown"
+}
+
+// ContentEvent is published after a content file was saved, renamed or deleted
+// and the content store already reflects the change
+type ContentEvent struct {
+	Type        ContentEventType
+	FileName    string
+	OldFileName string // only set for ContentRenamed
+}
+
+// ContentEventHandler reacts to a content event
+type ContentEventHandler func(ContentEvent) error
+
+// EventBus is a small synchronous pub/sub for content changes
+// handlers run in the order they subscribed, on the publishing goroutine
+type EventBus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers []eventSubscription
+}
+
+type eventSubscription struct {
+	id      int
+	handler ContentEventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers handler for every published event and returns a func that removes it
+func (b *EventBus) Subscribe(handler ContentEventHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.handlers = append(b.handlers, eventSubscription{id: id, handler: handler})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.handlers {
+			if sub.id == id {
+				b.handlers = append(b.handlers[:i:i], b.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish calls every handler with ev, a failing handler does not stop the others
+// and all of their errors are returned joined
+func (b *EventBus) Publish(ev ContentEvent) error {
+	b.mu.RLock()
+	handlers := make([]eventSubscription, len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range handlers {
+		if err := sub.handler(ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,77 @@
+package contentstuff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"oddity/pkg/config"
+)
+
+func TestEventBusPublishOnSave(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.md"), []byte("# Hello\n\nfirst"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	cs := NewContentStuff(&config.Config{Content: config.ContentConfig{
+		ContentDir: dir,
+		SidecarDB:  filepath.Join(t.TempDir(), "sidecar.db"),
+	}})
+	if err := cs.LoadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+
+	var got []ContentEvent
+	unsubscribe := cs.Events().Subscribe(func(ev ContentEvent) error {
+		// the content store must already reflect the change
+		if _, ok := cs.DoPath(ev.FileName); !ok {
+			t.Errorf("%s not in content store when %s was published", ev.FileName, ev.Type)
+		}
+		got = append(got, ev)
+		return nil
+	})
+
+	if err := SaveRawContent(cs, "hello.md", "# Hello\n\nsecond"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := SaveRawContent(cs, "notes/new.md", "# New"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	want := []ContentEvent{
+		{Type: ContentUpdated, FileName: "hello.md"},
+		{Type: ContentCreated, FileName: "notes/new.md"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	unsubscribe()
+	if err := SaveRawContent(cs, "hello.md", "# Hello\n\nthird"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("unsubscribed handler still received %v", got[len(want):])
+	}
+}
+
+func TestEventBusJoinsHandlerErrors(t *testing.T) {
+	bus := NewEventBus()
+	calls := 0
+	bus.Subscribe(func(ContentEvent) error { calls++; return errors.New("first") })
+	bus.Subscribe(func(ContentEvent) error { calls++; return nil })
+
+	err := bus.Publish(ContentEvent{Type: ContentDeleted, FileName: "gone.md"})
+	if err == nil || err.Error() != "first" {
+		t.Errorf("Publish error = %v, want first", err)
+	}
+	if calls != 2 {
+		t.Errorf("%d handlers ran, want 2", calls)
+	}
+}
@@ -2,7 +2,11 @@ package contentstuff
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/goccy/go-yaml"
@@ -37,7 +41,7 @@ func (fm *FrontmatterData) SetRaw(data []byte) error {
 	case FrontmatterYAML:
 		err = yaml.Unmarshal(data, &fm.Data)
 	case FrontmatterTOML:
-		err = toml.Unmarshal(data, &fm.Data)
+		fm.Data, err = unmarshalTOMLOrdered(data)
 	default:
 		return nil
 	}
@@ -49,16 +53,59 @@ func (fm *FrontmatterData) SetRaw(data []byte) error {
 	if fm.DataKV == nil {
 		fm.DataKV = make(map[string]interface{})
 	}
-	for _, item := range fm.Data {
+	for i, item := range fm.Data {
+		fm.Data[i].Value = normalizeScalar(item.Value)
 		keyString, ok := item.Key.(string)
 		if !ok {
 			continue
 		}
-		fm.DataKV[keyString] = item.Value
+		fm.DataKV[keyString] = fm.Data[i].Value
 	}
 	return nil
 }
 
+// normalizeScalar gives integers one type whether they were decoded or set in code,
+// yaml decodes positive ints as uint64 and negative ones as int64, so without this a value
+// set as int64 would come back as uint64 after a save
+func normalizeScalar(value any) any {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return normalizeScalar(uint64(v))
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeScalar(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalizeScalar(v[key])
+		}
+		return v
+	case []map[string]interface{}:
+		for i := range v {
+			normalizeScalar(v[i])
+		}
+		return v
+	case yaml.MapSlice:
+		for i := range v {
+			v[i].Value = normalizeScalar(v[i].Value)
+		}
+		return v
+	}
+	return value
+}
+
 // GetString safely gets a string value from frontmatter data
 func (fm *FrontmatterData) GetString(key string) (string, bool) {
 	if fm == nil || fm.Data == nil {
@@ -86,6 +133,8 @@ func (fm *FrontmatterData) SetValue(key string, value any) {
 		fm.DataKV = make(map[string]interface{})
 	}
 
+	value = normalizeScalar(value)
+
 	// if fm.DataKV[key] doesn't exist, add it only the first time as it helps preserve order
 	if _, exists := fm.DataKV[key]; !exists {
 		fm.Data = append(fm.Data, yaml.MapItem{Key: key, Value: value})
@@ -138,6 +187,77 @@ func (fm *FrontmatterData) GetBool(key string) bool {
 	return false
 }
 
+// GetInt safely gets an integer value from frontmatter data
+func (fm *FrontmatterData) GetInt(key string) (int, bool) {
+	if fm == nil || fm.Data == nil {
+		return 0, false
+	}
+	switch v := fm.DataKV[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// GetNested looks up a dotted path like "seo.description" through nested maps,
+// a numeric segment indexes into a list, so "authors.0.name" reads the first of an array of tables
+func (fm *FrontmatterData) GetNested(path string) (any, bool) {
+	if fm == nil || fm.Data == nil || path == "" {
+		return nil, false
+	}
+	parts := strings.Split(path, ".")
+	value, ok := fm.DataKV[parts[0]]
+	if !ok {
+		return nil, false
+	}
+	for _, part := range parts[1:] {
+		if value, ok = nestedValue(value, part); !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Param is GetNested for templates, it returns nil when the path does not exist
+func (fm *FrontmatterData) Param(path string) any {
+	value, _ := fm.GetNested(path)
+	return value
+}
+
+// nestedValue steps one path segment into a map or list
+func nestedValue(value any, part string) (any, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		child, ok := v[part]
+		return child, ok
+	case yaml.MapSlice:
+		for _, item := range v {
+			if key, ok := item.Key.(string); ok && key == part {
+				return item.Value, true
+			}
+		}
+	case []interface{}:
+		if i, err := strconv.Atoi(part); err == nil && i >= 0 && i < len(v) {
+			return v[i], true
+		}
+	case []map[string]interface{}:
+		if i, err := strconv.Atoi(part); err == nil && i >= 0 && i < len(v) {
+			return v[i], true
+		}
+	}
+	return nil, false
+}
+
 // HasKey checks if a key exists in frontmatter data
 func (fm *FrontmatterData) HasKey(key string) bool {
 	if fm == nil || fm.Data == nil {
@@ -154,31 +274,46 @@ func (fm *FrontmatterData) Marshal() (string, error) {
 	}
 
 	// sync DataKV to Data
-	// first update existing keys
+	// first update existing keys in place, dropping those no longer in DataKV
 	var updatedKeys = make(map[string]bool)
-	for i, item := range fm.Data {
+	synced := make(yaml.MapSlice, 0, len(fm.Data))
+	for _, item := range fm.Data {
 		// TODO: maybe DataKV doesn't have to have string keys
 		keyString, ok := item.Key.(string)
 		if !ok {
+			synced = append(synced, item)
 			continue
 		}
 
 		if val, ok := fm.DataKV[keyString]; ok {
 			item.Value = val
 			updatedKeys[keyString] = true
-		} else {
-			// remove key from Data if not in DataKV
-			fm.Data = append(fm.Data[:i], fm.Data[i+1:]...)
+			synced = append(synced, item)
 		}
 	}
-	// then add new keys
-	for key, val := range fm.DataKV {
+	// then add new keys, sorted so the output does not depend on map order
+	var newKeys []string
+	for key := range fm.DataKV {
 		if _, ok := updatedKeys[key]; !ok {
-			fm.Data = append(fm.Data, yaml.MapItem{Key: key, Value: val})
+			newKeys = append(newKeys, key)
 		}
 	}
+	sort.Strings(newKeys)
+	for _, key := range newKeys {
+		synced = append(synced, yaml.MapItem{Key: key, Value: fm.DataKV[key]})
+	}
+	fm.Data = synced
 
-	out, err := yaml.Marshal(fm.Data)
+	if fm.Type == FrontmatterTOML {
+		out, err := marshalTOMLOrdered(fm.Data)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("+++\n%s+++", out), nil
+	}
+
+	// the encoder cannot write an untyped nil, a nil pointer is written as null
+	out, err := yaml.Marshal(yamlNulls(fm.Data))
 	if err != nil {
 		return "", err
 	}
@@ -231,3 +366,59 @@ func ExtractFrontmatter(content []byte) (*FrontmatterData, []byte, error) {
 	// No frontmatter found
 	return nil, content, nil
 }
+
+// yamlNulls returns a copy of data with nil values replaced by a typed nil
+func yamlNulls(data yaml.MapSlice) yaml.MapSlice {
+	out := make(yaml.MapSlice, len(data))
+	for i, item := range data {
+		if item.Value == nil {
+			item.Value = (*string)(nil)
+		}
+		out[i] = item
+	}
+	return out
+}
+
+// unmarshalTOMLOrdered decodes TOML into a MapSlice keeping the top level keys in document order
+func unmarshalTOMLOrdered(data []byte) (yaml.MapSlice, error) {
+	values := make(map[string]interface{})
+	md, err := toml.Decode(string(data), &values)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(yaml.MapSlice, 0, len(values))
+	for _, key := range md.Keys() {
+		if len(key) != 1 {
+			continue
+		}
+		result = append(result, yaml.MapItem{Key: key[0], Value: values[key[0]]})
+	}
+	return result, nil
+}
+
+// marshalTOMLOrdered encodes a MapSlice as TOML in its order
+// plain values are written before tables since keys after a [table] header would belong to it
+func marshalTOMLOrdered(data yaml.MapSlice) (string, error) {
+	var plain, tables strings.Builder
+	for _, item := range data {
+		key, ok := item.Key.(string)
+		if !ok || item.Value == nil {
+			// toml has no null
+			continue
+		}
+		out := &plain
+		switch item.Value.(type) {
+		case map[string]interface{}, []map[string]interface{}, yaml.MapSlice:
+			out = &tables
+		}
+		value := item.Value
+		if ms, ok := value.(yaml.MapSlice); ok {
+			value = ms.ToMap()
+		}
+		if err := toml.NewEncoder(out).Encode(map[string]interface{}{key: value}); err != nil {
+			return "", fmt.Errorf("failed to encode %s as TOML: %w", key, err)
+		}
+	}
+	return plain.String() + tables.String(), nil
+}
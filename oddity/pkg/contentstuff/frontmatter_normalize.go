@@ -0,0 +1,116 @@
+package contentstuff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// FrontmatterTimeLayout is how the readable *_time companion of a unix timestamp is written
+const FrontmatterTimeLayout = "2006-01-02 15:04:05"
+
+// timestampKeys pairs each unix timestamp key with its readable companion, in the order they are written
+var timestampKeys = [][2]string{
+	{"created", "created_time"},
+	{"updated", "updated_time"},
+}
+
+// NormalizeFrontmatter makes saved frontmatter consistent:
+// each unix timestamp and its *_time companion are made to agree, a tags string becomes a list,
+// and the timestamp keys are moved after the user's keys, which keep their order
+// previous is the frontmatter before the edit, if only the *_time value was changed it wins,
+// otherwise the unix timestamp is authoritative since it is what pages are dated by
+func NormalizeFrontmatter(fm, previous *FrontmatterData) {
+	if fm == nil {
+		return
+	}
+
+	for _, pair := range timestampKeys {
+		reconcileTimestamp(fm, previous, pair[0], pair[1])
+	}
+
+	if tags, ok := fm.DataKV["tags"].(string); ok {
+		fm.SetValue("tags", splitTags(tags))
+	}
+
+	isTimestampKey := make(map[string]bool)
+	for _, pair := range timestampKeys {
+		isTimestampKey[pair[0]] = true
+		isTimestampKey[pair[1]] = true
+	}
+	ordered := make(yaml.MapSlice, 0, len(fm.Data))
+	for _, item := range fm.Data {
+		if key, ok := item.Key.(string); !ok || !isTimestampKey[key] {
+			ordered = append(ordered, item)
+		}
+	}
+	for _, pair := range timestampKeys {
+		for _, key := range pair {
+			if value, ok := fm.DataKV[key]; ok {
+				ordered = append(ordered, yaml.MapItem{Key: key, Value: value})
+			}
+		}
+	}
+	fm.Data = ordered
+}
+
+// reconcileTimestamp derives whichever of unixKey and timeKey is stale from the other
+func reconcileTimestamp(fm, previous *FrontmatterData, unixKey, timeKey string) {
+	unixValue, hasUnix := fm.GetInt(unixKey)
+	timeString, _ := fm.GetString(timeKey)
+	parsed, hasTime := parseFrontmatterTime(timeString)
+
+	if !hasUnix && !hasTime {
+		return
+	}
+
+	useTime := hasTime && !hasUnix
+	if hasTime && hasUnix && previous != nil {
+		prevUnix, _ := previous.GetValue(unixKey)
+		prevTime, _ := previous.GetValue(timeKey)
+		timeChanged := fmt.Sprint(prevTime) != timeString
+		unixChanged := fmt.Sprint(prevUnix) != strconv.Itoa(unixValue)
+		useTime = timeChanged && !unixChanged
+	}
+
+	if useTime {
+		fm.SetValue(unixKey, parsed.Unix())
+		fm.SetValue(timeKey, parsed.Format(FrontmatterTimeLayout))
+		return
+	}
+	fm.SetValue(timeKey, time.Unix(int64(unixValue), 0).Format(FrontmatterTimeLayout))
+}
+
+// parseFrontmatterTime parses a readable timestamp in local time
+func parseFrontmatterTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	for _, layout := range []string{FrontmatterTimeLayout, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// splitTags turns "go, web #blog" into [go web blog]
+func splitTags(tags string) []string {
+	fields := strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	result := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if tag := strings.TrimPrefix(field, "#"); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package contentstuff
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"oddity/pkg/config"
+)
+
+// ValidateFrontmatter checks fm against schema and describes every violation, nil means it is valid
+func ValidateFrontmatter(fm *FrontmatterData, schema config.FrontmatterSchema) []string {
+	var violations []string
+
+	for _, key := range schema.Required {
+		value, ok := fm.GetValue(key)
+		if str, isString := value.(string); !ok || value == nil || (isString && strings.TrimSpace(str) == "") {
+			violations = append(violations, fmt.Sprintf("%s is required", key))
+		}
+	}
+
+	keys := make([]string, 0, len(schema.Fields))
+	for key := range schema.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := schema.Fields[key]
+		value, ok := fm.GetValue(key)
+		if !ok || value == nil {
+			continue
+		}
+		if field.Type != "" && !matchesFieldType(value, field.Type) {
+			violations = append(violations, fmt.Sprintf("%s must be a %s, got %v", key, field.Type, value))
+			continue
+		}
+		if len(field.Allowed) == 0 {
+			continue
+		}
+		values := []any{value}
+		if list, ok := value.([]interface{}); ok {
+			values = list
+		}
+		for _, v := range values {
+			if !slices.Contains(field.Allowed, fmt.Sprint(v)) {
+				violations = append(violations, fmt.Sprintf("%s must be one of %s, got %v", key, strings.Join(field.Allowed, ", "), v))
+			}
+		}
+	}
+
+	return violations
+}
+
+// matchesFieldType reports whether a decoded frontmatter value is of the schema type
+func matchesFieldType(value any, fieldType string) bool {
+	switch fieldType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	case "list":
+		return isListValue(value)
+	case "date":
+		switch v := value.(type) {
+		case int, int64, uint64, time.Time:
+			return true
+		case string:
+			for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+				if _, err := time.Parse(layout, v); err == nil {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	// unknown types are not enforced
+	return true
+}
+
+func isListValue(value any) bool {
+	switch value.(type) {
+	case []interface{}, []string:
+		return true
+	}
+	return false
+}
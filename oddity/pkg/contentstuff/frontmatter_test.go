@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"oddity/pkg/config"
 )
 
 func TestFrontmatterParsing(t *testing.T) {
@@ -29,3 +31,103 @@ title_removed: "Sample YAML"
 	testify.True(fm.HasKey("title_removed"))
 	testify.False(fm.HasKey("title"))
 }
+
+func TestValidateFrontmatter(t *testing.T) {
+	testify := assert.New(t)
+	schema := config.FrontmatterSchema{
+		Required: []string{"title"},
+		Fields: map[string]config.FrontmatterField{
+			"private": {Type: "bool"},
+			"status":  {Type: "string", Allowed: []string{"draft", "published"}},
+			"tags":    {Type: "list", Allowed: []string{"go", "web"}},
+			"weight":  {Type: "number"},
+			"date":    {Type: "date"},
+		},
+	}
+
+	fm, _, err := ExtractFrontmatter([]byte("---\ntitle: Ok\nprivate: false\nstatus: draft\ntags: [go]\nweight: 3\ndate: 2024-01-05\n---\n"))
+	testify.NoError(err)
+	testify.Empty(ValidateFrontmatter(fm, schema))
+
+	fm, _, err = ExtractFrontmatter([]byte("---\ntitle: \"\"\nprivate: \"no\"\nstatus: live\ntags: [go, rust]\nweight: heavy\ndate: soon\n---\n"))
+	testify.NoError(err)
+	testify.Equal([]string{
+		"title is required",
+		"date must be a date, got soon",
+		"private must be a bool, got no",
+		"status must be one of draft, published, got live",
+		"tags must be one of go, web, got rust",
+		"weight must be a number, got heavy",
+	}, ValidateFrontmatter(fm, schema))
+
+	testify.Empty(ValidateFrontmatter(fm, config.FrontmatterSchema{}))
+}
+
+func TestFrontmatterRoundTripKeepsScalarTypes(t *testing.T) {
+	testify := assert.New(t)
+	sources := map[string]string{
+		"yaml": "---\ncreated: 1704412800\noffset: -5\ndraft: true\nzip: \"01234\"\nflag: \"true\"\nempty:\ntags: [go, \"1\", 2]\n---\n",
+		"toml": "+++\ncreated = 1704412800\noffset = -5\ndraft = true\nzip = \"01234\"\nflag = \"true\"\ntags = [\"go\", \"1\"]\n+++\n",
+	}
+	for name, src := range sources {
+		fm, _, err := ExtractFrontmatter([]byte(src))
+		testify.NoError(err, name)
+		fm.SetValue("updated", int64(1704500000))
+		fm.SetValue("weight", 3)
+
+		out, err := fm.Marshal()
+		testify.NoError(err, name)
+		again, _, err := ExtractFrontmatter([]byte(out + "\n"))
+		testify.NoError(err, name)
+
+		testify.Equal(int64(1704412800), again.DataKV["created"], name)
+		testify.Equal(int64(-5), again.DataKV["offset"], name)
+		testify.Equal(int64(1704500000), again.DataKV["updated"], name)
+		testify.Equal(int64(3), again.DataKV["weight"], name)
+		testify.Equal(true, again.DataKV["draft"], name)
+		testify.Equal("01234", again.DataKV["zip"], name)
+		testify.Equal("true", again.DataKV["flag"], name)
+		testify.Equal([]string{"go", "1"}, again.GetStringSlice("tags")[:2], name)
+
+		// the values read back must be exactly what was there before the save
+		for key, value := range fm.DataKV {
+			testify.Equal(value, again.DataKV[key], "%s: %s", name, key)
+		}
+	}
+}
+
+func TestFrontmatterGetNested(t *testing.T) {
+	testify := assert.New(t)
+	sources := map[string]string{
+		"yaml": "---\nseo:\n  description: A post\n  og:\n    image: /a.png\nauthors:\n  - name: Ann\n  - name: Bob\n---\n",
+		"toml": "+++\n[seo]\ndescription = \"A post\"\n[seo.og]\nimage = \"/a.png\"\n\n[[authors]]\nname = \"Ann\"\n[[authors]]\nname = \"Bob\"\n+++\n",
+	}
+	for name, src := range sources {
+		fm, _, err := ExtractFrontmatter([]byte(src))
+		testify.NoError(err, name)
+
+		value, ok := fm.GetNested("seo.description")
+		testify.True(ok, name)
+		testify.Equal("A post", value, name)
+
+		value, ok = fm.GetNested("seo.og.image")
+		testify.True(ok, name)
+		testify.Equal("/a.png", value, name)
+
+		value, ok = fm.GetNested("authors.1.name")
+		testify.True(ok, name)
+		testify.Equal("Bob", value, name)
+
+		for _, missing := range []string{"seo.keywords", "seo.description.more", "authors.2.name", "authors.x", "nope.deeper", ""} {
+			value, ok = fm.GetNested(missing)
+			testify.False(ok, "%s: %s", name, missing)
+			testify.Nil(value, "%s: %s", name, missing)
+		}
+		testify.Nil(fm.Param("seo.keywords"), name)
+		testify.Equal("Ann", fm.Param("authors.0.name"), name)
+	}
+
+	var none *FrontmatterData
+	_, ok := none.GetNested("seo.description")
+	testify.False(ok)
+}
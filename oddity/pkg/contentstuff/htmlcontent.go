@@ -0,0 +1,51 @@
+package contentstuff
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// ParseHTML parses an html content file, an optional frontmatter block is split off
+// and the rest is served as it is, the title comes from the frontmatter
+func (mp *MarkdownParser) ParseHTML(content []byte) (*ParsedContent, error) {
+	result := &ParsedContent{}
+
+	bodyContent := content
+	if mp.config.EnableFrontmatter {
+		var err error
+		result.Frontmatter, bodyContent, err = ExtractFrontmatter(content)
+		if err != nil {
+			return nil, fmt.Errorf("frontmatter parsing error: %w", err)
+		}
+	}
+	if title, ok := result.Frontmatter.GetString("title"); ok {
+		result.Title = title
+	}
+
+	result.Body = bodyContent
+	result.HTML = bodyContent
+	result.PlainText = htmlPlainText(bodyContent)
+	return result, nil
+}
+
+var htmlTagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// htmlPlainText drops the tags of an html fragment and collapses its whitespace
+func htmlPlainText(content []byte) string {
+	text := htmlTagPattern.ReplaceAllString(string(content), " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// ToHTML reconstructs an html content file, its frontmatter followed by the body
+func (pc *ParsedContent) ToHTML() (string, error) {
+	fmStr, err := pc.Frontmatter.Marshal()
+	if err != nil {
+		return "", err
+	}
+	if fmStr == "" {
+		return string(pc.Body), nil
+	}
+	return fmStr + "\n" + string(pc.Body), nil
+}
@@ -3,6 +3,7 @@ package contentstuff
 import (
 	"html/template"
 	"math"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -98,6 +99,20 @@ func (p *Page) Slug() string {
 	return pgslug
 }
 
+// Aliases returns the frontmatter aliases of the page as slugs without surrounding slashes
+func (p *Page) Aliases() []string {
+	if p.File.ParsedContent == nil || p.File.ParsedContent.Frontmatter == nil {
+		return nil
+	}
+	var aliases []string
+	for _, alias := range p.File.ParsedContent.Frontmatter.GetStringSlice("aliases") {
+		if alias = strings.Trim(alias, "/"); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
 func timeFromMilliOrSeconds(ts int64) time.Time {
 	if ts > 1e12 {
 		return time.Unix(ts/1000, 0)
@@ -203,3 +218,50 @@ func (p *Page) IsPrivate() bool {
 	}
 	return false
 }
+
+// IsPinned checks if the page is pinned to the top of listings in frontmatter
+func (p *Page) IsPinned() bool {
+	return p.Frontmatter().GetBool("pinned")
+}
+
+// NoIndex checks if the page asks search engines not to index it, it is also left out of the sitemap and feeds
+func (p *Page) NoIndex() bool {
+	return p.Frontmatter().GetBool("noindex")
+}
+
+// Frontmatter returns the page's frontmatter, nil when it has none
+func (p *Page) Frontmatter() *FrontmatterData {
+	if p.File.ParsedContent == nil {
+		return nil
+	}
+	return p.File.ParsedContent.Frontmatter
+}
+
+// Author is the frontmatter author, either a name or a map with a name, falling back to defaultAuthor
+func (p *Page) Author(defaultAuthor string) string {
+	fm := p.Frontmatter()
+	if author, ok := fm.GetString("author"); ok && strings.TrimSpace(author) != "" {
+		return strings.TrimSpace(author)
+	}
+	if name, ok := fm.GetNested("author.name"); ok {
+		if name, ok := name.(string); ok && strings.TrimSpace(name) != "" {
+			return strings.TrimSpace(name)
+		}
+	}
+	return defaultAuthor
+}
+
+// Canonical is the frontmatter canonical url, set on syndicated posts whose original lives elsewhere
+// it is empty unless the value is an absolute http or https url
+func (p *Page) Canonical() string {
+	canonical, ok := p.Frontmatter().GetString("canonical")
+	if !ok {
+		return ""
+	}
+	canonical = strings.TrimSpace(canonical)
+	u, err := url.Parse(canonical)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return canonical
+}
@@ -3,9 +3,12 @@ package contentstuff
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"net/url"
 	"path"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/ast"
@@ -22,8 +25,41 @@ type ParserConfig struct {
 	LazyLoadImages       bool
 	SmartypantsFractions bool
 
+	// Markdown extensions
+	EnableTables          bool
+	EnableStrikethrough   bool
+	EnableFootnotes       bool
+	EnableDefinitionLists bool
+	EnableMath            bool
+	EnableAutolinks       bool
+
+	// EnableHandleLinks links bare @handle to HandleLinkBase+handle,
+	// EnableIssueLinks links bare #123 to IssueLinkBase+123 instead of treating it as a hashtag
+	EnableHandleLinks bool
+	HandleLinkBase    string
+	EnableIssueLinks  bool
+	IssueLinkBase     string
+
+	// EnableEmoji converts :shortcode: emoji to unicode, or to <img> tags under EmojiImageBaseURL (e.g. twemoji)
+	EnableEmoji       bool
+	EmojiImageBaseURL string
+
+	// External link policy, links to hosts other than SiteHost are external
+	SiteHost                string
+	ExternalLinkRel         bool
+	ExternalLinkTargetBlank bool
+
 	WikiLinkRenderer  func(string) string
 	ShortcodeRenderer func(string) string
+
+	// WikiLinkResolution is the configured wiki link strategy, see config.WikiLinksSlug
+	WikiLinkResolution string
+	// WikiLinkResolver returns the href of a wiki link target, false when no page matches,
+	// when set it replaces WikiLinkRenderer and links it cannot resolve get the broken class
+	WikiLinkResolver func(target string) (string, bool)
+
+	// AssetVersion returns the cache-busting version of a local image url, false leaves the url as is
+	AssetVersion func(src string) (string, bool)
 }
 
 // DefaultParserConfig returns a default parser configuration
@@ -36,6 +72,14 @@ func DefaultParserConfig() *ParserConfig {
 		LazyLoadImages:       true,
 		SmartypantsFractions: false,
 
+		EnableTables:          true,
+		EnableStrikethrough:   true,
+		EnableFootnotes:       false,
+		EnableDefinitionLists: true,
+		EnableMath:            false,
+		EnableAutolinks:       true,
+		EnableEmoji:           false,
+
 		WikiLinkRenderer: func(linkText string) string {
 			// allow setting title with pipe syntax [[link-slug|Display Text]]
 			parts := strings.SplitN(linkText, "|", 2)
@@ -70,8 +114,15 @@ type ParsedContent struct {
 	WikiLinks   []string
 	Shortcodes  []ShortcodeData
 	Headings    []HeadingData
+	Definitions []DefinitionItem
+	HasMath     bool
 	Title       string
 	HTML        []byte
+
+	// AssetVersions is the ?v= version each local image url was rendered with
+	AssetVersions map[string]string
+	// WikiLinkHrefs is the href each wiki link target resolved to, empty for a broken link
+	WikiLinkHrefs map[string]string
 }
 
 // ToMarkdown reconstructs the markdown content from parsed parts
@@ -116,9 +167,11 @@ type MarkdownParser struct {
 	parser   *parser.Parser
 	renderer *html.Renderer
 
-	hashtags   *[]string
-	shortcodes *[]ShortcodeData
-	wikilinks  *[]string
+	hashtags      *[]string
+	shortcodes    *[]ShortcodeData
+	wikilinks     *[]string
+	wikiLinkHrefs map[string]string
+	hasMath       bool
 }
 
 // NewMarkdownParser creates a new parser with the given configuration
@@ -136,14 +189,36 @@ func NewMarkdownParser(config *ParserConfig) *MarkdownParser {
 	return mp
 }
 
-// initializeParser sets up the markdown parser with extensions and inline parsers
-func (mp *MarkdownParser) initializeParser() {
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.Attributes
-	if !mp.config.SmartypantsFractions {
-		extensions = extensions &^ parser.MathJax
+// toggledExtensions maps each configurable extension to its parser bit
+var toggledExtensions = parser.Tables | parser.Strikethrough | parser.Footnotes |
+	parser.DefinitionLists | parser.MathJax | parser.Autolink
+
+// extensions builds the parser extension flags from the config toggles
+func (mp *MarkdownParser) extensions() parser.Extensions {
+	extensions := (parser.CommonExtensions | parser.AutoHeadingIDs | parser.Attributes) &^ toggledExtensions
+
+	toggles := []struct {
+		enabled bool
+		flag    parser.Extensions
+	}{
+		{mp.config.EnableTables, parser.Tables},
+		{mp.config.EnableStrikethrough, parser.Strikethrough},
+		{mp.config.EnableFootnotes, parser.Footnotes},
+		{mp.config.EnableDefinitionLists, parser.DefinitionLists},
+		{mp.config.EnableMath, parser.MathJax},
+		{mp.config.EnableAutolinks, parser.Autolink},
+	}
+	for _, t := range toggles {
+		if t.enabled {
+			extensions |= t.flag
+		}
 	}
+	return extensions
+}
 
-	mp.parser = parser.NewWithExtensions(extensions)
+// initializeParser sets up the markdown parser with extensions and inline parsers
+func (mp *MarkdownParser) initializeParser() {
+	mp.parser = parser.NewWithExtensions(mp.extensions())
 
 	// Register inline parsers based on configuration
 	if mp.config.EnableWikiLinks {
@@ -179,10 +254,80 @@ func (mp *MarkdownParser) initializeRenderer() {
 		htmlFlags = htmlFlags | html.LazyLoadImages
 	}
 
-	opts := html.RendererOptions{Flags: htmlFlags}
+	opts := html.RendererOptions{Flags: htmlFlags, RenderNodeHook: mp.renderHook}
 	mp.renderer = html.NewRenderer(opts)
 }
 
+// renderHook overrides rendering of code fences with attributes and, when enabled, math
+func (mp *MarkdownParser) renderHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	if codeBlock, ok := node.(*ast.CodeBlock); ok {
+		return ast.GoToNext, renderCodeBlock(w, codeBlock)
+	}
+	if mp.config.EnableMath {
+		return mp.mathRenderHook(w, node, entering)
+	}
+	return ast.GoToNext, false
+}
+
+// applyExternalLinkPolicy adds rel/target attributes to links that point off-site
+func (mp *MarkdownParser) applyExternalLinkPolicy(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		link, ok := node.(*ast.Link)
+		if !entering || !ok || !mp.isExternalLink(string(link.Destination)) {
+			return ast.GoToNext
+		}
+
+		if mp.config.ExternalLinkRel {
+			link.AdditionalAttributes = append(link.AdditionalAttributes, `rel="noopener noreferrer"`)
+		}
+		if mp.config.ExternalLinkTargetBlank {
+			link.AdditionalAttributes = append(link.AdditionalAttributes, `target="_blank"`)
+		}
+		return ast.GoToNext
+	})
+}
+
+// isExternalLink reports whether dest is an absolute http(s) url to another host
+func (mp *MarkdownParser) isExternalLink(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	return !strings.EqualFold(u.Hostname(), mp.config.SiteHost)
+}
+
+// mathRenderHook wraps $...$ and $$...$$ in markup a client-side KaTeX can typeset
+func (mp *MarkdownParser) mathRenderHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	var tex []byte
+	display := false
+
+	switch n := node.(type) {
+	case *ast.Math:
+		tex = n.Literal
+	case *ast.MathBlock:
+		if !entering {
+			return ast.GoToNext, true
+		}
+		tex = n.Literal
+		display = true
+	default:
+		return ast.GoToNext, false
+	}
+
+	mp.hasMath = true
+
+	var escaped bytes.Buffer
+	html.EscapeHTML(&escaped, tex)
+
+	if display {
+		fmt.Fprintf(w, `<div class="math display">\[%s\]</div>`, escaped.Bytes())
+	} else {
+		fmt.Fprintf(w, `<span class="math inline">\(%s\)</span>`, escaped.Bytes())
+	}
+
+	return ast.GoToNext, true
+}
+
 // Parse parses the complete markdown content including frontmatter
 func (mp *MarkdownParser) Parse(content []byte) (*ParsedContent, error) {
 	result := &ParsedContent{}
@@ -217,7 +362,24 @@ func (mp *MarkdownParser) Parse(content []byte) (*ParsedContent, error) {
 	}
 
 	result.Body = bodyContent
-	result.HTML = markdown.ToHTML(bodyContent, mp.parser, mp.renderer)
+	mp.hasMath = false
+	mp.wikiLinkHrefs = nil
+	doc := markdown.Parse(bodyContent, mp.parser)
+	if mp.config.EnableHandleLinks || mp.config.EnableIssueLinks {
+		mp.applyAutolinks(doc)
+	}
+	if mp.config.ExternalLinkRel || mp.config.ExternalLinkTargetBlank {
+		mp.applyExternalLinkPolicy(doc)
+	}
+	if mp.config.EnableEmoji {
+		mp.replaceEmojiShortcodes(doc)
+	}
+	if mp.config.AssetVersion != nil {
+		result.AssetVersions = mp.applyAssetVersions(doc)
+	}
+	result.HTML = markdown.Render(doc, mp.renderer)
+	result.HasMath = mp.hasMath
+	result.WikiLinkHrefs = mp.wikiLinkHrefs
 
 	// Extract hashtags if enabled
 	if mp.config.EnableHashtags && mp.hashtags != nil {
@@ -232,6 +394,11 @@ func (mp *MarkdownParser) Parse(content []byte) (*ParsedContent, error) {
 	// Extract images
 	result.Images = mp.ExtractImages(bodyContent, "")
 
+	// Extract definition lists
+	if mp.config.EnableDefinitionLists {
+		result.Definitions = mp.ExtractDefinitions(bodyContent)
+	}
+
 	// Extract shortcodes - get from parser state after HTML parsing
 	if mp.shortcodes != nil {
 		result.Shortcodes = *mp.shortcodes
@@ -333,6 +500,33 @@ func (mp *MarkdownParser) ExtractHeadings(content []byte) []HeadingData {
 	return ExtractHeadings(content)
 }
 
+// ExtractDefinitions collects term/definition pairs from definition lists
+func (mp *MarkdownParser) ExtractDefinitions(content []byte) []DefinitionItem {
+	var items []DefinitionItem
+	doc := markdown.Parse(content, parser.New())
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		item, ok := node.(*ast.ListItem)
+		if !ok || item.ListFlags&ast.ListTypeDefinition == 0 {
+			return ast.GoToNext
+		}
+
+		text := strings.TrimSpace(extractNodeText(item))
+		if item.ListFlags&ast.ListTypeTerm != 0 {
+			items = append(items, DefinitionItem{Term: text})
+		} else if len(items) > 0 {
+			last := &items[len(items)-1]
+			last.Definitions = append(last.Definitions, text)
+		}
+		return ast.SkipChildren
+	})
+
+	return items
+}
+
 func (mp *MarkdownParser) wikiLinkParser(fallback func(*parser.Parser, []byte, int) (int, ast.Node)) (func(*parser.Parser, []byte, int) (int, ast.Node), *[]string) {
 	wikilinks := make([]string, 0)
 
@@ -356,19 +550,36 @@ func (mp *MarkdownParser) wikiLinkParser(fallback func(*parser.Parser, []byte, i
 		}
 
 		linkText := string(data[2:i])
+		consumed := i + 2
 
 		// Record the wiki link
 		if mp.wikilinks != nil && linkText != "" {
 			*mp.wikilinks = append(*mp.wikilinks, linkText)
 		}
 
-		renderedLink := mp.config.WikiLinkRenderer(linkText)
+		var renderedLink string
+		if mp.config.WikiLinkResolver != nil {
+			renderedLink = renderResolvedWikiLink(linkText, mp.resolveWikiLink)
+		} else {
+			renderedLink = mp.config.WikiLinkRenderer(linkText)
+		}
+
+		// an attribute block right after the link, [[slug]]{.button}, applies to the anchor
+		if consumed < n && data[consumed] == '{' {
+			if end := bytes.IndexAny(data[consumed:], "}\n"); end > 0 && data[consumed+end] == '}' {
+				if attrs, ok := wikiLinkAttributes(string(data[consumed+1 : consumed+end])); ok {
+					renderedLink = withAnchorAttributes(renderedLink, attrs)
+					consumed += end + 1
+				}
+			}
+		}
+
 		link := &ast.HTMLSpan{
 			Leaf: ast.Leaf{Literal: []byte(renderedLink)},
 		}
 
 		// Return placeholder span instead of rendered link
-		return i + 2, link
+		return consumed, link
 	}
 
 	return parseFunc, &wikilinks
@@ -395,13 +606,17 @@ func (mp *MarkdownParser) hashtagParser() (func(*parser.Parser, []byte, int) (in
 		if i <= 1 {
 			return 0, nil
 		}
+		// #123 is left as text for the issue links
+		if mp.config.EnableIssueLinks && isIssueNumber(data[1:i]) {
+			return 0, nil
+		}
 
 		hashtagText := string(data[1:i])
 		hashtags = append(hashtags, hashtagText)
 
 		link := &ast.Link{
 			AdditionalAttributes: []string{`class="tag"`},
-			Destination:          []byte(fmt.Sprintf("/search/?q=%%23%s", hashtagText)),
+			Destination:          []byte(HashtagPath(hashtagText)),
 		}
 
 		// Replace underscores with spaces in display text
@@ -416,6 +631,11 @@ func (mp *MarkdownParser) hashtagParser() (func(*parser.Parser, []byte, int) (in
 	return parseFunc, &hashtags
 }
 
+// HashtagPath is the page a hashtag links to, a search for the tag
+func HashtagPath(tag string) string {
+	return fmt.Sprintf("/search/?q=%%23%s", tag)
+}
+
 // isHashtagChar checks if a character is valid for hashtags
 func isHashtagChar(c byte) bool {
 	return (c >= 'a' && c <= 'z') ||
@@ -490,6 +710,23 @@ func (mp *MarkdownParser) nodeToString(node ast.Node) string {
 	return buffer.String()
 }
 
+// Excerpt returns the body as plain text cut at a word boundary to at most maxChars, with … when cut
+func (pc *ParsedContent) Excerpt(maxChars int) string {
+	if pc == nil {
+		return ""
+	}
+	text := strings.Join(strings.Fields(ExtractPlainText(pc.Body)), " ")
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:maxChars])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
 // Utility functions for extracting specific data
 
 // ExtractPlainText extracts plain text from markdown content
@@ -548,7 +785,8 @@ func ExtractLinks(content []byte) []LinkData {
 // ExtractHeadings extracts all headings from markdown content
 func ExtractHeadings(content []byte) []HeadingData {
 	var headings []HeadingData
-	parser := parser.New()
+	// auto heading ids so ID matches the anchors in rendered html
+	parser := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs | parser.Attributes)
 	doc := markdown.Parse(content, parser)
 
 	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
@@ -581,9 +819,12 @@ func ExtractCodeBlocks(content []byte) []CodeBlockData {
 	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
 		if entering {
 			if codeBlock, ok := node.(*ast.CodeBlock); ok {
+				info := ParseCodeFenceInfo(string(codeBlock.Info))
 				code := CodeBlockData{
-					Language: string(codeBlock.Info),
-					Code:     string(codeBlock.Literal),
+					Language:  info.Language,
+					Filename:  info.Filename,
+					Highlight: info.Highlight,
+					Code:      string(codeBlock.Literal),
 				}
 				codeBlocks = append(codeBlocks, code)
 			}
@@ -632,10 +873,18 @@ type HeadingData struct {
 	ID    string `json:"id,omitempty"`
 }
 
+// DefinitionItem represents a term and its definitions from a definition list
+type DefinitionItem struct {
+	Term        string   `json:"term"`
+	Definitions []string `json:"definitions"`
+}
+
 // CodeBlockData represents a code block
 type CodeBlockData struct {
-	Language string `json:"language,omitempty"`
-	Code     string `json:"code"`
+	Language  string      `json:"language,omitempty"`
+	Filename  string      `json:"filename,omitempty"`
+	Highlight []LineRange `json:"highlight,omitempty"`
+	Code      string      `json:"code"`
 }
 
 // extractNodeText is a helper function to extract text from AST nodes
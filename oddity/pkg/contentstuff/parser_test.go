@@ -1,8 +1,17 @@
 package contentstuff
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+
+	"oddity/pkg/config"
 )
 
 func TestFrontmatterYAML(t *testing.T) {
@@ -323,3 +332,552 @@ This is a **test** post with #hashtags and a [[WikiLink]].`)
 	}
 
 }
+
+func TestFootnotesToggle(t *testing.T) {
+	content := []byte("Some claim.[^1]\n\n[^1]: The source.\n")
+
+	config := DefaultParserConfig()
+	config.EnableFootnotes = false
+	result, err := NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse content: %v", err)
+	}
+	if strings.Contains(string(result.HTML), `class="footnotes"`) {
+		t.Errorf("Expected no footnotes when disabled, got HTML: %s", result.HTML)
+	}
+
+	config = DefaultParserConfig()
+	config.EnableFootnotes = true
+	result, err = NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse content: %v", err)
+	}
+	if !strings.Contains(string(result.HTML), `class="footnotes"`) {
+		t.Errorf("Expected footnotes when enabled, got HTML: %s", result.HTML)
+	}
+}
+
+func TestMathIndependentOfSmartypants(t *testing.T) {
+	tests := []struct {
+		name         string
+		math         bool
+		smartypants  bool
+		expectMath   bool
+		expectSmarty bool
+	}{
+		{"math only", true, false, true, false},
+		{"smartypants only", false, true, false, true},
+		{"both", true, true, true, true},
+		{"neither", false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultParserConfig()
+			config.EnableMath = tt.math
+			config.SmartypantsFractions = tt.smartypants
+			mp := NewMarkdownParser(config)
+
+			if got := mp.extensions()&parser.MathJax != 0; got != tt.expectMath {
+				t.Errorf("MathJax extension = %v, want %v", got, tt.expectMath)
+			}
+			if got := mp.renderer.Opts.Flags&html.SmartypantsFractions != 0; got != tt.expectSmarty {
+				t.Errorf("SmartypantsFractions flag = %v, want %v", got, tt.expectSmarty)
+			}
+		})
+	}
+}
+
+func TestDefinitionLists(t *testing.T) {
+	content := []byte(`# Glossary
+
+Wire
+: The query engine that keeps generated sections fresh.
+
+Sidecar
+: The sqlite database storing history.
+: Lives next to the content dir.
+`)
+
+	parser := NewMarkdownParser(DefaultParserConfig())
+	result, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse content: %v", err)
+	}
+
+	htmlStr := string(result.HTML)
+	if !strings.Contains(htmlStr, "<dl>") || !strings.Contains(htmlStr, "<dt>Wire</dt>") {
+		t.Errorf("Expected definition list HTML, got: %s", htmlStr)
+	}
+
+	if len(result.Definitions) != 2 {
+		t.Fatalf("Expected 2 definitions, got %d: %+v", len(result.Definitions), result.Definitions)
+	}
+
+	expected := []DefinitionItem{
+		{Term: "Wire", Definitions: []string{"The query engine that keeps generated sections fresh."}},
+		{Term: "Sidecar", Definitions: []string{"The sqlite database storing history.", "Lives next to the content dir."}},
+	}
+	for i, want := range expected {
+		got := result.Definitions[i]
+		if got.Term != want.Term {
+			t.Errorf("Definition %d: expected term %q, got %q", i, want.Term, got.Term)
+		}
+		if strings.Join(got.Definitions, "|") != strings.Join(want.Definitions, "|") {
+			t.Errorf("Definition %d: expected %v, got %v", i, want.Definitions, got.Definitions)
+		}
+	}
+}
+
+func TestMathRendering(t *testing.T) {
+	content := []byte("Euler wrote $e^{i\\pi} + 1 = 0$ once.\n\n$$\na^2 + b^2 = c^2\n$$\n")
+
+	config := DefaultParserConfig()
+	config.EnableMath = true
+	result, err := NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse content: %v", err)
+	}
+
+	htmlStr := string(result.HTML)
+	if !result.HasMath {
+		t.Error("Expected math to be detected")
+	}
+	if !strings.Contains(htmlStr, `<span class="math inline">\(e^{i\pi} + 1 = 0\)</span>`) {
+		t.Errorf("Expected inline math wrapper, got HTML: %s", htmlStr)
+	}
+	if !strings.Contains(htmlStr, `<div class="math display">\[`) || !strings.Contains(htmlStr, `a^2 + b^2 = c^2`) {
+		t.Errorf("Expected display math wrapper, got HTML: %s", htmlStr)
+	}
+
+	config = DefaultParserConfig()
+	config.EnableMath = false
+	result, err = NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse content: %v", err)
+	}
+	if result.HasMath || strings.Contains(string(result.HTML), "math inline") {
+		t.Errorf("Expected no math when disabled, got HTML: %s", result.HTML)
+	}
+}
+
+func TestExternalLinkPolicy(t *testing.T) {
+	content := []byte(`Read [the docs](https://golang.org/doc), [my post](https://example.com/blog/post), [about](/about) and [mail](mailto:me@example.com).`)
+
+	config := DefaultParserConfig()
+	config.SiteHost = "example.com"
+	config.ExternalLinkRel = true
+	config.ExternalLinkTargetBlank = true
+	result, err := NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse content: %v", err)
+	}
+
+	htmlStr := string(result.HTML)
+	if !strings.Contains(htmlStr, `<a rel="noopener noreferrer" target="_blank" href="https://golang.org/doc">the docs</a>`) {
+		t.Errorf("Expected external link to get rel and target, got HTML: %s", htmlStr)
+	}
+	for _, internal := range []string{
+		`<a href="https://example.com/blog/post">my post</a>`,
+		`<a href="/about">about</a>`,
+		`<a href="mailto:me@example.com">mail</a>`,
+	} {
+		if !strings.Contains(htmlStr, internal) {
+			t.Errorf("Expected %s to be untouched, got HTML: %s", internal, htmlStr)
+		}
+	}
+
+	config.ExternalLinkRel = false
+	config.ExternalLinkTargetBlank = false
+	result, err = NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("Failed to parse content: %v", err)
+	}
+	if strings.Contains(string(result.HTML), "noopener") {
+		t.Errorf("Expected no link policy when disabled, got HTML: %s", result.HTML)
+	}
+}
+
+func TestParseCodeFenceInfo(t *testing.T) {
+	tests := []struct {
+		info     string
+		expected CodeFenceInfo
+	}{
+		{"", CodeFenceInfo{}},
+		{"go", CodeFenceInfo{Language: "go"}},
+		{`go title="main.go" {2,4-5}`, CodeFenceInfo{Language: "go", Filename: "main.go", Highlight: []LineRange{{2, 2}, {4, 5}}}},
+		{`python {1, 3 - 4} filename='my script.py'`, CodeFenceInfo{Language: "python", Filename: "my script.py", Highlight: []LineRange{{1, 1}, {3, 4}}}},
+		{`js linenos=true {x,0,5-2,7}`, CodeFenceInfo{Language: "js", Highlight: []LineRange{{7, 7}}}},
+		{`{3}`, CodeFenceInfo{Highlight: []LineRange{{3, 3}}}},
+	}
+
+	for _, tt := range tests {
+		got := ParseCodeFenceInfo(tt.info)
+		if got.Language != tt.expected.Language || got.Filename != tt.expected.Filename || fmt.Sprint(got.Highlight) != fmt.Sprint(tt.expected.Highlight) {
+			t.Errorf("ParseCodeFenceInfo(%q) = %+v, want %+v", tt.info, got, tt.expected)
+		}
+	}
+
+	content := []byte("```go title=\"main.go\" {2,4-5}\npackage main\n\nfunc main() {\n}\n```\n\n```sh\nls\n```\n")
+	blocks := ExtractCodeBlocks(content)
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 code blocks, got %d", len(blocks))
+	}
+	if blocks[0].Language != "go" || blocks[0].Filename != "main.go" || len(blocks[0].Highlight) != 2 {
+		t.Errorf("unexpected code block data: %+v", blocks[0])
+	}
+
+	parsed, err := NewMarkdownParser(DefaultParserConfig()).Parse(content)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	html := string(parsed.HTML)
+	if !strings.Contains(html, `<div class="code-block"><div class="code-filename">main.go</div><pre data-highlight="2,4-5"><code class="language-go">package main`) {
+		t.Errorf("expected filename header and highlight marker, got:\n%s", html)
+	}
+	if !strings.Contains(html, `<pre><code class="language-sh">ls`) {
+		t.Errorf("expected plain fences to render as before, got:\n%s", html)
+	}
+}
+
+func TestEmojiShortcodes(t *testing.T) {
+	content := []byte("Launch :rocket: now, :not_an_emoji: stays. At 10:30:45 too.\n\n`:rocket:` in code\n\n```\n:rocket:\n```\n")
+
+	config := DefaultParserConfig()
+	parsed, err := NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if strings.Contains(string(parsed.HTML), "🚀") {
+		t.Errorf("expected shortcodes untouched when emoji is disabled, got:\n%s", parsed.HTML)
+	}
+
+	config.EnableEmoji = true
+	parsed, err = NewMarkdownParser(config).Parse(content)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	html := string(parsed.HTML)
+	if !strings.Contains(html, "Launch 🚀 now, :not_an_emoji: stays. At 10:30:45 too.") {
+		t.Errorf("expected :rocket: replaced and unknown shortcodes left literal, got:\n%s", html)
+	}
+	if !strings.Contains(html, "<code>:rocket:</code>") || !strings.Contains(html, "<pre><code>:rocket:\n</code></pre>") {
+		t.Errorf("expected shortcodes inside code to be left alone, got:\n%s", html)
+	}
+
+	config.EmojiImageBaseURL = "https://cdn.example.org/twemoji/"
+	parsed, err = NewMarkdownParser(config).Parse([]byte("Launch :rocket: now"))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	expected := `Launch <img class="emoji" alt="🚀" src="https://cdn.example.org/twemoji/1f680.svg"> now`
+	if !strings.Contains(string(parsed.HTML), expected) {
+		t.Errorf("expected emoji image %s, got:\n%s", expected, parsed.HTML)
+	}
+}
+
+func TestWikiLinkAttributes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"See [[about]]{.button} now.", `<p>See <a class="button" href="about">about</a> now.</p>`},
+		{"[[about|About us]]{.button .primary #cta}", `<p><a id="cta" class="button primary" href="about">About us</a></p>`},
+		{"[[about]]{target=_blank}", `<p><a target="_blank" href="about">about</a></p>`},
+		{"[[about]] {.button}", `<p><a href="about">about</a> {.button}</p>`},
+		{"[[about]]{not an attribute}", `<p><a href="about">about</a>{not an attribute}</p>`},
+	}
+	for _, tt := range tests {
+		result, err := NewMarkdownParser(DefaultParserConfig()).Parse([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", tt.input, err)
+		}
+		if got := strings.TrimSpace(string(result.HTML)); got != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestWikiLinkResolution(t *testing.T) {
+	files := map[string]string{
+		"notes/links.md":   "# Links\n\n[[My Page Title]] [[pages/target|the target]] [[target]] [[Missing Page]]{.button}\n",
+		"pages/target.md":  "# My Page Title\n\nBody.\n",
+		"pages/custom.md":  "---\nslug: renamed\n---\n# Custom\n",
+		"pages/custom2.md": "# Custom Two\n\n[[pages/renamed]] [[pages/custom]]\n",
+	}
+
+	tests := []struct {
+		strategy string
+		file     string
+		expected string
+	}{
+		{
+			config.WikiLinksTitle, "notes/links.md",
+			`<a href="/pages/target">My Page Title</a> <a href="/pages/target">the target</a> <a class="broken" href="target">target</a> <a class="button broken" href="Missing Page">Missing Page</a>`,
+		},
+		{
+			config.WikiLinksSlug, "notes/links.md",
+			`<a class="broken" href="My Page Title">My Page Title</a> <a href="/pages/target">the target</a> <a class="broken" href="target">target</a> <a class="button broken" href="Missing Page">Missing Page</a>`,
+		},
+		{config.WikiLinksSlug, "pages/custom2.md", `<a href="/pages/renamed">pages/renamed</a> <a class="broken" href="pages/custom">pages/custom</a>`},
+		{
+			config.WikiLinksFilename, "notes/links.md",
+			`<a class="broken" href="My Page Title">My Page Title</a> <a href="/pages/target">the target</a> <a href="/pages/target">target</a> <a class="button broken" href="Missing Page">Missing Page</a>`,
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, body := range files {
+			target := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				t.Fatalf("failed to create dir for %s: %v", name, err)
+			}
+			if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", name, err)
+			}
+		}
+		cs := NewContentStuff(&config.Config{
+			Content:  config.ContentConfig{ContentDir: dir},
+			Markdown: config.MarkdownConfig{WikiLinks: tt.strategy},
+		})
+		if err := cs.cms.scanContent(); err != nil {
+			t.Fatalf("failed to scan content: %v", err)
+		}
+
+		fd, ok := cs.DoPath(tt.file)
+		if !ok {
+			t.Fatalf("expected %s to be loaded", tt.file)
+		}
+		if got := string(fd.ParsedContent.HTML); !strings.Contains(got, tt.expected) {
+			t.Errorf("%s %s: expected %s, got %s", tt.strategy, tt.file, tt.expected, got)
+		}
+	}
+}
+
+func TestWikiLinksReresolveOnlyChangedPages(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	write("a.md", "---\naliases: [first]\n---\n# A\n\nSee [[b]] and [[d]].\n")
+	write("b.md", "# B\n\nPlain.\n")
+	write("c.md", "# C\n\nBack to [[a]].\n")
+
+	cs := NewContentStuff(&config.Config{
+		Content:  config.ContentConfig{ContentDir: dir},
+		Markdown: config.MarkdownConfig{WikiLinks: config.WikiLinksSlug},
+	})
+	if err := cs.cms.scanContent(); err != nil {
+		t.Fatalf("failed to scan content: %v", err)
+	}
+	a, _ := cs.DoPath("a")
+	if got := string(a.ParsedContent.HTML); !strings.Contains(got, `<a href="/b">`) || !strings.Contains(got, `class="broken"`) {
+		t.Fatalf("expected [[b]] to resolve and [[d]] to be broken, got %s", got)
+	}
+	c, _ := cs.DoPath("c")
+
+	write("d.md", "# D\n\nNew.\n")
+	if err := cs.cms.scanContent(); err != nil {
+		t.Fatalf("failed to rescan content: %v", err)
+	}
+
+	for _, p := range []string{"a", "a.md", "first"} {
+		fd, ok := cs.DoPath(p)
+		if !ok {
+			t.Fatalf("expected %s to be loaded", p)
+		}
+		if got := string(fd.ParsedContent.HTML); !strings.Contains(got, `<a href="/d">`) {
+			t.Errorf("%s: expected [[d]] to resolve after d.md was added, got %s", p, got)
+		}
+	}
+	if fd, _ := cs.DoPath("c"); fd.ParsedContent != c.ParsedContent {
+		t.Errorf("expected c.md, whose links did not change, not to be parsed again")
+	}
+}
+
+func TestWikiLinksReresolveAfterSave(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A\n\nSee [[New Page]].\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	cs := NewContentStuff(&config.Config{
+		Content: config.ContentConfig{
+			ContentDir: dir,
+			SidecarDB:  filepath.Join(t.TempDir(), "sidecar.db"),
+		},
+		Markdown: config.MarkdownConfig{WikiLinks: config.WikiLinksTitle},
+	})
+	if err := cs.LoadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+	linksTo := func(href string) bool {
+		fd, _ := cs.DoPath("a")
+		return strings.Contains(string(fd.ParsedContent.HTML), `<a href="`+href+`">`)
+	}
+	if linksTo("/b") {
+		t.Fatalf("expected [[New Page]] to be broken before b.md exists")
+	}
+
+	if err := SaveRawContent(cs, "b.md", "# New Page\n"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if !linksTo("/b") {
+		t.Errorf("expected [[New Page]] to resolve after b.md was created")
+	}
+	parsed, err := NewMarkdownParser(cs.ParserConfig()).Parse([]byte("[[New Page]]"))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if !strings.Contains(string(parsed.HTML), `<a href="/b">`) {
+		t.Errorf("expected ParserConfig to resolve wiki links, got %s", parsed.HTML)
+	}
+
+	if err := SaveRawContent(cs, "b.md", "# Retitled\n"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if linksTo("/b") {
+		t.Errorf("expected [[New Page]] to be broken again after b.md was retitled")
+	}
+}
+
+func TestAssetVersions(t *testing.T) {
+	uploadDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(uploadDir, "post"), 0755); err != nil {
+		t.Fatalf("failed to create upload dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(uploadDir, "post", "photo.png"), []byte("png"), 0644); err != nil {
+		t.Fatalf("failed to write upload: %v", err)
+	}
+
+	cfg := DefaultParserConfig()
+	cfg.LazyLoadImages = false
+	cfg.AssetVersion = uploadVersioner(uploadDir)
+	mp := NewMarkdownParser(cfg)
+
+	parsed, err := mp.Parse([]byte("![local](/uploads/post/photo.png)\n\n![remote](https://example.com/photo.png)\n\n![missing](/uploads/post/gone.png)\n"))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	got := string(parsed.HTML)
+
+	version, ok := uploadVersioner(uploadDir)("/uploads/post/photo.png")
+	if !ok || len(version) != 8 {
+		t.Fatalf("expected an 8 character version, got %q", version)
+	}
+	for _, want := range []string{
+		`src="/uploads/post/photo.png?v=` + version + `"`,
+		`src="https://example.com/photo.png"`,
+		`src="/uploads/post/gone.png"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %s in %s", want, got)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(uploadDir, "post", "photo.png"), []byte("a bigger png"), 0644); err != nil {
+		t.Fatalf("failed to rewrite upload: %v", err)
+	}
+	if changed, _ := uploadVersioner(uploadDir)("/uploads/post/photo.png"); changed == version {
+		t.Errorf("expected the version to change with the file, still %s", changed)
+	}
+}
+
+func TestAssetVersionsFollowReplacedUpload(t *testing.T) {
+	dir := t.TempDir()
+	uploadDir := t.TempDir()
+	photo := filepath.Join(uploadDir, "photo.png")
+	if err := os.WriteFile(photo, []byte("png"), 0644); err != nil {
+		t.Fatalf("failed to write upload: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "post.md"), []byte("# Post\n\n![photo](/uploads/photo.png)\n"), 0644); err != nil {
+		t.Fatalf("failed to write post: %v", err)
+	}
+
+	cs := NewContentStuff(&config.Config{
+		Content:  config.ContentConfig{ContentDir: dir, UploadDir: uploadDir},
+		Markdown: config.MarkdownConfig{AssetVersions: true},
+	})
+	if err := cs.cms.scanContent(); err != nil {
+		t.Fatalf("failed to scan content: %v", err)
+	}
+
+	versionedSrc := func() string {
+		fd, ok := cs.DoPath("post")
+		if !ok {
+			t.Fatalf("expected post to be loaded")
+		}
+		match := regexp.MustCompile(`/uploads/photo\.png\?v=\w+`).Find(fd.ParsedContent.HTML)
+		if match == nil {
+			t.Fatalf("expected a versioned image in %s", fd.ParsedContent.HTML)
+		}
+		return string(match)
+	}
+
+	before := versionedSrc()
+	if err := os.WriteFile(photo, []byte("a replaced png"), 0644); err != nil {
+		t.Fatalf("failed to replace upload: %v", err)
+	}
+	if after := versionedSrc(); after == before {
+		t.Errorf("expected the version to change with the replaced upload, still %s", after)
+	}
+}
+
+func TestAutolinks(t *testing.T) {
+	input := "See https://example.com/docs, ask @kalyan or fix #123.\n\nNot `@code` or `#42`, nor me@example.com, [@linked](/x) or &#123; and #12ab.\n"
+
+	tests := []struct {
+		name      string
+		configure func(*ParserConfig)
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name: "on",
+			configure: func(cfg *ParserConfig) {
+				cfg.EnableHandleLinks = true
+				cfg.HandleLinkBase = "https://github.com/"
+				cfg.EnableIssueLinks = true
+				cfg.IssueLinkBase = "https://github.com/kalyan02/blogdkr/issues/"
+			},
+			contains: []string{
+				`<a href="https://example.com/docs">https://example.com/docs</a>`,
+				`<a class="mention" href="https://github.com/kalyan">@kalyan</a>`,
+				`<a class="issue" href="https://github.com/kalyan02/blogdkr/issues/123">#123</a>.`,
+				`<code>@code</code>`,
+				`<code>#42</code>`,
+				`<a href="/x">@linked</a>`,
+			},
+			excludes: []string{`github.com/example.com`, `issues/12ab`, `issues/42`},
+		},
+		{
+			name: "off",
+			configure: func(cfg *ParserConfig) {
+				cfg.EnableAutolinks = false
+			},
+			contains: []string{"See https://example.com/docs, ask @kalyan or fix"},
+			excludes: []string{`<a href="https://example.com/docs">`, `class="mention"`, `class="issue"`},
+		},
+	}
+	for _, tt := range tests {
+		cfg := DefaultParserConfig()
+		cfg.EnableHashtags = true
+		tt.configure(cfg)
+		parsed, err := NewMarkdownParser(cfg).Parse([]byte(input))
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", tt.name, err)
+		}
+		got := string(parsed.HTML)
+		for _, want := range tt.contains {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: expected %s in %s", tt.name, want, got)
+			}
+		}
+		for _, unwanted := range tt.excludes {
+			if strings.Contains(got, unwanted) {
+				t.Errorf("%s: expected no %s in %s", tt.name, unwanted, got)
+			}
+		}
+	}
+}
@@ -2,26 +2,60 @@ package contentstuff
 
 import (
 	"path/filepath"
-	"strings"
 )
 
+// sectionMetaFile only carries settings for its directory, it is never served or listed as a page
+const sectionMetaFile = "_meta.md"
+
+// sectionMetaFiles are consulted, in order, for the frontmatter of a directory,
+// _meta.md only carries settings for the section while an index page is also served
+var sectionMetaFiles = []string{sectionMetaFile, "index.md", "index.html"}
+
+// IsSectionMeta reports whether fd is a directory's _meta.md rather than a page
+func IsSectionMeta(fd FileDetail) bool {
+	return fd.FileType == FileTypeMarkdown && filepath.Base(fd.FileName) == sectionMetaFile
+}
+
+// IsPrivate reports whether a file is private, either by its own private flag or inherited from
+// the nearest directory whose _meta.md or index page sets private, a private: false lower down
+// makes that part of the tree public again
 func IsPrivate(sc *ContentStuff, fd FileDetail) bool {
 	page := NewPageFromFileDetail(&fd)
-	if page.IsPrivate() {
-		return true
+	if page.Frontmatter().HasKey("private") {
+		return page.IsPrivate()
 	}
 
-	// check parent index
-	if strings.Contains(page.Slug(), "/") {
-		parentDir := filepath.Dir(page.Slug())
-		parentIdxPath := filepath.Join(parentDir, "index.md")
-		if parentIdxFile, ok := sc.DoPath(parentIdxPath); ok {
-			parentIdxPage := NewPageFromFileDetail(&parentIdxFile)
-			if parentIdxPage.IsPrivate() {
-
-				return true
-			}
+	// an index without its own flag falls through to the _meta.md beside it and then the parent directories
+	dir := filepath.Dir(fd.FileName)
+	if fd.FileType == FileTypeDirectory {
+		dir = fd.FileName
+	}
+	for ; dir != ""; dir = parentDir(dir) {
+		if private, ok := sectionPrivate(sc, dir); ok {
+			return private
 		}
 	}
 	return false
 }
+
+// sectionPrivate is the private flag set by the first section meta file of dir that has one
+func sectionPrivate(sc *ContentStuff, dir string) (bool, bool) {
+	for _, name := range sectionMetaFiles {
+		metaFile, ok := sc.DoPath(filepath.Join(dir, name))
+		if !ok {
+			continue
+		}
+		if fm := NewPageFromFileDetail(&metaFile).Frontmatter(); fm.HasKey("private") {
+			return fm.GetBool("private"), true
+		}
+	}
+	return false, false
+}
+
+// parentDir walks up one directory, it returns "" once past the content root
+func parentDir(dir string) string {
+	if dir == "." || dir == "" {
+		return ""
+	}
+	return filepath.Dir(dir)
+}
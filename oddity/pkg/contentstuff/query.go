@@ -55,8 +55,12 @@ const (
 	FormatTable        FormatType = "table"
 	FormatListWithDate FormatType = "list-date"
 	FormatDetailed     FormatType = "detailed"
+	FormatCard         FormatType = "card"
 )
 
+// DefaultExcerptLength is how many characters of a post the card format shows without an excerpt attribute
+const DefaultExcerptLength = 160
+
 // QueryFilter represents a filter condition
 type QueryFilter struct {
 	Field    string `json:"field"`
@@ -68,13 +72,18 @@ type QueryFilter struct {
 type QueryAST struct {
 	Type           QueryType     `json:"type"`
 	Path           string        `json:"path,omitempty"` // path filter pattern
+	ExcludePath    string        `json:"exclude_path,omitempty"`
 	SortType       SortType      `json:"sort_type,omitempty"`
 	SortOrder      SortOrder     `json:"sort_order,omitempty"`
 	Limit          int           `json:"limit,omitempty"`
 	Filters        []QueryFilter `json:"filters,omitempty"`
 	HTMLTemplate   string        `json:"html_template,omitempty"`
 	MDFormat       FormatType    `json:"md_format,omitempty"`
+	ExcerptLength  int           `json:"excerpt_length,omitempty"`
 	IncludePrivate bool          `json:"include_private,omitempty"`
+	// PinnedFirst puts pinned posts ahead of the rest, embedded queries and the directory listing
+	// set it while feeds stay in date order
+	PinnedFirst bool `json:"pinned_first,omitempty"`
 }
 
 // QueryXML represents the XML structure for parsing
@@ -82,12 +91,14 @@ type QueryXML struct {
 	XMLName      xml.Name `xml:"query"`
 	Type         string   `xml:"type,attr"`
 	Path         string   `xml:"path,attr"`
+	ExcludePath  string   `xml:"exclude-path,attr"`
 	Sort         string   `xml:"sort,attr"`
 	Order        string   `xml:"order,attr"`
 	Limit        string   `xml:"limit,attr"`
 	HTMLTemplate string   `xml:"html-template,attr"`
 	Format       string   `xml:"format,attr"`
 	MDFormat     string   `xml:"md-format,attr"`
+	Excerpt      string   `xml:"excerpt,attr"`
 	Where        string   `xml:"where,attr"`
 	Tag          string   `xml:"tag,attr"`
 	Private      string   `xml:"private,attr"`
@@ -116,6 +127,8 @@ func ParseQuery(queryString string) (*QueryAST, error) {
 	query := &QueryAST{
 		HTMLTemplate: queryXML.HTMLTemplate,
 		Path:         queryXML.Path,
+		ExcludePath:  queryXML.ExcludePath,
+		PinnedFirst:  true,
 	}
 
 	// Parse query type
@@ -177,6 +190,13 @@ func ParseQuery(queryString string) (*QueryAST, error) {
 		query.MDFormat = FormatListWithDate // default
 	}
 
+	// Parse excerpt length, used by the card format
+	if queryXML.Excerpt != "" {
+		if length, err := strconv.Atoi(queryXML.Excerpt); err == nil && length > 0 {
+			query.ExcerptLength = length
+		}
+	}
+
 	// Parse filters
 	if queryXML.Where != "" {
 		filter, err := parseWhereClause(queryXML.Where)
@@ -199,9 +219,20 @@ func ParseQuery(queryString string) (*QueryAST, error) {
 	return query, nil
 }
 
-// parseWhereClause parses a simple where clause like "tag contains 'project'"
+// parseWhereClause parses a simple where clause like "tag contains 'project'",
+// the exists operator takes no value, as in "category exists"
+// any operator is negated with a not- prefix, "tag not-contains 'draft'" or "tag not contains 'draft'"
 func parseWhereClause(whereClause string) (*QueryFilter, error) {
 	parts := strings.Fields(whereClause)
+	if len(parts) > 2 && strings.EqualFold(parts[1], "not") {
+		parts = append([]string{parts[0], "not-" + parts[2]}, parts[3:]...)
+	}
+	if len(parts) > 1 {
+		parts[1] = strings.ToLower(parts[1])
+	}
+	if len(parts) == 2 && strings.TrimPrefix(parts[1], "not-") == "exists" {
+		return &QueryFilter{Field: parts[0], Operator: parts[1]}, nil
+	}
 	if len(parts) < 3 {
 		return nil, fmt.Errorf("invalid where clause: %s", whereClause)
 	}
@@ -228,6 +259,10 @@ func (q *QueryAST) String() string {
 		parts = append(parts, fmt.Sprintf("path:%s", q.Path))
 	}
 
+	if q.ExcludePath != "" {
+		parts = append(parts, fmt.Sprintf("exclude-path:%s", q.ExcludePath))
+	}
+
 	if q.SortType != "" {
 		parts = append(parts, fmt.Sprintf("sort:%s", q.SortType))
 		if q.SortOrder != "" && q.SortOrder != SortAsc {
@@ -240,6 +275,10 @@ func (q *QueryAST) String() string {
 	}
 
 	for _, filter := range q.Filters {
+		if filter.Operator == "exists" || filter.Operator == "not-exists" {
+			parts = append(parts, fmt.Sprintf("where:%s %s", filter.Field, filter.Operator))
+			continue
+		}
 		parts = append(parts, fmt.Sprintf("where:%s %s '%s'", filter.Field, filter.Operator, filter.Value))
 	}
 
@@ -251,5 +290,9 @@ func (q *QueryAST) String() string {
 		parts = append(parts, fmt.Sprintf("format:%s", q.MDFormat))
 	}
 
+	if q.ExcerptLength > 0 {
+		parts = append(parts, fmt.Sprintf("excerpt:%d", q.ExcerptLength))
+	}
+
 	return strings.Join(parts, " ")
 }
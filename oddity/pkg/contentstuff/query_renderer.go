@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"html/template"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 )
@@ -12,7 +11,12 @@ import (
 // QueryRenderer provides separate HTML rendering for query sections
 type QueryRenderer struct {
 	content   *ContentStuff
+	wire      *Wire
 	templates map[string]*template.Template
+
+	// request context used for access control on query results
+	ctx             *FileDetail
+	isAuthenticated bool
 }
 
 // QuerySection represents a detected query section in content
@@ -23,16 +27,33 @@ type QuerySection struct {
 	Content    string
 	Results    []FileDetail
 	HTMLOutput template.HTML
+
+	// TotalCount is the number of matches before the query limit was applied
+	TotalCount int
 }
 
 // NewQueryRenderer creates a new query-aware HTML renderer
-func NewQueryRenderer(content *ContentStuff) *QueryRenderer {
+// the wire is reused for filtering, sorting and limiting query results
+func NewQueryRenderer(content *ContentStuff, wire *Wire) *QueryRenderer {
+	if wire == nil {
+		wire = NewWire(content)
+	}
 	return &QueryRenderer{
 		content:   content,
+		wire:      wire,
 		templates: make(map[string]*template.Template),
 	}
 }
 
+// WithRequestContext returns a copy of the renderer bound to the page being rendered
+// and the viewer's auth state, so query sections respect private posts
+func (qr *QueryRenderer) WithRequestContext(ctx *FileDetail, isAuthenticated bool) *QueryRenderer {
+	bound := *qr
+	bound.ctx = ctx
+	bound.isAuthenticated = isAuthenticated
+	return &bound
+}
+
 // LoadTemplate loads a template for a specific query type
 func (qr *QueryRenderer) LoadTemplate(name string, templatePath string) error {
 	tmpl, err := template.ParseFiles(templatePath)
@@ -97,13 +118,10 @@ func (qr *QueryRenderer) extractQuerySections(content string) ([]QuerySection, e
 	lines := strings.Split(content, "\n")
 	sections := make([]QuerySection, 0)
 
-	queryStartRegex := regexp.MustCompile(`<!--\s*<query\s+([^>]+)>\s*-->`)
-	queryEndRegex := regexp.MustCompile(`<!--\s*</query>\s*-->`)
-
 	var currentSection *QuerySection
 
 	for i, line := range lines {
-		if matches := queryStartRegex.FindStringSubmatch(line); len(matches) > 1 {
+		if matches := queryStartPattern.FindStringSubmatch(line); len(matches) > 1 {
 			// Parse query attributes
 			xmlString := fmt.Sprintf("<query %s>", matches[1])
 			ast, err := ParseQuery(xmlString)
@@ -115,7 +133,7 @@ func (qr *QueryRenderer) extractQuerySections(content string) ([]QuerySection, e
 				Query:     ast,
 				StartLine: i,
 			}
-		} else if queryEndRegex.MatchString(line) && currentSection != nil {
+		} else if queryEndPattern.MatchString(line) && currentSection != nil {
 			// End of query found
 			currentSection.EndLine = i
 			currentSection.Content = qr.getContentLines(content, currentSection.StartLine, currentSection.EndLine+1)
@@ -154,28 +172,65 @@ func (qr *QueryRenderer) executePostsQueryForSection(section *QuerySection) erro
 	var allFiles = qr.content.AllFiles()
 	for _, file := range allFiles {
 		if (file.FileType == FileTypeMarkdown || file.FileType == FileTypeHTML) &&
-			!strings.HasSuffix(file.FileName, "index.md") {
+			!strings.HasSuffix(file.FileName, "index.md") && !IsSectionMeta(file) {
 			posts = append(posts, file)
 		}
 	}
 
+	posts = qr.applyViewerAccess(posts, section.Query)
+
 	// Apply filters, sorting, and limits (reuse Wire engine logic)
-	wire := NewWire(qr.content)
-	filtered := wire.applyFiltersToFiles(posts, section.Query.Filters)
-	sorted := wire.applySortToFiles(filtered, section.Query.SortType, section.Query.SortOrder)
-	limited := wire.applyLimitToFiles(sorted, section.Query)
+	filtered := qr.wire.applyFiltersToFiles(posts, section.Query.Filters)
+	sorted := qr.wire.applySortToFiles(filtered, section.Query.SortType, section.Query.SortOrder, section.Query.PinnedFirst)
+	limited := qr.wire.applyLimitToFiles(sorted, section.Query)
 
+	section.TotalCount = len(sorted)
 	section.Results = limited
 	return nil
 }
 
-// executeBacklinksQueryForSection executes a backlinks query
+// executeBacklinksQueryForSection executes a backlinks query against the bound page
 func (qr *QueryRenderer) executeBacklinksQueryForSection(section *QuerySection) error {
-	// TODO: Implement backlinks query execution
 	section.Results = []FileDetail{}
+	if qr.ctx == nil {
+		// without a page there is nothing to link back to
+		return nil
+	}
+
+	target := NewPageFromFileDetail(qr.ctx).Slug()
+
+	var linking []FileDetail
+	for _, file := range qr.content.AllFiles() {
+		if (file.FileType != FileTypeMarkdown && file.FileType != FileTypeHTML) || IsSectionMeta(file) {
+			continue
+		}
+		if file.FileName != qr.ctx.FileName && qr.wire.linksToSlug(file, target) {
+			linking = append(linking, file)
+		}
+	}
+
+	linking = qr.applyViewerAccess(linking, section.Query)
+
+	// Same sort/order/limit handling as posts queries
+	filtered := qr.wire.applyFiltersToFiles(linking, section.Query.Filters)
+	sorted := qr.wire.applySortToFiles(filtered, section.Query.SortType, section.Query.SortOrder, section.Query.PinnedFirst)
+	section.TotalCount = len(sorted)
+	section.Results = qr.wire.applyLimitToFiles(sorted, section.Query)
 	return nil
 }
 
+// applyViewerAccess lets authenticated viewers see everything, otherwise applies the wire's rules
+func (qr *QueryRenderer) applyViewerAccess(files []FileDetail, query *QueryAST) []FileDetail {
+	if qr.isAuthenticated {
+		return files
+	}
+	ctx := qr.ctx
+	if ctx == nil {
+		ctx = &FileDetail{}
+	}
+	return qr.wire.applyAccessControl(ctx, files, query)
+}
+
 // renderQuerySection renders a query section using custom template or fallback
 func (qr *QueryRenderer) renderQuerySection(section *QuerySection) (template.HTML, error) {
 	// Check if custom HTML template is specified
@@ -235,7 +290,7 @@ func (qr *QueryRenderer) renderPostsDefault(section *QuerySection) template.HTML
 		page := NewPageFromFileDetail(&file)
 		title := page.Title()
 		slug := page.Slug()
-		date := file.ModifiedAt.Format("2006-01-02")
+		date := qr.content.Config().Site.DisplayDate(file.ModifiedAt)
 
 		result.WriteString(`<div class="query-item">`)
 		result.WriteString(fmt.Sprintf(`<h3><a href="/%s">%s</a></h3>`, slug, title))
@@ -253,6 +308,10 @@ func (qr *QueryRenderer) renderPostsDefault(section *QuerySection) template.HTML
 		result.WriteString(`</div>`)
 	}
 
+	if section.TotalCount > len(section.Results) {
+		result.WriteString(fmt.Sprintf(`<p class="query-count text-sm text-gray-500">Showing %d of %d</p>`, len(section.Results), section.TotalCount))
+	}
+
 	result.WriteString(`</div>`)
 	return template.HTML(result.String())
 }
@@ -290,7 +349,7 @@ func (qr *QueryRenderer) prepareTemplateData(section *QuerySection) map[string]i
 		post := map[string]interface{}{
 			"Title":       page.Title(),
 			"Slug":        page.Slug(),
-			"Date":        file.ModifiedAt.Format("2006-01-02"),
+			"Date":        qr.content.Config().Site.DisplayDate(file.ModifiedAt),
 			"CreatedAt":   page.DateCreated(),
 			"ModifiedAt":  file.ModifiedAt,
 			"Tags":        page.Hashtags(),
@@ -304,6 +363,8 @@ func (qr *QueryRenderer) prepareTemplateData(section *QuerySection) map[string]i
 		"Query":      section.Query,
 		"Posts":      posts,
 		"Count":      len(posts),
+		"TotalCount": section.TotalCount,
+		"HasMore":    section.TotalCount > len(posts),
 		"UpdatedAt":  time.Now().Format("2006-01-02 15:04:05"),
 		"QueryType":  section.Query.Type.String(),
 		"HasResults": len(posts) > 0,
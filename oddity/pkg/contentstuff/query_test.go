@@ -1,11 +1,45 @@
 package contentstuff
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"oddity/pkg/config"
 )
 
+// newTestContent writes files into a temp content dir and scans it without a sidecar db
+func newTestContent(t *testing.T, files map[string]string) *ContentStuff {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	cs := NewContentStuff(&config.Config{Content: config.ContentConfig{ContentDir: dir}})
+	if err := cs.cms.scanContent(); err != nil {
+		t.Fatalf("failed to scan content: %v", err)
+	}
+	return cs
+}
+
+func slugsOf(files []FileDetail) []string {
+	var slugs []string
+	for _, fd := range files {
+		slugs = append(slugs, NewPageFromFileDetail(&fd).Slug())
+	}
+	return slugs
+}
+
 func TestParseQuery(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -397,3 +431,656 @@ End of page.`,
 		})
 	}
 }
+
+func TestQueryRendererMatchesWire(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/index.md": "# Blog\n",
+		"blog/one.md":   "---\ncreated: 1700000000\n---\n# One\n\n#golang",
+		"blog/two.md":   "---\ncreated: 1700100000\n---\n# Two\n\n#golang",
+		"blog/three.md": "---\ncreated: 1700200000\n---\n# Three\n",
+	})
+	wire := NewWire(cs)
+	renderer := NewQueryRenderer(cs, wire)
+
+	query, err := ParseQuery(`<query type="posts" tag="golang" sort="recent" limit="5">`)
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+
+	section := &QuerySection{Query: query}
+	if err := renderer.executeQueryForSection(section); err != nil {
+		t.Fatalf("failed to execute section: %v", err)
+	}
+
+	ctx, _ := cs.DoPath("blog/index.md")
+	expected := slugsOf(wire.executePostsQuery(&ctx, query))
+	got := slugsOf(section.Results)
+
+	if len(got) != 2 || len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("result %d mismatch: got %s, want %s", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestQueryRendererAccessControl(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/index.md":  "# Blog\n",
+		"blog/public.md": "---\ncreated: 1700000000\n---\n# Public\n",
+		"blog/secret.md": "---\ncreated: 1700100000\nprivate: true\n---\n# Secret\n",
+	})
+	ctx, _ := cs.DoPath("blog/index.md")
+	renderer := NewQueryRenderer(cs, nil)
+
+	query, err := ParseQuery(`<query type="posts" sort="recent">`)
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		isAuthenticated bool
+		expected        []string
+	}{
+		{"public view", false, []string{"blog/public"}},
+		{"authenticated view", true, []string{"blog/secret", "blog/public"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := &QuerySection{Query: query}
+			if err := renderer.WithRequestContext(&ctx, tt.isAuthenticated).executeQueryForSection(section); err != nil {
+				t.Fatalf("failed to execute section: %v", err)
+			}
+
+			got := slugsOf(section.Results)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range tt.expected {
+				if got[i] != tt.expected[i] {
+					t.Errorf("result %d mismatch: got %s, want %s", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBacklinksQuerySortAndLimit(t *testing.T) {
+	files := map[string]string{
+		"notes/target.md":    "# Target\n",
+		"notes/unrelated.md": "---\ncreated: 1700900000\n---\n# Unrelated\n\n[[notes/other]]",
+	}
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		files["notes/"+name+".md"] = fmt.Sprintf("---\ncreated: %d\n---\n# %s\n\nSee [[notes/target|the target]].", 1700000000+i*1000, name)
+	}
+	cs := newTestContent(t, files)
+	ctx, _ := cs.DoPath("notes/target.md")
+	wire := NewWire(cs)
+
+	query, err := ParseQuery(`<query type="backlinks" limit="3" sort="recent">`)
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+
+	expected := []string{"notes/e", "notes/d", "notes/c"}
+
+	section := &QuerySection{Query: query}
+	if err := NewQueryRenderer(cs, wire).WithRequestContext(&ctx, false).executeQueryForSection(section); err != nil {
+		t.Fatalf("failed to execute section: %v", err)
+	}
+
+	results := map[string][]string{
+		"renderer": slugsOf(section.Results),
+		"wire":     slugsOf(wire.executeBacklinksQuery(&ctx, query)),
+	}
+	for name, got := range results {
+		if len(got) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", name, expected, got)
+		}
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Errorf("%s: result %d mismatch: got %s, want %s", name, i, got[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestPinnedPostsLead(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/old.md":    "---\ncreated: 1600000000\npinned: true\n---\n# Old\n",
+		"blog/newer.md":  "---\ncreated: 1700000000\n---\n# Newer\n",
+		"blog/newest.md": "---\ncreated: 1710000000\n---\n# Newest\n",
+	})
+	wire := NewWire(cs)
+
+	expected := []string{"blog/old", "blog/newest", "blog/newer"}
+
+	for _, q := range []string{`<query type="posts" path="blog/*" sort="recent">`, `<query type="posts" path="blog/*" sort="date" order="asc">`} {
+		query, err := ParseQuery(q)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", q, err)
+		}
+		section := &QuerySection{Query: query}
+		if err := NewQueryRenderer(cs, wire).WithRequestContext(&FileDetail{}, false).executeQueryForSection(section); err != nil {
+			t.Fatalf("failed to execute section: %v", err)
+		}
+		for name, got := range map[string][]string{
+			"renderer": slugsOf(section.Results),
+			"wire":     slugsOf(wire.executePostsQuery(&FileDetail{}, query)),
+		} {
+			if len(got) != 3 || got[0] != "blog/old" {
+				t.Errorf("%s %s: expected the pinned post first, got %v", name, q, got)
+			}
+		}
+	}
+
+	got := slugsOf(wire.GetDirectoryPosts("blog"))
+	if len(got) != len(expected) {
+		t.Fatalf("directory listing: expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("directory listing: result %d mismatch: got %s, want %s", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestQueryListDateFormat(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/one.md": "---\ntitle: One\ncreated: 1704456000\n---\n# One\n", // 2024-01-05 12:00 UTC
+	})
+	cs.Config().Site.DateFormat = "Jan 2, 2006"
+	wire := NewWire(cs)
+
+	fd, ok := cs.DoPath("blog/one.md")
+	if !ok {
+		t.Fatalf("expected blog/one.md to exist")
+	}
+	results, _ := wire.formatResults([]FileDetail{fd}, FormatListWithDate, 0)
+	if len(results) != 1 || results[0] != "- Jan 5, 2024 - [One](/blog/one)" {
+		t.Errorf("expected configured date format in list output, got %v", results)
+	}
+
+	cs.Config().Site.DateFormat = ""
+	results, _ = wire.formatResults([]FileDetail{fd}, FormatListWithDate, 0)
+	if len(results) != 1 || results[0] != "- 2024-01-05 - [One](/blog/one)" {
+		t.Errorf("expected default date format in list output, got %v", results)
+	}
+}
+
+func TestQueryLimitDefaultAndCap(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 12; i++ {
+		files[fmt.Sprintf("blog/post-%02d.md", i)] = fmt.Sprintf("---\ncreated: %d\n---\n# Post %d\n", 1700000000+i, i)
+	}
+	cs := newTestContent(t, files)
+	cs.Config().Content.QueryDefaultLimit = 5
+	cs.Config().Content.QueryMaxLimit = 8
+	wire := NewWire(cs)
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{`<query type="posts">`, 5},
+		{`<query type="posts" limit="3">`, 3},
+		{`<query type="posts" limit="100000">`, 8},
+		{`<query type="posts" limit="-1">`, 5},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.query, err)
+		}
+		if got := len(wire.executePostsQuery(&FileDetail{}, query)); got != tt.expected {
+			t.Errorf("%s: expected %d results, got %d", tt.query, tt.expected, got)
+		}
+	}
+
+	if got := len(wire.GetRecentPosts()); got != 12 {
+		t.Errorf("expected internal listings to ignore query limits, got %d posts", got)
+	}
+}
+
+func TestQueryTotalCount(t *testing.T) {
+	files := map[string]string{"blog/index.md": "# Blog\n"}
+	for i := 0; i < 7; i++ {
+		files[fmt.Sprintf("blog/post-%d.md", i)] = fmt.Sprintf("---\ncreated: %d\n---\n# Post %d\n", 1700000000+i, i)
+	}
+	cs := newTestContent(t, files)
+	renderer := NewQueryRenderer(cs, NewWire(cs))
+
+	query, err := ParseQuery(`<query type="posts" sort="recent" limit="3">`)
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	section := &QuerySection{Query: query}
+	if err := renderer.executeQueryForSection(section); err != nil {
+		t.Fatalf("failed to execute section: %v", err)
+	}
+
+	if section.TotalCount != 7 || len(section.Results) != 3 {
+		t.Errorf("expected 3 of 7 results, got %d of %d", len(section.Results), section.TotalCount)
+	}
+
+	data := renderer.prepareTemplateData(section)
+	if data["Count"] != 3 || data["TotalCount"] != 7 || data["HasMore"] != true {
+		t.Errorf("unexpected template counts: Count=%v TotalCount=%v HasMore=%v", data["Count"], data["TotalCount"], data["HasMore"])
+	}
+}
+
+func TestIgnoreGlobs(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/post.md":           "# Post\n",
+		"_drafts/idea.md":        "# Idea\n",
+		"_drafts/nested/more.md": "# More\n",
+		"blog/card.tmpl.md":      "# Template\n",
+	})
+	cs.Config().Content.IgnoreGlobs = []string{"_drafts/**", "*.tmpl.md"}
+	if err := cs.ReloadContent(); err != nil {
+		t.Fatalf("failed to reload content: %v", err)
+	}
+
+	for _, p := range []string{"_drafts", "_drafts/idea.md", "_drafts/idea", "_drafts/nested/more.md", "blog/card.tmpl.md"} {
+		if _, ok := cs.DoPath(p); ok {
+			t.Errorf("expected %s to be ignored", p)
+		}
+	}
+	if _, ok := cs.DoPath("blog/post"); !ok {
+		t.Errorf("expected blog/post to be loaded")
+	}
+
+	if err := cs.RefreshContent("_drafts/idea.md"); err != nil {
+		t.Errorf("refreshing an ignored file should be a no-op, got %v", err)
+	}
+	if _, ok := cs.DoPath("_drafts/idea.md"); ok {
+		t.Errorf("expected refresh to keep ignoring _drafts/idea.md")
+	}
+
+	query, _ := ParseQuery(`<query type="posts">`)
+	if got := slugsOf(NewWire(cs).executePostsQuery(&FileDetail{}, query)); len(got) != 1 || got[0] != "blog/post" {
+		t.Errorf("expected only blog/post in query results, got %v", got)
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	cs := newTestContent(t, map[string]string{"blog/post.md": "# Post\n"})
+	contentDir := cs.Config().Content.ContentDir
+
+	shared := t.TempDir()
+	for name, body := range map[string]string{"a.md": "# A\n", "sub/b.md": "# B\n"} {
+		target := filepath.Join(shared, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(contentDir, "shared"):     shared,
+		filepath.Join(shared, "sub", "loop"):    shared,     // cycle back into the linked dir
+		filepath.Join(contentDir, "blog", "up"): contentDir, // cycle back to the content root
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	if err := cs.ReloadContent(); err != nil {
+		t.Fatalf("failed to reload content: %v", err)
+	}
+	if _, ok := cs.DoPath("shared/a"); ok {
+		t.Errorf("expected symlinked content to be skipped by default")
+	}
+
+	cs.Config().Content.FollowSymlinks = true
+	if err := cs.ReloadContent(); err != nil {
+		t.Fatalf("failed to reload content: %v", err)
+	}
+	for _, p := range []string{"blog/post", "shared/a", "shared/sub/b"} {
+		if _, ok := cs.DoPath(p); !ok {
+			t.Errorf("expected %s to be indexed through the symlink", p)
+		}
+	}
+	for _, p := range []string{"shared/sub/loop/a", "blog/up/blog/post"} {
+		if _, ok := cs.DoPath(p); ok {
+			t.Errorf("expected cycle %s to be skipped", p)
+		}
+	}
+}
+
+func TestFollowSymlinksPrefersRealDirectory(t *testing.T) {
+	cs := newTestContent(t, map[string]string{"z-real/post.md": "# Post\n"})
+	contentDir := cs.Config().Content.ContentDir
+	if err := os.Symlink(filepath.Join(contentDir, "z-real"), filepath.Join(contentDir, "a-link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	cs.Config().Content.FollowSymlinks = true
+	if err := cs.ReloadContent(); err != nil {
+		t.Fatalf("failed to reload content: %v", err)
+	}
+	if _, ok := cs.DoPath("z-real/post"); !ok {
+		t.Errorf("expected the real directory to be indexed")
+	}
+	if _, ok := cs.DoPath("a-link/post"); ok {
+		t.Errorf("expected the link that sorts first not to claim the directory")
+	}
+}
+
+func TestQueryCardFormat(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/one.md": "---\ntitle: One\ncreated: 1704456000\n---\nThe quick brown fox jumps over the lazy dog.\n",
+	})
+	wire := NewWire(cs)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{
+			`<query type="posts" path="blog/*" md-format="card">`,
+			[]string{"- [One](/blog/one)", "  Date: 2024-01-05", "  The quick brown fox jumps over the lazy dog."},
+		},
+		{
+			`<query type="posts" path="blog/*" md-format="card" excerpt="20">`,
+			[]string{"- [One](/blog/one)", "  Date: 2024-01-05", "  The quick brown fox…"},
+		},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.query, err)
+		}
+		results, err := wire.executeQuery(&FileDetail{}, query)
+		if err != nil {
+			t.Fatalf("failed to execute %s: %v", tt.query, err)
+		}
+		if fmt.Sprint(results) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.query, tt.expected, results)
+		}
+	}
+}
+
+func TestQueryFrontmatterFilters(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/go.md":      "---\ntitle: Go\ncreated: 1704456000\ncategory: tech\nseries: [basics, tooling]\n---\n# Go\n",
+		"blog/rust.md":    "---\ntitle: Rust\ncreated: 1704456100\ncategory: technology\n---\n# Rust\n",
+		"blog/bread.md":   "---\ntitle: Bread\ncreated: 1704456200\ncategory: food\n---\n# Bread\n",
+		"blog/untyped.md": "---\ntitle: Untyped\ncreated: 1704456300\n---\n# Untyped\n",
+	})
+	wire := NewWire(cs)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`<query type="posts" path="blog/*" where="category equals 'tech'">`, []string{"blog/go"}},
+		{`<query type="posts" path="blog/*" where="category contains 'tech'">`, []string{"blog/rust", "blog/go"}},
+		{`<query type="posts" path="blog/*" where="category exists">`, []string{"blog/bread", "blog/rust", "blog/go"}},
+		{`<query type="posts" path="blog/*" where="series equals 'tooling'">`, []string{"blog/go"}},
+		{`<query type="posts" path="blog/*" where="missing exists">`, nil},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.query, err)
+		}
+		got := slugsOf(wire.executePostsQuery(&FileDetail{}, query))
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestQueryNegatedFilters(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/go.md":      "---\ntitle: Go\ncreated: 1704456000\ncategory: tech\n---\n# Go\n\nNotes on #golang.\n",
+		"blog/wip.md":     "---\ntitle: WIP\ncreated: 1704456100\ncategory: tech\n---\n# WIP\n\nStill a #draft.\n",
+		"blog/diary.md":   "---\ntitle: Diary\ncreated: 1704456200\ncategory: personal\n---\n# Diary\n",
+		"blog/untyped.md": "---\ntitle: Untyped\ncreated: 1704456300\n---\n# Untyped\n",
+	})
+	wire := NewWire(cs)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`<query type="posts" path="blog/*" where="tag not-contains 'draft'">`, []string{"blog/untyped", "blog/diary", "blog/go"}},
+		{`<query type="posts" path="blog/*" where="category not-equals 'personal'">`, []string{"blog/untyped", "blog/wip", "blog/go"}},
+		{`<query type="posts" path="blog/*" where="category not equals 'personal'">`, []string{"blog/untyped", "blog/wip", "blog/go"}},
+		{`<query type="posts" path="blog/*" where="category not-exists">`, []string{"blog/untyped"}},
+		{`<query type="posts" path="blog/*" where="category not-equals 'personal'" tag="draft">`, []string{"blog/wip"}},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.query, err)
+		}
+		got := slugsOf(wire.executePostsQuery(&FileDetail{}, query))
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestQueryExcludePath(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/one.md":          "---\ntitle: One\ncreated: 1704456000\n---\n# One\n",
+		"blog/2024/two.md":     "---\ntitle: Two\ncreated: 1704456100\n---\n# Two\n",
+		"blog/drafts/three.md": "---\ntitle: Three\ncreated: 1704456200\n---\n# Three\n",
+		"blog/drafts/old/x.md": "---\ntitle: X\ncreated: 1704456300\n---\n# X\n",
+	})
+	wire := NewWire(cs)
+
+	query, err := ParseQuery(`<query type="posts" path="blog/**" exclude-path="blog/drafts/**">`)
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	got := slugsOf(wire.executePostsQuery(&FileDetail{}, query))
+	expected := []string{"blog/2024/two", "blog/one"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected drafts to be excluded, got %v", got)
+	}
+}
+
+func TestQuerySortTieBreaker(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/alpha.md": "---\ntitle: Same\ncreated: 1704456000\n---\n# Same\n",
+		"blog/beta.md":  "---\ntitle: Same\ncreated: 1704456000\n---\n# Same\n",
+		"blog/gamma.md": "---\ntitle: Same\ncreated: 1704456000\n---\n# Same\n",
+		"blog/delta.md": "---\ntitle: Same\ncreated: 1704456000\n---\n# Same\n",
+	})
+	wire := NewWire(cs)
+
+	expected := []string{"blog/alpha", "blog/beta", "blog/delta", "blog/gamma"}
+	for _, attrs := range []string{`sort="date"`, `sort="date" order="asc"`, `sort="title"`} {
+		query, err := ParseQuery(`<query type="posts" path="blog/*" ` + attrs + `>`)
+		if err != nil {
+			t.Fatalf("failed to parse query: %v", err)
+		}
+		for i := 0; i < 20; i++ {
+			got := slugsOf(wire.executePostsQuery(&FileDetail{}, query))
+			if fmt.Sprint(got) != fmt.Sprint(expected) {
+				t.Fatalf("%s run %d: expected ties ordered by slug %v, got %v", attrs, i, expected, got)
+			}
+		}
+	}
+}
+
+func TestUpdateQuerySkipsUnchangedBlock(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.md":    "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n- [One](/blog/one)\n<!-- </query> -->\n",
+		"blog/one.md": "---\ntitle: One\ncreated: 1704456000\n---\n# One\n",
+	}
+	for name, body := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	cs := NewContentStuff(&config.Config{Content: config.ContentConfig{
+		ContentDir: dir,
+		SidecarDB:  filepath.Join(t.TempDir(), "sidecar.db"),
+	}})
+	if err := cs.LoadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+	wire := NewWire(cs)
+	if err := wire.ScanForQueries(); err != nil {
+		t.Fatalf("failed to scan queries: %v", err)
+	}
+
+	index := filepath.Join(dir, "index.md")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(index, past, past); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+	fd, _ := cs.DoPath("index.md")
+	historyBefore := len(cs.GetHistory("index.md"))
+
+	if err := wire.updateQuery(&fd, wire.queries["index.md"][0]); err != nil {
+		t.Fatalf("updateQuery failed: %v", err)
+	}
+	info, err := os.Stat(index)
+	if err != nil {
+		t.Fatalf("failed to stat index: %v", err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("expected unchanged query block not to be written, mtime moved to %v", info.ModTime())
+	}
+	if got := len(cs.GetHistory("index.md")); got != historyBefore {
+		t.Errorf("expected no new history row, had %d now %d", historyBefore, got)
+	}
+
+	// a new post changes the block, so the file is written and recorded
+	if err := SaveRawContent(cs, "blog/two.md", "---\ntitle: Two\ncreated: 1704456100\n---\n# Two\n"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := wire.updateQuery(&fd, wire.queries["index.md"][0]); err != nil {
+		t.Fatalf("updateQuery failed: %v", err)
+	}
+	if got := len(cs.GetHistory("index.md")); got != historyBefore+1 {
+		t.Errorf("expected a history row for the changed block, had %d now %d", historyBefore, got)
+	}
+	content, _ := os.ReadFile(index)
+	if !strings.Contains(string(content), "- [Two](/blog/two)\n- [One](/blog/one)\n") {
+		t.Errorf("expected the new post in the query block, got:\n%s", content)
+	}
+}
+
+func TestUpdateQueryRelocatesMarkers(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.md":    "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n<!-- </query> -->\n\nFooter.\n",
+		"blog/one.md": "---\ntitle: One\ncreated: 1704456000\n---\n# One\n",
+	}
+	for name, body := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	cs := NewContentStuff(&config.Config{Content: config.ContentConfig{
+		ContentDir: dir,
+		SidecarDB:  filepath.Join(t.TempDir(), "sidecar.db"),
+	}})
+	if err := cs.LoadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+	wire := NewWire(cs)
+	if err := wire.ScanForQueries(); err != nil {
+		t.Fatalf("failed to scan queries: %v", err)
+	}
+	location := wire.queries["index.md"][0]
+
+	// edited after the scan: an intro pushes the block down and stale results grew it
+	edited := "# Home\n\nIntro.\n\nMore intro.\n\n<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n- [Old](/blog/old)\n- [Older](/blog/older)\n<!-- </query> -->\n\nFooter.\n"
+	index := filepath.Join(dir, "index.md")
+	if err := os.WriteFile(index, []byte(edited), 0644); err != nil {
+		t.Fatalf("failed to edit index: %v", err)
+	}
+
+	fd, _ := cs.DoPath("index.md")
+	if err := wire.updateQuery(&fd, location); err != nil {
+		t.Fatalf("updateQuery failed: %v", err)
+	}
+	content, _ := os.ReadFile(index)
+	expected := "# Home\n\nIntro.\n\nMore intro.\n\n<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n- [One](/blog/one)\n<!-- </query> -->\n\nFooter.\n"
+	if string(content) != expected {
+		t.Errorf("expected the relocated block to be replaced, got:\n%s", content)
+	}
+
+	// with the end marker gone the update fails instead of swallowing the rest of the file
+	if err := os.WriteFile(index, []byte("# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n\nFooter.\n"), 0644); err != nil {
+		t.Fatalf("failed to edit index: %v", err)
+	}
+	if err := wire.updateQuery(&fd, location); err == nil {
+		t.Errorf("expected an error for a block without an end marker")
+	}
+}
+
+func TestNotifyFileChangedUpdatesEveryQuery(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.md": "# Home\n\n" +
+			"<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n<!-- </query> -->\n\n" +
+			"Between.\n\n" +
+			"<!-- <query type=\"posts\" path=\"notes/*\" md-format=\"list\"> -->\n- [Stale](/notes/stale)\n<!-- </query> -->\n\n" +
+			"<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n<!-- </query> -->\n",
+		"blog/one.md":  "---\ntitle: One\ncreated: 1704456000\n---\n# One\n",
+		"blog/two.md":  "---\ntitle: Two\ncreated: 1704456100\n---\n# Two\n",
+		"notes/tip.md": "---\ntitle: Tip\ncreated: 1704456200\n---\n# Tip\n",
+	}
+	for name, body := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	cs := NewContentStuff(&config.Config{Content: config.ContentConfig{
+		ContentDir: dir,
+		SidecarDB:  filepath.Join(t.TempDir(), "sidecar.db"),
+	}})
+	if err := cs.LoadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+	wire := NewWire(cs)
+	if err := wire.ScanForQueries(); err != nil {
+		t.Fatalf("failed to scan queries: %v", err)
+	}
+	historyBefore := len(cs.GetHistory("index.md"))
+
+	if err := wire.NotifyFileChanged("index.md"); err != nil {
+		t.Fatalf("NotifyFileChanged failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(dir, "index.md"))
+	expected := "# Home\n\n" +
+		"<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n- [Two](/blog/two)\n- [One](/blog/one)\n<!-- </query> -->\n\n" +
+		"Between.\n\n" +
+		"<!-- <query type=\"posts\" path=\"notes/*\" md-format=\"list\"> -->\n- [Tip](/notes/tip)\n<!-- </query> -->\n\n" +
+		"<!-- <query type=\"posts\" path=\"blog/*\" md-format=\"list\"> -->\n- [Two](/blog/two)\n- [One](/blog/one)\n<!-- </query> -->\n"
+	if string(content) != expected {
+		t.Errorf("expected every query block updated, got:\n%s", content)
+	}
+	if got := len(cs.GetHistory("index.md")); got != historyBefore+1 {
+		t.Errorf("expected one history row for the whole refresh, had %d now %d", historyBefore, got)
+	}
+}
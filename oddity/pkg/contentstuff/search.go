@@ -0,0 +1,195 @@
+package contentstuff
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// titleMatchWeight makes a term found in the title count more than one in the body
+const titleMatchWeight = 5
+
+// snippetRadius is how many words of context are kept around the first match
+const snippetRadius = 12
+
+// SearchResult is a page matching a search query
+type SearchResult struct {
+	Title   string  `json:"title"`
+	Slug    string  `json:"slug"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// Search looks the stemmed query terms up in the search index and ranks pages by how often
+// they appear in their title and text
+// private pages are only searched when includePrivate is set, limit <= 0 returns every match
+func (c *ContentStuff) Search(q string, includePrivate bool, limit int) []SearchResult {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var hits []searchHit
+	c.cmsMux.RLock()
+	if idx := c.cms.search; idx != nil {
+		for fileName, score := range idx.lookup(terms) {
+			doc := idx.docs[fileName]
+			hits = append(hits, searchHit{file: c.cms.fileNameMap[fileName], title: doc.title, text: doc.text, score: score})
+		}
+	}
+	c.cmsMux.RUnlock()
+
+	return c.rankHits(hits, terms, includePrivate, limit)
+}
+
+// searchLinear scores every page by scanning its text, it is the baseline the index must agree with
+func (c *ContentStuff) searchLinear(q string, includePrivate bool, limit int) []SearchResult {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var hits []searchHit
+	for _, fd := range c.AllFiles() {
+		if fd.ParsedContent == nil || (fd.FileType != FileTypeMarkdown && fd.FileType != FileTypeHTML) || IsSectionMeta(fd) {
+			continue
+		}
+
+		title := NewPageFromFileDetail(&fd).Title()
+		text := ExtractPlainText(fd.ParsedContent.Body)
+
+		score := 0
+		for _, token := range Tokenize(title, true) {
+			if terms[token] {
+				score += titleMatchWeight
+			}
+		}
+		for _, token := range Tokenize(text, true) {
+			if terms[token] {
+				score++
+			}
+		}
+		if score > 0 {
+			hits = append(hits, searchHit{file: fd, title: title, text: text, score: score})
+		}
+	}
+
+	return c.rankHits(hits, terms, includePrivate, limit)
+}
+
+// searchHit is a matching page before visibility filtering and ranking
+type searchHit struct {
+	file  FileDetail
+	slug  string
+	title string
+	text  string
+	score int
+}
+
+// rankHits sorts hits by score, drops private pages unless allowed and builds highlighted results
+// snippets are only built for the hits that make it into the limit
+func (c *ContentStuff) rankHits(hits []searchHit, terms map[string]bool, includePrivate bool, limit int) []SearchResult {
+	for i := range hits {
+		hits[i].slug = NewPageFromFileDetail(&hits[i].file).Slug()
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].slug < hits[j].slug
+	})
+
+	var results []SearchResult
+	for _, hit := range hits {
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+		if !includePrivate && IsPrivate(c, hit.file) {
+			continue
+		}
+		title := hit.title
+		if title == "" {
+			title = hit.slug
+		}
+		results = append(results, SearchResult{
+			Title:   title,
+			Slug:    hit.slug,
+			Score:   float64(hit.score),
+			Snippet: highlightSnippet(hit.text, terms),
+		})
+	}
+	return results
+}
+
+// searchTerms returns the set of stemmed query terms
+func searchTerms(q string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, term := range Tokenize(q, true) {
+		terms[term] = true
+	}
+	return terms
+}
+
+// matchesTerm reports whether a word of the original text stems to one of the terms
+func matchesTerm(word string, terms map[string]bool) bool {
+	for _, token := range Tokenize(word, true) {
+		if terms[token] {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightSnippet cuts a window of words around the first matching word and wraps matches in <mark>
+// the returned snippet is html escaped apart from the mark tags
+func highlightSnippet(text string, terms map[string]bool) string {
+	words := strings.Fields(text)
+
+	first := -1
+	for i, word := range words {
+		if matchesTerm(word, terms) {
+			first = i
+			break
+		}
+	}
+
+	start, end := 0, min(len(words), 2*snippetRadius)
+	if first >= 0 {
+		start = max(0, first-snippetRadius)
+		end = min(len(words), first+snippetRadius+1)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("… ")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			sb.WriteString(" ")
+		}
+		if matchesTerm(words[i], terms) {
+			sb.WriteString(markWord(words[i]))
+		} else {
+			sb.WriteString(html.EscapeString(words[i]))
+		}
+	}
+	if end < len(words) {
+		sb.WriteString(" …")
+	}
+	return sb.String()
+}
+
+// markWord wraps the letters of word in <mark>, leaving surrounding punctuation outside
+func markWord(word string) string {
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }
+	start := strings.IndexFunc(word, isWordRune)
+	end := strings.LastIndexFunc(word, isWordRune)
+	if start < 0 {
+		return html.EscapeString(word)
+	}
+	_, size := utf8.DecodeRuneInString(word[end:])
+	end += size
+	return html.EscapeString(word[:start]) + "<mark>" + html.EscapeString(word[start:end]) + "</mark>" + html.EscapeString(word[end:])
+}
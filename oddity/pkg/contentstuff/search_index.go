@@ -0,0 +1,76 @@
+package contentstuff
+
+// searchIndex is an inverted index from stemmed term to the files containing it
+// it is kept next to the file maps in fileCMS and updated whenever a file is (re)scanned
+type searchIndex struct {
+	postings map[string]map[string]int // term -> file name -> weighted occurrences
+	docs     map[string]indexedDoc     // file name -> what is needed to build a result
+}
+
+type indexedDoc struct {
+	title string
+	text  string
+	terms []string // distinct terms, used to drop the file's postings on update
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]int),
+		docs:     make(map[string]indexedDoc),
+	}
+}
+
+// add indexes fd, replacing anything previously indexed for the same file
+func (idx *searchIndex) add(fd FileDetail) {
+	idx.remove(fd.FileName)
+	if fd.ParsedContent == nil || (fd.FileType != FileTypeMarkdown && fd.FileType != FileTypeHTML) || IsSectionMeta(fd) {
+		return
+	}
+
+	title := NewPageFromFileDetail(&fd).Title()
+	text := ExtractPlainText(fd.ParsedContent.Body)
+
+	weights := make(map[string]int)
+	for _, term := range Tokenize(title, true) {
+		weights[term] += titleMatchWeight
+	}
+	for _, term := range Tokenize(text, true) {
+		weights[term]++
+	}
+
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		idx.postings[term][fd.FileName] = weight
+		terms = append(terms, term)
+	}
+	idx.docs[fd.FileName] = indexedDoc{title: title, text: text, terms: terms}
+}
+
+// remove drops fileName from every posting list it appears in
+func (idx *searchIndex) remove(fileName string) {
+	doc, ok := idx.docs[fileName]
+	if !ok {
+		return
+	}
+	for _, term := range doc.terms {
+		delete(idx.postings[term], fileName)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docs, fileName)
+}
+
+// lookup sums the weights of terms per file
+func (idx *searchIndex) lookup(terms map[string]bool) map[string]int {
+	scores := make(map[string]int)
+	for term := range terms {
+		for fileName, weight := range idx.postings[term] {
+			scores[fileName] += weight
+		}
+	}
+	return scores
+}
@@ -0,0 +1,166 @@
+package contentstuff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"oddity/pkg/config"
+)
+
+func TestPorterStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"sized":          "size",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"sky":            "sky",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"generalization": "gener",
+		"triplicate":     "triplic",
+		"hopeful":        "hope",
+		"goodness":       "good",
+		"allowance":      "allow",
+		"adoption":       "adopt",
+		"controll":       "control",
+		"running":        "run",
+		"runs":           "run",
+		"go":             "go",
+		"café":           "café",
+	}
+	for word, expected := range tests {
+		if got := PorterStem(word); got != expected {
+			t.Errorf("PorterStem(%q) = %q, want %q", word, got, expected)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := strings.Join(Tokenize("Running, runs & RAN-fast!", false), " ")
+	if got != "running runs ran fast" {
+		t.Errorf("unexpected tokens without stemming: %q", got)
+	}
+	got = strings.Join(Tokenize("Running, runs & RAN-fast!", true), " ")
+	if got != "run run ran fast" {
+		t.Errorf("unexpected stemmed tokens: %q", got)
+	}
+}
+
+func TestSearchStemming(t *testing.T) {
+	cs := newTestContent(t, map[string]string{
+		"blog/morning.md": "---\ntitle: Morning\n---\n# Morning\n\nI went running by the river.",
+		"blog/brunch.md":  "---\ntitle: Brunch\n---\n# Brunch\n\nEggs and coffee.",
+	})
+
+	results := cs.Search("run", false, 0)
+	if len(results) != 1 || results[0].Slug != "blog/morning" {
+		t.Fatalf("expected run to match only the running post, got %+v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>running</mark>") {
+		t.Errorf("expected the matched variant to be highlighted, got %q", results[0].Snippet)
+	}
+}
+
+// searchFixture builds n posts sharing a small vocabulary so queries hit many pages
+func searchFixture(n int) map[string]string {
+	words := []string{"garden", "running", "coffee", "river", "tomatoes", "walking", "notes", "cooking", "reading", "bikes"}
+	files := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		var body strings.Builder
+		for j := 0; j < 60; j++ {
+			body.WriteString(words[(i*7+j*3)%len(words)])
+			body.WriteString(" ")
+		}
+		private := ""
+		if i%5 == 0 {
+			private = "private: true\n"
+		}
+		files[fmt.Sprintf("blog/post-%03d.md", i)] = fmt.Sprintf("---\ntitle: Post %d %s\n%s---\n# Post\n\n%s", i, words[i%len(words)], private, body.String())
+	}
+	return files
+}
+
+func TestSearchIndexMatchesLinear(t *testing.T) {
+	cs := newTestContent(t, searchFixture(40))
+
+	compare := func(q string, includePrivate bool) {
+		t.Helper()
+		indexed := cs.Search(q, includePrivate, 0)
+		linear := cs.searchLinear(q, includePrivate, 0)
+		if !reflect.DeepEqual(indexed, linear) {
+			t.Errorf("%q (private=%v): indexed results differ from linear scan\nindexed: %+v\nlinear:  %+v", q, includePrivate, indexed, linear)
+		}
+	}
+	for _, q := range []string{"run", "coffee river", "Tomato", "gardens", "nothing-matches", "post"} {
+		compare(q, false)
+		compare(q, true)
+	}
+
+	// saving a file must update its postings
+	dir := cs.Config().Content.ContentDir
+	if err := os.WriteFile(filepath.Join(dir, "blog/post-001.md"), []byte("# Changed\n\nonly zebras now"), 0644); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "blog/post-001.md"), future, future); err != nil {
+		t.Fatalf("failed to touch: %v", err)
+	}
+	if err := cs.RefreshContent("blog/post-001.md"); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+	if results := cs.Search("zebra", false, 0); len(results) != 1 || results[0].Slug != "blog/post-001" {
+		t.Errorf("expected updated file to be found by its new text, got %+v", results)
+	}
+	for _, res := range cs.Search("garden", true, 0) {
+		if res.Slug == "blog/post-001" {
+			t.Errorf("expected stale terms of the updated file to be dropped")
+		}
+	}
+	compare("coffee", true)
+
+	cs.RebuildSearchIndex()
+	compare("coffee river", false)
+}
+
+func BenchmarkSearch(b *testing.B) {
+	dir := b.TempDir()
+	for name, body := range searchFixture(500) {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	cs := NewContentStuff(&config.Config{Content: config.ContentConfig{ContentDir: dir}})
+	if err := cs.cms.scanContent(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cs.Search("coffee river", false, 10)
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cs.searchLinear("coffee river", false, 10)
+		}
+	})
+}
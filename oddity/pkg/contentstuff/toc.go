@@ -0,0 +1,78 @@
+package contentstuff
+
+import (
+	"html/template"
+	"strings"
+)
+
+// defaultTOCDepth is the deepest heading level included when toc_depth is not set
+const defaultTOCDepth = 3
+
+// TableOfContents renders a nested list of the page headings when frontmatter has toc: true
+// toc_depth limits the deepest heading level included
+func (p *Page) TableOfContents() template.HTML {
+	if p.File.ParsedContent == nil || !p.File.ParsedContent.Frontmatter.GetBool("toc") {
+		return ""
+	}
+
+	depth := defaultTOCDepth
+	if d, ok := p.File.ParsedContent.Frontmatter.GetInt("toc_depth"); ok && d > 0 {
+		depth = d
+	}
+
+	// body has the title h1 stripped, so its headings match the rendered html
+	var headings []HeadingData
+	for _, h := range ExtractHeadings(p.File.ParsedContent.Body) {
+		if h.Level <= depth && h.ID != "" {
+			headings = append(headings, h)
+		}
+	}
+	if len(headings) == 0 {
+		return ""
+	}
+
+	return template.HTML(buildTOCList(headings))
+}
+
+// buildTOCList nests headings into lists relative to the shallowest level present
+func buildTOCList(headings []HeadingData) string {
+	base := headings[0].Level
+	for _, h := range headings {
+		if h.Level < base {
+			base = h.Level
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<nav class="toc"><ul>`)
+	level := base
+	for i, h := range headings {
+		if i > 0 {
+			switch {
+			case h.Level > level:
+				sb.WriteString(`<ul>`)
+				for level++; level < h.Level; level++ {
+					sb.WriteString(`<li><ul>`)
+				}
+			case h.Level < level:
+				for ; level > h.Level; level-- {
+					sb.WriteString(`</li></ul>`)
+				}
+				sb.WriteString(`</li>`)
+			default:
+				sb.WriteString(`</li>`)
+			}
+		} else {
+			// first heading deeper than base still needs the nesting
+			for ; level < h.Level; level++ {
+				sb.WriteString(`<li><ul>`)
+			}
+		}
+		sb.WriteString(`<li><a href="#` + template.HTMLEscapeString(h.ID) + `">` + template.HTMLEscapeString(h.Text) + `</a>`)
+	}
+	for ; level > base; level-- {
+		sb.WriteString(`</li></ul>`)
+	}
+	sb.WriteString(`</li></ul></nav>`)
+	return sb.String()
+}
@@ -0,0 +1,214 @@
+package contentstuff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashDir is where deleted pages are kept, relative to the content dir
+// every deletion gets its own <id> directory holding the page, its uploads and an entry.json
+const TrashDir = ".trash"
+
+const trashEntryFile = "entry.json"
+
+// TrashEntry describes a deleted page waiting in the trash
+type TrashEntry struct {
+	ID         string    `json:"id"`
+	FileName   string    `json:"fileName"`
+	Slug       string    `json:"slug"`
+	Title      string    `json:"title"`
+	DeletedAt  time.Time `json:"deletedAt"`
+	HasUploads bool      `json:"hasUploads"`
+}
+
+func (c *ContentStuff) trashPath(id string, elem ...string) string {
+	return filepath.Join(append([]string{c.config.Content.ContentDir, TrashDir, id}, elem...)...)
+}
+
+// uploadsDirFor is the uploads directory of a page, named after its file without the extension
+// it is empty when uploads are not stored per page
+func (c *ContentStuff) uploadsDirFor(fileName string) string {
+	if c.config.Content.UploadDir == "" || !c.config.Content.UploadsPerPage() {
+		return ""
+	}
+	return filepath.Join(c.config.Content.UploadDir, strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+}
+
+// TrashContentFile moves a page and its uploads into the trash, removes it from the content
+// store and publishes ContentDeleted, the deletion is also recorded in history
+func (c *ContentStuff) TrashContentFile(fileName string) (TrashEntry, error) {
+	fd, ok := c.DoPath(fileName)
+	if !ok || (fd.FileType != FileTypeMarkdown && fd.FileType != FileTypeHTML) {
+		return TrashEntry{}, fmt.Errorf("content file not found: %s", fileName)
+	}
+	fileName = fd.FileName
+	sourceFile := filepath.Join(c.config.Content.ContentDir, fileName)
+
+	content, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return TrashEntry{}, fmt.Errorf("error reading file: %v", err)
+	}
+
+	pg := NewPageFromFileDetail(&fd)
+	entry := TrashEntry{
+		ID:        strconv.FormatInt(time.Now().UnixNano(), 10),
+		FileName:  fileName,
+		Slug:      pg.Slug(),
+		Title:     pg.Title(),
+		DeletedAt: time.Now(),
+	}
+
+	ph := PostHistory{
+		FileName: fileName,
+		FullSlug: entry.Slug,
+		Title:    entry.Title,
+		Content:  string(content),
+		Deleted:  true,
+	}
+	if fd.ParsedContent != nil {
+		ph.HTML = string(fd.ParsedContent.HTML)
+	}
+	if err := c.dbHandle.Create(&ph).Error; err != nil {
+		return TrashEntry{}, fmt.Errorf("error recording deletion of %s: %v", fileName, err)
+	}
+
+	if err := os.MkdirAll(c.trashPath(entry.ID), 0755); err != nil {
+		return TrashEntry{}, fmt.Errorf("error creating trash entry: %v", err)
+	}
+
+	if uploadsDir := c.uploadsDirFor(fileName); uploadsDir != "" {
+		if _, err := os.Stat(uploadsDir); err == nil {
+			if err := os.Rename(uploadsDir, c.trashPath(entry.ID, "uploads")); err != nil {
+				os.RemoveAll(c.trashPath(entry.ID))
+				return TrashEntry{}, fmt.Errorf("error moving uploads to trash: %v", err)
+			}
+			entry.HasUploads = true
+		}
+	}
+
+	trashedFile := c.trashPath(entry.ID, "content", fileName)
+	if err := os.MkdirAll(filepath.Dir(trashedFile), 0755); err != nil {
+		return TrashEntry{}, fmt.Errorf("error creating trash entry: %v", err)
+	}
+	if err := os.Rename(sourceFile, trashedFile); err != nil {
+		// put the uploads back so nothing is half deleted
+		if entry.HasUploads {
+			os.Rename(c.trashPath(entry.ID, "uploads"), c.uploadsDirFor(fileName))
+		}
+		os.RemoveAll(c.trashPath(entry.ID))
+		return TrashEntry{}, fmt.Errorf("error moving file to trash: %v", err)
+	}
+
+	entryJSON, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return TrashEntry{}, fmt.Errorf("error encoding trash entry: %v", err)
+	}
+	if err := os.WriteFile(c.trashPath(entry.ID, trashEntryFile), entryJSON, 0644); err != nil {
+		return TrashEntry{}, fmt.Errorf("error writing trash entry: %v", err)
+	}
+
+	c.cmsMux.Lock()
+	c.cms.removeFile(fileName)
+	c.cmsMux.Unlock()
+
+	return entry, c.events.Publish(ContentEvent{Type: ContentDeleted, FileName: fileName})
+}
+
+// ListTrash returns the pages in the trash, most recently deleted first
+func (c *ContentStuff) ListTrash() ([]TrashEntry, error) {
+	dirs, err := os.ReadDir(filepath.Join(c.config.Content.ContentDir, TrashDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading trash: %v", err)
+	}
+
+	var entries []TrashEntry
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		entry, ok := c.GetTrashEntry(dir.Name())
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// GetTrashEntry reads a single trash entry by id
+func (c *ContentStuff) GetTrashEntry(id string) (TrashEntry, bool) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return TrashEntry{}, false
+	}
+	data, err := os.ReadFile(c.trashPath(id, trashEntryFile))
+	if err != nil {
+		return TrashEntry{}, false
+	}
+	var entry TrashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return TrashEntry{}, false
+	}
+	return entry, true
+}
+
+// RestoreTrash moves a trashed page and its uploads back to where they were
+// and publishes ContentCreated, the original file name must be free
+func (c *ContentStuff) RestoreTrash(id string) (TrashEntry, error) {
+	entry, ok := c.GetTrashEntry(id)
+	if !ok {
+		return TrashEntry{}, fmt.Errorf("trash entry not found: %s", id)
+	}
+
+	targetFile := filepath.Join(c.config.Content.ContentDir, entry.FileName)
+	if _, err := os.Stat(targetFile); !os.IsNotExist(err) {
+		return TrashEntry{}, fmt.Errorf("%s already exists", entry.FileName)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetFile), 0755); err != nil {
+		return TrashEntry{}, fmt.Errorf("error creating target directory: %v", err)
+	}
+	if err := os.Rename(c.trashPath(id, "content", entry.FileName), targetFile); err != nil {
+		return TrashEntry{}, fmt.Errorf("error restoring file: %v", err)
+	}
+
+	if uploadsDir := c.uploadsDirFor(entry.FileName); entry.HasUploads && uploadsDir != "" {
+		if err := os.MkdirAll(filepath.Dir(uploadsDir), 0755); err != nil {
+			return TrashEntry{}, fmt.Errorf("error creating uploads directory: %v", err)
+		}
+		if err := os.Rename(c.trashPath(id, "uploads"), uploadsDir); err != nil {
+			return TrashEntry{}, fmt.Errorf("error restoring uploads: %v", err)
+		}
+	}
+
+	if err := os.RemoveAll(c.trashPath(id)); err != nil {
+		return TrashEntry{}, fmt.Errorf("error removing trash entry: %v", err)
+	}
+
+	if err := c.RefreshContent(filepath.Dir(entry.FileName)); err != nil {
+		return TrashEntry{}, fmt.Errorf("error refreshing content: %v", err)
+	}
+	if err := c.RefreshContent(entry.FileName); err != nil {
+		return TrashEntry{}, fmt.Errorf("error refreshing content: %v", err)
+	}
+
+	return entry, c.events.Publish(ContentEvent{Type: ContentCreated, FileName: entry.FileName})
+}
+
+// PurgeTrash permanently removes a trash entry, its history records are kept
+func (c *ContentStuff) PurgeTrash(id string) error {
+	if _, ok := c.GetTrashEntry(id); !ok {
+		return fmt.Errorf("trash entry not found: %s", id)
+	}
+	return os.RemoveAll(c.trashPath(id))
+}
@@ -16,6 +16,7 @@ type PageMeta struct {
 	PublishDate  time.Time `json:"publish_date,omitempty"`
 	ModifyDate   time.Time `json:"modify_date,omitempty"`
 	CanonicalURL string    `json:"canonical_url,omitempty"`
+	NoIndex      bool      `json:"noindex,omitempty"`
 }
 
 // PostPage represents the data structure for rendering individual posts/pages
@@ -45,6 +46,41 @@ type PostPage struct {
 	ParentSlug      string     `json:"parent_slug,omitempty"`
 	BackLink        string     `json:"back_link,omitempty"`
 	FeedsLink       string     `json:"feeds_link,omitempty"`
+	// Theme is the light or dark theme the visitor picked, empty renders the light theme
+	Theme string `json:"theme,omitempty"`
+
+	// PrevPost and NextPost are the chronologically adjacent posts in the same directory
+	PrevPost *PostSummary `json:"prev_post,omitempty"`
+	NextPost *PostSummary `json:"next_post,omitempty"`
+
+	// Comments is set when the site renders a comments container
+	Comments *CommentsHook `json:"comments,omitempty"`
+
+	// Frontmatter gives templates the page's own metadata, e.g. {{.Frontmatter.Param "seo.description"}}
+	Frontmatter *FrontmatterData `json:"-"`
+}
+
+// UpdatedDate is ModifiedDate when it is meaningfully newer than CreatedDate, nil after minor saves,
+// the threshold is the site's updated_threshold
+func (p PostPage) UpdatedDate() *time.Time {
+	return MeaningfulUpdate(p.CreatedDate, p.ModifiedDate, p.Site.UpdatedAfter())
+}
+
+// MeaningfulUpdate returns modified when it is more than threshold after created, otherwise nil
+func MeaningfulUpdate(created, modified *time.Time, threshold time.Duration) *time.Time {
+	if modified == nil {
+		return nil
+	}
+	if created != nil && modified.Sub(*created) <= threshold {
+		return nil
+	}
+	return modified
+}
+
+// CommentsHook carries what a third-party comment widget needs to find its thread
+type CommentsHook struct {
+	URL  string `json:"url"`
+	Slug string `json:"slug"`
 }
 
 // IndexPage represents the data structure for rendering the main blog index
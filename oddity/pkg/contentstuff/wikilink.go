@@ -0,0 +1,217 @@
+package contentstuff
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"oddity/pkg/config"
+)
+
+// wikiLinkAttributes parses the attribute block after a wiki link, the text between { and },
+// into html attributes: .name adds a class, #name sets the id and key=value or key="value" sets key
+func wikiLinkAttributes(block string) ([]string, bool) {
+	var id string
+	var classes []string
+	var others []string
+
+	fields := strings.Fields(block)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	for _, field := range fields {
+		switch {
+		case strings.HasPrefix(field, ".") && len(field) > 1:
+			classes = append(classes, field[1:])
+		case strings.HasPrefix(field, "#") && len(field) > 1:
+			id = field[1:]
+		case strings.Contains(field, "="):
+			key, value, _ := strings.Cut(field, "=")
+			if key == "" {
+				return nil, false
+			}
+			value = strings.Trim(value, `"'`)
+			others = append(others, key+`="`+html.EscapeString(value)+`"`)
+		default:
+			return nil, false
+		}
+	}
+
+	var attrs []string
+	if id != "" {
+		attrs = append(attrs, `id="`+html.EscapeString(id)+`"`)
+	}
+	if len(classes) > 0 {
+		attrs = append(attrs, `class="`+html.EscapeString(strings.Join(classes, " "))+`"`)
+	}
+	attrs = append(attrs, others...)
+	return attrs, true
+}
+
+// withAnchorAttributes adds attrs to the opening tag of a rendered wiki link,
+// classes join a class the link already has, such as broken
+func withAnchorAttributes(rendered string, attrs []string) string {
+	if len(attrs) == 0 || !strings.HasPrefix(rendered, "<a ") {
+		return rendered
+	}
+	var added []string
+	for _, attr := range attrs {
+		if classes, ok := strings.CutPrefix(attr, `class="`); ok && strings.Contains(rendered, ` class="`) {
+			rendered = strings.Replace(rendered, ` class="`, ` class="`+strings.TrimSuffix(classes, `"`)+" ", 1)
+			continue
+		}
+		added = append(added, attr)
+	}
+	if len(added) == 0 {
+		return rendered
+	}
+	return "<a " + strings.Join(added, " ") + rendered[2:]
+}
+
+// resolveWikiLink resolves target with the configured resolver and remembers the href for the parsed result
+func (mp *MarkdownParser) resolveWikiLink(target string) (string, bool) {
+	href, ok := mp.config.WikiLinkResolver(target)
+	if mp.wikiLinkHrefs == nil {
+		mp.wikiLinkHrefs = make(map[string]string)
+	}
+	if !ok {
+		href = ""
+	}
+	mp.wikiLinkHrefs[target] = href
+	return href, ok
+}
+
+// renderResolvedWikiLink renders [[target]] or [[target|text]] with the href resolve finds for target
+func renderResolvedWikiLink(linkText string, resolve func(string) (string, bool)) string {
+	target, text, hasText := strings.Cut(linkText, "|")
+	if !hasText {
+		text = target
+	}
+	href, ok := resolve(strings.TrimSpace(target))
+	if !ok {
+		return fmt.Sprintf(`<a class="broken" href="%s">%s</a>`, html.EscapeString(target), html.EscapeString(text))
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(text))
+}
+
+// pageParserConfig is the parser config for content files, with a wiki link resolver
+// over the loaded pages when wiki link resolution is configured
+func (c *fileCMS) pageParserConfig() *ParserConfig {
+	if c.parserConfig.WikiLinkResolution == "" {
+		return c.parserConfig
+	}
+	pc := *c.parserConfig
+	pc.WikiLinkResolver = c.resolveWikiLink
+	return &pc
+}
+
+// resolveWikiLink finds the page a wiki link target names with the configured strategy and returns its url
+func (c *fileCMS) resolveWikiLink(target string) (string, bool) {
+	target = strings.Trim(target, "/")
+	if target == "" {
+		return "", false
+	}
+
+	var fd FileDetail
+	var ok bool
+	switch c.parserConfig.WikiLinkResolution {
+	case config.WikiLinksTitle:
+		if fd, ok = c.pageByTitle(target); !ok {
+			fd, ok = c.pageBySlug(target)
+		}
+	case config.WikiLinksFilename:
+		fd, ok = c.pageByFilename(target)
+	default:
+		fd, ok = c.pageBySlug(target)
+	}
+	if !ok {
+		return "", false
+	}
+	return "/" + NewPageFromFileDetail(&fd).Slug(), true
+}
+
+func (c *fileCMS) pageBySlug(slug string) (FileDetail, bool) {
+	fd, ok := c.doPath(slug)
+	if !ok || (fd.FileType != FileTypeMarkdown && fd.FileType != FileTypeHTML) || IsSectionMeta(fd) {
+		return FileDetail{}, false
+	}
+	return fd, true
+}
+
+// pageByTitle matches titles case-insensitively, when pages share a title the first file name wins
+func (c *fileCMS) pageByTitle(title string) (FileDetail, bool) {
+	return c.firstPage(func(fd FileDetail) bool {
+		return strings.EqualFold(strings.TrimSpace(NewPageFromFileDetail(&fd).Title()), title)
+	})
+}
+
+// pageByFilename matches the file name with or without its extension, either the base name
+// or the path from the content dir, when several directories hold the name the first file name wins
+func (c *fileCMS) pageByFilename(name string) (FileDetail, bool) {
+	return c.firstPage(func(fd FileDetail) bool {
+		fileName := filepath.ToSlash(fd.FileName)
+		withoutExt := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		if strings.Contains(name, "/") {
+			return name == fileName || name == withoutExt
+		}
+		return name == path.Base(fileName) || name == path.Base(withoutExt)
+	})
+}
+
+// firstPage returns the page with the lowest file name that match reports true for
+func (c *fileCMS) firstPage(match func(FileDetail) bool) (FileDetail, bool) {
+	var found FileDetail
+	ok := false
+	for _, fd := range c.fileNameMap {
+		if (fd.FileType != FileTypeMarkdown && fd.FileType != FileTypeHTML) || IsSectionMeta(fd) {
+			continue
+		}
+		if (!ok || fd.FileName < found.FileName) && match(fd) {
+			found, ok = fd, true
+		}
+	}
+	return found, ok
+}
+
+// reresolveWikiLinks parses a page again once every page is loaded when one of its wiki links
+// now resolves differently, during the scan a link can point at a page that was not read yet
+func (c *fileCMS) reresolveWikiLinks() error {
+	if c.parserConfig == nil || c.parserConfig.WikiLinkResolution == "" {
+		return nil
+	}
+	var changed []FileDetail
+	for _, fd := range c.fileNameMap {
+		if fd.FileType == FileTypeMarkdown && c.wikiLinksChanged(fd) {
+			changed = append(changed, fd)
+		}
+	}
+	for _, fd := range changed {
+		content, err := os.ReadFile(filepath.Join(c.ContentDir, fd.FileName))
+		if err != nil {
+			return err
+		}
+		pc, err := NewMarkdownParser(c.pageParserConfig()).Parse(content)
+		if err != nil {
+			return err
+		}
+		fd.ParsedContent = pc
+		c.storePage(fd)
+	}
+	return nil
+}
+
+// wikiLinksChanged reports whether a wiki link of fd resolves to another href than when fd was parsed
+func (c *fileCMS) wikiLinksChanged(fd FileDetail) bool {
+	if fd.ParsedContent == nil {
+		return false
+	}
+	for target, href := range fd.ParsedContent.WikiLinkHrefs {
+		if current, _ := c.resolveWikiLink(target); current != href {
+			return true
+		}
+	}
+	return false
+}
@@ -5,8 +5,17 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// queryStartPattern and queryEndPattern match the comments around a query block
+var (
+	queryStartPattern = regexp.MustCompile(`<!--\s*<query\s+([^>]+)>\s*-->`)
+	queryEndPattern   = regexp.MustCompile(`<!--\s*</query>\s*-->`)
 )
 
 // Wire is the notification and modification engine
@@ -57,6 +66,80 @@ func NewWire(content *ContentStuff) *Wire {
 	}
 }
 
+// Subscribe keeps the wire's queries up to date with content events published on bus
+func (w *Wire) Subscribe(bus *EventBus) func() {
+	return bus.Subscribe(w.handleContentEvent)
+}
+
+func (w *Wire) handleContentEvent(ev ContentEvent) error {
+	switch ev.Type {
+	case ContentCreated, ContentUpdated:
+		return w.refreshAfterSave(ev.FileName)
+	case ContentRenamed:
+		delete(w.queries, ev.OldFileName)
+		return w.refreshAfterSave(ev.FileName)
+	case ContentDeleted:
+		delete(w.queries, ev.FileName)
+		return w.refreshAfterDelete(ev.FileName)
+	}
+	return nil
+}
+
+// refreshAfterSave rescans the saved file for queries, runs them and
+// updates the queries in other files that list it
+func (w *Wire) refreshAfterSave(fileName string) error {
+	err := w.ScanContentFileForQueries(fileName)
+	if err != nil {
+		return fmt.Errorf("error scanning content file for queries: %v", err)
+	}
+
+	err = w.NotifyFileChanged(fileName)
+	if err != nil {
+		return fmt.Errorf("error notifying file %s changed: %v", fileName, err)
+	}
+
+	// the file's own queries may have rewritten it and moved their end markers
+	w.refreshQueryFile(fileName)
+
+	err = w.TriggerDependencyUpdates(fileName)
+	if err != nil {
+		logrus.Errorf("error notifying file change for %s: %v", fileName, err)
+	}
+
+	for _, ip := range w.FindDependencies(fileName) {
+		w.refreshQueryFile(ip)
+	}
+	return nil
+}
+
+// refreshAfterDelete reruns the queries of every other file since any of them may have listed the deleted one
+func (w *Wire) refreshAfterDelete(fileName string) error {
+	for _, filePath := range w.FilesWithQueries() {
+		fileCtx, exists := w.content.DoPath(filePath)
+		if !exists {
+			continue
+		}
+		if err := w.updateQueries(&fileCtx, filePath, w.queries[filePath]); err != nil {
+			return fmt.Errorf("error updating queries in %s after deleting %s: %v", filePath, fileName, err)
+		}
+		w.refreshQueryFile(filePath)
+	}
+	return nil
+}
+
+// refreshQueryFile reloads a file whose queries were rewritten and rescans its query locations
+func (w *Wire) refreshQueryFile(filePath string) {
+	if _, err := os.Stat(filepath.Join(w.content.Config().Content.ContentDir, filePath)); err != nil {
+		return
+	}
+	if err := w.content.RefreshContent(filePath); err != nil {
+		logrus.Errorf("error refreshing content for %s: %v", filePath, err)
+	}
+	if err := w.ScanContentFileForQueries(filePath); err != nil {
+		logrus.Errorf("error scanning content file for queries %s: %v", filePath, err)
+	}
+}
+
 func (w *Wire) QueryCount() int {
 	cnt := 0
 	for _, qList := range w.queries {
@@ -126,15 +209,11 @@ func (w *Wire) extractQueriesFromContent(filePath string, content string) ([]Que
 	lines := strings.Split(content, "\n")
 	queries := make([]QueryLocation, 0)
 
-	// Updated regex for XML-like syntax
-	queryStartRegex := regexp.MustCompile(`<!--\s*<query\s+([^>]+)>\s*-->`)
-	queryEndRegex := regexp.MustCompile(`<!--\s*</query>\s*-->`)
-
 	var currentQuery *QueryLocation
 
 	for i, line := range lines {
 		// Look for start of query
-		if matches := queryStartRegex.FindStringSubmatch(line); len(matches) > 1 {
+		if matches := queryStartPattern.FindStringSubmatch(line); len(matches) > 1 {
 			// Store raw query string, don't parse yet
 			currentQuery = &QueryLocation{
 				Query:     nil, // Will be set when we find the end tag
@@ -143,7 +222,7 @@ func (w *Wire) extractQueriesFromContent(filePath string, content string) ([]Que
 				Content:   make([]string, 0),
 				rawQuery:  matches[1], // Store raw query attributes
 			}
-		} else if queryEndRegex.MatchString(line) && currentQuery != nil {
+		} else if queryEndPattern.MatchString(line) && currentQuery != nil {
 			// End of query found - now parse the complete query
 			xmlString := fmt.Sprintf("<query %s>", currentQuery.rawQuery)
 			ast, err := ParseQuery(xmlString)
@@ -198,10 +277,8 @@ func (w *Wire) NotifyFileChanged(filePath string) error {
 	fileQueries, ok := w.queries[filePath]
 	if ok {
 		// the target file has queries - execute them
-		for _, query := range fileQueries {
-			if err := w.updateQuery(&fileCtx, query); err != nil {
-				return fmt.Errorf("error updating query in %s: %v", query.FilePath, err)
-			}
+		if err := w.updateQueries(&fileCtx, filePath, fileQueries); err != nil {
+			return fmt.Errorf("error updating queries in %s: %v", filePath, err)
 		}
 	}
 
@@ -265,40 +342,97 @@ func (w *Wire) shouldRefreshQuery(query QueryLocation, modifiedFile string, file
 	return false
 }
 
-// updateQuery re-executes a query and updates the file
+// updateQuery re-executes a query and updates the file, a file whose query block already
+// holds the results is left alone so it gets no new history row
 func (w *Wire) updateQuery(fileCtx *FileDetail, location QueryLocation) error {
-	// Execute the query to get new results
-	results, err := w.executeQuery(fileCtx, location.Query)
-	if err != nil {
-		return err
-	}
+	return w.updateQueries(fileCtx, location.FilePath, []QueryLocation{location})
+}
 
+// updateQueries re-executes locations, the queries of filePath in file order, and splices all
+// their results into one copy of the file, which is written once if any block changed
+func (w *Wire) updateQueries(fileCtx *FileDetail, filePath string, locations []QueryLocation) error {
 	// Read current file content
-	fullPath := filepath.Join(w.content.Config().Content.ContentDir, location.FilePath)
+	fullPath := filepath.Join(w.content.Config().Content.ContentDir, filePath)
 	content, err := os.ReadFile(fullPath)
 	if err != nil {
 		return err
 	}
 
 	lines := strings.Split(string(content), "\n")
+	changed := false
+	// from keeps each query past the block before it, shift is how far earlier splices moved the lines
+	from, shift := 0, 0
+
+	for _, location := range locations {
+		// Execute the query to get new results
+		results, err := w.executeQuery(fileCtx, location.Query)
+		if err != nil {
+			return err
+		}
 
-	// Replace content between start and end lines
-	newLines := make([]string, 0, len(lines))
-	newLines = append(newLines, lines[:location.StartLine+1]...) // up to and including start comment
+		// the file may have changed since it was scanned, so find the markers again
+		location.StartLine += shift
+		start, end, err := locateQueryBlock(lines, location, from)
+		if err != nil {
+			return fmt.Errorf("error updating query in %s: %v", filePath, err)
+		}
 
-	// Add new query results
-	for _, result := range results {
-		newLines = append(newLines, result)
+		// compare with the block as it is in the file, location.Content may predate the last write
+		if !slices.Equal(lines[start+1:end], results) {
+			// Replace content between start and end lines
+			newLines := make([]string, 0, len(lines)+len(results))
+			newLines = append(newLines, lines[:start+1]...) // up to and including start comment
+			newLines = append(newLines, results...)
+			newLines = append(newLines, lines[end:]...) // from end comment onwards
+
+			shift += len(results) - (end - start - 1)
+			end = start + 1 + len(results)
+			lines = newLines
+			changed = true
+		}
+		from = end + 1
 	}
 
-	// Add from end comment onwards
-	if location.EndLine < len(lines) {
-		newLines = append(newLines, lines[location.EndLine:]...)
+	if !changed {
+		return nil
 	}
-
 	// Write back to file
-	newContent := strings.Join(newLines, "\n")
-	return w.content.WriteContentFile(location.FilePath, newContent)
+	return w.content.WriteContentFile(filePath, strings.Join(lines, "\n"))
+}
+
+// locateQueryBlock finds the start and end marker lines of location's query in lines at or after from,
+// the start marker with the same query nearest to where it was scanned, and the first end marker after it
+func locateQueryBlock(lines []string, location QueryLocation, from int) (int, int, error) {
+	start := -1
+	for i := from; i < len(lines); i++ {
+		matches := queryStartPattern.FindStringSubmatch(lines[i])
+		if matches == nil || (location.rawQuery != "" && matches[1] != location.rawQuery) {
+			continue
+		}
+		if start == -1 || abs(i-location.StartLine) < abs(start-location.StartLine) {
+			start = i
+		}
+	}
+	if start == -1 {
+		return 0, 0, fmt.Errorf("query start marker not found")
+	}
+
+	for i := start + 1; i < len(lines); i++ {
+		if queryEndPattern.MatchString(lines[i]) {
+			return start, i, nil
+		}
+		if queryStartPattern.MatchString(lines[i]) {
+			break
+		}
+	}
+	return 0, 0, fmt.Errorf("query end marker not found after line %d", start+1)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
 }
 
 // executeQuery runs a query against current content
@@ -307,7 +441,10 @@ func (w *Wire) executeQuery(ctx *FileDetail, query *QueryAST) ([]string, error)
 	case QueryPosts:
 		filtered := w.executePostsQuery(ctx, query)
 		// Convert to markdown format based on specified format
-		return w.formatResults(filtered, query.MDFormat)
+		return w.formatResults(filtered, query.MDFormat, query.ExcerptLength)
+	case QueryBacklinks:
+		filtered := w.executeBacklinksQuery(ctx, query)
+		return w.formatResults(filtered, query.MDFormat, query.ExcerptLength)
 	default:
 		return nil, fmt.Errorf("unsupported query type: %v", query.Type)
 	}
@@ -324,13 +461,18 @@ func (w *Wire) TriggerDependencyUpdates(changedFile string) error {
 		if !exists {
 			continue
 		}
+		var affected []QueryLocation
 		for _, query := range queries {
 			if w.shouldRefreshQuery(query, changedFile, fileDetail) {
-				if err := w.updateQuery(&fileDetail, query); err != nil {
-					return fmt.Errorf("error updating query in %s: %v", query.FilePath, err)
-				}
+				affected = append(affected, query)
 			}
 		}
+		if len(affected) == 0 {
+			continue
+		}
+		if err := w.updateQueries(&fileDetail, filePath, affected); err != nil {
+			return fmt.Errorf("error updating queries in %s: %v", filePath, err)
+		}
 	}
 	return nil
 }
@@ -367,6 +509,99 @@ func (w *Wire) PostHasQueries(filePath string) bool {
 	return false
 }
 
+// FilesWithQueries returns the sorted file names that contain queries
+func (w *Wire) FilesWithQueries() []string {
+	files := make([]string, 0, len(w.queries))
+	for filePath := range w.queries {
+		files = append(files, filePath)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// QueryBlocks returns the queries of content with the lines generated for them, in file order,
+// content can be any version of a page such as one kept in its history
+func (w *Wire) QueryBlocks(content string) []QueryLocation {
+	locations, _ := w.extractQueriesFromContent("", content)
+	return locations
+}
+
+// GetTagPosts returns public posts tagged with tag, most recent first
+func (w *Wire) GetTagPosts(tag string) []FileDetail {
+	query := &QueryAST{
+		Type:      QueryPosts,
+		SortType:  SortRecent,
+		SortOrder: SortDesc,
+		Filters:   []QueryFilter{{Field: "tag", Operator: "contains", Value: tag}},
+	}
+	return w.collectPosts(&FileDetail{}, query)
+}
+
+// GetRecentPosts returns all public posts excluding index pages, most recent first
+func (w *Wire) GetRecentPosts() []FileDetail {
+	query := &QueryAST{
+		Type:      QueryPosts,
+		SortType:  SortRecent,
+		SortOrder: SortDesc,
+	}
+	var posts []FileDetail
+	for _, fd := range w.collectPosts(&FileDetail{}, query) {
+		if filepath.Base(fd.FileName) != "index.md" && filepath.Base(fd.FileName) != "index.html" {
+			posts = append(posts, fd)
+		}
+	}
+	return posts
+}
+
+// GetDirectoryPosts returns public posts directly inside dir, pinned posts first and then most recent first
+func (w *Wire) GetDirectoryPosts(dir string) []FileDetail {
+	query := &QueryAST{
+		Type:        QueryPosts,
+		SortType:    SortRecent,
+		SortOrder:   SortDesc,
+		PinnedFirst: true,
+	}
+	var posts []FileDetail
+	for _, fd := range w.collectPosts(&FileDetail{}, query) {
+		if filepath.Dir(fd.FileName) == dir {
+			posts = append(posts, fd)
+		}
+	}
+	return posts
+}
+
+// GetSiblingPosts returns the dated posts in the directory of fileName, oldest first,
+// index pages are left out and private posts are only included with includePrivate
+func (w *Wire) GetSiblingPosts(fileName string, includePrivate bool) []FileDetail {
+	query := &QueryAST{
+		Type:           QueryPosts,
+		IncludePrivate: includePrivate,
+	}
+	dir := filepath.Dir(fileName)
+	var posts []FileDetail
+	for _, fd := range w.collectPosts(&FileDetail{}, query) {
+		base := filepath.Base(fd.FileName)
+		if filepath.Dir(fd.FileName) != dir || base == "index.md" || base == "index.html" {
+			continue
+		}
+		if NewPageFromFileDetail(&fd).DateCreated() == nil {
+			continue
+		}
+		posts = append(posts, fd)
+	}
+
+	// reading order ignores pinning, posts created at the same time keep their file name order
+	sort.SliceStable(posts, func(i, j int) bool {
+		di := NewPageFromFileDetail(&posts[i]).DateCreated()
+		dj := NewPageFromFileDetail(&posts[j]).DateCreated()
+		if di.Equal(*dj) {
+			return posts[i].FileName < posts[j].FileName
+		}
+		return di.Before(*dj)
+	})
+	return posts
+}
+
 func (w *Wire) GetQueryResultsForPost(filePath string) ([]FileDetail, error) {
 	var results []FileDetail
 	fileDetail, exists := w.content.DoPath(filePath)
@@ -386,21 +621,30 @@ func (w *Wire) GetQueryResultsForPost(filePath string) ([]FileDetail, error) {
 
 	for _, q := range queries {
 		if q.Query.Type == QueryPosts {
-			res := w.executePostsQuery(&fileDetail, q.Query)
+			// feeds stay in date order, a pinned post would also take a slot of the limit
+			query := *q.Query
+			query.PinnedFirst = false
+			res := w.executePostsQuery(&fileDetail, &query)
 			results = append(results, res...)
 		}
 	}
 
-	return w.applySortToFiles(results, SortDate, SortDesc), nil
+	return w.applySortToFiles(results, SortDate, SortDesc, false), nil
 }
 
 // executePostsQuery handles "posts" queries
 func (w *Wire) executePostsQuery(ctx *FileDetail, query *QueryAST) []FileDetail {
+	return w.applyLimitToFiles(w.collectPosts(ctx, query), query)
+}
+
+// collectPosts returns the filtered and sorted posts for a query without applying any limit
+// internal listings (feeds, archive, tag pages) use it so the query caps only bound embedded queries
+func (w *Wire) collectPosts(ctx *FileDetail, query *QueryAST) []FileDetail {
 	// Get all posts (non-index markdown files)
 	var posts []FileDetail
 	var allFiles = w.content.AllFiles()
 	for _, file := range allFiles {
-		if file.FileType == FileTypeMarkdown || file.FileType == FileTypeHTML {
+		if (file.FileType == FileTypeMarkdown || file.FileType == FileTypeHTML) && !IsSectionMeta(file) {
 			// Exclude self files if ctx is provided
 			if ctx != nil && file.FileName == ctx.FileName {
 				continue
@@ -412,6 +656,9 @@ func (w *Wire) executePostsQuery(ctx *FileDetail, query *QueryAST) []FileDetail
 					continue
 				}
 			}
+			if query.ExcludePath != "" && w.matchesPathPattern(file.FileName, query.ExcludePath) {
+				continue
+			}
 
 			posts = append(posts, file)
 		}
@@ -423,12 +670,51 @@ func (w *Wire) executePostsQuery(ctx *FileDetail, query *QueryAST) []FileDetail
 	filtered := w.applyFiltersToFiles(allowed, query.Filters)
 
 	// Apply sorting
-	sorted := w.applySortToFiles(filtered, query.SortType, query.SortOrder)
+	return w.applySortToFiles(filtered, query.SortType, query.SortOrder, query.PinnedFirst)
+}
+
+// executeBacklinksQuery handles "backlinks" queries - pages that wiki-link to ctx
+func (w *Wire) executeBacklinksQuery(ctx *FileDetail, query *QueryAST) []FileDetail {
+	if ctx == nil {
+		return nil
+	}
+
+	target := NewPageFromFileDetail(ctx).Slug()
 
-	// Apply limit
-	limited := w.applyLimitToFiles(sorted, query)
+	var linking []FileDetail
+	for _, file := range w.content.AllFiles() {
+		if (file.FileType != FileTypeMarkdown && file.FileType != FileTypeHTML) || IsSectionMeta(file) {
+			continue
+		}
+		if file.FileName == ctx.FileName {
+			continue
+		}
+		if w.linksToSlug(file, target) {
+			linking = append(linking, file)
+		}
+	}
 
-	return limited
+	allowed := w.applyAccessControl(ctx, linking, query)
+	filtered := w.applyFiltersToFiles(allowed, query.Filters)
+	sorted := w.applySortToFiles(filtered, query.SortType, query.SortOrder, query.PinnedFirst)
+	return w.applyLimitToFiles(sorted, query)
+}
+
+// linksToSlug reports whether the file has a wiki link pointing at slug
+func (w *Wire) linksToSlug(file FileDetail, slug string) bool {
+	if file.ParsedContent == nil {
+		return false
+	}
+	for _, link := range file.ParsedContent.WikiLinks {
+		// [[target|Display Text]] - only the target matters
+		target := strings.SplitN(link, "|", 2)[0]
+		target = strings.SplitN(target, "#", 2)[0]
+		target = strings.TrimSuffix(strings.Trim(target, "/"), ".md")
+		if target == slug {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *Wire) applyAccessControl(ctx *FileDetail, posts []FileDetail, query *QueryAST) []FileDetail {
@@ -440,13 +726,11 @@ func (w *Wire) applyAccessControl(ctx *FileDetail, posts []FileDetail, query *Qu
 		panic("ctx is nil")
 	}
 
-	ctxPage := NewPageFromFileDetail(ctx)
-	isCtxPrivate := ctxPage.IsPrivate()
+	isCtxPrivate := IsPrivate(w.content, *ctx)
 
 	var filtered []FileDetail
 	for _, post := range posts {
-		postPage := NewPageFromFileDetail(&post)
-		isPostPrivate := postPage.IsPrivate()
+		isPostPrivate := IsPrivate(w.content, post)
 
 		if isCtxPrivate {
 			// Context is private, include all posts
@@ -489,7 +773,13 @@ func (w *Wire) applyFiltersToFiles(files []FileDetail, filters []QueryFilter) []
 	return filtered
 }
 
+// fileMatchesFilter reports whether file passes filter, a not- operator passes every file the operator does not
 func (w *Wire) fileMatchesFilter(file FileDetail, filter QueryFilter) bool {
+	if operator, negated := strings.CutPrefix(filter.Operator, "not-"); negated {
+		filter.Operator = operator
+		return !w.fileMatchesFilter(file, filter)
+	}
+
 	switch filter.Field {
 	case "tag":
 		if file.ParsedContent != nil {
@@ -502,18 +792,70 @@ func (w *Wire) fileMatchesFilter(file FileDetail, filter QueryFilter) bool {
 				}
 			}
 		}
+		return false
+	}
+
+	// any other field is read from the frontmatter
+	if file.ParsedContent == nil {
+		return false
+	}
+	value, ok := file.ParsedContent.Frontmatter.GetValue(filter.Field)
+	if !ok {
+		return false
+	}
+	return frontmatterValueMatches(value, filter.Operator, filter.Value)
+}
+
+// frontmatterValueMatches compares a frontmatter value with a filter, a list matches when one of its items does
+// equals compares the value as text, contains looks for a substring, exists matches any value
+func frontmatterValueMatches(value any, operator string, want string) bool {
+	if operator == "exists" {
+		return true
+	}
+	if items, ok := value.([]any); ok {
+		for _, item := range items {
+			if frontmatterValueMatches(item, operator, want) {
+				return true
+			}
+		}
+		return false
+	}
+	if value == nil {
+		return false
+	}
+
+	text := fmt.Sprint(value)
+	switch operator {
+	case "equals":
+		return text == want
+	case "contains":
+		return strings.Contains(text, want)
 	}
 	return false
 }
 
-func (w *Wire) applySortToFiles(files []FileDetail, sortType SortType, sortOrder SortOrder) []FileDetail {
-	if sortType == "" {
-		return files
+// applySortToFiles orders files by sortType, with pinnedFirst pinned posts (pinned: true) lead in their sorted order
+// files that tie on the sort key are ordered by slug, so a query renders the same on every run
+func (w *Wire) applySortToFiles(files []FileDetail, sortType SortType, sortOrder SortOrder, pinnedFirst bool) []FileDetail {
+	// AllFiles comes from a map, start from slug order and keep it through the stable sorts below
+	sort.SliceStable(files, func(i, j int) bool {
+		return w.getSlugFromFile(files[i]) < w.getSlugFromFile(files[j])
+	})
+
+	if sortType != "" {
+		w.sortFiles(files, sortType, sortOrder)
 	}
 
-	//var query = &QueryAST{}
+	if pinnedFirst {
+		sort.SliceStable(files, func(i, j int) bool {
+			return NewPageFromFileDetail(&files[i]).IsPinned() && !NewPageFromFileDetail(&files[j]).IsPinned()
+		})
+	}
+	return files
+}
 
-	sort.Slice(files, func(i, j int) bool {
+func (w *Wire) sortFiles(files []FileDetail, sortType SortType, sortOrder SortOrder) {
+	sort.SliceStable(files, func(i, j int) bool {
 		switch sortType {
 		case SortDate, SortModified, SortRecent:
 			pgi := NewPageFromFileDetail(&files[i])
@@ -546,17 +888,33 @@ func (w *Wire) applySortToFiles(files []FileDetail, sortType SortType, sortOrder
 		}
 		return false
 	})
-	return files
 }
 
+// applyLimitToFiles truncates results to the query limit, falling back to the configured
+// default when the query has none and clamping to the configured cap
 func (w *Wire) applyLimitToFiles(files []FileDetail, query *QueryAST) []FileDetail {
-	if query.Limit > 0 && len(files) > query.Limit {
-		return files[:query.Limit]
+	limit := query.Limit
+	if cfg := w.content.Config(); cfg != nil {
+		if limit <= 0 {
+			limit = cfg.Content.QueryDefaultLimit
+		}
+		if cfg.Content.QueryMaxLimit > 0 && (limit <= 0 || limit > cfg.Content.QueryMaxLimit) {
+			limit = cfg.Content.QueryMaxLimit
+		}
+	}
+	if limit > 0 && len(files) > limit {
+		return files[:limit]
 	}
 	return files
 }
 
-func (w *Wire) formatResults(files []FileDetail, format FormatType) ([]string, error) {
+// formatResults renders files as markdown lines in format, excerptLength is the card excerpt
+// length in characters, 0 uses DefaultExcerptLength
+func (w *Wire) formatResults(files []FileDetail, format FormatType, excerptLength int) ([]string, error) {
+	if excerptLength <= 0 {
+		excerptLength = DefaultExcerptLength
+	}
+
 	results := make([]string, 0, len(files))
 
 	for _, file := range files {
@@ -571,7 +929,10 @@ func (w *Wire) formatResults(files []FileDetail, format FormatType) ([]string, e
 		}
 
 		slug = "/" + strings.TrimPrefix(slug, "/")
-		date := page.DateCreated().Format("2006-01-02")
+		date := ""
+		if created := page.DateCreated(); created != nil {
+			date = w.content.Config().Site.FormatDate(*created)
+		}
 
 		switch format {
 		case FormatList:
@@ -585,6 +946,14 @@ func (w *Wire) formatResults(files []FileDetail, format FormatType) ([]string, e
 				tags := strings.Join(page.Hashtags(), ", ")
 				results = append(results, fmt.Sprintf("  Tags: %s", tags))
 			}
+		case FormatCard:
+			results = append(results, fmt.Sprintf("- [%s](%s)", title, slug))
+			if date != "" {
+				results = append(results, fmt.Sprintf("  Date: %s", date))
+			}
+			if excerpt := file.ParsedContent.Excerpt(excerptLength); excerpt != "" {
+				results = append(results, "  "+excerpt)
+			}
 		case FormatTable:
 			// For table format, we'd need to collect all rows and format as a markdown table
 			// This is more complex, so for now use compact format
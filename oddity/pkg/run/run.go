@@ -39,6 +39,7 @@ func StartServer(cfg config.Config) {
 
 	startT = time.Now()
 	wireController = contentstuff.NewWire(siteContent)
+	wireController.Subscribe(siteContent.Events())
 	err = wireController.ScanForQueries()
 	if err != nil {
 		logrus.Fatalf("error scanning for queries: %v", err)
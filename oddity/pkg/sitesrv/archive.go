@@ -0,0 +1,82 @@
+package sitesrv
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/authz"
+	"oddity/pkg/contentstuff"
+)
+
+// buildArchive groups public posts by the year they were created, newest year and post first
+// private and draft posts, and posts without a date, are left out
+func (s *SiteApp) buildArchive() contentstuff.PostsPage {
+	byYear := make(map[int][]contentstuff.PostSummary)
+	for _, post := range s.WireController.GetRecentPosts() {
+		if contentstuff.IsPrivate(s.SiteContent, post) {
+			continue
+		}
+		if post.ParsedContent != nil && post.ParsedContent.Frontmatter != nil && post.ParsedContent.Frontmatter.GetBool("draft") {
+			continue
+		}
+		pg := contentstuff.NewPageFromFileDetail(&post)
+		created := pg.DateCreated()
+		if created == nil {
+			continue
+		}
+		title := pg.Title()
+		if title == "" {
+			title = filepath.Base(pg.Slug())
+		}
+		byYear[created.Year()] = append(byYear[created.Year()], contentstuff.PostSummary{
+			Title: title,
+			Slug:  pg.Slug(),
+			Date:  *created,
+			Tags:  pg.Hashtags(),
+		})
+	}
+
+	page := contentstuff.PostsPage{}
+	for year, posts := range byYear {
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].Date.After(posts[j].Date) })
+		page.PostsByYear = append(page.PostsByYear, contentstuff.YearGroup{Year: year, Posts: posts})
+		page.PostCount += len(posts)
+	}
+	sort.Slice(page.PostsByYear, func(i, j int) bool { return page.PostsByYear[i].Year > page.PostsByYear[j].Year })
+	for _, group := range page.PostsByYear {
+		page.Archives = append(page.Archives, contentstuff.Archive{Year: group.Year, Count: len(group.Posts)})
+	}
+	return page
+}
+
+// renderArchive lists public posts grouped by year through the post template
+func (s *SiteApp) renderArchive(c *gin.Context) {
+	archive := s.buildArchive()
+	site := s.buildSiteConfigWithNav(c, "archive")
+
+	var sb strings.Builder
+	for _, group := range archive.PostsByYear {
+		sb.WriteString(fmt.Sprintf(`<section class="archive-year"><h2 id="%d">%d</h2><ul class="listing">`, group.Year, group.Year))
+		for _, post := range group.Posts {
+			sb.WriteString(fmt.Sprintf(`<li><time datetime="%s">%s</time> <a href="/%s">%s</a></li>`,
+				post.Date.Format("2006-01-02"), site.DisplayDate(post.Date), post.Slug, template.HTMLEscapeString(post.Title)))
+		}
+		sb.WriteString(`</ul></section>`)
+	}
+
+	postPage := contentstuff.PostPage{
+		Site:  site,
+		Theme: authz.Theme(c),
+		Meta: contentstuff.PageMeta{
+			Title: "Archive",
+		},
+		PageHTML: template.HTML(sb.String()),
+	}
+	c.HTML(http.StatusOK, "post.html", postPage)
+}
@@ -0,0 +1,58 @@
+package sitesrv
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestArchiveByYear(t *testing.T) {
+	site, r := newTestSite(t, map[string]string{
+		"index.md":          "# Home\n",
+		"blog/old.md":       "---\ntitle: Old\ncreated: 1609545600\n---\n# Old\n",       // 2021-01-02
+		"blog/mid-early.md": "---\ntitle: Mid Early\ncreated: 1675209600\n---\n# Mid\n", // 2023-02-01
+		"blog/mid-late.md":  "---\ntitle: Mid Late\ncreated: 1701388800\n---\n# Mid\n",  // 2023-12-01
+		"blog/new.md":       "---\ntitle: New\ncreated: 1709337600\n---\n# New\n",       // 2024-03-02
+		"blog/secret.md":    "---\ntitle: Secret\ncreated: 1709337600\nprivate: true\n---\n# Secret\n",
+		"blog/draft.md":     "---\ntitle: Draft\ncreated: 1709337600\ndraft: true\n---\n# Draft\n",
+	})
+
+	archive := site.buildArchive()
+	expected := map[int][]string{
+		2024: {"blog/new"},
+		2023: {"blog/mid-late", "blog/mid-early"},
+		2021: {"blog/old"},
+	}
+	years := []int{2024, 2023, 2021}
+	if len(archive.PostsByYear) != len(years) {
+		t.Fatalf("expected %d year groups, got %+v", len(years), archive.PostsByYear)
+	}
+	for i, group := range archive.PostsByYear {
+		if group.Year != years[i] {
+			t.Errorf("group %d: expected year %d, got %d", i, years[i], group.Year)
+			continue
+		}
+		var slugs []string
+		for _, post := range group.Posts {
+			slugs = append(slugs, post.Slug)
+		}
+		if strings.Join(slugs, ",") != strings.Join(expected[group.Year], ",") {
+			t.Errorf("year %d: expected %v, got %v", group.Year, expected[group.Year], slugs)
+		}
+	}
+	if archive.PostCount != 4 {
+		t.Errorf("expected 4 archived posts, got %d", archive.PostCount)
+	}
+
+	w := get(r, "/archive")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	html := w.Body.String()
+	if strings.Contains(html, "Secret") || strings.Contains(html, "Draft") {
+		t.Errorf("expected private and draft posts to be excluded, got:\n%s", html)
+	}
+	if i, j := strings.Index(html, ">2024<"), strings.Index(html, ">2021<"); i < 0 || j < 0 || i > j {
+		t.Errorf("expected years in descending order, got:\n%s", html)
+	}
+}
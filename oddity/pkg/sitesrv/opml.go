@@ -0,0 +1,85 @@
+package sitesrv
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/contentstuff"
+)
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Outline []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XMLURL  string `xml:"xmlUrl,attr"`
+	HTMLURL string `xml:"htmlUrl,attr,omitempty"`
+}
+
+// renderOPML lists the site feed, index page feeds and tag feeds as an OPML document
+func (s *SiteApp) renderOPML(c *gin.Context) {
+	host := requestHost(c)
+	siteTitle := s.Config.Site.Title
+
+	doc := opmlDocument{
+		Version: "2.0",
+		Title:   fmt.Sprintf("%s feeds", siteTitle),
+	}
+
+	addOutline := func(title, feedPath, pagePath string) {
+		doc.Outline = append(doc.Outline, opmlOutline{
+			Type:    "rss",
+			Text:    title,
+			Title:   title,
+			XMLURL:  host + feedPath,
+			HTMLURL: host + pagePath,
+		})
+	}
+
+	// index pages with queries have their own feeds, the root index is the main site feed
+	for _, fileName := range s.WireController.FilesWithQueries() {
+		fd, ok := s.SiteContent.DoPath(fileName)
+		if !ok {
+			continue
+		}
+		if contentstuff.IsPrivate(s.SiteContent, fd) {
+			continue
+		}
+		pg := contentstuff.NewPageFromFileDetail(&fd)
+
+		feedLink := s.createFeedsLink(pg)
+		if feedLink == "" {
+			continue
+		}
+
+		title, pagePath := pg.Title(), "/"+pg.Slug()
+		if fileName == "index.md" || fileName == "index.html" {
+			title, pagePath = siteTitle, "/"
+		}
+		if title == "" {
+			title = siteTitle
+		}
+		addOutline(title, feedLink, pagePath)
+	}
+
+	for _, tag := range s.SiteContent.AllTags() {
+		addOutline(fmt.Sprintf("%s - #%s", siteTitle, tag), "/"+tagFeedPrefix+tag+".xml", contentstuff.HashtagPath(tag))
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", append([]byte(xml.Header), out...))
+}
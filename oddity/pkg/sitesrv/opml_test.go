@@ -0,0 +1,36 @@
+package sitesrv
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFeedsOPML(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"index.md":        "# Home\n\n<!-- <query type=\"posts\"> -->\n<!-- </query> -->\n",
+		"blog/first.md":   "---\ncreated: 1700000000\n---\n# First\n\nHello #golang",
+		"blog/private.md": "---\nprivate: true\n---\n# Private\n\nHidden #secret",
+	})
+
+	w := get(r, "/feeds.opml")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	expected := []string{
+		`<outline type="rss" text="Test Site" title="Test Site" xmlUrl="http://example.com/index.xml" htmlUrl="http://example.com/">`,
+		`xmlUrl="http://example.com/tags/golang.xml" htmlUrl="http://example.com/search/?q=%23golang"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected OPML to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	if strings.Contains(body, "secret") {
+		t.Errorf("expected tags from private posts to be excluded, got:\n%s", body)
+	}
+}
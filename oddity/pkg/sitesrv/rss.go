@@ -10,9 +10,16 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/feeds"
 
+	"oddity/pkg/config"
 	"oddity/pkg/contentstuff"
 )
 
+// tagFeedPrefix is the path prefix for per-tag feeds, e.g. /tags/golang.xml
+const tagFeedPrefix = "tags/"
+
+// feedExcerptLength is how many characters of text an item carries when the feed is not full content
+const feedExcerptLength = 300
+
 // s.renderRSSFeed(c, requestPath)
 func (s *SiteApp) renderRSSFeed(c *gin.Context, requestPath string) {
 	if !strings.HasSuffix(requestPath, ".xml") && !strings.HasSuffix(requestPath, ".rss") && !strings.HasSuffix(requestPath, ".atom") {
@@ -26,6 +33,10 @@ func (s *SiteApp) renderRSSFeed(c *gin.Context, requestPath string) {
 	// check if pathBase exists in site content
 	fd, ok := s.SiteContent.DoPath(pathBase)
 	if !ok {
+		if strings.HasPrefix(pathBase, tagFeedPrefix) {
+			s.renderTagFeed(c, requestPath, strings.TrimPrefix(pathBase, tagFeedPrefix))
+			return
+		}
 		s.render404(c)
 		return
 	}
@@ -41,14 +52,74 @@ func (s *SiteApp) renderRSSFeed(c *gin.Context, requestPath string) {
 		return
 	}
 
-	_ = posts
+	s.writeFeed(c, requestPath, s.Config.Site.Title, posts, s.Config.Feed.Main())
+}
 
-	// get domain from http host headers
-	scheme := "http"
-	if c.Request.TLS != nil {
-		scheme = "https"
+// renderTagFeed serves the feed of posts carrying a hashtag
+func (s *SiteApp) renderTagFeed(c *gin.Context, requestPath string, tag string) {
+	posts := s.WireController.GetTagPosts(tag)
+	if tag == "" || len(posts) == 0 {
+		s.render404(c)
+		return
+	}
+
+	s.writeFeed(c, requestPath, fmt.Sprintf("%s - #%s", s.Config.Site.Title, tag), posts, s.Config.Feed.Tag())
+}
+
+// writeFeed renders posts as rss or atom based on the request path extension
+func (s *SiteApp) writeFeed(c *gin.Context, requestPath string, title string, posts []contentstuff.FileDetail, opts config.FeedOptions) {
+	feed := s.buildFeed(c, title, posts, opts)
+
+	if strings.HasSuffix(requestPath, ".atom") {
+		atom, err := feed.ToAtom()
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+		}
+		c.Status(http.StatusOK)
+		c.Writer.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		_, _ = fmt.Fprintf(c.Writer, atom)
+		return
+	}
+	if strings.HasSuffix(requestPath, ".xml") || strings.HasSuffix(requestPath, ".rss") {
+		rss, err := feed.ToRss()
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+		}
+		c.Status(http.StatusOK)
+		c.Writer.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		_, _ = fmt.Fprintf(c.Writer, rss)
+		return
+	}
+}
+
+// renderJSONFeed serves the site feed as JSON Feed 1.1
+// posts come from the root index queries, or all recent posts when it has none
+func (s *SiteApp) renderJSONFeed(c *gin.Context) {
+	var posts []contentstuff.FileDetail
+	if fd, ok := s.SiteContent.DoPath("index.md"); ok && s.WireController.PostHasQueries(fd.FileName) {
+		var err error
+		posts, err = s.WireController.GetQueryResultsForPost(fd.FileName)
+		if err != nil {
+			s.renderError(c, "feed.json")
+			return
+		}
+	} else {
+		posts = s.WireController.GetRecentPosts()
 	}
-	host := scheme + "://" + c.Request.Host
+
+	jsonFeed, err := s.buildFeed(c, s.Config.Site.Title, posts, s.Config.Feed.Main()).ToJSON()
+	if err != nil {
+		s.renderError(c, "feed.json")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/feed+json; charset=utf-8", []byte(jsonFeed))
+}
+
+// buildFeed turns up to opts.Limit posts into feed items, skipping private and noindex posts
+// without opts.FullContent items carry a plain text excerpt instead of the post html
+func (s *SiteApp) buildFeed(c *gin.Context, title string, posts []contentstuff.FileDetail, opts config.FeedOptions) *feeds.Feed {
+	host := requestHost(c)
 
 	lastCreated := time.Now()
 	if len(posts) > 0 {
@@ -60,26 +131,39 @@ func (s *SiteApp) renderRSSFeed(c *gin.Context, requestPath string) {
 
 	now := time.Now()
 	feed := &feeds.Feed{
-		Title:       s.Config.Site.Title,
+		Title:       title,
 		Link:        &feeds.Link{Href: host},
 		Description: s.Config.Site.Description,
-		Author:      &feeds.Author{Name: s.Config.Site.Title, Email: "jmoiron@jmoiron.net"},
+		Author:      &feeds.Author{Name: s.Config.Site.PostAuthor(), Email: s.Config.Site.AuthorEmail},
 		Created:     lastCreated,
 	}
 
 	for _, post := range posts {
+		if contentstuff.IsPrivate(s.SiteContent, post) {
+			continue
+		}
 		pg := contentstuff.NewPageFromFileDetail(&post)
-		if pg.IsPrivate() {
+		if pg.NoIndex() {
 			continue
 		}
 
+		link := host + "/" + pg.Slug()
+		if canonical := pg.Canonical(); canonical != "" {
+			link = canonical
+		}
 		item := &feeds.Item{
-			Title:       pg.Title(),
-			Link:        &feeds.Link{Href: host + "/" + pg.Slug()},
-			Description: string(pg.SafeHTML()),
+			Id:     host + "/" + pg.Slug(),
+			Title:  pg.Title(),
+			Link:   &feeds.Link{Href: link},
+			Author: &feeds.Author{Name: pg.Author(s.Config.Site.PostAuthor())},
 		}
 
-		item.Content = string(pg.SafeHTML())
+		if opts.FullContent {
+			item.Description = string(pg.SafeHTML())
+			item.Content = string(pg.SafeHTML())
+		} else {
+			item.Description = post.ParsedContent.Excerpt(feedExcerptLength)
+		}
 
 		if m := pg.DateCreated(); m != nil {
 			item.Created = *m
@@ -95,30 +179,19 @@ func (s *SiteApp) renderRSSFeed(c *gin.Context, requestPath string) {
 
 		feed.Add(item)
 
-		if len(feed.Items) >= 20 {
+		if len(feed.Items) >= opts.Limit {
 			break
 		}
 	}
 
-	if strings.HasSuffix(requestPath, ".atom") {
-		atom, err := feed.ToAtom()
-		if err != nil {
-			c.Status(http.StatusInternalServerError)
-		}
-		c.Status(http.StatusOK)
-		c.Writer.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
-		_, _ = fmt.Fprintf(c.Writer, atom)
-		return
-	}
-	if strings.HasSuffix(requestPath, ".xml") || strings.HasSuffix(requestPath, ".rss") {
-		rss, err := feed.ToRss()
-		if err != nil {
-			c.Status(http.StatusInternalServerError)
-		}
-		c.Status(http.StatusOK)
-		c.Writer.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
-		_, _ = fmt.Fprintf(c.Writer, rss)
-		return
-	}
+	return feed
+}
 
+// requestHost builds the scheme and host of the site from the request headers
+func requestHost(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
 }
@@ -0,0 +1,192 @@
+package sitesrv
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+
+	"oddity/pkg/config"
+)
+
+func TestJSONFeed(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"index.md":        "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\"> -->\n<!-- </query> -->\n",
+		"blog/older.md":   "---\ncreated: 1700000000\n---\n# Older\n\nFirst post.",
+		"blog/newer.md":   "---\ncreated: 1700100000\n---\n# Newer\n\nSecond post.",
+		"blog/private.md": "---\ncreated: 1700200000\nprivate: true\n---\n# Private\n",
+	})
+
+	w := get(r, "/feed.json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/feed+json; charset=utf-8" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	var feed feeds.JSONFeed
+	if err := json.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("failed to unmarshal feed: %v\n%s", err, w.Body.String())
+	}
+
+	if feed.Version != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("unexpected version %q", feed.Version)
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("expected 2 public items, got %d", len(feed.Items))
+	}
+
+	item := feed.Items[0]
+	if item.Id != "http://example.com/blog/newer" || item.Url != "http://example.com/blog/newer" {
+		t.Errorf("unexpected id/url %q %q", item.Id, item.Url)
+	}
+	if item.Title != "Newer" {
+		t.Errorf("unexpected title %q", item.Title)
+	}
+	if item.ContentHTML != "<p>Second post.</p>\n" {
+		t.Errorf("unexpected content_html %q", item.ContentHTML)
+	}
+	if item.PublishedDate == nil || item.PublishedDate.Unix() != 1700100000 {
+		t.Errorf("unexpected date_published %v", item.PublishedDate)
+	}
+}
+
+func TestAtomFeedDefaultAuthor(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"index.md":       "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\"> -->\n<!-- </query> -->\n",
+		"blog/anon.md":   "---\ncreated: 1700000000\n---\n# Anon\n\nNo author here.",
+		"blog/signed.md": "---\ncreated: 1700100000\nauthor: Guest Writer\n---\n# Signed\n\nWritten by a guest.",
+	}, func(cfg *config.Config) {
+		cfg.Site.Author = "Owner"
+		cfg.Site.DefaultAuthor = "Site Team"
+	})
+
+	w := get(r, "/index.atom")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var feed feeds.AtomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("failed to unmarshal atom: %v\n%s", err, w.Body.String())
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(feed.Entries))
+	}
+	authors := make(map[string]string)
+	for _, entry := range feed.Entries {
+		if entry.Author == nil {
+			t.Fatalf("entry %s has no <author>", entry.Title)
+		}
+		authors[entry.Title] = entry.Author.Name
+	}
+	if authors["Anon"] != "Site Team" {
+		t.Errorf("expected post without author to credit the site default, got %q", authors["Anon"])
+	}
+	if authors["Signed"] != "Guest Writer" {
+		t.Errorf("expected frontmatter author to win, got %q", authors["Signed"])
+	}
+}
+
+func TestFeedLimitsPerFeed(t *testing.T) {
+	files := map[string]string{
+		"index.md": "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\"> -->\n<!-- </query> -->\n",
+	}
+	for i := 0; i < 5; i++ {
+		files[fmt.Sprintf("blog/post%d.md", i)] = fmt.Sprintf("---\ncreated: %d\n---\n# Post %d\n\nAll about #golang and more words after it.", 1700000000+i*1000, i)
+	}
+	fullTags := false
+	_, r := newTestSite(t, files, func(cfg *config.Config) {
+		cfg.Feed = config.FeedConfig{MainLimit: 2, TagLimit: 4, TagFullContent: &fullTags}
+	})
+
+	var main feeds.AtomFeed
+	if err := xml.Unmarshal(get(r, "/index.atom").Body.Bytes(), &main); err != nil {
+		t.Fatalf("failed to unmarshal main feed: %v", err)
+	}
+	if len(main.Entries) != 2 {
+		t.Errorf("expected main feed to carry 2 entries, got %d", len(main.Entries))
+	}
+	if len(main.Entries) > 0 && (main.Entries[0].Content == nil || !strings.Contains(main.Entries[0].Content.Content, "<p>")) {
+		t.Errorf("expected main feed to carry full content, got %+v", main.Entries[0].Content)
+	}
+
+	var tag feeds.AtomFeed
+	if err := xml.Unmarshal(get(r, "/tags/golang.atom").Body.Bytes(), &tag); err != nil {
+		t.Fatalf("failed to unmarshal tag feed: %v", err)
+	}
+	if len(tag.Entries) != 4 {
+		t.Errorf("expected tag feed to carry 4 entries, got %d", len(tag.Entries))
+	}
+	if len(tag.Entries) > 0 {
+		entry := tag.Entries[0]
+		if entry.Content != nil {
+			t.Errorf("expected tag feed without full content, got %q", entry.Content.Content)
+		}
+		if entry.Summary == nil || strings.Contains(entry.Summary.Content, "<p>") || !strings.Contains(entry.Summary.Content, "All about") {
+			t.Errorf("expected a plain text excerpt summary, got %+v", entry.Summary)
+		}
+	}
+}
+
+func TestFeedUsesCanonicalFrontmatter(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"index.md":           "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\"> -->\n<!-- </query> -->\n",
+		"blog/syndicated.md": "---\ncreated: 1700000000\ncanonical: https://elsewhere.example/original\n---\n# Syndicated\n\nBody.",
+	})
+
+	var feed feeds.JSONFeed
+	if err := json.Unmarshal(get(r, "/feed.json").Body.Bytes(), &feed); err != nil {
+		t.Fatalf("failed to unmarshal feed: %v", err)
+	}
+	if len(feed.Items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(feed.Items))
+	}
+	if feed.Items[0].Url != "https://elsewhere.example/original" {
+		t.Errorf("expected item url to be the canonical url, got %q", feed.Items[0].Url)
+	}
+	if feed.Items[0].Id != "http://example.com/blog/syndicated" {
+		t.Errorf("expected item id to stay on this site, got %q", feed.Items[0].Id)
+	}
+}
+
+func TestFeedIgnoresPinnedPosts(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"index.md":       "# Home\n\n<!-- <query type=\"posts\" path=\"blog/*\"> -->\n<!-- </query> -->\n",
+		"blog/old.md":    "---\ncreated: 1600000000\npinned: true\n---\n# Old\n\nStill #news.",
+		"blog/newer.md":  "---\ncreated: 1700100000\n---\n# Newer\n\nMore #news.",
+		"blog/newest.md": "---\ncreated: 1700200000\n---\n# Newest\n\nLatest #news.",
+	})
+
+	for _, path := range []string{"/index.xml", "/tags/news.xml"} {
+		var rss struct {
+			Channel struct {
+				PubDate string `xml:"pubDate"`
+				Items   []struct {
+					Title string `xml:"title"`
+				} `xml:"item"`
+			} `xml:"channel"`
+		}
+		w := get(r, path)
+		if err := xml.Unmarshal(w.Body.Bytes(), &rss); err != nil {
+			t.Fatalf("%s: failed to unmarshal feed: %v\n%s", path, err, w.Body.String())
+		}
+
+		var titles []string
+		for _, item := range rss.Channel.Items {
+			titles = append(titles, item.Title)
+		}
+		if strings.Join(titles, ",") != "Newest,Newer,Old" {
+			t.Errorf("%s: expected items in date order, got %v", path, titles)
+		}
+		if date, err := time.Parse(time.RFC1123Z, rss.Channel.PubDate); err != nil || date.Unix() != 1700200000 {
+			t.Errorf("%s: expected the feed date of the newest post, got %q", path, rss.Channel.PubDate)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package sitesrv
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/authz"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// renderSearchAPI answers /api/search?q=...&limit=... with scored results as json
+// private pages are only searched for authenticated callers
+func (s *SiteApp) renderSearchAPI(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = min(l, maxSearchLimit)
+	}
+
+	results := s.SiteContent.Search(q, authz.IsAuthenticated(c), limit)
+	c.JSON(http.StatusOK, gin.H{
+		"query":   q,
+		"results": results,
+	})
+}
@@ -0,0 +1,59 @@
+package sitesrv
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"oddity/pkg/contentstuff"
+)
+
+func TestSearchAPI(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"blog/gardening.md": "---\ntitle: Gardening\n---\n# Gardening\n\nTomatoes need <sun> and plenty of water every morning.",
+		"blog/cooking.md":   "---\ntitle: Cooking\n---\n# Cooking\n\nRoast the tomatoes with garlic.",
+		"blog/diary.md":     "---\ntitle: Diary\nprivate: true\n---\n# Diary\n\nSecret tomatoes plan.",
+		"blog/other.md":     "# Other\n\nNothing relevant here.",
+	})
+
+	w := get(r, "/api/search?q=Tomatoes")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp struct {
+		Results []contentstuff.SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Results) != 2 {
+		t.Fatalf("expected 2 public results, got %+v", resp.Results)
+	}
+	for _, res := range resp.Results {
+		if res.Slug == "blog/diary" {
+			t.Errorf("expected private page to be excluded for public callers")
+		}
+		if res.Score <= 0 {
+			t.Errorf("expected a positive score for %s", res.Slug)
+		}
+	}
+
+	garden := resp.Results[0]
+	if garden.Slug != "blog/gardening" && resp.Results[1].Slug == "blog/gardening" {
+		garden = resp.Results[1]
+	}
+	if !strings.Contains(garden.Snippet, "<mark>Tomatoes</mark> need &lt;sun&gt;") {
+		t.Errorf("expected highlighted and escaped snippet, got %q", garden.Snippet)
+	}
+
+	w = get(r, "/api/search?q=tomatoes&limit=1")
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Results) != 1 {
+		t.Errorf("expected limit to cap results, got %s", w.Body.String())
+	}
+
+	if w := get(r, "/api/search"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without q, got %d", w.Code)
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"oddity/pkg/contentstuff"
 )
 
+const mimeMarkdown = "text/markdown"
+
 type SiteApp struct {
 	WireController *contentstuff.Wire
 	SiteContent    *contentstuff.ContentStuff
@@ -93,24 +95,82 @@ func (s *SiteApp) handleAllContentPages(c *gin.Context) {
 		}
 	}
 
+	if requestPath == "feeds.opml" {
+		s.renderOPML(c)
+		return
+	}
+
+	if requestPath == "sitemap.xml" {
+		s.renderSitemap(c)
+		return
+	}
+
+	if requestPath == "feed.json" {
+		s.renderJSONFeed(c)
+		return
+	}
+
+	if requestPath == "api/search" {
+		s.renderSearchAPI(c)
+		return
+	}
+
+	// a content file named archive takes precedence over the generated archive
+	if requestPath == "archive" {
+		if _, ok := s.SiteContent.DoPath(requestPath); !ok {
+			s.renderArchive(c)
+			return
+		}
+	}
+
 	if strings.HasSuffix(requestPath, ".xml") || strings.HasSuffix(requestPath, ".rss") || strings.HasSuffix(requestPath, ".atom") {
 		// handle rss feed request
 		s.renderRSSFeed(c, requestPath)
 		return
 	}
 
+	if s.Config.Content.AliasRedirect {
+		if file, ok := s.SiteContent.ResolveAlias(requestPath); ok {
+			c.Redirect(http.StatusMovedPermanently, "/"+contentstuff.NewPageFromFileDetail(&file).Slug())
+			return
+		}
+	}
+
 	if file, ok := s.SiteContent.DoPath(requestPath); ok {
+		if contentstuff.IsSectionMeta(file) {
+			// section settings are not a page of their own
+			s.render404(c)
+			return
+		}
 		if file.FileType == contentstuff.FileTypeDirectory {
 			// look for index.md or index.html in this directory
 			s.renderIndexAtPath(c, requestPath)
 			return
 		}
+		// filename and other non-canonical forms redirect to the slug url
+		if canonical, redirect := s.canonicalRedirect(requestPath, file); redirect {
+			if c.Request.URL.RawQuery != "" {
+				canonical += "?" + c.Request.URL.RawQuery
+			}
+			c.Redirect(http.StatusMovedPermanently, canonical)
+			return
+		}
+
 		// if ends with index.html or index.md then render index of parent directory
 		if strings.HasSuffix(requestPath, "index.html") || strings.HasSuffix(requestPath, "index.md") || strings.HasSuffix(requestPath, "index") {
 			s.renderIndexFileAtPath(c, requestPath)
 			return
 		}
 
+		if s.Config.Content.ServeMarkdown {
+			// html and markdown share the url, caches must key on Accept
+			c.Header("Vary", "Accept")
+			if c.NegotiateFormat(gin.MIMEHTML, mimeMarkdown) == mimeMarkdown {
+				s.renderMarkdown(c, file)
+				return
+			}
+		}
+
 		s.renderPage(c, file)
 		return
 	}
@@ -143,9 +203,91 @@ func (s *SiteApp) renderIndexAtPath(c *gin.Context, path string) {
 		return
 	}
 
+	if s.Config.Content.AutoIndex {
+		s.renderDirectoryListing(c, path)
+		return
+	}
+
 	s.render404(c)
 }
 
+// canonicalURL builds the absolute url of slug, preferring the configured base url
+func (s *SiteApp) canonicalURL(c *gin.Context, slug string) string {
+	base := strings.TrimSuffix(s.Config.Site.BaseURL, "/")
+	if base == "" {
+		base = requestHost(c)
+	}
+	return base + "/" + strings.TrimPrefix(slug, "/")
+}
+
+// pageCanonicalURL is the canonical url of page, its frontmatter canonical overrides the site url
+func (s *SiteApp) pageCanonicalURL(c *gin.Context, page *contentstuff.Page) string {
+	if canonical := page.Canonical(); canonical != "" {
+		return canonical
+	}
+	return s.canonicalURL(c, page.Slug())
+}
+
+// canonicalRedirect returns the canonical url when requestPath reached file by a non-canonical form
+// such as blog/post.md instead of blog/post, aliases are left to the alias config
+func (s *SiteApp) canonicalRedirect(requestPath string, file contentstuff.FileDetail) (string, bool) {
+	if _, isAlias := s.SiteContent.ResolveAlias(requestPath); isAlias {
+		return "", false
+	}
+
+	slug := contentstuff.NewPageFromFileDetail(&file).Slug()
+	base := filepath.Base(file.FileName)
+	if base == "index.md" || base == "index.html" {
+		// index pages are served at their directory, only the raw filename is redirected
+		if requestPath != file.FileName {
+			return "", false
+		}
+		dir := filepath.Dir(file.FileName)
+		if dir == "." {
+			return "/", true
+		}
+		return "/" + dir, true
+	}
+
+	if requestPath == slug {
+		return "", false
+	}
+	return "/" + slug, true
+}
+
+// renderDirectoryListing lists the public posts of a directory that has no index file
+func (s *SiteApp) renderDirectoryListing(c *gin.Context, path string) {
+	var sb strings.Builder
+	for _, post := range s.WireController.GetDirectoryPosts(path) {
+		if contentstuff.IsPrivate(s.SiteContent, post) {
+			continue
+		}
+		pg := contentstuff.NewPageFromFileDetail(&post)
+		title := pg.Title()
+		if title == "" {
+			title = filepath.Base(pg.Slug())
+		}
+		sb.WriteString(fmt.Sprintf(`<li><a href="/%s">%s</a></li>`, pg.Slug(), template.HTMLEscapeString(title)))
+	}
+
+	if sb.Len() == 0 {
+		s.render404(c)
+		return
+	}
+
+	postPage := contentstuff.PostPage{
+		Site:  s.buildSiteConfigWithNav(c, path),
+		Theme: authz.Theme(c),
+		Meta: contentstuff.PageMeta{
+			Title: filepath.Base(path),
+		},
+		PageHTML: template.HTML(`<ul class="listing">` + sb.String() + `</ul>`),
+		BackLink: s.backLinkToParent(path),
+	}
+
+	c.HTML(http.StatusOK, "post.html", postPage)
+}
+
 func (s *SiteApp) backLinkToParent(path string) string {
 	if path == "" || path == "." || path == "/" || path == "index" {
 		return ""
@@ -167,23 +309,27 @@ func (s *SiteApp) renderIndexFileAtPath(c *gin.Context, path string) {
 		return
 	}
 	page := contentstuff.NewPageFromFileDetail(&file)
-	if page.IsPrivate() && !authz.IsAuthenticated(c) {
+	isPrivate := contentstuff.IsPrivate(s.SiteContent, file)
+	if isPrivate && !authz.IsAuthenticated(c) {
 		s.render404(c)
 		return
 	}
 
 	indexPage := contentstuff.PostPage{
-		Site: s.buildSiteConfigWithNav(c, page.Slug()),
+		Site:  s.buildSiteConfigWithNav(c, page.Slug()),
+		Theme: authz.Theme(c),
 		Meta: contentstuff.PageMeta{
-			Title: page.Title(),
+			Title:   page.Title(),
+			NoIndex: page.NoIndex(),
 		},
 		PageHTML:        page.SafeHTML(),
 		NewPostHintSlug: s.createNewPostSlugHint(page),
 		EditURL:         fmt.Sprintf("/admin/edit?path=%s", page.Slug()),
-		IsPrivate:       page.IsPrivate(),
+		IsPrivate:       isPrivate,
 		IsAuthenticated: authz.IsAuthenticated(c),
 		BackLink:        s.backLinkToParent(page.Slug()),
 		FeedsLink:       s.createFeedsLink(page),
+		Frontmatter:     page.Frontmatter(),
 	}
 
 	c.HTML(200, "post.html", indexPage)
@@ -232,24 +378,99 @@ func (s *SiteApp) renderPage(c *gin.Context, file contentstuff.FileDetail) {
 
 	postPage := contentstuff.PostPage{
 		Site:            s.buildSiteConfigWithNav(c, page.Slug()),
+		Theme:           authz.Theme(c),
 		EditURL:         fmt.Sprintf("/admin/edit?path=%s", page.Slug()),
 		IsAuthenticated: authz.IsAuthenticated(c),
 		IsPrivate:       contentstuff.IsPrivate(s.SiteContent, file),
 		NewPostHintSlug: s.createNewPostSlugHint(page),
 		Meta: contentstuff.PageMeta{
-			Title: page.Title(),
+			Title:        page.Title(),
+			Author:       page.Author(s.Config.Site.PostAuthor()),
+			CanonicalURL: s.pageCanonicalURL(c, page),
+			NoIndex:      page.NoIndex(),
 		},
-		PageHTML:     page.SafeHTML(),
+		PageHTML:     page.TableOfContents() + page.SafeHTML(),
 		CreatedDate:  page.DateCreated(),
 		ModifiedDate: page.DateModified(),
 		BackLink:     s.backLinkToParent(page.Slug()),
 		FeedsLink:    s.createFeedsLink(page),
+		Frontmatter:  page.Frontmatter(),
 	}
 	//postPage.ModifiedDate = p.DateModified()
+	if s.Config.Site.PostNavigation {
+		postPage.PrevPost, postPage.NextPost = s.adjacentPosts(c, file)
+	}
+	if postPage.Site.Comments {
+		postPage.Comments = &contentstuff.CommentsHook{
+			URL:  s.pageCanonicalURL(c, page),
+			Slug: page.Slug(),
+		}
+	}
 
 	c.HTML(200, "post.html", postPage)
 }
 
+// adjacentPosts finds the posts created just before and after file in its directory,
+// private posts are skipped for visitors who are not signed in
+func (s *SiteApp) adjacentPosts(c *gin.Context, file contentstuff.FileDetail) (prev, next *contentstuff.PostSummary) {
+	authenticated := authz.IsAuthenticated(c)
+	var siblings []contentstuff.FileDetail
+	for _, post := range s.WireController.GetSiblingPosts(file.FileName, authenticated) {
+		if !authenticated && contentstuff.IsPrivate(s.SiteContent, post) {
+			continue
+		}
+		siblings = append(siblings, post)
+	}
+
+	for i, post := range siblings {
+		if post.FileName != file.FileName {
+			continue
+		}
+		if i > 0 {
+			prev = postSummary(siblings[i-1])
+		}
+		if i < len(siblings)-1 {
+			next = postSummary(siblings[i+1])
+		}
+		break
+	}
+	return prev, next
+}
+
+// postSummary describes a dated post for navigation links
+func postSummary(post contentstuff.FileDetail) *contentstuff.PostSummary {
+	pg := contentstuff.NewPageFromFileDetail(&post)
+	title := pg.Title()
+	if title == "" {
+		title = filepath.Base(pg.Slug())
+	}
+	summary := &contentstuff.PostSummary{Title: title, Slug: pg.Slug()}
+	if created := pg.DateCreated(); created != nil {
+		summary.Date = *created
+	}
+	return summary
+}
+
+// renderMarkdown serves the page source for clients that asked for text/markdown
+func (s *SiteApp) renderMarkdown(c *gin.Context, file contentstuff.FileDetail) {
+	if !authz.IsAuthenticated(c) && contentstuff.IsPrivate(s.SiteContent, file) {
+		c.String(http.StatusNotFound, "Not Found")
+		return
+	}
+	if file.ParsedContent == nil {
+		s.renderError(c, file.FileName)
+		return
+	}
+
+	md, err := file.ParsedContent.ToMarkdown()
+	if err != nil {
+		s.renderError(c, file.FileName)
+		return
+	}
+
+	c.Data(http.StatusOK, mimeMarkdown+"; charset=utf-8", []byte(md))
+}
+
 func (s *SiteApp) createNewPostSlugHint(path *contentstuff.Page) string {
 	currSlug := path.Slug()
 	return s.createNewPostSlugHintFromPath(currSlug)
@@ -287,12 +508,14 @@ func (s *SiteApp) createFeedsLink(pg *contentstuff.Page) string {
 
 func (s *SiteApp) render404(c *gin.Context) {
 	postPage := contentstuff.PostPage{
-		Site: s.buildSiteConfigWithNav(c, ""), // page is only used for nav and we don't care for public 404
+		Site:  s.buildSiteConfigWithNav(c, ""), // page is only used for nav and we don't care for public 404
+		Theme: authz.Theme(c),
 	}
 	postPage.Meta = contentstuff.PageMeta{
 		Title: "404 Not Found",
 	}
 	postPage.PageHTML = template.HTML("<p>The page you are looking for does not exist.</p>")
+	s.applyErrorContentPage(&postPage, "404.md")
 
 	c.HTML(http.StatusNotFound, "post.html", postPage)
 
@@ -301,6 +524,7 @@ func (s *SiteApp) render404(c *gin.Context) {
 func (s *SiteApp) render404ButMaybeCreate(c *gin.Context, path string) {
 	postPage := contentstuff.PostPage{
 		Site:            s.buildSiteConfigWithNav(c, path),
+		Theme:           authz.Theme(c),
 		IsAuthenticated: authz.IsAuthenticated(c),
 		NewPostHintSlug: s.createNewPostSlugHintFromPath(path),
 	}
@@ -327,6 +551,7 @@ func (s *SiteApp) render404ButMaybeCreate(c *gin.Context, path string) {
 func (s *SiteApp) renderError(c *gin.Context, path string) {
 	postPage := contentstuff.PostPage{
 		Site:            s.buildSiteConfigWithNav(c, path),
+		Theme:           authz.Theme(c),
 		IsAuthenticated: authz.IsAuthenticated(c),
 		NewPostHintSlug: s.createNewPostSlugHintFromPath(path),
 		Meta: contentstuff.PageMeta{
@@ -334,5 +559,20 @@ func (s *SiteApp) renderError(c *gin.Context, path string) {
 		},
 		PageHTML: template.HTML(fmt.Sprintf(`<p>There was an error processing your request for %s</p>`, path)),
 	}
+	s.applyErrorContentPage(&postPage, "500.md")
 	c.HTML(http.StatusInternalServerError, "post.html", postPage)
 }
+
+// applyErrorContentPage replaces the built-in error message with a content page like 404.md when present
+func (s *SiteApp) applyErrorContentPage(postPage *contentstuff.PostPage, fileName string) {
+	file, ok := s.SiteContent.DoPath(fileName)
+	if !ok || file.ParsedContent == nil {
+		return
+	}
+
+	page := contentstuff.NewPageFromFileDetail(&file)
+	if title := page.Title(); title != "" {
+		postPage.Meta.Title = title
+	}
+	postPage.PageHTML = page.SafeHTML()
+}
@@ -0,0 +1,566 @@
+package sitesrv
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/authz"
+	"oddity/pkg/config"
+	"oddity/pkg/contentstuff"
+	"oddity/tmpl"
+)
+
+// newTestSite builds a SiteApp over files written into a temp content dir
+// post.html only renders the page html so tests can assert on it
+func newTestSite(t *testing.T, files map[string]string, opts ...func(*config.Config)) (*SiteApp, *gin.Engine) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{
+		Content: config.ContentConfig{ContentDir: dir},
+		Site:    config.SiteConfig{Title: "Test Site"},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	content := contentstuff.NewContentStuff(&cfg)
+	if err := content.ReloadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+	wire := contentstuff.NewWire(content)
+	wire.Subscribe(content.Events())
+	if err := wire.ScanForQueries(); err != nil {
+		t.Fatalf("failed to scan queries: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("post.html").Parse(`{{.PageHTML}}`)))
+	site := &SiteApp{WireController: wire, SiteContent: content, Config: cfg}
+	site.RegisterRoutes(r)
+	return site, r
+}
+
+func get(r *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestRenderPageTOC(t *testing.T) {
+	body := "# Title\n\n## Setup\n\nText.\n\n### Install\n\nMore.\n\n#### Deep\n\nDeeper.\n\n## Usage\n\nDone.\n"
+	_, r := newTestSite(t, map[string]string{
+		"with-toc.md":    "---\ntoc: true\n---\n" + body,
+		"shallow-toc.md": "---\ntoc: true\ntoc_depth: 2\n---\n" + body,
+		"no-toc.md":      body,
+	})
+
+	w := get(r, "/with-toc")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	html := w.Body.String()
+	expectedTOC := `<nav class="toc"><ul><li><a href="#setup">Setup</a><ul><li><a href="#install">Install</a></li></ul></li><li><a href="#usage">Usage</a></li></ul></nav>`
+	if !strings.HasPrefix(html, expectedTOC) {
+		t.Errorf("expected page to start with TOC %s, got:\n%s", expectedTOC, html)
+	}
+	if !strings.Contains(html, `id="setup"`) {
+		t.Errorf("expected heading anchors to match TOC links, got:\n%s", html)
+	}
+
+	html = get(r, "/shallow-toc").Body.String()
+	if !strings.Contains(html, `class="toc"`) || strings.Contains(html, `href="#install"`) {
+		t.Errorf("expected toc_depth 2 to only list h2 headings, got:\n%s", html)
+	}
+
+	html = get(r, "/no-toc").Body.String()
+	if strings.Contains(html, `class="toc"`) {
+		t.Errorf("expected no TOC without toc: true, got:\n%s", html)
+	}
+}
+
+func TestRender404ContentPage(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"404.md": "# Lost\n\nNothing lives here, try the [archive](/archive).\n",
+	})
+
+	w := get(r, "/does/not/exist")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Nothing lives here") {
+		t.Errorf("expected 404.md content, got:\n%s", w.Body.String())
+	}
+
+	_, r = newTestSite(t, map[string]string{"index.md": "# Home\n"})
+	w = get(r, "/does/not/exist")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "does not exist") {
+		t.Errorf("expected built-in 404 without 404.md, got %d:\n%s", w.Code, w.Body.String())
+	}
+}
+
+func TestAutoIndexListing(t *testing.T) {
+	files := map[string]string{
+		"notes/first.md":         "---\ncreated: 1700000000\n---\n# First Note\n",
+		"notes/second.md":        "---\ncreated: 1700100000\n---\n# Second Note\n",
+		"notes/secret.md":        "---\nprivate: true\n---\n# Secret Note\n",
+		"notes/deeper/nested.md": "# Nested\n",
+	}
+
+	_, r := newTestSite(t, files)
+	if w := get(r, "/notes"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without auto_index, got %d", w.Code)
+	}
+
+	_, r = newTestSite(t, files, func(cfg *config.Config) {
+		cfg.Content.AutoIndex = true
+	})
+	w := get(r, "/notes")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	expected := `<ul class="listing"><li><a href="/notes/second">Second Note</a></li><li><a href="/notes/first">First Note</a></li></ul>`
+	if w.Body.String() != expected {
+		t.Errorf("expected listing %s, got:\n%s", expected, w.Body.String())
+	}
+}
+
+func TestSlugAliases(t *testing.T) {
+	files := map[string]string{
+		"blog/new-home.md": "---\naliases: [/old-path, /archive/2019/old-home]\n---\n# New Home\n\nMoved here.",
+		"blog/other.md":    "---\naliases: [/old-path]\n---\n# Other\n",
+	}
+
+	site, r := newTestSite(t, files)
+	w := get(r, "/archive/2019/old-home")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Moved here.") {
+		t.Errorf("expected alias to serve the page, got %d:\n%s", w.Code, w.Body.String())
+	}
+
+	collisions := site.SiteContent.AliasCollisions()
+	if len(collisions["old-path"]) != 1 {
+		t.Errorf("expected old-path to be reported as a collision, got %v", collisions)
+	}
+
+	// the rejected page gives the alias up, its stale collision must go with it
+	other := filepath.Join(site.Config.Content.ContentDir, "blog/other.md")
+	if err := os.WriteFile(other, []byte("# Other\n"), 0644); err != nil {
+		t.Fatalf("failed to rewrite other.md: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(other, later, later); err != nil {
+		t.Fatalf("failed to touch other.md: %v", err)
+	}
+	if err := site.SiteContent.RefreshContent("blog/other.md"); err != nil {
+		t.Fatalf("failed to refresh other.md: %v", err)
+	}
+	if collisions := site.SiteContent.AliasCollisions(); len(collisions) != 0 {
+		t.Errorf("expected no collisions once other.md dropped the alias, got %v", collisions)
+	}
+
+	_, r = newTestSite(t, files, func(cfg *config.Config) {
+		cfg.Content.AliasRedirect = true
+	})
+	w = get(r, "/archive/2019/old-home")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/blog/new-home" {
+		t.Errorf("expected 301 to /blog/new-home, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestCanonicalRedirect(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"blog/index.md":            "# Blog\n",
+		"blog/2024-01-01-post.md":  "# Post\n",
+		"blog/2024-02-01-draft.md": "---\nslug: custom\n---\n# Custom\n",
+	})
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/blog/2024-01-01-post.md", http.StatusMovedPermanently, "/blog/2024-01-01-post"},
+		{"/blog/2024-01-01-post.md?ref=feed", http.StatusMovedPermanently, "/blog/2024-01-01-post?ref=feed"},
+		{"/blog/2024-02-01-draft.md", http.StatusMovedPermanently, "/blog/custom"},
+		{"/blog/index.md", http.StatusMovedPermanently, "/blog"},
+		{"/blog/2024-01-01-post", http.StatusOK, ""},
+		{"/blog/custom", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		w := get(r, tt.path)
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.path, tt.code, tt.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestCommentsHook(t *testing.T) {
+	files := map[string]string{
+		"blog/post.md":       "# Post\n\nBody.",
+		"blog/syndicated.md": "---\ncanonical: https://elsewhere.example/original\n---\n# Syndicated\n\nBody.",
+	}
+
+	for _, enabled := range []bool{true, false} {
+		site, _ := newTestSite(t, files, func(cfg *config.Config) {
+			cfg.Site.BaseURL = "https://example.org/"
+			cfg.Site.Comments = enabled
+		})
+
+		// render with the real post template so the container markup is covered
+		r := gin.New()
+		r.SetHTMLTemplate(template.Must(template.ParseFS(tmpl.Files, "post.html")))
+		site.RegisterRoutes(r)
+
+		body := get(r, "/blog/post").Body.String()
+		container := `<section id="comments" class="comments mt-12 pt-8 border-t border-gray-100" data-url="https://example.org/blog/post" data-slug="blog/post"></section>`
+		if enabled && !strings.Contains(body, container) {
+			t.Errorf("expected comments container when enabled, got:\n%s", body)
+		}
+		if !enabled && strings.Contains(body, `id="comments"`) {
+			t.Errorf("expected no comments container when disabled")
+		}
+		if enabled && !strings.Contains(get(r, "/blog/syndicated").Body.String(), `data-url="https://elsewhere.example/original"`) {
+			t.Errorf("expected the comments thread of a syndicated post to follow its canonical url")
+		}
+	}
+}
+
+func TestMarkdownNegotiation(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"blog/post.md":   "---\ntitle: Post\n---\n# Post\n\nSome *markdown* body.",
+		"blog/secret.md": "---\nprivate: true\n---\n# Secret\n",
+	}, func(cfg *config.Config) {
+		cfg.Content.ServeMarkdown = true
+	})
+
+	request := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/blog/post", "text/markdown")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/markdown") {
+		t.Fatalf("expected markdown response, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if expected := "---\ntitle: Post\n---\n# Post\n\nSome *markdown* body."; w.Body.String() != expected {
+		t.Errorf("expected markdown body %q, got %q", expected, w.Body.String())
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("expected Vary: Accept on markdown response, got %q", vary)
+	}
+
+	w = request("/blog/post", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	if !strings.Contains(w.Body.String(), "<em>markdown</em>") {
+		t.Errorf("expected HTML for browser Accept, got:\n%s", w.Body.String())
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("expected Vary: Accept on HTML response, got %q", vary)
+	}
+
+	if w = request("/blog/secret", "text/markdown"); w.Code != http.StatusNotFound {
+		t.Errorf("expected private page to stay hidden, got %d", w.Code)
+	}
+}
+
+func TestCanonicalFrontmatter(t *testing.T) {
+	site, _ := newTestSite(t, map[string]string{
+		"blog/syndicated.md": "---\ncanonical: https://elsewhere.example/original\n---\n# Syndicated\n\nBody.",
+		"blog/local.md":      "# Local\n\nBody.",
+		"blog/bad.md":        "---\ncanonical: not a url\n---\n# Bad\n\nBody.",
+	}, func(cfg *config.Config) {
+		cfg.Site.BaseURL = "https://example.org/"
+	})
+
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.ParseFS(tmpl.Files, "post.html")))
+	site.RegisterRoutes(r)
+
+	tests := []struct {
+		path      string
+		canonical string
+	}{
+		{"/blog/syndicated", "https://elsewhere.example/original"},
+		{"/blog/local", "https://example.org/blog/local"},
+		{"/blog/bad", "https://example.org/blog/bad"},
+	}
+	for _, tt := range tests {
+		body := get(r, tt.path).Body.String()
+		link := `<link rel="canonical" href="` + tt.canonical + `">`
+		if !strings.Contains(body, link) {
+			t.Errorf("%s: expected %s in page, got:\n%s", tt.path, link, body)
+		}
+	}
+}
+
+func TestPostNavigation(t *testing.T) {
+	site, _ := newTestSite(t, map[string]string{
+		"blog/first.md":   "---\ncreated: 1700000000\n---\n# First\n",
+		"blog/second.md":  "---\ncreated: 1700100000\n---\n# Second\n",
+		"blog/hidden.md":  "---\ncreated: 1700150000\nprivate: true\n---\n# Hidden\n",
+		"blog/third.md":   "---\ncreated: 1700200000\n---\n# Third\n",
+		"blog/index.md":   "# Blog\n",
+		"other/nearby.md": "---\ncreated: 1700120000\n---\n# Nearby\n",
+	}, func(cfg *config.Config) {
+		cfg.Site.PostNavigation = true
+	})
+
+	neighbors := `{{with .PrevPost}}{{.Slug}}{{end}}|{{with .NextPost}}{{.Slug}}{{end}}`
+	public := gin.New()
+	public.SetHTMLTemplate(template.Must(template.New("post.html").Parse(neighbors)))
+	site.RegisterRoutes(public)
+
+	signedIn := gin.New()
+	signedIn.Use(func(c *gin.Context) { c.Set("authenticated_user", "admin") })
+	signedIn.SetHTMLTemplate(template.Must(template.New("post.html").Parse(neighbors)))
+	site.RegisterRoutes(signedIn)
+
+	tests := []struct {
+		r        *gin.Engine
+		path     string
+		expected string
+	}{
+		{public, "/blog/second", "blog/first|blog/third"},
+		{public, "/blog/first", "|blog/second"},
+		{public, "/blog/third", "blog/second|"},
+		{signedIn, "/blog/second", "blog/first|blog/hidden"},
+		{signedIn, "/blog/hidden", "blog/second|blog/third"},
+	}
+	for _, tt := range tests {
+		if body := get(tt.r, tt.path).Body.String(); body != tt.expected {
+			t.Errorf("%s: expected neighbors %q, got %q", tt.path, tt.expected, body)
+		}
+	}
+}
+
+func TestHTMLContentFrontmatter(t *testing.T) {
+	body := "<section>\n    <p>Hand written <em>html</em></p>\n</section>\n"
+	site, _ := newTestSite(t, map[string]string{
+		"about.html": "---\ntitle: About Us\ncreated: 1700000000\n---\n" + body,
+	})
+
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("post.html").Parse(`{{.Meta.Title}}|{{with .CreatedDate}}{{.Unix}}{{end}}|{{.PageHTML}}`)))
+	site.RegisterRoutes(r)
+
+	w := get(r, "/about")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	expected := "About Us|1700000000|" + body
+	if w.Body.String() != expected {
+		t.Errorf("expected %q, got %q", expected, w.Body.String())
+	}
+}
+
+func TestThemePersistsAcrossRequests(t *testing.T) {
+	site, _ := newTestSite(t, map[string]string{
+		"hello.md": "# Hello\n\nHi.\n",
+	}, func(cfg *config.Config) {
+		cfg.Content.SidecarDB = filepath.Join(t.TempDir(), "sidecar.db")
+	})
+	if err := site.SiteContent.LoadContent(); err != nil {
+		t.Fatalf("failed to load content: %v", err)
+	}
+	authzApp := &authz.AuthzApp{SiteContent: site.SiteContent}
+	authzApp.Init()
+
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("post.html").Parse(`theme={{.Theme}}`)))
+	r.Use(authzApp.AuthMiddleware())
+	authzApp.RegisterRoutes(r)
+	site.RegisterRoutes(r)
+
+	send := func(method, path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if body := send(http.MethodGet, "/hello", nil).Body.String(); body != "theme=" {
+		t.Errorf("expected no theme before one is picked, got %s", body)
+	}
+	if w := send(http.MethodPost, "/theme", url.Values{"theme": {"purple"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown theme to be rejected, got %d", w.Code)
+	}
+
+	// visitors without a session keep the theme in a cookie
+	w := send(http.MethodPost, "/theme", url.Values{"theme": {"dark"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 setting the theme, got %d: %s", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "theme" || cookies[0].Value != "dark" {
+		t.Fatalf("expected a dark theme cookie, got %v", cookies)
+	}
+	if body := send(http.MethodGet, "/hello", nil, cookies[0]).Body.String(); body != "theme=dark" {
+		t.Errorf("expected the cookie theme on the next request, got %s", body)
+	}
+
+	// signed in users keep it in their session
+	var admin authz.User
+	if err := site.SiteContent.DB().Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatalf("failed to find admin user: %v", err)
+	}
+	session, err := authzApp.CreateSession(admin.ID)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	sessionCookie := &http.Cookie{Name: "session_token", Value: session.Token}
+
+	w = send(http.MethodPost, "/theme", url.Values{"theme": {"dark"}}, sessionCookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 setting the theme, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Errorf("expected the session to hold the theme instead of a cookie, got %v", w.Result().Cookies())
+	}
+	for i := 0; i < 2; i++ {
+		if body := send(http.MethodGet, "/hello", nil, sessionCookie).Body.String(); body != "theme=dark" {
+			t.Errorf("expected the session theme on request %d, got %s", i+1, body)
+		}
+	}
+
+	// posting without a theme toggles it
+	if w := send(http.MethodPost, "/theme", nil, sessionCookie); !strings.Contains(w.Body.String(), `"theme":"light"`) {
+		t.Errorf("expected the toggle to switch to light, got %s", w.Body.String())
+	}
+	if body := send(http.MethodGet, "/hello", nil, sessionCookie).Body.String(); body != "theme=light" {
+		t.Errorf("expected the toggled theme to persist, got %s", body)
+	}
+}
+
+func TestFolderPrivateInheritance(t *testing.T) {
+	site, public := newTestSite(t, map[string]string{
+		"notes/index.md":          "---\nprivate: true\n---\n# Notes\n",
+		"notes/post.md":           "# Post\n\nSecret.\n",
+		"notes/deep/post.md":      "# Deep\n\nSecret.\n",
+		"notes/override.md":       "---\nprivate: false\n---\n# Override\n\nShared.\n",
+		"notes/shared/index.md":   "---\nprivate: false\n---\n# Shared\n",
+		"notes/shared/post.md":    "# Shared Post\n\nShared.\n",
+		"journal/_meta.md":        "---\nprivate: true\n---\n",
+		"journal/day.md":          "# Day\n\nSecret.\n",
+		"journal/index.md":        "# Journal\n",
+		"blog/post.md":            "# Blog Post\n\nPublic.\n",
+		"blog/nested/index.md":    "# Nested\n",
+		"blog/nested/post.md":     "# Nested Post\n\nPublic.\n",
+		"blog/nested/private.md":  "---\nprivate: true\n---\n# Private\n\nSecret.\n",
+		"blog/nested/deeper/a.md": "# A\n\nPublic.\n",
+	})
+
+	signedIn := gin.New()
+	signedIn.Use(func(c *gin.Context) { c.Set("authenticated_user", "admin") })
+	signedIn.SetHTMLTemplate(template.Must(template.New("post.html").Parse(`{{.PageHTML}}`)))
+	site.RegisterRoutes(signedIn)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/notes/post", http.StatusNotFound},
+		{"/notes/deep/post", http.StatusNotFound},
+		{"/notes", http.StatusNotFound},
+		{"/notes/override", http.StatusOK},
+		{"/notes/shared", http.StatusOK},
+		{"/notes/shared/post", http.StatusOK},
+		{"/journal/day", http.StatusNotFound},
+		{"/journal", http.StatusNotFound},
+		{"/blog/post", http.StatusOK},
+		{"/blog/nested/post", http.StatusOK},
+		{"/blog/nested/private", http.StatusNotFound},
+		{"/blog/nested/deeper/a", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := get(public, tt.path); w.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, w.Code)
+		}
+		if w := get(signedIn, tt.path); w.Code != http.StatusOK {
+			t.Errorf("%s: expected signed in users to see it, got %d", tt.path, w.Code)
+		}
+	}
+}
+
+func TestSectionMetaIsNotAPage(t *testing.T) {
+	_, r := newTestSite(t, map[string]string{
+		"index.md":      "# Home\n\n<!-- <query type=\"posts\"> -->\n<!-- </query> -->\n",
+		"blog/_meta.md": "---\ntitle: Blog Settings\ncreated: 1700000100\n---\n# Blog Settings\n\nZucchini settings.\n",
+		"blog/post.md":  "---\ncreated: 1700000000\n---\n# Post\n\nZucchini soup.\n",
+	})
+
+	for _, path := range []string{"/blog/_meta", "/blog/_meta.md"} {
+		if w := get(r, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+	if w := get(r, "/blog/post"); w.Code != http.StatusOK {
+		t.Errorf("expected the post to be served, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/sitemap.xml", "/index.xml", "/api/search?q=zucchini"} {
+		body := get(r, path).Body.String()
+		if !strings.Contains(body, "blog/post") {
+			t.Errorf("%s: expected the post to be listed, got:\n%s", path, body)
+		}
+		if strings.Contains(body, "_meta") || strings.Contains(body, "Blog Settings") {
+			t.Errorf("%s: expected _meta.md to be left out, got:\n%s", path, body)
+		}
+	}
+}
+
+func TestUpdatedDateThreshold(t *testing.T) {
+	files := map[string]string{
+		"same-day.md":   "---\ncreated: 1700000000\nupdated: 1700003600\n---\n# Same Day\n",
+		"week-later.md": "---\ncreated: 1700000000\nupdated: 1700604800\n---\n# Week Later\n",
+	}
+	updated := `{{with .UpdatedDate}}updated {{.UTC.Format "2006-01-02"}}{{end}}`
+
+	tests := []struct {
+		threshold string
+		path      string
+		expected  string
+	}{
+		{"", "/same-day", ""},
+		{"", "/week-later", "updated 2023-11-21"},
+		{"200h", "/week-later", ""},
+		{"30m", "/same-day", "updated 2023-11-14"},
+	}
+	for _, tt := range tests {
+		site, _ := newTestSite(t, files, func(cfg *config.Config) {
+			cfg.Site.UpdatedThreshold = tt.threshold
+		})
+		r := gin.New()
+		r.SetHTMLTemplate(template.Must(template.New("post.html").Parse(updated)))
+		site.RegisterRoutes(r)
+
+		if body := get(r, tt.path).Body.String(); body != tt.expected {
+			t.Errorf("%s with threshold %q: expected %q, got %q", tt.path, tt.threshold, tt.expected, body)
+		}
+	}
+}
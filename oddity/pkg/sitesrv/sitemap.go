@@ -0,0 +1,87 @@
+package sitesrv
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"oddity/pkg/contentstuff"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// sitemapErrorPages are content pages shown in place of error messages, never listed in the sitemap
+var sitemapErrorPages = map[string]bool{"404.md": true, "500.md": true}
+
+// renderSitemap lists every public page in sitemap.xml, private and noindex pages are left out
+// and so are pages whose canonical url is on another site
+func (s *SiteApp) renderSitemap(c *gin.Context) {
+	urlSet := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	for _, file := range s.SiteContent.AllFiles() {
+		if file.FileType != contentstuff.FileTypeMarkdown && file.FileType != contentstuff.FileTypeHTML {
+			continue
+		}
+		if sitemapErrorPages[file.FileName] || contentstuff.IsSectionMeta(file) || contentstuff.IsPrivate(s.SiteContent, file) {
+			continue
+		}
+		pg := contentstuff.NewPageFromFileDetail(&file)
+		if pg.NoIndex() || s.canonicalOffSite(c, pg) {
+			continue
+		}
+
+		// index pages are served at their directory
+		slug := pg.Slug()
+		if base := filepath.Base(file.FileName); base == "index.md" || base == "index.html" {
+			slug = filepath.Dir(file.FileName)
+			if slug == "." {
+				slug = ""
+			}
+		}
+
+		entry := sitemapURL{Loc: s.canonicalURL(c, slug)}
+		if modified := pg.DateModified(); modified != nil {
+			entry.LastMod = modified.Format("2006-01-02")
+		}
+		urlSet.URLs = append(urlSet.URLs, entry)
+	}
+
+	sort.Slice(urlSet.URLs, func(i, j int) bool {
+		return urlSet.URLs[i].Loc < urlSet.URLs[j].Loc
+	})
+
+	out, err := xml.MarshalIndent(urlSet, "", "  ")
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+// canonicalOffSite reports whether the frontmatter canonical of page points to another host
+func (s *SiteApp) canonicalOffSite(c *gin.Context, page *contentstuff.Page) bool {
+	canonical, err := url.Parse(page.Canonical())
+	if err != nil || canonical.Host == "" {
+		return false
+	}
+	site, err := url.Parse(s.canonicalURL(c, ""))
+	if err != nil {
+		return false
+	}
+	return !strings.EqualFold(canonical.Host, site.Host)
+}
@@ -0,0 +1,58 @@
+package sitesrv
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSitemapNoIndex(t *testing.T) {
+	site, _ := newTestSite(t, map[string]string{
+		"index.md":           "# Home\n\n<!-- <query type=\"posts\"> -->\n<!-- </query> -->\n",
+		"blog/index.md":      "# Blog",
+		"blog/first.md":      "---\ncreated: 1700000000\n---\n# First\n\nHello",
+		"blog/hidden.md":     "---\ncreated: 1700000100\nnoindex: true\n---\n# Hidden\n\nNot for search engines",
+		"blog/private.md":    "---\nprivate: true\n---\n# Private\n\nSecret",
+		"blog/syndicated.md": "---\ncanonical: https://elsewhere.example/original\n---\n# Syndicated\n\nFirst published elsewhere",
+		"blog/self.md":       "---\ncanonical: http://example.com/blog/self\n---\n# Self\n\nCanonical on this site",
+	})
+
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("post.html").Parse(`noindex={{.Meta.NoIndex}}`)))
+	site.RegisterRoutes(r)
+
+	w := get(r, "/sitemap.xml")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"<loc>http://example.com/</loc>",
+		"<loc>http://example.com/blog</loc>",
+		"<loc>http://example.com/blog/first</loc>",
+		"<loc>http://example.com/blog/self</loc>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected sitemap to contain %q, got:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"blog/hidden", "blog/private", "blog/syndicated"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("expected sitemap to leave out %s, got:\n%s", unwanted, body)
+		}
+	}
+
+	if w := get(r, "/index.xml"); !strings.Contains(w.Body.String(), "blog/first") || strings.Contains(w.Body.String(), "blog/hidden") {
+		t.Errorf("expected the feed to leave out the noindex page, got:\n%s", w.Body.String())
+	}
+
+	if w := get(r, "/blog/hidden"); w.Body.String() != "noindex=true" {
+		t.Errorf("expected the noindex page to carry the meta flag, got %q", w.Body.String())
+	}
+	if w := get(r, "/blog/first"); w.Body.String() != "noindex=false" {
+		t.Errorf("expected a regular page not to carry the meta flag, got %q", w.Body.String())
+	}
+}